@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// parseAPIKeys splits a comma-separated -api-key value, dropping blanks.
+func parseAPIKeys(value string) []string {
+	var keys []string
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// withAuth wraps next in apiKeyMiddleware when API keys are configured.
+func withAuth(config Config, next http.Handler) http.Handler {
+	if len(config.APIKeys) == 0 {
+		return next
+	}
+	return apiKeyMiddleware(config.APIKeys, next)
+}
+
+// withAdminAuth is withAuth for the admin endpoints, which can reset
+// metrics, switch patterns and expose patient IDs: with no API keys
+// configured they are refused with 403 Forbidden rather than left open.
+func withAdminAuth(config Config, next http.Handler) http.Handler {
+	if len(config.APIKeys) == 0 {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "admin endpoints require -api-key", http.StatusForbidden)
+		})
+	}
+	return apiKeyMiddleware(config.APIKeys, next)
+}
+
+// apiKeyMiddleware rejects requests whose Authorization header does not
+// carry one of keys as a bearer token, with 401 Unauthorized.
+//
+// Every key is compared in constant time, and all of them are checked even
+// after a match, so response timing reveals nothing about which prefix of
+// which key a guess got right.
+func apiKeyMiddleware(keys []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !validAPIKey(keys, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="healthcare-api"`)
+			http.Error(w, "missing or invalid API key", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validAPIKey reports whether token matches any of keys.
+func validAPIKey(keys []string, token string) bool {
+	match := 0
+	for _, key := range keys {
+		match |= subtle.ConstantTimeCompare([]byte(key), []byte(token))
+	}
+	return match == 1
+}
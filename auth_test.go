@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	config := validConfig()
+	config.APIKeys = []string{"key-one", "key-two"}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux := newServeMux(config, ok, simulator.NewDatabase(1, 2, 0), metrics.NewCollector(), nil)
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		want          int
+	}{
+		{"valid key", "/api/v1/patients?id=P1", "Bearer key-one", http.StatusOK},
+		{"second valid key", "/api/v1/patients?id=P1", "Bearer key-two", http.StatusOK},
+		{"invalid key", "/api/v1/patients?id=P1", "Bearer key-three", http.StatusUnauthorized},
+		{"key prefix", "/api/v1/patients?id=P1", "Bearer key-", http.StatusUnauthorized},
+		{"wrong scheme", "/api/v1/patients?id=P1", "Basic key-one", http.StatusUnauthorized},
+		{"missing header", "/api/v1/patients?id=P1", "", http.StatusUnauthorized},
+		{"health bypasses auth", "/health", "", http.StatusOK},
+		{"livez bypasses auth", "/livez", "", http.StatusOK},
+		{"readyz bypasses auth", "/readyz", "", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d", rec.Code, tc.want)
+			}
+			if tc.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+// TestAdminEndpointsForbiddenWithoutAPIKey checks the admin endpoints are
+// refused, not left open, when no API key is configured.
+func TestAdminEndpointsForbiddenWithoutAPIKey(t *testing.T) {
+	config := validConfig()
+	config.SlowQueryThreshold = 20 * time.Millisecond
+
+	db := simulator.NewDatabase(1, 2, 0, simulator.WithSlowQueryLog(config.SlowQueryThreshold, config.SlowQueryLogSize))
+	handler, err := newSwitchableHandler(config, db)
+	if err != nil {
+		t.Fatalf("newSwitchableHandler: %v", err)
+	}
+	defer handler.Shutdown(context.Background())
+	mux := newServeMux(config, handler, db, metrics.NewCollector(), nil)
+
+	for _, path := range []string{"/admin/metrics/reset", "/admin/pattern", "/admin/slow-queries", "/admin/benchmark"} {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("%s %s = %d, want 403", method, path, rec.Code)
+			}
+		}
+	}
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	tests := map[string][]string{
+		"":                nil,
+		"abc":             {"abc"},
+		"abc, def,,ghi ,": {"abc", "def", "ghi"},
+	}
+
+	for input, want := range tests {
+		if got := parseAPIKeys(input); !reflect.DeepEqual(got, want) {
+			t.Errorf("parseAPIKeys(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
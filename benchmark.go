@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/runner"
+)
+
+// Bounds on a single /admin/benchmark run. Each client sends its requests
+// one after another at 50-100ms apiece, so capping requests per client
+// keeps a run to tens of seconds however the total is split.
+const (
+	defaultBenchmarkRequests      = 1000
+	defaultBenchmarkConcurrency   = 100
+	maxBenchmarkRequests          = 10000
+	maxBenchmarkConcurrency       = 1000
+	maxBenchmarkRequestsPerClient = 100
+)
+
+// benchmarkPatterns are the patterns compared by /admin/benchmark.
+var benchmarkPatterns = []string{"naive", "workerpool", "optimized"}
+
+// benchmarkResponse is the body returned by /admin/benchmark.
+type benchmarkResponse struct {
+	Results []runner.Result `json:"results"`
+	Winner  string          `json:"winner"`
+}
+
+// adminBenchmarkHandler serves POST /admin/benchmark {"requests":1000,"concurrency":100}.
+// It runs the comparison the loadtest command does, in-process against a
+// fresh database, and returns once every pattern has finished. Only one
+// benchmark runs at a time; overlapping requests get 409 Conflict.
+func adminBenchmarkHandler(config Config) http.HandlerFunc {
+	var running sync.Mutex
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body := struct {
+			Requests    int `json:"requests"`
+			Concurrency int `json:"concurrency"`
+		}{defaultBenchmarkRequests, defaultBenchmarkConcurrency}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		if body.Requests <= 0 || body.Requests > maxBenchmarkRequests {
+			http.Error(w, fmt.Sprintf("requests must be between 1 and %d", maxBenchmarkRequests), http.StatusBadRequest)
+			return
+		}
+		if body.Concurrency <= 0 || body.Concurrency > maxBenchmarkConcurrency {
+			http.Error(w, fmt.Sprintf("concurrency must be between 1 and %d", maxBenchmarkConcurrency), http.StatusBadRequest)
+			return
+		}
+		if body.Requests > body.Concurrency*maxBenchmarkRequestsPerClient {
+			http.Error(w, fmt.Sprintf("at most %d requests per unit of concurrency", maxBenchmarkRequestsPerClient), http.StatusBadRequest)
+			return
+		}
+
+		if !running.TryLock() {
+			http.Error(w, "a benchmark is already running", http.StatusConflict)
+			return
+		}
+		defer running.Unlock()
+
+		benchConfig := runner.Config{
+			TotalRequests:   body.Requests,
+			Concurrency:     body.Concurrency,
+			Workers:         config.Workers,
+			QueueSize:       config.QueueSize,
+			EnqueueTimeout:  config.EnqueueTimeout,
+			MaxConnections:  config.MaxConnections,
+			CorpusSize:      config.CorpusSize,
+			TailProbability: config.TailProbability,
+			TailLatency:     config.TailLatency,
+		}
+		selected, err := benchConfig.Select(benchmarkPatterns...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// A run can outlast the server's write timeout
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		db := benchConfig.NewDatabase()
+		defer db.Close()
+
+		results := make([]runner.Result, 0, len(selected))
+		for _, p := range selected {
+			results = append(results, runner.Run(p, benchConfig, db))
+		}
+
+		patterns.WriteJSON(w, r, http.StatusOK, benchmarkResponse{
+			Results: results,
+			Winner:  runner.Winner(results).PatternName,
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func postBenchmark(t *testing.T, handler http.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/benchmark", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminBenchmark(t *testing.T) {
+	rec := postBenchmark(t, adminBenchmarkHandler(validConfig()), `{"requests":30,"concurrency":10}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var got benchmarkResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if len(got.Results) != 3 {
+		t.Fatalf("got %d results, want 3", len(got.Results))
+	}
+	winnerFound := false
+	for _, r := range got.Results {
+		if r.TotalRequests != 30 {
+			t.Errorf("%s: total_requests = %d, want 30", r.PatternName, r.TotalRequests)
+		}
+		if r.MinLatency <= 0 || r.MeanLatency <= 0 || r.P95Latency <= 0 || r.MaxLatency <= 0 {
+			t.Errorf("%s: latency fields not populated: %+v", r.PatternName, r)
+		}
+		if r.MinLatency > r.MeanLatency || r.MeanLatency > r.MaxLatency {
+			t.Errorf("%s: min %.2f, mean %.2f, max %.2f out of order",
+				r.PatternName, r.MinLatency, r.MeanLatency, r.MaxLatency)
+		}
+		if r.PatternName == got.Winner {
+			winnerFound = true
+		}
+	}
+	if !winnerFound {
+		t.Errorf("winner %q is not one of the results", got.Winner)
+	}
+}
+
+func TestAdminBenchmarkRejectsBadRequests(t *testing.T) {
+	handler := adminBenchmarkHandler(validConfig())
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid JSON", `{`},
+		{"zero requests", `{"requests":0,"concurrency":10}`},
+		{"too many requests", `{"requests":10001,"concurrency":1000}`},
+		{"too much concurrency", `{"requests":100,"concurrency":1001}`},
+		{"too many requests per client", `{"requests":1000,"concurrency":1}`},
+	}
+	for _, tc := range tests {
+		if rec := postBenchmark(t, handler, tc.body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", tc.name, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/benchmark", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status = %d, want 405", rec.Code)
+	}
+}
@@ -97,6 +97,101 @@ func BenchmarkOptimized(b *testing.B) {
 	}
 }
 
+// BenchmarkSemaphore benchmarks the semaphore-bounded pattern.
+func BenchmarkSemaphore(b *testing.B) {
+	concurrencyLevels := []int{10, 50, 100}
+
+	for _, concurrency := range concurrencyLevels {
+		b.Run(fmt.Sprintf("Concurrency-%d", concurrency), func(b *testing.B) {
+			db := simulator.NewDefaultDatabase()
+			config := patterns.DefaultSemaphoreConfig()
+			handler := patterns.NewSemaphoreHandler(db, config)
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				patientID := "P12345"
+
+				for pb.Next() {
+					_, _ = handler.HandleRequest(ctx, patientID)
+				}
+			})
+			b.StopTimer()
+
+			// Cleanup
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			handler.Shutdown(ctx)
+		})
+	}
+}
+
+// BenchmarkPipeline benchmarks the stage-based pipeline pattern.
+func BenchmarkPipeline(b *testing.B) {
+	concurrencyLevels := []int{10, 50, 100}
+
+	for _, concurrency := range concurrencyLevels {
+		b.Run(fmt.Sprintf("Concurrency-%d", concurrency), func(b *testing.B) {
+			db := simulator.NewDefaultDatabase()
+			config := patterns.DefaultPipelineConfig()
+			handler := patterns.NewPipelineHandler(db, config)
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				patientID := "P12345"
+
+				for pb.Next() {
+					_, _ = handler.HandleRequest(ctx, patientID)
+				}
+			})
+			b.StopTimer()
+
+			// Cleanup
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			handler.Shutdown(ctx)
+		})
+	}
+}
+
+// BenchmarkBulkhead benchmarks the bulkhead pattern, with traffic split
+// between its routine and stat classes.
+func BenchmarkBulkhead(b *testing.B) {
+	concurrencyLevels := []int{10, 50, 100}
+
+	for _, concurrency := range concurrencyLevels {
+		b.Run(fmt.Sprintf("Concurrency-%d", concurrency), func(b *testing.B) {
+			db := simulator.NewDefaultDatabase()
+			config := patterns.DefaultBulkheadConfig()
+			handler := patterns.NewBulkheadHandler(db, config)
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				stat := patterns.WithRequestClass(ctx, patterns.ClassStat)
+				patientID := "P12345"
+
+				for i := 0; pb.Next(); i++ {
+					// One request in four is urgent, matching the stat
+					// class's share of the workers
+					if i%4 == 0 {
+						_, _ = handler.HandleRequest(stat, patientID)
+					} else {
+						_, _ = handler.HandleRequest(ctx, patientID)
+					}
+				}
+			})
+			b.StopTimer()
+
+			// Cleanup
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			handler.Shutdown(ctx)
+		})
+	}
+}
+
 // BenchmarkComparison runs all patterns at the same concurrency for direct comparison.
 func BenchmarkComparison(b *testing.B) {
 	const concurrency = 100
@@ -148,6 +243,57 @@ func BenchmarkComparison(b *testing.B) {
 				handler.Shutdown(ctx)
 			}()
 
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				for pb.Next() {
+					_, _ = handler.HandleRequest(ctx, "P12345")
+				}
+			})
+		}},
+		{"Semaphore", func(b *testing.B) {
+			db := simulator.NewDefaultDatabase()
+			config := patterns.DefaultSemaphoreConfig()
+			handler := patterns.NewSemaphoreHandler(db, config)
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				handler.Shutdown(ctx)
+			}()
+
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				for pb.Next() {
+					_, _ = handler.HandleRequest(ctx, "P12345")
+				}
+			})
+		}},
+		{"Pipeline", func(b *testing.B) {
+			db := simulator.NewDefaultDatabase()
+			config := patterns.DefaultPipelineConfig()
+			handler := patterns.NewPipelineHandler(db, config)
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				handler.Shutdown(ctx)
+			}()
+
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				for pb.Next() {
+					_, _ = handler.HandleRequest(ctx, "P12345")
+				}
+			})
+		}},
+		{"Bulkhead", func(b *testing.B) {
+			db := simulator.NewDefaultDatabase()
+			config := patterns.DefaultBulkheadConfig()
+			handler := patterns.NewBulkheadHandler(db, config)
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				handler.Shutdown(ctx)
+			}()
+
 			b.RunParallel(func(pb *testing.PB) {
 				ctx := context.Background()
 				for pb.Next() {
@@ -216,6 +362,60 @@ func BenchmarkMemoryAllocation(b *testing.B) {
 			b.ReportAllocs()
 			b.ResetTimer()
 
+			for i := 0; i < b.N; i++ {
+				ctx := context.Background()
+				_, _ = handler.HandleRequest(ctx, "P12345")
+			}
+		}},
+		{"Semaphore", func(b *testing.B) {
+			db := simulator.NewDefaultDatabase()
+			config := patterns.DefaultSemaphoreConfig()
+			handler := patterns.NewSemaphoreHandler(db, config)
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				handler.Shutdown(ctx)
+			}()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				ctx := context.Background()
+				_, _ = handler.HandleRequest(ctx, "P12345")
+			}
+		}},
+		{"Pipeline", func(b *testing.B) {
+			db := simulator.NewDefaultDatabase()
+			config := patterns.DefaultPipelineConfig()
+			handler := patterns.NewPipelineHandler(db, config)
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				handler.Shutdown(ctx)
+			}()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				ctx := context.Background()
+				_, _ = handler.HandleRequest(ctx, "P12345")
+			}
+		}},
+		{"Bulkhead", func(b *testing.B) {
+			db := simulator.NewDefaultDatabase()
+			config := patterns.DefaultBulkheadConfig()
+			handler := patterns.NewBulkheadHandler(db, config)
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				handler.Shutdown(ctx)
+			}()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
 			for i := 0; i < b.N; i++ {
 				ctx := context.Background()
 				_, _ = handler.HandleRequest(ctx, "P12345")
@@ -0,0 +1,236 @@
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+const (
+	// gcSeed fixes the query latencies and the patients requested, so
+	// every pattern sees the same load from one run to the next
+	gcSeed = 42
+
+	gcConcurrency = 50
+	gcCorpusSize  = 1000
+
+	// gcSampleInterval is how often the sampler reads the GC pauses.
+	// runtime.MemStats keeps only the last 256, so it must come round
+	// before 256 collections have gone by.
+	gcSampleInterval = 10 * time.Millisecond
+)
+
+// gcHandler is implemented by every pattern handler.
+type gcHandler interface {
+	HandleRequest(ctx context.Context, patientID string) (*models.PatientResponse, error)
+	Shutdown(ctx context.Context) error
+}
+
+// responseReleaser is implemented by handlers that pool their responses.
+type responseReleaser interface {
+	ReleaseResponse(response *models.PatientResponse)
+}
+
+// BenchmarkGCPause runs each pattern under sustained load while sampling
+// the garbage collector, and reports the GC pause time each request costs
+// and the distribution of individual pauses. Responses go back to the
+// optimized pattern's sync.Pool as they would in the load test runner, so
+// the difference it makes to GC pauses shows up here.
+//
+// Run it with:
+//
+//	go test -run=^$ -bench=BenchmarkGCPause -benchtime=20000x ./benchmarks/
+func BenchmarkGCPause(b *testing.B) {
+	if testing.Short() {
+		b.Skip("sustained load; skipped in short mode")
+	}
+
+	handlers := []struct {
+		name string
+		new  func(db *simulator.Database) gcHandler
+	}{
+		{"Naive", func(db *simulator.Database) gcHandler { return patterns.NewNaiveHandler(db) }},
+		{"WorkerPool", func(db *simulator.Database) gcHandler {
+			return patterns.NewWorkerPoolHandler(db, patterns.DefaultWorkerPoolConfig())
+		}},
+		{"Optimized", func(db *simulator.Database) gcHandler {
+			return patterns.NewOptimizedHandler(db, patterns.DefaultWorkerPoolConfig())
+		}},
+		{"Semaphore", func(db *simulator.Database) gcHandler {
+			return patterns.NewSemaphoreHandler(db, patterns.DefaultSemaphoreConfig())
+		}},
+		{"Pipeline", func(db *simulator.Database) gcHandler {
+			return patterns.NewPipelineHandler(db, patterns.DefaultPipelineConfig())
+		}},
+		{"Bulkhead", func(db *simulator.Database) gcHandler {
+			return patterns.NewBulkheadHandler(db, patterns.DefaultBulkheadConfig())
+		}},
+	}
+
+	for _, h := range handlers {
+		b.Run(h.name, func(b *testing.B) {
+			handler := h.new(newSeededDatabase())
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				handler.Shutdown(ctx)
+			}()
+
+			// Start from a clean heap, so garbage left by the previous
+			// pattern isn't collected on this one's time
+			runtime.GC()
+			sampler := startGCSampler()
+			b.ResetTimer()
+
+			sustainLoad(handler, b.N)
+
+			b.StopTimer()
+			gc := sampler.stop()
+
+			b.ReportMetric(float64(gc.total.Nanoseconds())/float64(b.N), "gc-pause-ns/op")
+			b.ReportMetric(float64(gc.count), "gcs")
+			b.ReportMetric(microseconds(pausePercentile(gc.pauses, 50)), "p50-pause-us")
+			b.ReportMetric(microseconds(pausePercentile(gc.pauses, 99)), "p99-pause-us")
+			b.ReportMetric(microseconds(pausePercentile(gc.pauses, 100)), "max-pause-us")
+		})
+	}
+}
+
+// newSeededDatabase returns an error-free database serving a fixed corpus
+// with query latencies of 1-2ms drawn from gcSeed.
+func newSeededDatabase() *simulator.Database {
+	rng := rand.New(rand.NewSource(gcSeed))
+	latencies := make([]time.Duration, 1000)
+	for i := range latencies {
+		latencies[i] = time.Millisecond + time.Duration(rng.Int63n(int64(time.Millisecond)))
+	}
+
+	db := simulator.NewDatabase(1, 2, 0, simulator.WithCorpus(gcCorpusSize, simulator.DefaultCorpusSeed))
+	db.SetLatencySource(simulator.NewTraceLatencySource(latencies))
+	return db
+}
+
+// sustainLoad sends n reads of corpus patients from gcConcurrency clients,
+// each client's sequence of patients drawn from gcSeed.
+func sustainLoad(handler gcHandler, n int) {
+	releaser, pooled := handler.(responseReleaser)
+	var wg sync.WaitGroup
+
+	for client := 0; client < gcConcurrency; client++ {
+		// Spread n over the clients, the first n%gcConcurrency taking one more
+		requests := n / gcConcurrency
+		if client < n%gcConcurrency {
+			requests++
+		}
+
+		wg.Add(1)
+		go func(client, requests int) {
+			defer wg.Done()
+			ctx := context.Background()
+			rng := rand.New(rand.NewSource(gcSeed + int64(client)))
+
+			for i := 0; i < requests; i++ {
+				patientID := fmt.Sprintf("P%05d", rng.Intn(gcCorpusSize))
+				response, err := handler.HandleRequest(ctx, patientID)
+				if err == nil && pooled {
+					releaser.ReleaseResponse(response)
+				}
+			}
+		}(client, requests)
+	}
+	wg.Wait()
+}
+
+// gcPauses summarises the collections during a sampled stretch.
+type gcPauses struct {
+	count  uint32          // Collections completed
+	total  time.Duration   // Their cumulative stop-the-world pause time
+	pauses []time.Duration // Each collection's pause, as far as sampled
+}
+
+// gcSampler reads runtime.MemStats every gcSampleInterval, keeping each
+// collection's pause before the runtime's 256-entry buffer overwrites it.
+type gcSampler struct {
+	done   chan struct{}
+	result chan gcPauses
+}
+
+// startGCSampler starts sampling the collections from now on.
+func startGCSampler() *gcSampler {
+	s := &gcSampler{done: make(chan struct{}), result: make(chan gcPauses, 1)}
+
+	var start runtime.MemStats
+	runtime.ReadMemStats(&start)
+
+	go func() {
+		var stats runtime.MemStats
+		var pauses []time.Duration
+		seen := start.NumGC
+
+		read := func() {
+			runtime.ReadMemStats(&stats)
+			// PauseNs[(n+255)%256] is the pause of collection n; any older
+			// than the last 256 are gone, so start no earlier than those
+			first := seen + 1
+			if buffered := uint32(len(stats.PauseNs)); stats.NumGC > buffered && first <= stats.NumGC-buffered {
+				first = stats.NumGC - buffered + 1
+			}
+			for n := first; n <= stats.NumGC; n++ {
+				pauses = append(pauses, time.Duration(stats.PauseNs[(n+255)%256]))
+			}
+			seen = stats.NumGC
+		}
+
+		ticker := time.NewTicker(gcSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				read()
+			case <-s.done:
+				read()
+				s.result <- gcPauses{
+					count:  stats.NumGC - start.NumGC,
+					total:  time.Duration(stats.PauseTotalNs - start.PauseTotalNs),
+					pauses: pauses,
+				}
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// stop ends sampling and returns the collections since startGCSampler.
+func (s *gcSampler) stop() gcPauses {
+	close(s.done)
+	return <-s.result
+}
+
+// pausePercentile returns the pth percentile of pauses, ranked as
+// metrics.Collector ranks latencies, or zero if there were none.
+func pausePercentile(pauses []time.Duration, p float64) time.Duration {
+	if len(pauses) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(pauses))
+	copy(sorted, pauses)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(p / 100 * float64(len(sorted)))
+	return sorted[min(rank, len(sorted)-1)]
+}
+
+// microseconds converts d to fractional microseconds for ReportMetric.
+func microseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
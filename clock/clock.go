@@ -0,0 +1,102 @@
+// Package clock abstracts the passage of time so that timing-dependent
+// code (simulated latency, error schedules, measurement periods) can be
+// tested deterministically with a Fake instead of sleeping.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits for it to pass.
+// Implementations must be safe for concurrent use.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real is the wall clock, backed by the time package.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Fake is a Clock that only moves when Advance is called. Channels from
+// After fire once the clock has been advanced to or past their deadline.
+type Fake struct {
+	mu      sync.Mutex
+	waiting *sync.Cond // Signalled when After adds a waiter
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewFake returns a fake clock reading start.
+func NewFake(start time.Time) *Fake {
+	f := &Fake{now: start}
+	f.waiting = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the fake time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After returns a channel that receives the fake time once the clock has
+// advanced by d. A non-positive d fires immediately.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+
+	f.waiters = append(f.waiters, waiter{deadline: f.now.Add(d), ch: ch})
+	f.waiting.Broadcast()
+	return ch
+}
+
+// Advance moves the clock forward by d, firing every After whose deadline
+// has been reached.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.deadline.After(f.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- f.now
+	}
+	f.waiters = pending
+}
+
+// BlockUntil waits until at least n Afters are pending, so a test can be
+// sure a goroutine is waiting on the clock before advancing it. Afters
+// whose caller has since given up (on a cancelled context, say) still
+// count until the clock passes their deadline.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.waiters) < n {
+		f.waiting.Wait()
+	}
+}
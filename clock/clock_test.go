@@ -0,0 +1,88 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeNowMovesOnlyOnAdvance(t *testing.T) {
+	f := NewFake(epoch)
+	if !f.Now().Equal(epoch) {
+		t.Fatalf("Now() = %v, want %v", f.Now(), epoch)
+	}
+
+	f.Advance(90 * time.Second)
+	if got := f.Now().Sub(epoch); got != 90*time.Second {
+		t.Errorf("after Advance(90s), elapsed = %v", got)
+	}
+}
+
+func TestFakeAfterFiresAtDeadline(t *testing.T) {
+	f := NewFake(epoch)
+	short, long := f.After(time.Second), f.After(3*time.Second)
+
+	f.Advance(999 * time.Millisecond)
+	select {
+	case <-short:
+		t.Fatal("After(1s) fired before its deadline")
+	default:
+	}
+
+	f.Advance(time.Millisecond)
+	select {
+	case at := <-short:
+		if !at.Equal(epoch.Add(time.Second)) {
+			t.Errorf("After(1s) delivered %v, want %v", at, epoch.Add(time.Second))
+		}
+	default:
+		t.Fatal("After(1s) did not fire at its deadline")
+	}
+
+	// Overshooting fires everything due
+	f.Advance(time.Minute)
+	select {
+	case <-long:
+	default:
+		t.Fatal("After(3s) did not fire once the clock passed it")
+	}
+}
+
+func TestFakeAfterNonPositiveFiresImmediately(t *testing.T) {
+	f := NewFake(epoch)
+	select {
+	case <-f.After(0):
+	default:
+		t.Fatal("After(0) did not fire immediately")
+	}
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	f := NewFake(epoch)
+	done := make(chan struct{})
+	go func() {
+		<-f.After(time.Second)
+		close(done)
+	}()
+
+	f.BlockUntil(1)
+	f.Advance(time.Second)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("goroutine waiting on the fake clock never woke")
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	before := time.Now()
+	if now := Real.Now(); now.Before(before) {
+		t.Errorf("Real.Now() = %v, before %v", now, before)
+	}
+	select {
+	case <-Real.After(time.Millisecond):
+	case <-time.After(time.Second):
+		t.Fatal("Real.After(1ms) never fired")
+	}
+}
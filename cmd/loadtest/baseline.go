@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/runner"
+)
+
+// exitRegression is the exit status when -baseline finds a regression,
+// distinct from configuration errors so CI can tell them apart.
+const exitRegression = 3
+
+// resultsSchemaVersion is the version of the -json document written by
+// this build. Bump it when a field is renamed, removed or changes meaning;
+// adding a field doesn't need a bump.
+const resultsSchemaVersion = 1
+
+// resultsJSON is the -json output: one entry per pattern, versioned so
+// tooling reading it can tell which fields to expect. -baseline reads the
+// same document back. Every measurement's unit is in its field name.
+type resultsJSON struct {
+	SchemaVersion int          `json:"schema_version"`
+	Results       []resultJSON `json:"results"`
+}
+
+// resultJSON is one pattern's entry in the -json output.
+type resultJSON struct {
+	Pattern                   string                 `json:"pattern"`
+	TotalRequests             int64                  `json:"total_requests"`
+	SuccessRequests           int64                  `json:"success_requests"`
+	ErrorRequests             int64                  `json:"error_requests"`
+	RejectedRequests          int64                  `json:"rejected_requests"`
+	WarmupRequests            int64                  `json:"warmup_requests,omitempty"`
+	LatencySamples            int64                  `json:"latency_samples,omitempty"`
+	Runs                      int                    `json:"runs,omitempty"`
+	DurationSeconds           float64                `json:"duration_seconds"`
+	RequestsPerSecond         float64                `json:"requests_per_second"`
+	RequestsPerSecondCI       *metrics.Interval      `json:"requests_per_second_ci95,omitempty"`
+	LatencyMs                 runner.LatencySummary  `json:"latency_ms"`
+	P99LatencyMsCI            *metrics.Interval      `json:"p99_latency_ms_ci95,omitempty"`
+	CorrectedLatencyMs        *runner.LatencySummary `json:"corrected_latency_ms,omitempty"`
+	ErrorRatePercent          float64                `json:"error_rate_percent"`
+	RejectionRatePercent      float64                `json:"rejection_rate_percent"`
+	MemoryAllocations         int64                  `json:"memory_allocations,omitempty"`
+	MemoryBytes               int64                  `json:"memory_bytes,omitempty"`
+	PeakQueueUtilizationPct   *float64               `json:"peak_queue_utilization_percent,omitempty"`
+	PoolHitRatePercent        *float64               `json:"pool_hit_rate_percent,omitempty"`
+	TheoreticalRequestsPerSec float64                `json:"theoretical_requests_per_second"`
+	TheoreticalMinLatencyMs   float64                `json:"theoretical_min_latency_ms"`
+	EfficiencyPercent         float64                `json:"efficiency_percent"`
+	Baseline                  *baselineDelta         `json:"baseline,omitempty"`
+}
+
+// newResultJSON converts a result to its -json form.
+func newResultJSON(r runner.Result) resultJSON {
+	return resultJSON{
+		Pattern:             r.PatternName,
+		TotalRequests:       r.TotalRequests,
+		SuccessRequests:     r.SuccessRequests,
+		ErrorRequests:       r.ErrorRequests,
+		RejectedRequests:    r.RejectedRequests,
+		WarmupRequests:      r.WarmupRequests,
+		LatencySamples:      r.LatencySamples,
+		Runs:                r.Runs,
+		DurationSeconds:     r.Duration,
+		RequestsPerSecond:   r.RequestsPerSec,
+		RequestsPerSecondCI: r.ThroughputCI,
+		LatencyMs: runner.LatencySummary{
+			Min:    r.MinLatency,
+			Mean:   r.MeanLatency,
+			Median: r.MedianLatency,
+			P95:    r.P95Latency,
+			P99:    r.P99Latency,
+			P999:   r.P999Latency,
+			Max:    r.MaxLatency,
+		},
+		P99LatencyMsCI:            r.P99CI,
+		CorrectedLatencyMs:        r.Corrected,
+		ErrorRatePercent:          r.ErrorRate,
+		RejectionRatePercent:      r.RejectionRate,
+		MemoryAllocations:         r.MemoryAllocations,
+		MemoryBytes:               r.MemoryBytes,
+		PeakQueueUtilizationPct:   r.PeakQueueUtilization,
+		PoolHitRatePercent:        r.PoolHitRate,
+		TheoreticalRequestsPerSec: r.TheoreticalRPS,
+		TheoreticalMinLatencyMs:   r.TheoreticalMinLatency,
+		EfficiencyPercent:         r.Efficiency,
+	}
+}
+
+// result converts a -json entry back to a result.
+func (j resultJSON) result() runner.Result {
+	return runner.Result{
+		PatternName:      j.Pattern,
+		TotalRequests:    j.TotalRequests,
+		SuccessRequests:  j.SuccessRequests,
+		ErrorRequests:    j.ErrorRequests,
+		RejectedRequests: j.RejectedRequests,
+		WarmupRequests:   j.WarmupRequests,
+		LatencySamples:   j.LatencySamples,
+		Runs:             j.Runs,
+		ThroughputCI:     j.RequestsPerSecondCI,
+		P99CI:            j.P99LatencyMsCI,
+		Duration:         j.DurationSeconds,
+		RequestsPerSec:   j.RequestsPerSecond,
+		MinLatency:       j.LatencyMs.Min,
+		MeanLatency:      j.LatencyMs.Mean,
+		MedianLatency:    j.LatencyMs.Median,
+		P95Latency:       j.LatencyMs.P95,
+		P99Latency:       j.LatencyMs.P99,
+		P999Latency:      j.LatencyMs.P999,
+		MaxLatency:       j.LatencyMs.Max,
+		ErrorRate:        j.ErrorRatePercent,
+		RejectionRate:    j.RejectionRatePercent,
+		Corrected:        j.CorrectedLatencyMs,
+
+		MemoryAllocations: j.MemoryAllocations,
+		MemoryBytes:       j.MemoryBytes,
+
+		PeakQueueUtilization: j.PeakQueueUtilizationPct,
+		PoolHitRate:          j.PoolHitRatePercent,
+
+		TheoreticalRPS:        j.TheoreticalRequestsPerSec,
+		TheoreticalMinLatency: j.TheoreticalMinLatencyMs,
+		Efficiency:            j.EfficiencyPercent,
+	}
+}
+
+// loadBaseline reads results previously written with -json. Output from
+// before schema_version existed, a bare array of entries, is read too.
+func loadBaseline(path string) ([]runner.Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading baseline: %w", err)
+	}
+
+	var doc resultsJSON
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &doc.Results)
+	} else {
+		err = json.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing baseline %s: %w", path, err)
+	}
+	if doc.SchemaVersion > resultsSchemaVersion {
+		return nil, fmt.Errorf("baseline %s has schema version %d; this loadtest reads up to %d", path, doc.SchemaVersion, resultsSchemaVersion)
+	}
+
+	results := make([]runner.Result, len(doc.Results))
+	for i, entry := range doc.Results {
+		results[i] = entry.result()
+	}
+	return results, nil
+}
+
+// baselineDelta is the percentage change of one pattern against its
+// baseline. Positive throughput is an improvement; positive latency is a
+// slowdown.
+type baselineDelta struct {
+	Pattern           string   `json:"-"`
+	ThroughputPercent float64  `json:"throughput_percent"`
+	MeanPercent       float64  `json:"mean_latency_percent"`
+	P95Percent        float64  `json:"p95_latency_percent"`
+	P99Percent        float64  `json:"p99_latency_percent"`
+	Regressions       []string `json:"regressions,omitempty"`
+}
+
+// compareToBaseline matches results to the baseline by pattern name and
+// flags any throughput drop or latency increase beyond threshold percent.
+// Patterns missing from the baseline are skipped.
+func compareToBaseline(current, baseline []runner.Result, threshold float64) []baselineDelta {
+	byName := make(map[string]runner.Result, len(baseline))
+	for _, r := range baseline {
+		byName[r.PatternName] = r
+	}
+
+	var deltas []baselineDelta
+	for _, r := range current {
+		base, ok := byName[r.PatternName]
+		if !ok {
+			continue
+		}
+
+		d := baselineDelta{
+			Pattern:           r.PatternName,
+			ThroughputPercent: percentChange(base.RequestsPerSec, r.RequestsPerSec),
+			MeanPercent:       percentChange(base.MeanLatency, r.MeanLatency),
+			P95Percent:        percentChange(base.P95Latency, r.P95Latency),
+			P99Percent:        percentChange(base.P99Latency, r.P99Latency),
+		}
+		if -d.ThroughputPercent > threshold {
+			d.Regressions = append(d.Regressions, fmt.Sprintf("throughput down %.1f%%", -d.ThroughputPercent))
+		}
+		for _, metric := range []struct {
+			name   string
+			change float64
+		}{
+			{"mean latency", d.MeanPercent},
+			{"p95 latency", d.P95Percent},
+			{"p99 latency", d.P99Percent},
+		} {
+			if metric.change > threshold {
+				d.Regressions = append(d.Regressions, fmt.Sprintf("%s up %.1f%%", metric.name, metric.change))
+			}
+		}
+		deltas = append(deltas, d)
+	}
+	return deltas
+}
+
+// percentChange returns the change from base to current in percent, or
+// zero when there is no base to compare against.
+func percentChange(base, current float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return (current - base) / base * 100
+}
+
+// regressed reports whether any pattern regressed.
+func regressed(deltas []baselineDelta) bool {
+	for _, d := range deltas {
+		if len(d.Regressions) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// printBaselineComparison prints each pattern's change against the baseline.
+func printBaselineComparison(deltas []baselineDelta, threshold float64) {
+	fmt.Printf("Baseline Comparison (regression threshold %.1f%%):\n", threshold)
+	for _, d := range deltas {
+		status := "ok"
+		if len(d.Regressions) > 0 {
+			status = "REGRESSION"
+		}
+		fmt.Printf("  %-12s req/s %+6.1f%%  mean %+6.1f%%  p95 %+6.1f%%  p99 %+6.1f%%  %s\n",
+			d.Pattern+":", d.ThroughputPercent, d.MeanPercent, d.P95Percent, d.P99Percent, status)
+		for _, regression := range d.Regressions {
+			fmt.Printf("  %-12s %s\n", "", regression)
+		}
+	}
+	fmt.Println()
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/runner"
+)
+
+func syntheticResult(name string, rps, p99 float64) runner.Result {
+	return runner.Result{
+		PatternName:    name,
+		RequestsPerSec: rps,
+		MeanLatency:    p99 / 2,
+		P95Latency:     p99 * 0.9,
+		P99Latency:     p99,
+		P999Latency:    p99 * 1.5,
+		LatencySamples: 5000,
+	}
+}
+
+func TestCompareToBaseline(t *testing.T) {
+	baseline := []runner.Result{
+		syntheticResult("Naive", 1000, 100),
+		syntheticResult("Worker Pool", 500, 100),
+	}
+
+	tests := []struct {
+		name       string
+		current    []runner.Result
+		regression bool
+	}{
+		{"unchanged", []runner.Result{syntheticResult("Naive", 1000, 100)}, false},
+		{"within threshold", []runner.Result{syntheticResult("Naive", 960, 104)}, false},
+		{"faster", []runner.Result{syntheticResult("Naive", 2000, 50)}, false},
+		{"p99 up 10%", []runner.Result{syntheticResult("Naive", 1000, 110)}, true},
+		{"throughput down 10%", []runner.Result{syntheticResult("Worker Pool", 450, 100)}, true},
+		{"pattern not in baseline", []runner.Result{syntheticResult("Optimized", 1, 1000)}, false},
+	}
+
+	for _, tc := range tests {
+		deltas := compareToBaseline(tc.current, baseline, 5)
+		if got := regressed(deltas); got != tc.regression {
+			t.Errorf("%s: regressed = %v, want %v (deltas %+v)", tc.name, got, tc.regression, deltas)
+		}
+	}
+
+	deltas := compareToBaseline([]runner.Result{syntheticResult("Naive", 900, 120)}, baseline, 5)
+	if len(deltas) != 1 {
+		t.Fatalf("got %d deltas, want 1", len(deltas))
+	}
+	d := deltas[0]
+	if d.ThroughputPercent != -10 || d.P99Percent != 20 {
+		t.Errorf("throughput %+.1f%%, p99 %+.1f%%; want -10%%, +20%%", d.ThroughputPercent, d.P99Percent)
+	}
+	// Throughput, mean, p95 and p99 all moved past the threshold
+	if len(d.Regressions) != 4 {
+		t.Errorf("regressions = %v, want four", d.Regressions)
+	}
+}
+
+// TestBaselineRoundTrip saves results in the bare-array -json format from
+// before schema_version and loads them back as a baseline.
+func TestBaselineRoundTrip(t *testing.T) {
+	results := []runner.Result{syntheticResult("Naive", 1000, 100), syntheticResult("Optimized", 1500, 80)}
+	entries := []resultJSON{newResultJSON(results[0]), newResultJSON(results[1])}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	loaded, err := loadBaseline(path)
+	if err != nil {
+		t.Fatalf("loadBaseline: %v", err)
+	}
+	if len(loaded) != 2 || !reflect.DeepEqual(loaded[1], results[1]) {
+		t.Errorf("loaded %+v, want %+v", loaded, results)
+	}
+
+	if _, err := loadBaseline(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing baseline")
+	}
+}
+
+// TestJSONResultsRoundTrip writes results as the -json document, with a
+// pattern name full of characters JSON has to escape, and checks the
+// document decodes back to the same entries and loads as a baseline.
+func TestJSONResultsRoundTrip(t *testing.T) {
+	quirky := syntheticResult("Pool \"fast\" <b>\\ \u00e9\n", 1500, 80)
+	quirky.ThroughputCI = &metrics.Interval{Mean: 1500, HalfWidth: 12.5}
+	quirky.Corrected = &runner.LatencySummary{Min: 1, Mean: 40, Median: 38, P95: 70, P99: 90, P999: 120, Max: 150}
+	peak, hitRate := 87.5, 96.25
+	quirky.PeakQueueUtilization = &peak
+	quirky.PoolHitRate = &hitRate
+	results := []runner.Result{syntheticResult("Naive", 1000, 100), quirky}
+	deltas := []baselineDelta{{Pattern: "Naive", ThroughputPercent: -2, P99Percent: 1}}
+
+	var buf bytes.Buffer
+	if err := writeJSONResults(&buf, results, deltas); err != nil {
+		t.Fatalf("writeJSONResults: %v", err)
+	}
+
+	var doc resultsJSON
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if doc.SchemaVersion != resultsSchemaVersion {
+		t.Errorf("schema_version = %d, want %d", doc.SchemaVersion, resultsSchemaVersion)
+	}
+	want := []resultJSON{newResultJSON(results[0]), newResultJSON(results[1])}
+	delta := deltas[0]
+	delta.Pattern = "" // Not encoded; the entry's own pattern names it
+	want[0].Baseline = &delta
+	if !reflect.DeepEqual(doc.Results, want) {
+		t.Errorf("decoded %+v, want %+v", doc.Results, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "results.json")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	loaded, err := loadBaseline(path)
+	if err != nil {
+		t.Fatalf("loadBaseline: %v", err)
+	}
+	if len(loaded) != 2 || !reflect.DeepEqual(loaded[1], quirky) {
+		t.Errorf("loaded %+v, want %+v", loaded, results)
+	}
+
+	future := filepath.Join(t.TempDir(), "future.json")
+	if err := os.WriteFile(future, []byte(`{"schema_version": 99, "results": []}`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := loadBaseline(future); err == nil {
+		t.Error("expected an error for a baseline from a newer schema")
+	}
+}
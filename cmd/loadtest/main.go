@@ -1,232 +1,328 @@
 package main
 
 import (
-	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"sync"
+	"runtime"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
-	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
 	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
-	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/runner"
 )
 
-// LoadTestConfig holds configuration for the load test.
-type LoadTestConfig struct {
-	TotalRequests int
-	Concurrency   int
-	Workers       int
-	QueueSize     int
-}
-
-// PatternHandler wraps the handler interface for testing.
-type PatternHandler interface {
-	HandleRequest(ctx context.Context, patientID string) (*models.PatientResponse, error)
-	GetName() string
-	Shutdown(ctx context.Context) error
-}
-
 func main() {
 	// Parse flags
 	var (
 		requests    = flag.Int("requests", 1000, "Total number of requests to send")
 		concurrency = flag.Int("concurrency", 100, "Number of concurrent clients")
-		workers     = flag.Int("workers", 20, "Number of workers for pool patterns")
+		sweep       = flag.String("concurrency-sweep", "", "Run each pattern at each of these comma-separated concurrency levels (e.g. 10,50,100,250,500) and report throughput and P99 as a matrix, instead of at -concurrency")
+		workers     = flag.Int("workers", 20, "Number of workers for pool patterns (slots for semaphore, query workers for pipeline)")
 		queueSize   = flag.Int("queue-size", 100, "Queue size for pool patterns")
+		workload    = flag.String("workload", "", "Size -workers and -queue-size for this machine's CPUs: io (4 workers per CPU) or cpu (1 per CPU); explicit -workers and -queue-size still win")
+		enqueueWait = flag.Duration("enqueue-timeout", patterns.DefaultEnqueueTimeout, "Max wait for queue space or a semaphore slot before rejecting")
 		outputJSON  = flag.Bool("json", false, "Output results in JSON format")
-		pattern     = flag.String("pattern", "all", "Pattern to test: naive, workerpool, optimized, or all")
+		pattern     = flag.String("pattern", "all", "Pattern to test: naive, workerpool, optimized, semaphore, pipeline, bulkhead, or all")
+		maxConns    = flag.Int("max-connections", 0, "Simulated database connection pool size (0 for unlimited)")
+		maxRoutines = flag.Int("max-goroutines", 0, "Safety cap on the naive pattern's concurrent goroutines; requests beyond it are rejected (0 for unbounded)")
+		tailProb    = flag.Float64("tail-probability", 0, "Fraction of queries that get a tail-latency spike (0.0 to 1.0)")
+		tailLatency = flag.Duration("tail-latency", time.Second, "Extra latency added to queries that spike")
+		corpusSize  = flag.Int("corpus-size", 0, "Pre-generate this many patients and serve reads from them (0 to generate a record per query)")
+		writeRatio  = flag.Float64("write-ratio", 0, "Fraction of requests that are patient updates (0.0 to 1.0)")
+		theoretical = flag.Bool("theoretical", false, "Compare achieved throughput against the ideal theoretical model")
+		runs        = flag.Int("runs", 1, "Run each pattern this many times and report the mean with a 95% confidence interval")
+		warmup      = flag.String("warmup", "", "Unmeasured warm-up traffic before each pattern's run: a request count (500) or a duration (2s)")
+		thinkTime   = flag.String("think-time", "", "Pause between each client's requests, fixed (20ms) or a random range (10ms-50ms); not counted in latency")
+		htmlReport  = flag.String("html", "", "Also write the results as a self-contained HTML report to this file")
+		baseline    = flag.String("baseline", "", "Compare against results saved earlier with -json and exit 3 on a regression")
+		threshold   = flag.Float64("regression-threshold", 5, "Percent throughput drop or latency increase that counts as a regression")
+		sloP99      = flag.Float64("slo-p99", 0, "Exit 4 if the winning pattern's (or the only pattern's) P99 latency is above this many ms (0 for no check)")
+		sloErrors   = flag.Float64("slo-error-rate", 0, "Exit 4 if the winning pattern's (or the only pattern's) error rate is above this percent (0 for no check)")
+		correctCO   = flag.Bool("correct-co", false, "Send on a fixed schedule and also report latency from each request's intended send time (coordinated-omission correction)")
+		coInterval  = flag.Duration("co-interval", 0, "Per-client gap between intended sends with -correct-co (0 for the mean query latency)")
+		pushGateway = flag.String("push-gateway", "", "Push each pattern's metrics to this Prometheus Pushgateway URL after the run, with the pattern as the job")
+		traceFile   = flag.String("trace", "", "Replay the requests in this trace file (timestamp, patient ID, optional read/write per line) at their recorded times, instead of -requests from -concurrency clients")
+		traceSpeed  = flag.Float64("trace-speed", 1, "Replay -trace this many times faster than recorded (0.5 for half speed)")
 	)
 	flag.Parse()
 
-	config := LoadTestConfig{
-		TotalRequests: *requests,
-		Concurrency:   *concurrency,
-		Workers:       *workers,
-		QueueSize:     *queueSize,
+	thinkMin, thinkMax, err := runner.ParseThinkTime(*thinkTime)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	warmupRequests, warmupDuration, err := runner.ParseWarmup(*warmup)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	config := runner.Config{
+		TotalRequests:  *requests,
+		Concurrency:    *concurrency,
+		Workers:        *workers,
+		QueueSize:      *queueSize,
+		EnqueueTimeout: *enqueueWait,
+		WriteRatio:     *writeRatio,
+		MaxConnections: *maxConns,
+		CorpusSize:     *corpusSize,
+		MaxGoroutines:  *maxRoutines,
+
+		TailProbability: *tailProb,
+		TailLatency:     *tailLatency,
+
+		WarmupRequests: warmupRequests,
+		WarmupDuration: warmupDuration,
+
+		ThinkTimeMin: thinkMin,
+		ThinkTimeMax: thinkMax,
+
+		CorrectCO:        *correctCO,
+		ScheduleInterval: *coInterval,
+
+		TraceSpeed: *traceSpeed,
+
+		MeasureMemory: true,
+	}
+
+	if *workload != "" {
+		sized, err := patterns.WorkloadConfig(*workload, runtime.NumCPU())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-workload: %v\n", err)
+			os.Exit(1)
+		}
+		set := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if !set["workers"] {
+			config.Workers = sized.Workers
+		}
+		if !set["queue-size"] {
+			config.QueueSize = sized.QueueSize
+		}
+	}
+
+	if *traceFile != "" {
+		config.Trace, err = runner.LoadTrace(*traceFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		config.TotalRequests = len(config.Trace)
+	}
+
+	if err := config.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *runs < 1 {
+		fmt.Fprintf(os.Stderr, "-runs must be positive (got %d)\n", *runs)
+		os.Exit(1)
+	}
+	target := slo{P99Ms: *sloP99, ErrorRatePercent: *sloErrors}
+	if target.P99Ms < 0 || target.ErrorRatePercent < 0 {
+		fmt.Fprintf(os.Stderr, "-slo-p99 and -slo-error-rate must not be negative\n")
+		os.Exit(1)
+	}
+
+	var sweepLevels []int
+	if *sweep != "" {
+		sweepLevels, err = parseConcurrencySweep(*sweep)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if *baseline != "" || target.enabled() || *htmlReport != "" || *pushGateway != "" || *traceFile != "" || *theoretical {
+			fmt.Fprintf(os.Stderr, "-concurrency-sweep can't be combined with -baseline, -slo-*, -html, -push-gateway, -trace or -theoretical\n")
+			os.Exit(1)
+		}
+		// Validate each level's config, since Validate only saw -concurrency
+		for _, level := range sweepLevels {
+			levelConfig := config
+			levelConfig.Concurrency = level
+			if err := levelConfig.Validate(); err != nil {
+				fmt.Fprintf(os.Stderr, "concurrency %d: %v\n", level, err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Load the baseline up front so a bad path fails before the run
+	var baselineResults []runner.Result
+	if *baseline != "" {
+		baselineResults, err = loadBaseline(*baseline)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	// Print header
 	if !*outputJSON {
-		printHeader(config)
+		printHeader(config, sweepLevels)
+	}
+
+	// Select patterns
+	keys := []string{*pattern}
+	if *pattern == "all" {
+		keys = []string{"naive", "workerpool", "optimized", "semaphore", "pipeline", "bulkhead"}
+	}
+	selected, err := config.Select(keys...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid pattern: %s\n", *pattern)
+		os.Exit(1)
 	}
 
 	// Create database simulator
-	db := simulator.NewDefaultDatabase()
+	db := config.NewDatabase()
 	defer db.Close()
 
-	// Run tests based on pattern selection
-	var results []TestResult
-
-	switch *pattern {
-	case "naive":
-		results = append(results, runTest("Naive", config, db, func(db *simulator.Database) PatternHandler {
-			return patterns.NewNaiveHandler(db)
-		}))
-	case "workerpool":
-		results = append(results, runTest("Worker Pool", config, db, func(db *simulator.Database) PatternHandler {
-			poolConfig := patterns.WorkerPoolConfig{
-				Workers:   config.Workers,
-				QueueSize: config.QueueSize,
-			}
-			return patterns.NewWorkerPoolHandler(db, poolConfig)
-		}))
-	case "optimized":
-		results = append(results, runTest("Optimized", config, db, func(db *simulator.Database) PatternHandler {
-			poolConfig := patterns.WorkerPoolConfig{
-				Workers:   config.Workers,
-				QueueSize: config.QueueSize,
-			}
-			return patterns.NewOptimizedHandler(db, poolConfig)
-		}))
-	case "all":
-		results = append(results, runTest("Naive", config, db, func(db *simulator.Database) PatternHandler {
-			return patterns.NewNaiveHandler(db)
-		}))
-		results = append(results, runTest("Worker Pool", config, db, func(db *simulator.Database) PatternHandler {
-			poolConfig := patterns.WorkerPoolConfig{
-				Workers:   config.Workers,
-				QueueSize: config.QueueSize,
-			}
-			return patterns.NewWorkerPoolHandler(db, poolConfig)
-		}))
-		results = append(results, runTest("Optimized", config, db, func(db *simulator.Database) PatternHandler {
-			poolConfig := patterns.WorkerPoolConfig{
-				Workers:   config.Workers,
-				QueueSize: config.QueueSize,
+	// Run tests based on pattern selection. Progress goes to stderr with
+	// -json so stdout stays parseable.
+	progress := os.Stdout
+	if *outputJSON {
+		progress = os.Stderr
+	}
+
+	if sweepLevels != nil {
+		rows := runSweep(selected, config, db, sweepLevels, *runs, progress)
+		if *outputJSON {
+			if err := writeSweepJSON(os.Stdout, rows, sweepLevels); err != nil {
+				fmt.Fprintf(os.Stderr, "encoding results: %v\n", err)
+				db.Close()
+				os.Exit(1)
 			}
-			return patterns.NewOptimizedHandler(db, poolConfig)
-		}))
-	default:
-		fmt.Fprintf(os.Stderr, "Invalid pattern: %s\n", *pattern)
-		os.Exit(1)
+		} else {
+			printSweepTable(os.Stdout, rows, sweepLevels)
+		}
+		return
+	}
+
+	var results []runner.Result
+	for _, p := range selected {
+		fmt.Fprintf(progress, "\n=== Testing %s ===\n", p.Name)
+		var result runner.Result
+		if *runs > 1 {
+			result = runner.Repeat(p, config, db, *runs)
+		} else {
+			result = runner.Run(p, config, db)
+		}
+		if result.WarmupRequests > 0 {
+			fmt.Fprintf(progress, "Warm-up:   %d requests (not measured)\n", result.WarmupRequests)
+		}
+		fmt.Fprintf(progress, "Completed: %d requests in %.2fs (%.2f req/s)\n",
+			result.TotalRequests, result.Duration, result.RequestsPerSec)
+		results = append(results, result)
+	}
+
+	var deltas []baselineDelta
+	if baselineResults != nil {
+		deltas = compareToBaseline(results, baselineResults, *threshold)
 	}
 
 	// Output results
 	if *outputJSON {
-		printJSONResults(results)
+		printJSONResults(results, deltas)
 	} else {
 		printComparisonTable(results)
+		if *theoretical {
+			printTheoreticalComparison(results)
+		}
+		if baselineResults != nil {
+			printBaselineComparison(deltas, *threshold)
+		}
 	}
-}
 
-// TestResult holds the results of a single test run.
-type TestResult struct {
-	PatternName      string
-	TotalRequests    int64
-	SuccessRequests  int64
-	ErrorRequests    int64
-	RejectedRequests int64
-	Duration         float64
-	RequestsPerSec   float64
-	MinLatency       float64
-	MeanLatency      float64
-	MedianLatency    float64
-	P95Latency       float64
-	P99Latency       float64
-	MaxLatency       float64
-	ErrorRate        float64
-	RejectionRate    float64
-}
-
-// runTest executes a load test for a specific pattern.
-func runTest(name string, config LoadTestConfig, db *simulator.Database, createHandler func(*simulator.Database) PatternHandler) TestResult {
-	fmt.Printf("\n=== Testing %s ===\n", name)
-
-	// Create handler
-	handler := createHandler(db)
-	defer func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-		handler.Shutdown(ctx)
-	}()
-
-	// Create metrics collector
-	collector := metrics.NewCollector()
-
-	// Calculate requests per worker
-	requestsPerWorker := config.TotalRequests / config.Concurrency
-	remainder := config.TotalRequests % config.Concurrency
-
-	// Run the load test
-	var wg sync.WaitGroup
-
-	for i := 0; i < config.Concurrency; i++ {
-		wg.Add(1)
-		requests := requestsPerWorker
-		if i < remainder {
-			requests++
+	if *htmlReport != "" {
+		if err := saveHTMLReport(*htmlReport, results); err != nil {
+			fmt.Fprintf(os.Stderr, "writing HTML report: %v\n", err)
+			db.Close()
+			os.Exit(1)
 		}
+		fmt.Fprintf(progress, "HTML report written to %s\n", *htmlReport)
+	}
 
-		go func(workerID, numRequests int) {
-			defer wg.Done()
-
-			for j := 0; j < numRequests; j++ {
-				// Use a variety of patient IDs
-				patientID := fmt.Sprintf("P%05d", (workerID*1000+j)%10000)
+	if *pushGateway != "" {
+		pushResults(progress, *pushGateway, selected, results)
+	}
 
-				// Time the request
-				requestStart := time.Now()
-				ctx := context.Background()
-				_, err := handler.HandleRequest(ctx, patientID)
-				latency := time.Since(requestStart)
+	if regressed(deltas) {
+		db.Close()
+		os.Exit(exitRegression)
+	}
 
-				// Record metrics
-				success := err == nil
-				collector.RecordRequest(latency, success)
-			}
-		}(i, requests)
-	}
-
-	// Wait for all workers to complete
-	wg.Wait()
-	collector.Stop()
-
-	// Get statistics
-	stats := collector.GetStats()
-
-	// Print progress
-	fmt.Printf("Completed: %d requests in %.2fs (%.2f req/s)\n",
-		stats.TotalRequests, stats.Duration, stats.RequestsPerSec)
-
-	// Convert to TestResult
-	return TestResult{
-		PatternName:      name,
-		TotalRequests:    stats.TotalRequests,
-		SuccessRequests:  stats.SuccessRequests,
-		ErrorRequests:    stats.ErrorRequests,
-		RejectedRequests: stats.RejectedRequests,
-		Duration:         stats.Duration,
-		RequestsPerSec:   stats.RequestsPerSec,
-		MinLatency:       stats.MinLatency,
-		MeanLatency:      stats.MeanLatency,
-		MedianLatency:    stats.MedianLatency,
-		P95Latency:       stats.P95Latency,
-		P99Latency:       stats.P99Latency,
-		MaxLatency:       stats.MaxLatency,
-		ErrorRate:        stats.ErrorRate,
-		RejectionRate:    stats.RejectionRate,
+	if target.enabled() {
+		judged, violations := target.check(results)
+		for _, violation := range violations {
+			fmt.Fprintf(os.Stderr, "SLO violated by %s: %s\n", judged, violation)
+		}
+		if len(violations) > 0 {
+			db.Close()
+			os.Exit(exitSLOViolation)
+		}
+		fmt.Fprintf(progress, "SLO met by %s\n", judged)
 	}
 }
 
-// printHeader prints the test configuration.
-func printHeader(config LoadTestConfig) {
+// printHeader prints the test configuration. sweepLevels are the
+// -concurrency-sweep levels, if any.
+func printHeader(config runner.Config, sweepLevels []int) {
 	fmt.Println("\n╔══════════════════════════════════════════════════════════════╗")
 	fmt.Println("║     Healthcare API Concurrency Pattern Load Test            ║")
 	fmt.Println("╚══════════════════════════════════════════════════════════════╝")
 	fmt.Println()
 	fmt.Printf("Configuration:\n")
 	fmt.Printf("  Total Requests:  %d\n", config.TotalRequests)
-	fmt.Printf("  Concurrency:     %d clients\n", config.Concurrency)
+	if n := len(config.Trace); n > 0 {
+		span := config.Trace[n-1].Offset
+		fmt.Printf("  Trace Replay:    %d requests over %v at %gx speed\n", n,
+			time.Duration(float64(span)/config.TraceSpeed), config.TraceSpeed)
+	} else if sweepLevels != nil {
+		fmt.Printf("  Concurrency:     sweep over %s clients\n", strings.Trim(fmt.Sprint(sweepLevels), "[]"))
+		fmt.Printf("  Write Ratio:     %.0f%%\n", config.WriteRatio*100)
+	} else {
+		fmt.Printf("  Concurrency:     %d clients\n", config.Concurrency)
+		fmt.Printf("  Write Ratio:     %.0f%%\n", config.WriteRatio*100)
+	}
 	fmt.Printf("  Workers:         %d (for pool patterns)\n", config.Workers)
 	fmt.Printf("  Queue Size:      %d (for pool patterns)\n", config.QueueSize)
+	fmt.Printf("  Enqueue Timeout: %v\n", config.EnqueueTimeout)
+	if config.MaxConnections > 0 {
+		fmt.Printf("  DB Connections:  %d\n", config.MaxConnections)
+	}
+	if config.MaxGoroutines > 0 {
+		fmt.Printf("  Naive Cap:       %d goroutines\n", config.MaxGoroutines)
+	}
+	if config.CorpusSize > 0 {
+		fmt.Printf("  Corpus:          %d pre-generated patients\n", config.CorpusSize)
+	}
+	if config.TailProbability > 0 {
+		fmt.Printf("  Tail Spikes:     +%v on %.1f%% of queries\n", config.TailLatency, config.TailProbability*100)
+	}
+	if config.WarmupRequests > 0 {
+		fmt.Printf("  Warm-up:         %d requests per pattern (not measured)\n", config.WarmupRequests)
+	} else if config.WarmupDuration > 0 {
+		fmt.Printf("  Warm-up:         %v per pattern (not measured)\n", config.WarmupDuration)
+	}
+	if config.ThinkTimeMax > 0 {
+		if config.ThinkTimeMin == config.ThinkTimeMax {
+			fmt.Printf("  Think Time:      %v between requests\n", config.ThinkTimeMin)
+		} else {
+			fmt.Printf("  Think Time:      %v-%v between requests\n", config.ThinkTimeMin, config.ThinkTimeMax)
+		}
+	}
+	if config.CorrectCO {
+		fmt.Printf("  CO Correction:   on (latency also measured from intended send time)\n")
+	}
 	fmt.Println()
 }
 
 // printComparisonTable prints a comparison table of all results.
-func printComparisonTable(results []TestResult) {
+func printComparisonTable(results []runner.Result) {
 	fmt.Println("\n╔══════════════════════════════════════════════════════════════╗")
 	fmt.Println("║                    RESULTS COMPARISON                        ║")
 	fmt.Println("╚══════════════════════════════════════════════════════════════╝")
@@ -240,18 +336,64 @@ func printComparisonTable(results []TestResult) {
 		if result.RejectedRequests > 0 {
 			fmt.Printf(", %d rejected", result.RejectedRequests)
 		}
+		if result.WarmupRequests > 0 {
+			fmt.Printf(" (after %d warm-up)", result.WarmupRequests)
+		}
 		fmt.Println()
-		fmt.Printf("├─ Throughput:    %.2f req/s\n", result.RequestsPerSec)
+		if ci := result.ThroughputCI; ci != nil {
+			fmt.Printf("├─ Throughput:    %.2f ± %.2f req/s (95%% CI, %d runs)\n", ci.Mean, ci.HalfWidth, result.Runs)
+		} else {
+			fmt.Printf("├─ Throughput:    %.2f req/s\n", result.RequestsPerSec)
+		}
 		fmt.Printf("├─ Duration:      %.2f seconds\n", result.Duration)
-		fmt.Printf("├─ Latency (ms):\n")
+		if result.Corrected != nil {
+			fmt.Printf("├─ Latency (ms, uncorrected service time):\n")
+		} else {
+			fmt.Printf("├─ Latency (ms):\n")
+		}
 		fmt.Printf("│  ├─ Min:        %.2f\n", result.MinLatency)
 		fmt.Printf("│  ├─ Mean:       %.2f\n", result.MeanLatency)
 		fmt.Printf("│  ├─ Median:     %.2f\n", result.MedianLatency)
-		fmt.Printf("│  ├─ P95:        %.2f\n", result.P95Latency)
-		fmt.Printf("│  ├─ P99:        %.2f\n", result.P99Latency)
+		fmt.Printf("│  ├─ P95:        %.2f%s\n", result.P95Latency, unreliableMark(result, 95))
+		if ci := result.P99CI; ci != nil {
+			fmt.Printf("│  ├─ P99:        %.2f ± %.2f%s\n", ci.Mean, ci.HalfWidth, unreliableMark(result, 99))
+		} else {
+			fmt.Printf("│  ├─ P99:        %.2f%s\n", result.P99Latency, unreliableMark(result, 99))
+		}
+		fmt.Printf("│  ├─ P99.9:      %.2f%s\n", result.P999Latency, unreliableMark(result, 99.9))
 		fmt.Printf("│  └─ Max:        %.2f\n", result.MaxLatency)
+		if co := result.Corrected; co != nil {
+			fmt.Printf("├─ Latency (ms, corrected for coordinated omission):\n")
+			fmt.Printf("│  ├─ Min:        %.2f\n", co.Min)
+			fmt.Printf("│  ├─ Mean:       %.2f\n", co.Mean)
+			fmt.Printf("│  ├─ Median:     %.2f\n", co.Median)
+			fmt.Printf("│  ├─ P95:        %.2f%s\n", co.P95, unreliableMark(result, 95))
+			fmt.Printf("│  ├─ P99:        %.2f%s\n", co.P99, unreliableMark(result, 99))
+			fmt.Printf("│  ├─ P99.9:      %.2f%s\n", co.P999, unreliableMark(result, 99.9))
+			fmt.Printf("│  └─ Max:        %.2f\n", co.Max)
+		}
+		if note := percentileFootnote(result); note != "" {
+			fmt.Printf("│  %s\n", note)
+		}
+		if result.MemoryAllocations > 0 {
+			fmt.Printf("├─ Memory:        %s\n", formatMemory(result))
+		}
+		if peak := result.PeakQueueUtilization; peak != nil {
+			fmt.Printf("├─ Peak Queue:    %.1f%% of capacity\n", *peak)
+		}
+		if rate := result.PoolHitRate; rate != nil {
+			fmt.Printf("├─ Pool Hit Rate: %.2f%% of responses reused\n", *rate)
+		}
 		if result.ErrorRate > 0 {
 			fmt.Printf("└─ Error Rate:   %.2f%%\n", result.ErrorRate)
+			categories := make([]string, 0, len(result.ErrorsByCategory))
+			for category := range result.ErrorsByCategory {
+				categories = append(categories, category)
+			}
+			sort.Strings(categories)
+			for _, category := range categories {
+				fmt.Printf("   %-16s %d\n", category+":", result.ErrorsByCategory[category])
+			}
 		}
 		if result.RejectionRate > 0 {
 			fmt.Printf("└─ Rejection:    %.2f%%\n", result.RejectionRate)
@@ -262,29 +404,44 @@ func printComparisonTable(results []TestResult) {
 	// Print summary table
 	if len(results) > 1 {
 		fmt.Println("Summary Table:")
-		fmt.Println("┌─────────────────────┬──────────┬──────────┬──────────┬──────────┬──────────┐")
-		fmt.Println("│ Pattern             │ Req/s    │ Mean(ms) │ P95(ms)  │ P99(ms)  │ Errors   │")
-		fmt.Println("├─────────────────────┼──────────┼──────────┼──────────┼──────────┼──────────┤")
+		fmt.Println("┌─────────────────────┬──────────┬──────────┬──────────┬──────────┬──────────┬──────────┐")
+		fmt.Println("│ Pattern             │ Req/s    │ Mean(ms) │ P95(ms)  │ P99(ms)  │ Errors   │ Peak Q   │")
+		fmt.Println("├─────────────────────┼──────────┼──────────┼──────────┼──────────┼──────────┼──────────┤")
 
 		for _, result := range results {
-			fmt.Printf("│ %-19s │ %8.2f │ %8.2f │ %8.2f │ %8.2f │ %7.2f%% │\n",
+			fmt.Printf("│ %-19s │ %8.2f │ %8.2f │ %s │ %s │ %7.2f%% │ %s │\n",
 				result.PatternName,
 				result.RequestsPerSec,
 				result.MeanLatency,
-				result.P95Latency,
-				result.P99Latency,
-				result.ErrorRate)
+				tableCell(result, 95, result.P95Latency),
+				tableCell(result, 99, result.P99Latency),
+				result.ErrorRate,
+				queueCell(result))
 		}
 
-		fmt.Println("└─────────────────────┴──────────┴──────────┴──────────┴──────────┴──────────┘")
+		fmt.Println("└─────────────────────┴──────────┴──────────┴──────────┴──────────┴──────────┴──────────┘")
+		for _, result := range results {
+			if percentileFootnote(result) != "" {
+				fmt.Println("* Too few latency samples for this percentile to be reliable; see each pattern above")
+				break
+			}
+		}
 		fmt.Println()
 
-		// Find the winner
-		best := results[0]
-		for _, r := range results[1:] {
-			if r.RequestsPerSec > best.RequestsPerSec {
-				best = r
+		if results[0].Corrected != nil {
+			fmt.Println("Latency above is uncorrected service time. Corrected for coordinated omission:")
+			for _, r := range results {
+				fmt.Printf("   %-19s mean %.2fms, p99 %.2fms\n", r.PatternName+":", r.Corrected.Mean, r.Corrected.P99)
 			}
+			fmt.Println()
+		}
+
+		// Find the winner
+		best, significant := runner.SignificantWinner(results)
+		if !significant {
+			fmt.Printf("No significant difference: %s has the highest mean throughput, but its 95%% CI overlaps another pattern's\n",
+				best.PatternName)
+			return
 		}
 
 		fmt.Printf("🏆 Winner: %s\n", best.PatternName)
@@ -301,33 +458,114 @@ func printComparisonTable(results []TestResult) {
 	}
 }
 
-// printJSONResults outputs results in JSON format.
-func printJSONResults(results []TestResult) {
-	fmt.Println("[")
+// reportedPercentiles are the percentiles printed for every result.
+var reportedPercentiles = []float64{95, 99, 99.9}
+
+// unreliableMark returns "*" if r has too few latency samples for the pth
+// percentile to mean anything, footnoting it; see percentileFootnote.
+func unreliableMark(r runner.Result, p float64) string {
+	if r.HasReliablePercentiles(p) {
+		return ""
+	}
+	return "*"
+}
+
+// tableCell formats a percentile for the summary table, marked as
+// unreliable if need be, in the table's 8-character column.
+func tableCell(r runner.Result, p, value float64) string {
+	if mark := unreliableMark(r, p); mark != "" {
+		return fmt.Sprintf("%7.2f%s", value, mark)
+	}
+	return fmt.Sprintf("%8.2f", value)
+}
+
+// queueCell formats the peak queue utilization for the summary table's
+// 8-character column, or "-" for patterns without a queue.
+func queueCell(r runner.Result) string {
+	if r.PeakQueueUtilization == nil {
+		return fmt.Sprintf("%8s", "-")
+	}
+	return fmt.Sprintf("%7.1f%%", *r.PeakQueueUtilization)
+}
+
+// percentileFootnote explains the percentiles unreliableMark marked for r,
+// or returns "" if there are none.
+func percentileFootnote(r runner.Result) string {
+	var needs []string
+	for _, p := range reportedPercentiles {
+		if !r.HasReliablePercentiles(p) {
+			needs = append(needs, fmt.Sprintf("P%g needs %d", p, metrics.MinSamplesForPercentile(p)))
+		}
+	}
+	if len(needs) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("* Unreliable: only %d latency samples (%s)", r.LatencySamples, strings.Join(needs, ", "))
+}
+
+// formatMemory describes the heap allocations of a run, in total and per
+// request.
+func formatMemory(r runner.Result) string {
+	return fmt.Sprintf("%.2f MB allocated, %.1f allocs/request",
+		float64(r.MemoryBytes)/(1024*1024), r.AllocsPerRequest())
+}
+
+// printTheoreticalComparison prints achieved vs theoretical throughput for each pattern.
+func printTheoreticalComparison(results []runner.Result) {
+	fmt.Println("Theoretical Comparison:")
+	for _, r := range results {
+		fmt.Printf("  %-12s %8.2f / %8.2f req/s theoretical (%.1f%% efficiency), min latency %.2fms vs %.2fms ideal\n",
+			r.PatternName+":",
+			r.RequestsPerSec,
+			r.TheoreticalRPS,
+			r.Efficiency,
+			r.MinLatency,
+			r.TheoreticalMinLatency)
+	}
+	fmt.Println()
+}
+
+// printJSONResults outputs results in JSON format, with each pattern's
+// change against the baseline if one was given.
+func printJSONResults(results []runner.Result, deltas []baselineDelta) {
+	if err := writeJSONResults(os.Stdout, results, deltas); err != nil {
+		fmt.Fprintf(os.Stderr, "encoding results: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// writeJSONResults writes results to w as a resultsJSON document.
+func writeJSONResults(w io.Writer, results []runner.Result, deltas []baselineDelta) error {
+	doc := resultsJSON{SchemaVersion: resultsSchemaVersion, Results: make([]resultJSON, len(results))}
+	for i, result := range results {
+		doc.Results[i] = newResultJSON(result)
+		for j := range deltas {
+			if deltas[j].Pattern == result.PatternName {
+				doc.Results[i].Baseline = &deltas[j]
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// pushResults pushes each pattern's metrics to a Prometheus Pushgateway.
+// A failed push is reported but doesn't fail the run: the results have
+// already been printed.
+func pushResults(progress io.Writer, gatewayURL string, selected []runner.Pattern, results []runner.Result) {
 	for i, result := range results {
-		fmt.Printf("  {\n")
-		fmt.Printf("    \"pattern\": \"%s\",\n", result.PatternName)
-		fmt.Printf("    \"total_requests\": %d,\n", result.TotalRequests)
-		fmt.Printf("    \"success_requests\": %d,\n", result.SuccessRequests)
-		fmt.Printf("    \"error_requests\": %d,\n", result.ErrorRequests)
-		fmt.Printf("    \"rejected_requests\": %d,\n", result.RejectedRequests)
-		fmt.Printf("    \"duration_seconds\": %.2f,\n", result.Duration)
-		fmt.Printf("    \"requests_per_second\": %.2f,\n", result.RequestsPerSec)
-		fmt.Printf("    \"latency_ms\": {\n")
-		fmt.Printf("      \"min\": %.2f,\n", result.MinLatency)
-		fmt.Printf("      \"mean\": %.2f,\n", result.MeanLatency)
-		fmt.Printf("      \"median\": %.2f,\n", result.MedianLatency)
-		fmt.Printf("      \"p95\": %.2f,\n", result.P95Latency)
-		fmt.Printf("      \"p99\": %.2f,\n", result.P99Latency)
-		fmt.Printf("      \"max\": %.2f\n", result.MaxLatency)
-		fmt.Printf("    },\n")
-		fmt.Printf("    \"error_rate_percent\": %.2f,\n", result.ErrorRate)
-		fmt.Printf("    \"rejection_rate_percent\": %.2f\n", result.RejectionRate)
-		if i < len(results)-1 {
-			fmt.Printf("  },\n")
-		} else {
-			fmt.Printf("  }\n")
+		if result.Collector == nil {
+			continue
+		}
+		if err := result.Collector.PushPrometheus(gatewayURL, selected[i].Key); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			continue
 		}
+		fmt.Fprintf(progress, "Pushed %s metrics to %s\n", result.PatternName, gatewayURL)
 	}
-	fmt.Println("]")
 }
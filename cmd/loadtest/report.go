@@ -0,0 +1,157 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/runner"
+)
+
+// Chart geometry in SVG user units.
+const (
+	chartLabelWidth = 140
+	chartBarWidth   = 360
+	chartRowHeight  = 28
+)
+
+// reportTemplate renders a self-contained page: no scripts, styles or
+// images are loaded from elsewhere.
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Healthcare API Load Test Results</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.4em 0.8em; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+tr.winner { background: #e6f4ea; font-weight: bold; }
+.chart { margin-bottom: 2em; }
+</style>
+</head>
+<body>
+<h1>Healthcare API Load Test Results</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}.
+{{- if .Winner}} Winner: <strong>🏆 {{.Winner}}</strong> (highest throughput).{{end}}</p>
+
+<table>
+<thead>
+<tr><th>Pattern</th><th>Req/s</th><th>Mean (ms)</th><th>Median (ms)</th><th>P95 (ms)</th><th>P99 (ms)</th><th>Max (ms)</th><th>Errors</th><th>Rejected</th></tr>
+</thead>
+<tbody>
+{{- range .Results}}
+<tr{{if eq .PatternName $.Winner}} class="winner"{{end}}>
+<td>{{.PatternName}}{{if eq .PatternName $.Winner}} 🏆{{end}}</td>
+<td>{{printf "%.2f" .RequestsPerSec}}</td>
+<td>{{printf "%.2f" .MeanLatency}}</td>
+<td>{{printf "%.2f" .MedianLatency}}</td>
+<td>{{printf "%.2f" .P95Latency}}</td>
+<td>{{printf "%.2f" .P99Latency}}</td>
+<td>{{printf "%.2f" .MaxLatency}}</td>
+<td>{{printf "%.2f%%" .ErrorRate}}</td>
+<td>{{printf "%.2f%%" .RejectionRate}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+{{range .Charts}}
+<div class="chart">
+<h2>{{.Title}}</h2>
+<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="{{.Height}}" role="img" aria-label="{{.Title}}">
+{{- range .Bars}}
+<text x="0" y="{{.TextY}}" font-size="13">{{.Label}}</text>
+<rect x="{{.X}}" y="{{.Y}}" width="{{printf "%.1f" .Length}}" height="20" fill="{{if .Winner}}#2e7d32{{else}}#5c6bc0{{end}}"/>
+<text x="{{.ValueX}}" y="{{.TextY}}" font-size="13">{{printf "%.2f" .Value}}</text>
+{{- end}}
+</svg>
+</div>
+{{- end}}
+</body>
+</html>
+`))
+
+// reportData is the input to reportTemplate.
+type reportData struct {
+	Generated time.Time
+	Winner    string
+	Results   []runner.Result
+	Charts    []reportChart
+}
+
+// reportChart is a horizontal bar chart with one bar per pattern.
+type reportChart struct {
+	Title         string
+	Width, Height int
+	Bars          []reportBar
+}
+
+// reportBar is one bar of a reportChart, laid out in SVG units.
+type reportBar struct {
+	Label  string
+	Value  float64
+	Winner bool
+
+	X, Y, TextY, ValueX int
+	Length              float64
+}
+
+// newReportChart lays out a bar per result, scaled to the largest value.
+func newReportChart(title string, results []runner.Result, winner string, value func(runner.Result) float64) reportChart {
+	var largest float64
+	for _, r := range results {
+		largest = max(largest, value(r))
+	}
+
+	chart := reportChart{
+		Title:  title,
+		Width:  chartLabelWidth + chartBarWidth + 80,
+		Height: len(results) * chartRowHeight,
+	}
+	for i, r := range results {
+		bar := reportBar{
+			Label:  r.PatternName,
+			Value:  value(r),
+			Winner: r.PatternName == winner,
+			X:      chartLabelWidth,
+			Y:      i * chartRowHeight,
+			TextY:  i*chartRowHeight + 15,
+		}
+		if largest > 0 {
+			bar.Length = bar.Value / largest * chartBarWidth
+		}
+		bar.ValueX = chartLabelWidth + int(bar.Length) + 6
+		chart.Bars = append(chart.Bars, bar)
+	}
+	return chart
+}
+
+// writeHTMLReport renders results as a standalone HTML page.
+func writeHTMLReport(w io.Writer, results []runner.Result, generated time.Time) error {
+	winner := runner.Winner(results).PatternName
+	return reportTemplate.Execute(w, reportData{
+		Generated: generated,
+		Winner:    winner,
+		Results:   results,
+		Charts: []reportChart{
+			newReportChart("Throughput (req/s)", results, winner, func(r runner.Result) float64 { return r.RequestsPerSec }),
+			newReportChart("P95 latency (ms)", results, winner, func(r runner.Result) float64 { return r.P95Latency }),
+			newReportChart("P99 latency (ms)", results, winner, func(r runner.Result) float64 { return r.P99Latency }),
+		},
+	})
+}
+
+// saveHTMLReport writes the report to path.
+func saveHTMLReport(path string, results []runner.Result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeHTMLReport(f, results, time.Now()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
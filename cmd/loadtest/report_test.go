@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/runner"
+)
+
+func TestWriteHTMLReport(t *testing.T) {
+	results := []runner.Result{
+		{PatternName: "Naive", RequestsPerSec: 812.5, P95Latency: 140.25, P99Latency: 180.75},
+		{PatternName: "Optimized", RequestsPerSec: 1234.56, P95Latency: 95.5, P99Latency: 99.99},
+		{PatternName: "<script>alert(1)</script>", RequestsPerSec: 1},
+	}
+
+	var buf bytes.Buffer
+	if err := writeHTMLReport(&buf, results, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)); err != nil {
+		t.Fatalf("writeHTMLReport: %v", err)
+	}
+	page := buf.String()
+
+	for _, want := range []string{
+		"Naive", "Optimized",
+		"1234.56", "812.50", // throughput
+		"140.25", "95.50", // P95
+		"180.75", "99.99", // P99
+		"<svg", "Throughput (req/s)", "P99 latency (ms)",
+		`<tr class="winner">`, "Winner: <strong>🏆 Optimized</strong>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+
+	if strings.Contains(page, "<script>") {
+		t.Error("pattern name was not escaped")
+	}
+	if strings.Count(page, `class="winner"`) != 1 {
+		t.Errorf("expected exactly one winning row")
+	}
+}
+
+func TestPushResults(t *testing.T) {
+	var jobs []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jobs = append(jobs, strings.TrimPrefix(r.URL.Path, "/metrics/job/"))
+	}))
+	defer gateway.Close()
+
+	collector := metrics.NewCollector()
+	collector.RecordRequest(time.Millisecond, true)
+	selected := []runner.Pattern{{Key: "naive"}, {Key: "workerpool"}, {Key: "optimized"}}
+	results := []runner.Result{
+		{PatternName: "Naive", Collector: collector},
+		{PatternName: "Worker Pool"}, // Read back from JSON: nothing to push
+		{PatternName: "Optimized", Collector: collector},
+	}
+
+	var progress bytes.Buffer
+	pushResults(&progress, gateway.URL, selected, results)
+
+	if strings.Join(jobs, ",") != "naive,optimized" {
+		t.Errorf("pushed jobs %v, want [naive optimized]", jobs)
+	}
+	if !strings.Contains(progress.String(), "Pushed Optimized metrics") {
+		t.Errorf("progress = %q, want a line per push", progress.String())
+	}
+
+	// A dead gateway must not stop the run
+	gateway.Close()
+	pushResults(&progress, gateway.URL, selected, results)
+}
+
+func TestFormatMemory(t *testing.T) {
+	result := runner.Result{TotalRequests: 1000, MemoryAllocations: 34800, MemoryBytes: 5 * 1024 * 1024}
+	if got, want := formatMemory(result), "5.00 MB allocated, 34.8 allocs/request"; got != want {
+		t.Errorf("formatMemory = %q, want %q", got, want)
+	}
+}
+
+func TestPercentileFootnote(t *testing.T) {
+	tests := []struct {
+		samples int64
+		want    string
+	}{
+		{5000, ""},
+		{500, "* Unreliable: only 500 latency samples (P99.9 needs 1000)"},
+		{50, "* Unreliable: only 50 latency samples (P99 needs 100, P99.9 needs 1000)"},
+	}
+	for _, tc := range tests {
+		result := runner.Result{LatencySamples: tc.samples}
+		if got := percentileFootnote(result); got != tc.want {
+			t.Errorf("%d samples: percentileFootnote = %q, want %q", tc.samples, got, tc.want)
+		}
+	}
+
+	result := runner.Result{LatencySamples: 500}
+	if got, want := tableCell(result, 99, 12.5), "   12.50"; got != want {
+		t.Errorf("reliable tableCell = %q, want %q", got, want)
+	}
+	if got, want := tableCell(result, 99.9, 12.5), "  12.50*"; got != want {
+		t.Errorf("unreliable tableCell = %q, want %q", got, want)
+	}
+}
+
+func TestQueueCell(t *testing.T) {
+	if got, want := queueCell(runner.Result{}), "       -"; got != want {
+		t.Errorf("queueCell without a queue = %q, want %q", got, want)
+	}
+	peak := 100.0
+	if got, want := queueCell(runner.Result{PeakQueueUtilization: &peak}), "  100.0%"; got != want {
+		t.Errorf("queueCell at capacity = %q, want %q", got, want)
+	}
+}
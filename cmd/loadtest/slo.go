@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/runner"
+)
+
+// exitSLOViolation is the exit status when the judged pattern misses
+// -slo-p99 or -slo-error-rate, distinct from exitRegression so CI can
+// tell a missed target from a slowdown against the baseline.
+const exitSLOViolation = 4
+
+// slo is the service level a run must meet to pass. A zero field is not
+// checked.
+type slo struct {
+	P99Ms            float64 // Highest acceptable P99 latency
+	ErrorRatePercent float64 // Highest acceptable error rate; rejections don't count
+}
+
+// enabled reports whether any threshold is set.
+func (s slo) enabled() bool {
+	return s.P99Ms > 0 || s.ErrorRatePercent > 0
+}
+
+// check returns the pattern the SLO is judged on and a description of
+// each threshold it misses, if any. With a single pattern that pattern is
+// judged; with several, the winner by throughput is, since that is the
+// one the comparison recommends.
+func (s slo) check(results []runner.Result) (judged string, violations []string) {
+	if len(results) == 0 {
+		return "", nil
+	}
+	r := runner.Winner(results)
+
+	if s.P99Ms > 0 && r.P99Latency > s.P99Ms {
+		violations = append(violations, fmt.Sprintf("p99 latency %.2fms exceeds -slo-p99 %.2fms", r.P99Latency, s.P99Ms))
+	}
+	if s.ErrorRatePercent > 0 && r.ErrorRate > s.ErrorRatePercent {
+		violations = append(violations, fmt.Sprintf("error rate %.2f%% exceeds -slo-error-rate %.2f%%", r.ErrorRate, s.ErrorRatePercent))
+	}
+	return r.PatternName, violations
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/runner"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// TestSLOCheck runs the worker pool against a fast and a slow database
+// and checks only the slow run misses a P99 target between the two.
+func TestSLOCheck(t *testing.T) {
+	config := runner.Config{TotalRequests: 200, Concurrency: 10, Workers: 10, QueueSize: 100, EnqueueTimeout: time.Second}
+	selected, err := config.Select("workerpool")
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+
+	run := func(latency time.Duration) []runner.Result {
+		db := simulator.NewDatabase(0, 0, 0)
+		db.SetLatencySource(simulator.NewTraceLatencySource([]time.Duration{latency}))
+		return []runner.Result{runner.Run(selected[0], config, db)}
+	}
+	target := slo{P99Ms: 20, ErrorRatePercent: 1}
+
+	judged, violations := target.check(run(time.Millisecond))
+	if judged != selected[0].Name || len(violations) != 0 {
+		t.Errorf("fast database: judged %q, violations %v; want %q with none", judged, violations, selected[0].Name)
+	}
+
+	_, violations = target.check(run(40 * time.Millisecond))
+	if len(violations) != 1 || !strings.Contains(violations[0], "-slo-p99") {
+		t.Errorf("slow database: violations = %v, want one naming -slo-p99", violations)
+	}
+}
+
+func TestSLOCheckJudgesWinner(t *testing.T) {
+	results := []runner.Result{
+		{PatternName: "Naive", RequestsPerSec: 500, P99Latency: 300, ErrorRate: 20},
+		{PatternName: "Worker Pool", RequestsPerSec: 900, P99Latency: 80, ErrorRate: 0.5},
+	}
+
+	judged, violations := slo{P99Ms: 100, ErrorRatePercent: 1}.check(results)
+	if judged != "Worker Pool" || len(violations) != 0 {
+		t.Errorf("judged %q with violations %v, want Worker Pool with none", judged, violations)
+	}
+
+	results[1].ErrorRate = 2
+	_, violations = slo{P99Ms: 100, ErrorRatePercent: 1}.check(results)
+	if len(violations) != 1 || !strings.Contains(violations[0], "-slo-error-rate") {
+		t.Errorf("violations = %v, want one naming -slo-error-rate", violations)
+	}
+
+	if (slo{}).enabled() {
+		t.Error("zero SLO reported as enabled")
+	}
+}
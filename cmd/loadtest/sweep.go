@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/runner"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// parseConcurrencySweep parses -concurrency-sweep, a comma-separated list
+// of concurrency levels such as "10,50,100", into ascending order.
+func parseConcurrencySweep(value string) ([]int, error) {
+	var levels []int
+	seen := make(map[int]bool)
+	for _, field := range strings.Split(value, ",") {
+		level, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || level <= 0 {
+			return nil, fmt.Errorf("-concurrency-sweep: %q is not a positive concurrency level", strings.TrimSpace(field))
+		}
+		if seen[level] {
+			return nil, fmt.Errorf("-concurrency-sweep: %d is listed twice", level)
+		}
+		seen[level] = true
+		levels = append(levels, level)
+	}
+	sort.Ints(levels)
+	return levels, nil
+}
+
+// sweepCell is one pattern's result at one concurrency level.
+type sweepCell struct {
+	Concurrency       int     `json:"concurrency"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	MeanLatencyMs     float64 `json:"mean_latency_ms"`
+	P99LatencyMs      float64 `json:"p99_latency_ms"`
+	ErrorRatePercent  float64 `json:"error_rate_percent"`
+	RejectionPercent  float64 `json:"rejection_rate_percent"`
+}
+
+// sweepRow is one pattern's results across the sweep, one cell per
+// concurrency level in ascending order, with its knee point (see
+// findKnee).
+type sweepRow struct {
+	Pattern string      `json:"pattern"`
+	Cells   []sweepCell `json:"results"`
+
+	// Highest concurrency worth running at: the knee if the pattern
+	// saturated within the sweep, and otherwise the highest level swept
+	RecommendedConcurrency int  `json:"recommended_max_concurrency"`
+	Saturated              bool `json:"saturated"`
+}
+
+// kneeEfficiency is the least fraction of a concurrency increase that
+// throughput must keep up with for the step to count as still scaling.
+// Below it, the extra clients mostly queue.
+const kneeEfficiency = 0.2
+
+// findKnee returns the concurrency level at which a pattern saturates:
+// the last level before a step where throughput stops increasing while
+// mean latency keeps rising. saturated is false if every step still
+// scaled, in which case the knee lies beyond the sweep and knee is the
+// highest level swept.
+//
+// By Little's law, concurrency = throughput × latency. While a pattern
+// has spare capacity, more clients mean proportionally more throughput at
+// the same latency. Past the knee throughput is flat, so each extra client
+// only adds to latency. A step counts as past the knee when throughput
+// grows by less than kneeEfficiency of the relative concurrency increase
+// (or falls) and mean latency rises.
+func findKnee(cells []sweepCell) (knee int, saturated bool) {
+	if len(cells) == 0 {
+		return 0, false
+	}
+
+	for i := 1; i < len(cells); i++ {
+		prev, cur := cells[i-1], cells[i]
+		if prev.RequestsPerSecond <= 0 {
+			continue
+		}
+		concurrencyGrowth := float64(cur.Concurrency-prev.Concurrency) / float64(prev.Concurrency)
+		throughputGrowth := (cur.RequestsPerSecond - prev.RequestsPerSecond) / prev.RequestsPerSecond
+		if throughputGrowth < kneeEfficiency*concurrencyGrowth && cur.MeanLatencyMs > prev.MeanLatencyMs {
+			return prev.Concurrency, true
+		}
+	}
+	return cells[len(cells)-1].Concurrency, false
+}
+
+// sweepJSON is the -json output of a sweep.
+type sweepJSON struct {
+	SchemaVersion     int        `json:"schema_version"`
+	ConcurrencyLevels []int      `json:"concurrency_levels"`
+	Patterns          []sweepRow `json:"patterns"`
+}
+
+// runSweep runs every selected pattern at every concurrency level, each
+// level runs times, and returns the matrix of results. Progress is
+// written to progress.
+func runSweep(selected []runner.Pattern, config runner.Config, db *simulator.Database, levels []int, runs int, progress io.Writer) []sweepRow {
+	rows := make([]sweepRow, len(selected))
+	for i, p := range selected {
+		rows[i].Pattern = p.Name
+		for _, level := range levels {
+			fmt.Fprintf(progress, "\n=== Testing %s at concurrency %d ===\n", p.Name, level)
+			levelConfig := config
+			levelConfig.Concurrency = level
+
+			var result runner.Result
+			if runs > 1 {
+				result = runner.Repeat(p, levelConfig, db, runs)
+			} else {
+				result = runner.Run(p, levelConfig, db)
+			}
+			fmt.Fprintf(progress, "Completed: %d requests in %.2fs (%.2f req/s)\n",
+				result.TotalRequests, result.Duration, result.RequestsPerSec)
+
+			rows[i].Cells = append(rows[i].Cells, sweepCell{
+				Concurrency:       level,
+				RequestsPerSecond: result.RequestsPerSec,
+				MeanLatencyMs:     result.MeanLatency,
+				P99LatencyMs:      result.P99Latency,
+				ErrorRatePercent:  result.ErrorRate,
+				RejectionPercent:  result.RejectionRate,
+			})
+		}
+		rows[i].RecommendedConcurrency, rows[i].Saturated = findKnee(rows[i].Cells)
+	}
+	return rows
+}
+
+// printSweepTable prints the sweep as two matrices, throughput and P99
+// latency, with a row per pattern and a column per concurrency level.
+func printSweepTable(w io.Writer, rows []sweepRow, levels []int) {
+	fmt.Fprintln(w, "\n╔══════════════════════════════════════════════════════════════╗")
+	fmt.Fprintln(w, "║                    CONCURRENCY SWEEP                         ║")
+	fmt.Fprintln(w, "╚══════════════════════════════════════════════════════════════╝")
+
+	for _, metric := range []struct {
+		title string
+		value func(sweepCell) float64
+	}{
+		{"Throughput (req/s)", func(c sweepCell) float64 { return c.RequestsPerSecond }},
+		{"P99 latency (ms)", func(c sweepCell) float64 { return c.P99LatencyMs }},
+	} {
+		fmt.Fprintf(w, "\n%s by concurrency:\n", metric.title)
+		fmt.Fprintf(w, "  %-19s", "Pattern")
+		for _, level := range levels {
+			fmt.Fprintf(w, " %10d", level)
+		}
+		fmt.Fprintln(w)
+		for _, row := range rows {
+			fmt.Fprintf(w, "  %-19s", row.Pattern)
+			for _, cell := range row.Cells {
+				fmt.Fprintf(w, " %10.2f", metric.value(cell))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	fmt.Fprintln(w, "\nRecommended max concurrency (knee point):")
+	for _, row := range rows {
+		if row.Saturated {
+			fmt.Fprintf(w, "  %-19s %d\n", row.Pattern, row.RecommendedConcurrency)
+		} else {
+			fmt.Fprintf(w, "  %-19s at least %d (still scaling; sweep higher to find the knee)\n", row.Pattern, row.RecommendedConcurrency)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// writeSweepJSON writes the sweep to w as a sweepJSON document.
+func writeSweepJSON(w io.Writer, rows []sweepRow, levels []int) error {
+	data, err := json.MarshalIndent(sweepJSON{
+		SchemaVersion:     resultsSchemaVersion,
+		ConcurrencyLevels: levels,
+		Patterns:          rows,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
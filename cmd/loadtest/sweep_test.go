@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/runner"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+func TestParseConcurrencySweep(t *testing.T) {
+	levels, err := parseConcurrencySweep("50, 10,250")
+	if err != nil {
+		t.Fatalf("parseConcurrencySweep: %v", err)
+	}
+	if fmt.Sprint(levels) != "[10 50 250]" {
+		t.Errorf("levels = %v, want [10 50 250]", levels)
+	}
+
+	for _, bad := range []string{"", "10,,20", "0", "-5", "ten", "10,10"} {
+		if _, err := parseConcurrencySweep(bad); err == nil {
+			t.Errorf("parseConcurrencySweep(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+// TestSweepMatrix sweeps two patterns over three levels and checks the
+// matrix has a row per pattern and a column per level, in both forms.
+func TestSweepMatrix(t *testing.T) {
+	config := runner.Config{TotalRequests: 60, Workers: 5, QueueSize: 100, EnqueueTimeout: time.Second}
+	selected, err := config.Select("workerpool", "semaphore")
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	db := simulator.NewDatabase(0, 0, 0)
+	db.SetLatencySource(simulator.NewTraceLatencySource([]time.Duration{time.Millisecond}))
+	levels := []int{1, 5, 20}
+
+	rows := runSweep(selected, config, db, levels, 1, io.Discard)
+
+	if len(rows) != len(selected) {
+		t.Fatalf("got %d rows, want one per pattern (%d)", len(rows), len(selected))
+	}
+	for i, row := range rows {
+		if row.Pattern != selected[i].Name {
+			t.Errorf("row %d is %q, want %q", i, row.Pattern, selected[i].Name)
+		}
+		if len(row.Cells) != len(levels) {
+			t.Fatalf("%s: got %d columns, want one per level (%d)", row.Pattern, len(row.Cells), len(levels))
+		}
+		for j, cell := range row.Cells {
+			if cell.Concurrency != levels[j] || cell.RequestsPerSecond <= 0 {
+				t.Errorf("%s column %d = %+v, want concurrency %d with a measured throughput", row.Pattern, j, cell, levels[j])
+			}
+		}
+	}
+
+	var table bytes.Buffer
+	printSweepTable(&table, rows, levels)
+	for _, row := range rows {
+		if n := strings.Count(table.String(), row.Pattern); n != 3 {
+			t.Errorf("table lists %s %d times, want once per matrix and once with its knee (3)", row.Pattern, n)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeSweepJSON(&out, rows, levels); err != nil {
+		t.Fatalf("writeSweepJSON: %v", err)
+	}
+	var doc sweepJSON
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("decoding sweep JSON: %v", err)
+	}
+	if doc.SchemaVersion != resultsSchemaVersion || len(doc.ConcurrencyLevels) != len(levels) || len(doc.Patterns) != len(rows) {
+		t.Errorf("sweep JSON = %+v, want version %d with %d levels and %d patterns", doc, resultsSchemaVersion, len(levels), len(rows))
+	}
+	for _, row := range doc.Patterns {
+		if len(row.Cells) != len(levels) {
+			t.Errorf("JSON %s: %d results, want %d", row.Pattern, len(row.Cells), len(levels))
+		}
+	}
+}
+
+// sweepCells builds cells from parallel lists of levels, throughputs and
+// mean latencies.
+func sweepCells(levels []int, throughput, meanMs []float64) []sweepCell {
+	cells := make([]sweepCell, len(levels))
+	for i, level := range levels {
+		cells[i] = sweepCell{Concurrency: level, RequestsPerSecond: throughput[i], MeanLatencyMs: meanMs[i]}
+	}
+	return cells
+}
+
+func TestFindKnee(t *testing.T) {
+	levels := []int{10, 50, 100, 250, 500}
+	tests := []struct {
+		name          string
+		throughput    []float64
+		meanMs        []float64
+		wantKnee      int
+		wantSaturated bool
+	}{
+		{
+			// 100 workers at 100ms: throughput tracks concurrency up to
+			// 100 clients, then stays flat while latency grows by
+			// Little's law
+			name:          "monotone then flat",
+			throughput:    []float64{100, 500, 1000, 1010, 1005},
+			meanMs:        []float64{100, 100, 100, 247, 497},
+			wantKnee:      100,
+			wantSaturated: true,
+		},
+		{
+			name:          "collapse",
+			throughput:    []float64{100, 500, 550, 300, 150},
+			meanMs:        []float64{100, 100, 181, 833, 3333},
+			wantKnee:      50,
+			wantSaturated: true,
+		},
+		{
+			name:          "still scaling",
+			throughput:    []float64{100, 500, 1000, 2500, 5000},
+			meanMs:        []float64{100, 100, 100, 100, 100},
+			wantKnee:      500,
+			wantSaturated: false,
+		},
+		{
+			// Throughput flat but latency falling is noise, not saturation
+			name:          "flat throughput, falling latency",
+			throughput:    []float64{100, 500, 1000, 1000, 2000},
+			meanMs:        []float64{100, 100, 100, 90, 100},
+			wantKnee:      500,
+			wantSaturated: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			knee, saturated := findKnee(sweepCells(levels, tt.throughput, tt.meanMs))
+			if knee != tt.wantKnee || saturated != tt.wantSaturated {
+				t.Errorf("findKnee = %d (saturated %v), want %d (saturated %v)", knee, saturated, tt.wantKnee, tt.wantSaturated)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// drainLogInterval is how often shutdown logs the requests still in flight.
+const drainLogInterval = time.Second
+
+// inFlightRequests counts the patient API requests being served, so that
+// shutdown can report how many it is still waiting for. Unlike the
+// collector's in-flight gauge it leaves out gRPC calls, which
+// server.Shutdown doesn't wait for.
+type inFlightRequests struct {
+	count atomic.Int64
+}
+
+// middleware counts requests to /api/v1/patients, and the endpoints under
+// it, while next serves them.
+func (f *inFlightRequests) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/v1/patients") {
+			f.count.Add(1)
+			defer f.count.Add(-1)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// drainServer shuts server down, logging every interval how many patient
+// API requests it is still waiting for. If ctx ends first, it logs how
+// many were cut off and returns the error from server.Shutdown.
+func drainServer(ctx context.Context, server *http.Server, inFlight *inFlightRequests, interval time.Duration, logf func(format string, args ...interface{})) error {
+	done := make(chan error, 1)
+	go func() { done <- server.Shutdown(ctx) }()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				logf("Shutdown timed out: dropping %d in-flight requests", inFlight.count.Load())
+				return err
+			}
+			logf("All in-flight requests drained")
+			return nil
+		case <-ticker.C:
+			logf("Draining: %d requests in flight", inFlight.count.Load())
+		}
+	}
+}
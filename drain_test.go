@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// drainLog records drainServer's log lines.
+type drainLog struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *drainLog) logf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *drainLog) contains(line string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, got := range l.lines {
+		if got == line {
+			return true
+		}
+	}
+	return false
+}
+
+// startSlowServer serves a patient API that holds each request for
+// latency, and returns the server once one request is in flight.
+func startSlowServer(t *testing.T, latency time.Duration) (*http.Server, *inFlightRequests) {
+	t.Helper()
+
+	inFlight := &inFlightRequests{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/patients", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(latency)
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {})
+	server := &http.Server{Handler: inFlight.middleware(mux)}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go server.Serve(ln)
+
+	base := "http://" + ln.Addr().String()
+	if _, err := http.Get(base + "/health"); err != nil {
+		t.Fatalf("GET /health: %v", err)
+	}
+	go http.Get(base + "/api/v1/patients?id=P00001")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for inFlight.count.Load() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("in-flight count = %d, want 1 (only the patient request)", inFlight.count.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return server, inFlight
+}
+
+func TestDrainServerWaitsForSlowRequest(t *testing.T) {
+	server, inFlight := startSlowServer(t, 150*time.Millisecond)
+
+	var log drainLog
+	if err := drainServer(context.Background(), server, inFlight, 20*time.Millisecond, log.logf); err != nil {
+		t.Fatalf("drainServer: %v", err)
+	}
+
+	for _, line := range []string{"Draining: 1 requests in flight", "All in-flight requests drained"} {
+		if !log.contains(line) {
+			t.Errorf("log missing %q; got %q", line, strings.Join(log.lines, " | "))
+		}
+	}
+}
+
+func TestDrainServerReportsDroppedRequests(t *testing.T) {
+	server, inFlight := startSlowServer(t, time.Second)
+	defer server.Close()
+
+	var log drainLog
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := drainServer(ctx, server, inFlight, 20*time.Millisecond, log.logf); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("drainServer: got %v, want context.DeadlineExceeded", err)
+	}
+
+	for _, line := range []string{"Draining: 1 requests in flight", "Shutdown timed out: dropping 1 in-flight requests"} {
+		if !log.contains(line) {
+			t.Errorf("log missing %q; got %q", line, strings.Join(log.lines, " | "))
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patientpb"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcRequestIDKey is the metadata key carrying a call's correlation ID,
+// the gRPC counterpart of the X-Request-ID header.
+const grpcRequestIDKey = "x-request-id"
+
+// patientReader is the part of a pattern handler the gRPC service needs.
+type patientReader interface {
+	HandleRequest(ctx context.Context, patientID string) (*models.PatientResponse, error)
+}
+
+// patientService implements patientpb.PatientServiceServer on top of a
+// pattern handler, so gRPC calls go through the same concurrency pattern,
+// database and metrics as HTTP requests.
+type patientService struct {
+	patientpb.UnimplementedPatientServiceServer
+	handler   patientReader
+	collector *metrics.Collector
+}
+
+// GetPatient looks up a single patient through the pattern handler.
+// Like metricsMiddleware, it records every call it serves: Unavailable is
+// the pattern shedding load and counts as a rejection, and any other
+// failure is recorded by category.
+func (s *patientService) GetPatient(ctx context.Context, req *patientpb.PatientRequest) (*patientpb.PatientResponse, error) {
+	s.collector.IncInFlight()
+	defer s.collector.DecInFlight()
+	start := time.Now()
+
+	if req.GetPatientId() == "" {
+		s.collector.RecordRequest(time.Since(start), false)
+		return nil, status.Error(codes.InvalidArgument, "patient ID required")
+	}
+
+	response, err := s.handler.HandleRequest(ctx, req.GetPatientId())
+	if err != nil {
+		code := grpcCode(err)
+		if code == codes.Unavailable {
+			s.collector.RecordRejection()
+		} else {
+			s.collector.RecordRequestWithError(time.Since(start), err)
+		}
+		return nil, status.Error(code, err.Error())
+	}
+	s.collector.RecordRequestWithError(time.Since(start), nil)
+
+	return &patientpb.PatientResponse{
+		Patient:   response.Patient.ToProto(),
+		Timestamp: timestamppb.New(response.Timestamp),
+		RequestId: patterns.RequestIDFromContext(ctx),
+		Success:   true,
+	}, nil
+}
+
+// grpcCode maps a pattern or simulator error to a gRPC status code, the
+// gRPC counterpart of the HTTP status mapping in the patterns package.
+func grpcCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, simulator.ErrPatientNotFound):
+		return codes.NotFound
+	case errors.Is(err, patterns.ErrInvalidRequest):
+		return codes.InvalidArgument
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, simulator.ErrConnectionTimeout),
+		errors.Is(err, simulator.ErrQueryCancelled),
+		errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, simulator.ErrPoolExhausted),
+		errors.Is(err, simulator.ErrCircuitOpen),
+		errors.Is(err, patterns.ErrQueueFull),
+		errors.Is(err, patterns.ErrJobDropped),
+		errors.Is(err, patterns.ErrGoroutineLimit),
+		errors.Is(err, patterns.ErrSemaphoreFull),
+		errors.Is(err, patterns.ErrLoadShed),
+		errors.Is(err, patterns.ErrShuttingDown):
+		return codes.Unavailable
+	case errors.Is(err, simulator.ErrLockTimeout):
+		return codes.Aborted
+	default:
+		return codes.Internal
+	}
+}
+
+// newGRPCServer returns a gRPC server exposing PatientService backed by
+// handler. Calls get the same request IDs, API key checks and metrics as
+// /api/v1/patients, and TLS when -tls-cert and -tls-key are set.
+func newGRPCServer(config Config, handler patientReader, c *metrics.Collector) (*grpc.Server, error) {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			requestIDInterceptor,
+			authInterceptor(config.APIKeys),
+		),
+	}
+
+	if config.tlsEnabled() {
+		minVersion, err := parseTLSVersion(config.TLSMinVersion)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := tls.LoadX509KeyPair(config.TLSCert, config.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   minVersion,
+		})))
+	}
+
+	server := grpc.NewServer(opts...)
+	patientpb.RegisterPatientServiceServer(server, &patientService{handler: handler, collector: c})
+	return server, nil
+}
+
+// requestIDInterceptor is requestIDMiddleware for gRPC: it takes the ID
+// from x-request-id metadata or generates one, stores it in the context
+// and returns it in the response header.
+func requestIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(grpcRequestIDKey); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		id = newRequestID()
+	}
+
+	grpc.SetHeader(ctx, metadata.Pairs(grpcRequestIDKey, id))
+	return next(patterns.WithRequestID(ctx, id), req)
+}
+
+// authInterceptor is apiKeyMiddleware for gRPC, reading the bearer token
+// from authorization metadata. With no keys configured it allows all calls.
+func authInterceptor(keys []string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+		if len(keys) == 0 {
+			return next(ctx, req)
+		}
+
+		var token string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("authorization"); len(values) > 0 {
+				token, _ = strings.CutPrefix(values[0], "Bearer ")
+			}
+		}
+		if !validAPIKey(keys, token) {
+			return nil, status.Error(codes.Unauthenticated, "missing or invalid API key")
+		}
+
+		return next(ctx, req)
+	}
+}
+
+// stopGRPC lets in-flight calls finish, forcing the server closed if ctx
+// ends first.
+func stopGRPC(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		server.Stop()
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patientpb"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// readerFunc adapts a function to patientReader.
+type readerFunc func(ctx context.Context, patientID string) (*models.PatientResponse, error)
+
+func (f readerFunc) HandleRequest(ctx context.Context, patientID string) (*models.PatientResponse, error) {
+	return f(ctx, patientID)
+}
+
+// newGRPCClient serves handler over an in-memory connection and returns a
+// client for it.
+func newGRPCClient(t *testing.T, config Config, handler patientReader, c *metrics.Collector) patientpb.PatientServiceClient {
+	t.Helper()
+
+	server, err := newGRPCServer(config, handler, c)
+	if err != nil {
+		t.Fatalf("newGRPCServer: %v", err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return patientpb.NewPatientServiceClient(conn)
+}
+
+func TestGRPCGetPatient(t *testing.T) {
+	db := simulator.NewDatabase(1, 2, 0)
+	handler := patterns.NewWorkerPoolHandler(db, patterns.DefaultWorkerPoolConfig())
+	defer handler.Shutdown(context.Background())
+
+	c := metrics.NewCollector()
+	client := newGRPCClient(t, validConfig(), handler, c)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), grpcRequestIDKey, "req-42")
+	var header metadata.MD
+	resp, err := client.GetPatient(ctx, &patientpb.PatientRequest{PatientId: "P00001"}, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("GetPatient: %v", err)
+	}
+
+	got := resp.GetPatient()
+	if got.GetId() != "P00001" || got.GetLastName() == "" || got.GetDateOfBirth().AsTime().IsZero() || len(got.GetDiagnosisCodes()) == 0 {
+		t.Errorf("patient = %v, want a full record for P00001", got)
+	}
+	if resp.GetRequestId() != "req-42" {
+		t.Errorf("request ID = %q, want req-42", resp.GetRequestId())
+	}
+	if ids := header.Get(grpcRequestIDKey); len(ids) != 1 || ids[0] != "req-42" {
+		t.Errorf("x-request-id header = %v, want [req-42]", ids)
+	}
+
+	// Calls share the collector with the HTTP API
+	if stats := c.GetStats(); stats.TotalRequests != 1 || stats.SuccessRequests != 1 {
+		t.Errorf("total = %d, success = %d; want 1 successful request", stats.TotalRequests, stats.SuccessRequests)
+	}
+}
+
+func TestGRPCErrorCodes(t *testing.T) {
+	tests := []struct {
+		err      error
+		want     codes.Code
+		rejected bool
+	}{
+		{fmt.Errorf("%w: no such patient", simulator.ErrPatientNotFound), codes.NotFound, false},
+		{fmt.Errorf("%w: patient ID required", patterns.ErrInvalidRequest), codes.InvalidArgument, false},
+		{fmt.Errorf("database error: %w", simulator.ErrConnectionTimeout), codes.DeadlineExceeded, false},
+		{fmt.Errorf("%w: %w", simulator.ErrQueryCancelled, context.DeadlineExceeded), codes.DeadlineExceeded, false},
+		{simulator.ErrPoolExhausted, codes.Unavailable, true},
+		{patterns.ErrQueueFull, codes.Unavailable, true},
+		{patterns.ErrJobDropped, codes.Unavailable, true},
+		{patterns.ErrGoroutineLimit, codes.Unavailable, true},
+		{patterns.ErrSemaphoreFull, codes.Unavailable, true},
+		{patterns.ErrLoadShed, codes.Unavailable, true},
+		{patterns.ErrShuttingDown, codes.Unavailable, true},
+		{simulator.ErrLockTimeout, codes.Aborted, false},
+		{errors.New("boom"), codes.Internal, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			c := metrics.NewCollector()
+			failing := readerFunc(func(context.Context, string) (*models.PatientResponse, error) {
+				return nil, tc.err
+			})
+			client := newGRPCClient(t, validConfig(), failing, c)
+
+			_, err := client.GetPatient(context.Background(), &patientpb.PatientRequest{PatientId: "P00001"})
+			if got := status.Code(err); got != tc.want {
+				t.Errorf("code = %v, want %v (err %v)", got, tc.want, err)
+			}
+
+			stats := c.GetStats()
+			if tc.rejected && stats.RejectedRequests != 1 {
+				t.Errorf("rejected = %d, want 1", stats.RejectedRequests)
+			}
+			if !tc.rejected && stats.ErrorRequests != 1 {
+				t.Errorf("errors = %d, want 1", stats.ErrorRequests)
+			}
+		})
+	}
+}
+
+func TestGRPCDeadlineExceeded(t *testing.T) {
+	db := simulator.NewDatabase(200, 200, 0)
+	handler := patterns.NewSemaphoreHandler(db, patterns.DefaultSemaphoreConfig())
+	defer handler.Shutdown(context.Background())
+
+	client := newGRPCClient(t, validConfig(), handler, metrics.NewCollector())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := client.GetPatient(ctx, &patientpb.PatientRequest{PatientId: "P00001"})
+	if got := status.Code(err); got != codes.DeadlineExceeded {
+		t.Errorf("code = %v, want DeadlineExceeded (err %v)", got, err)
+	}
+}
+
+func TestGRPCRejectsMissingPatientID(t *testing.T) {
+	client := newGRPCClient(t, validConfig(), readerFunc(func(context.Context, string) (*models.PatientResponse, error) {
+		t.Error("handler called without a patient ID")
+		return nil, nil
+	}), metrics.NewCollector())
+
+	_, err := client.GetPatient(context.Background(), &patientpb.PatientRequest{})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Errorf("code = %v, want InvalidArgument", got)
+	}
+}
+
+func TestGRPCAPIKeyAuth(t *testing.T) {
+	config := validConfig()
+	config.APIKeys = []string{"key-one"}
+	ok := readerFunc(func(context.Context, string) (*models.PatientResponse, error) {
+		return models.NewPatientResponse(models.GeneratePatient("P00001"), ""), nil
+	})
+	client := newGRPCClient(t, config, ok, metrics.NewCollector())
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          codes.Code
+	}{
+		{"valid key", "Bearer key-one", codes.OK},
+		{"invalid key", "Bearer key-two", codes.Unauthenticated},
+		{"wrong scheme", "Basic key-one", codes.Unauthenticated},
+		{"missing", "", codes.Unauthenticated},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.authorization != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tc.authorization)
+			}
+			_, err := client.GetPatient(ctx, &patientpb.PatientRequest{PatientId: "P00001"})
+			if got := status.Code(err); got != tc.want {
+				t.Errorf("code = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
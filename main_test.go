@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func validConfig() Config {
+	return Config{
+		Pattern:        "workerpool",
+		Port:           defaultPort,
+		Workers:        defaultWorkers,
+		QueueSize:      defaultQueueSize,
+		MinLatency:     defaultMinLatency,
+		MaxLatency:     defaultMaxLatency,
+		ErrorRate:      defaultErrorRate,
+		EnqueueTimeout: defaultEnqueueTimeout,
+		RejectStatus:   http.StatusServiceUnavailable,
+		MaxBodyBytes:   defaultMaxBodyBytes,
+		LogFormat:      "text",
+		TLSMinVersion:  "1.2",
+		Store:          storeSimulator,
+	}
+}
+
+// TestValidateConfigRejectsNonPositive sets each numeric flag to zero and to
+// a negative value and checks for a validation error naming that flag.
+func TestValidateConfigRejectsNonPositive(t *testing.T) {
+	if err := validateConfig(validConfig()); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+
+	tests := []struct {
+		flag string
+		set  func(c *Config, v int)
+	}{
+		{"port", func(c *Config, v int) { c.Port = v }},
+		{"workers", func(c *Config, v int) { c.Workers = v }},
+		{"queue-size", func(c *Config, v int) { c.QueueSize = v }},
+		{"enqueue-timeout", func(c *Config, v int) { c.EnqueueTimeout = time.Duration(v) }},
+		{"max-body-bytes", func(c *Config, v int) { c.MaxBodyBytes = int64(v) }},
+	}
+
+	for _, tc := range tests {
+		for _, v := range []int{0, -1} {
+			config := validConfig()
+			tc.set(&config, v)
+
+			err := validateConfig(config)
+			if err == nil {
+				t.Errorf("-%s=%d: expected a validation error", tc.flag, v)
+				continue
+			}
+			if !strings.Contains(err.Error(), "-"+tc.flag+" ") {
+				t.Errorf("-%s=%d: error %q does not name the flag", tc.flag, v, err)
+			}
+		}
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	c := metrics.NewCollector()
+	c.RecordRequestWithError(20*time.Millisecond, nil)
+	c.RecordRequestWithError(80*time.Millisecond, nil)
+	c.RecordRequestWithError(30*time.Second, simulator.ErrConnectionTimeout)
+
+	server := httptest.NewServer(newMetricsHandler(c, "workerpool"))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	// labels flattens a metric's label pairs for easy comparison.
+	labels := func(m *dto.Metric) map[string]string {
+		out := make(map[string]string)
+		for _, pair := range m.GetLabel() {
+			out[pair.GetName()] = pair.GetValue()
+		}
+		return out
+	}
+
+	// single returns the only metric in a family, checking its type.
+	single := func(name string, typ dto.MetricType) *dto.Metric {
+		t.Helper()
+		family, ok := families[name]
+		if !ok {
+			t.Fatalf("metric family %s missing", name)
+		}
+		if family.GetType() != typ {
+			t.Errorf("%s type = %v, want %v", name, family.GetType(), typ)
+		}
+		if len(family.GetMetric()) != 1 {
+			t.Fatalf("%s has %d series, want 1", name, len(family.GetMetric()))
+		}
+		return family.GetMetric()[0]
+	}
+
+	requests := single("healthcare_api_requests_total", dto.MetricType_COUNTER)
+	if got := labels(requests)["pattern"]; got != "workerpool" {
+		t.Errorf("requests_total pattern label = %q, want workerpool", got)
+	}
+	if got := requests.GetCounter().GetValue(); got != 3 {
+		t.Errorf("requests_total = %v, want 3", got)
+	}
+
+	errorsTotal := single("healthcare_api_errors_total", dto.MetricType_COUNTER)
+	if got := labels(errorsTotal); got["pattern"] != "workerpool" || got["category"] != simulator.CategoryTimeout {
+		t.Errorf("errors_total labels = %v, want pattern=workerpool category=timeout", got)
+	}
+
+	latency := single("healthcare_api_latency_seconds", dto.MetricType_HISTOGRAM)
+	if got := labels(latency)["pattern"]; got != "workerpool" {
+		t.Errorf("latency_seconds pattern label = %q, want workerpool", got)
+	}
+	histogram := latency.GetHistogram()
+	if histogram.GetSampleCount() != 3 {
+		t.Errorf("latency_seconds count = %d, want 3", histogram.GetSampleCount())
+	}
+	buckets := histogram.GetBucket() // finite bounds, then +Inf
+	if len(buckets) != len(metrics.DefaultLatencyBuckets)+1 {
+		t.Fatalf("latency_seconds has %d buckets, want %d", len(buckets), len(metrics.DefaultLatencyBuckets)+1)
+	}
+	// The 30s outlier lies beyond the largest finite bucket
+	if got := buckets[len(buckets)-2].GetCumulativeCount(); got != 2 {
+		t.Errorf("largest finite bucket = %d, want 2", got)
+	}
+
+	if _, ok := families["go_goroutines"]; !ok {
+		t.Error("Go runtime metrics missing")
+	}
+}
+
+func TestMetricsEndpointJSON(t *testing.T) {
+	c := metrics.NewCollector()
+	c.RecordRequest(10*time.Millisecond, true)
+
+	rec := httptest.NewRecorder()
+	newMetricsHandler(c, "naive").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics?format=json", nil))
+
+	var stats metrics.Stats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if stats.TotalRequests != 1 {
+		t.Errorf("total_requests = %d, want 1", stats.TotalRequests)
+	}
+}
+
+func TestMetricsEndpointQuick(t *testing.T) {
+	c := metrics.NewCollector()
+	c.RecordRequest(10*time.Millisecond, true)
+	c.RecordRequest(30*time.Millisecond, false)
+
+	rec := httptest.NewRecorder()
+	newMetricsHandler(c, "naive").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics?format=quick", nil))
+
+	var stats metrics.Stats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if stats.TotalRequests != 2 || stats.ErrorRequests != 1 {
+		t.Errorf("total/errors = %d/%d, want 2/1", stats.TotalRequests, stats.ErrorRequests)
+	}
+	if stats.MinLatency != 10 || stats.MeanLatency != 20 || stats.MaxLatency != 30 {
+		t.Errorf("min/mean/max = %v/%v/%v, want 10/20/30", stats.MinLatency, stats.MeanLatency, stats.MaxLatency)
+	}
+}
+
+func TestPprofRegisteredOnlyWhenEnabled(t *testing.T) {
+	db := simulator.NewDatabase(1, 2, 0)
+	handler := http.NotFoundHandler()
+
+	routeFor := func(h http.Handler, path string) (pattern string, status int) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if mux, ok := h.(*http.ServeMux); ok {
+			_, pattern = mux.Handler(req)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return pattern, rec.Code
+	}
+
+	off := validConfig()
+	if pattern, _ := routeFor(newServeMux(off, handler, db, metrics.NewCollector(), nil), "/debug/pprof/"); pattern != "/" {
+		t.Errorf("pprof disabled: /debug/pprof/ routed to %q, want the info handler", pattern)
+	}
+
+	on := validConfig()
+	on.Pprof = true
+	mux := newServeMux(on, handler, db, metrics.NewCollector(), nil)
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/pprof/heap?debug=1"} {
+		if pattern, status := routeFor(mux, path); pattern != "/debug/pprof/" || status != http.StatusOK {
+			t.Errorf("pprof enabled: %s routed to %q with status %d", path, pattern, status)
+		}
+	}
+	// Only route the CPU profile; serving it would block for 30s
+	if _, pattern := mux.Handler(httptest.NewRequest(http.MethodGet, "/debug/pprof/profile", nil)); pattern != "/debug/pprof/profile" {
+		t.Errorf("pprof enabled: CPU profile routed to %q", pattern)
+	}
+
+	// With an admin port, the API mux stays clean and the admin server serves pprof
+	separate := on
+	separate.PprofPort = 6060
+	if pattern, _ := routeFor(newServeMux(separate, handler, db, metrics.NewCollector(), nil), "/debug/pprof/"); pattern != "/" {
+		t.Errorf("pprof on admin port: API mux routes /debug/pprof/ to %q", pattern)
+	}
+	if _, status := routeFor(newPprofServer(6060).Handler, "/debug/pprof/goroutine?debug=1"); status != http.StatusOK {
+		t.Errorf("admin server: goroutine profile status %d, want 200", status)
+	}
+}
+
+func TestValidateConfigLogFormat(t *testing.T) {
+	for _, format := range []string{"json", "text", "off"} {
+		config := validConfig()
+		config.LogFormat = format
+		if err := validateConfig(config); err != nil {
+			t.Errorf("-log-format=%s rejected: %v", format, err)
+		}
+	}
+
+	config := validConfig()
+	config.LogFormat = "xml"
+	if err := validateConfig(config); err == nil || !strings.Contains(err.Error(), "-log-format") {
+		t.Errorf("-log-format=xml: got %v, want an error naming the flag", err)
+	}
+}
+
+func TestValidateConfigStore(t *testing.T) {
+	config := validConfig()
+	config.Store = storePostgres
+	if err := validateConfig(config); err == nil || !strings.Contains(err.Error(), "-dsn") {
+		t.Errorf("-store=postgres without -dsn: got %v, want an error naming -dsn", err)
+	}
+
+	config.DSN = "postgres://localhost/healthcare"
+	if err := validateConfig(config); err != nil {
+		t.Errorf("-store=postgres with -dsn rejected: %v", err)
+	}
+
+	config.Store = "mysql"
+	if err := validateConfig(config); err == nil || !strings.Contains(err.Error(), "-store") {
+		t.Errorf("-store=mysql: got %v, want an error naming the flag", err)
+	}
+}
+
+func TestSizeForWorkload(t *testing.T) {
+	config := validConfig()
+	config.Workload = patterns.WorkloadIO
+	if got := sizeForWorkload(config, 8, nil); got.Workers != 32 || got.QueueSize != 160 {
+		t.Errorf("-workload=io on 8 CPUs: %d workers, queue %d; want 32, 160", got.Workers, got.QueueSize)
+	}
+
+	config.Workload = patterns.WorkloadCPU
+	config.Workers = 3
+	if got := sizeForWorkload(config, 8, map[string]bool{"workers": true}); got.Workers != 3 || got.QueueSize != 40 {
+		t.Errorf("-workload=cpu -workers=3 on 8 CPUs: %d workers, queue %d; want the explicit 3 and a sized 40", got.Workers, got.QueueSize)
+	}
+
+	config.Workload = "gpu"
+	if got := sizeForWorkload(config, 8, nil); got.Workers != config.Workers || got.QueueSize != config.QueueSize {
+		t.Errorf("unknown workload resized the pool to %d workers, queue %d", got.Workers, got.QueueSize)
+	}
+	if err := validateConfig(config); err == nil || !strings.Contains(err.Error(), "-workload") {
+		t.Errorf("-workload=gpu: got %v, want an error naming the flag", err)
+	}
+}
+
+func TestValidateConfigRequestTimeout(t *testing.T) {
+	for _, timeout := range []time.Duration{-time.Second, writeTimeout} {
+		config := validConfig()
+		config.RequestTimeout = timeout
+		if err := validateConfig(config); err == nil || !strings.Contains(err.Error(), "-request-timeout") {
+			t.Errorf("-request-timeout=%v: got %v, want an error naming the flag", timeout, err)
+		}
+	}
+
+	config := validConfig()
+	config.RequestTimeout = 2 * time.Second
+	if err := validateConfig(config); err != nil {
+		t.Errorf("-request-timeout=2s rejected: %v", err)
+	}
+}
+
+func TestValidateConfigTLSMinVersion(t *testing.T) {
+	config := validConfig()
+	config.TLSMinVersion = "1.4"
+	if err := validateConfig(config); err == nil || !strings.Contains(err.Error(), "-tls-min-version") {
+		t.Errorf("-tls-min-version=1.4: got %v, want an error naming the flag", err)
+	}
+}
+
+func TestValidateConfigRejectStatus(t *testing.T) {
+	config := validConfig()
+	config.RejectStatus = http.StatusTooManyRequests
+	if err := validateConfig(config); err != nil {
+		t.Errorf("-reject-status=429 rejected: %v", err)
+	}
+
+	config.RejectStatus = http.StatusBadGateway
+	if err := validateConfig(config); err == nil || !strings.Contains(err.Error(), "-reject-status") {
+		t.Errorf("-reject-status=502: got %v, want an error naming the flag", err)
+	}
+}
+
+func TestLivezAndReadyz(t *testing.T) {
+	db := simulator.NewDatabase(1, 2, 0, simulator.WithMaxConnections(1), simulator.WithWaitOnExhaustion(false))
+	db.SetLatencySource(simulator.NewTraceLatencySource([]time.Duration{200 * time.Millisecond}))
+	mux := newServeMux(validConfig(), http.NotFoundHandler(), db, metrics.NewCollector(), nil)
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	// Hold the only connection with a slow query
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		db.QueryPatient(context.Background(), "P00001")
+	}()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		if inUse, _, _ := db.GetPoolStats(); inUse == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("query never checked out a connection")
+		}
+	}
+
+	if status := get("/livez"); status != http.StatusOK {
+		t.Errorf("/livez with the database saturated = %d, want 200", status)
+	}
+	if status := get("/readyz"); status != http.StatusServiceUnavailable {
+		t.Errorf("/readyz with the database saturated = %d, want 503", status)
+	}
+
+	<-done
+	for i := 0; i < 10; i++ {
+		if status := get("/readyz"); status != http.StatusOK {
+			t.Fatalf("/readyz = %d, want 200", status)
+		}
+		if status := get("/livez"); status != http.StatusOK {
+			t.Fatalf("/livez = %d, want 200", status)
+		}
+	}
+
+	// Only the slow query counts; none of the probes do
+	if queries, errors := db.GetStats(); queries != 1 || errors != 0 {
+		t.Errorf("GetStats() = (%d, %d) after probes, want (1, 0)", queries, errors)
+	}
+}
+
+// TestHealthReportsStuckWorkers checks that /health includes worker health
+// for the worker pool patterns, turns "degraded" while a worker is stuck,
+// and leaves the workers out for patterns that have none.
+func TestHealthReportsStuckWorkers(t *testing.T) {
+	db := simulator.NewDatabase(1, 2, 0)
+	db.SetLatencySource(simulator.NewTraceLatencySource([]time.Duration{300 * time.Millisecond}))
+	pool := patterns.NewWorkerPoolHandler(db, patterns.WorkerPoolConfig{Workers: 2, QueueSize: 10, StuckThreshold: 20 * time.Millisecond})
+	defer pool.Shutdown(context.Background())
+
+	health := func(handler http.Handler) map[string]interface{} {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		healthCheckHandler(db, handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("/health = %d, want 200", rec.Code)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding /health: %v", err)
+		}
+		return body
+	}
+
+	body := health(pool)
+	workers, ok := body["workers"].(map[string]interface{})
+	if !ok || body["status"] != "healthy" || workers["stuck"] != 0.0 {
+		t.Fatalf("idle pool: /health = %v, want healthy with no stuck workers", body)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pool.HandleRequest(context.Background(), "P00001")
+	}()
+	for deadline := time.Now().Add(time.Second); pool.WorkerHealth().Stuck == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("slow query never reported as stuck")
+		}
+	}
+
+	body = health(pool)
+	if workers := body["workers"].(map[string]interface{}); body["status"] != "degraded" || workers["stuck"] != 1.0 {
+		t.Errorf("stuck worker: /health = %v, want degraded with 1 stuck worker", body)
+	}
+	<-done
+
+	if body := health(patterns.NewNaiveHandler(db)); body["workers"] != nil {
+		t.Errorf("naive: /health reported workers %v, want none", body["workers"])
+	}
+}
+
+// TestHealthReportsHedging checks that /health reports the hedge delay
+// and rate when -hedge-delay is set, and nothing about hedging otherwise.
+func TestHealthReportsHedging(t *testing.T) {
+	db := simulator.NewDatabase(1, 2, 0)
+
+	for _, hedgeDelay := range []time.Duration{0, 50 * time.Millisecond} {
+		config := validConfig()
+		config.Pattern = "naive"
+		config.HedgeDelay = hedgeDelay
+		config.HedgeMaxRate = 0.05
+		handler, err := newSwitchableHandler(config, hedged(config, db))
+		if err != nil {
+			t.Fatalf("newSwitchableHandler: %v", err)
+		}
+
+		rec := httptest.NewRecorder()
+		healthCheckHandler(db, handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		var body map[string]interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding /health: %v", err)
+		}
+
+		hedging, ok := body["hedging"].(map[string]interface{})
+		if hedgeDelay == 0 {
+			if ok {
+				t.Errorf("without -hedge-delay: /health reported hedging %v", hedging)
+			}
+			continue
+		}
+		if !ok || hedging["delay_ms"] != 50.0 || hedging["hedge_rate"] != 0.0 {
+			t.Errorf("-hedge-delay=50ms: /health hedging = %v, want delay_ms 50 and hedge_rate 0", body["hedging"])
+		}
+	}
+}
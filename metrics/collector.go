@@ -1,11 +1,18 @@
 package metrics
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/clock"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
 )
 
 // Collector collects and aggregates metrics for API performance monitoring.
@@ -14,66 +21,135 @@ import (
 // - Datadog/New Relic for APM
 // - CloudWatch for AWS deployments
 // - Grafana for visualization
+//
+// Recording is safe for concurrent use and never takes a lock shared by
+// every caller: counters are atomic and latencies go to one of several
+// independently locked shards, which GetStats merges. Under heavy load a
+// single mutex would otherwise serialize the requests being measured.
 type Collector struct {
-	mu sync.RWMutex
+	mu sync.RWMutex // Guards the timing fields
 
 	// Request counters
-	totalRequests   int64
-	successRequests int64
-	errorRequests   int64
-	rejectedRequests int64 // Requests rejected due to queue full
+	totalRequests    atomic.Int64
+	successRequests  atomic.Int64
+	errorRequests    atomic.Int64
+	rejectedRequests atomic.Int64 // Requests rejected due to queue full
+	deadlineMisses   atomic.Int64 // Failed requests whose deadline passed first
+
+	// Requests being served right now. A gauge, not a total: Reset and
+	// Merge leave it alone, since the requests it counts will still call
+	// DecInFlight.
+	inFlight atomic.Int64
+
+	// Latencies, running aggregates and error breakdown by category
+	// (see simulator.ErrorCategory), spread across shards
+	shards []latencyShard
 
-	// Latency tracking
-	latencies []time.Duration
+	// Latency histogram upper bounds; each shard counts latencies in
+	// (bucketBounds[i-1], bucketBounds[i]] plus an extra +Inf bucket
+	bucketBounds []time.Duration
+
+	// Most latencies kept for percentiles, 0 to keep every one
+	reservoirSize int
 
 	// Timing
+	clock     clock.Clock
 	startTime time.Time
 	endTime   time.Time
 
 	// Memory tracking (if enabled)
-	memoryAllocations int64
-	memoryBytes       int64
+	memoryAllocations atomic.Int64
+	memoryBytes       atomic.Int64
 }
 
 // NewCollector creates a new metrics collector.
-func NewCollector() *Collector {
-	return &Collector{
-		latencies: make([]time.Duration, 0, 10000), // Pre-allocate for efficiency
-		startTime: time.Now(),
+// By default latencies are bucketed with DefaultLatencyBuckets.
+func NewCollector(opts ...CollectorOption) *Collector {
+	c := &Collector{
+		bucketBounds: DefaultLatencyBuckets,
+		clock:        clock.Real,
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
+	c.shards = newShards(len(c.bucketBounds)+1, c.reservoirSize)
+	c.startTime = c.clock.Now()
+
+	return c
 }
 
 // RecordRequest records a completed request with its latency.
 func (c *Collector) RecordRequest(latency time.Duration, success bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	c.totalRequests++
+	c.totalRequests.Add(1)
 	if success {
-		c.successRequests++
+		c.successRequests.Add(1)
 	} else {
-		c.errorRequests++
+		c.errorRequests.Add(1)
 	}
 
-	c.latencies = append(c.latencies, latency)
+	s := c.shard()
+	s.mu.Lock()
+	s.record(latency, c.bucketBounds)
+	s.mu.Unlock()
+}
+
+// RecordRequestWithError records a completed request and, if err is non-nil,
+// classifies it by category. A nil err records a success.
+// Use this instead of RecordRequest when the error is available, so that
+// ErrorsByCategory always sums to ErrorRequests. An err wrapping
+// context.DeadlineExceeded also counts as a deadline miss.
+func (c *Collector) RecordRequestWithError(latency time.Duration, err error) {
+	c.totalRequests.Add(1)
+	if err == nil {
+		c.successRequests.Add(1)
+	} else {
+		c.errorRequests.Add(1)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		c.deadlineMisses.Add(1)
+	}
+
+	s := c.shard()
+	s.mu.Lock()
+	if err != nil {
+		s.errorsByCategory[simulator.ErrorCategory(err)]++
+	}
+	s.record(latency, c.bucketBounds)
+	s.mu.Unlock()
+}
+
+// RecordError records the category of a failed request.
+// Call it alongside RecordRequest(latency, false) to break errors down by
+// cause, e.g. timeouts versus not-found versus overload.
+func (c *Collector) RecordError(category string) {
+	s := c.shard()
+	s.mu.Lock()
+	s.errorsByCategory[category]++
+	s.mu.Unlock()
 }
 
 // RecordRejection records a request that was rejected (queue full, etc).
 func (c *Collector) RecordRejection() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.totalRequests.Add(1)
+	c.rejectedRequests.Add(1)
+}
 
-	c.totalRequests++
-	c.rejectedRequests++
+// IncInFlight marks the start of a request. Pair every call with
+// DecInFlight when the request is done.
+func (c *Collector) IncInFlight() {
+	c.inFlight.Add(1)
+}
+
+// DecInFlight marks the end of a request started with IncInFlight.
+func (c *Collector) DecInFlight() {
+	c.inFlight.Add(-1)
 }
 
 // RecordMemory records memory allocation information.
 func (c *Collector) RecordMemory(allocations int64, bytes int64) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	c.memoryAllocations += allocations
-	c.memoryBytes += bytes
+	c.memoryAllocations.Add(allocations)
+	c.memoryBytes.Add(bytes)
 }
 
 // Stop marks the end of the measurement period.
@@ -81,7 +157,61 @@ func (c *Collector) Stop() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.endTime = time.Now()
+	c.endTime = c.clock.Now()
+}
+
+// Merge adds everything recorded by other into c: counters are summed,
+// latencies concatenated, and the measurement period widened to the
+// earliest start and latest end of the two. Stop both collectors first so
+// the end times are set. other is left unchanged.
+//
+// If other is a reservoir collector that has seen more latencies than it
+// keeps, each kept latency is recorded in c as an equal share of those it
+// saw. Counts stay exact, but c's latency mean, min and max become
+// estimates, like its percentiles.
+//
+// Giving each group of load generators its own Collector and merging them
+// at the end keeps the collectors from contending with one another.
+func (c *Collector) Merge(other *Collector) {
+	if other == c {
+		return
+	}
+
+	snap := other.snapshot(true)
+	other.mu.RLock()
+	startTime, endTime := other.startTime, other.endTime
+	other.mu.RUnlock()
+
+	c.totalRequests.Add(other.totalRequests.Load())
+	c.successRequests.Add(other.successRequests.Load())
+	c.errorRequests.Add(other.errorRequests.Load())
+	c.rejectedRequests.Add(other.rejectedRequests.Load())
+	c.deadlineMisses.Add(other.deadlineMisses.Load())
+	c.memoryAllocations.Add(other.memoryAllocations.Load())
+	c.memoryBytes.Add(other.memoryBytes.Load())
+
+	// Re-record rather than add bucket counts, since the bounds may differ
+	s := c.shard()
+	s.mu.Lock()
+	for category, count := range snap.errorsByCategory {
+		s.errorsByCategory[category] += count
+	}
+	kept := int64(len(snap.latencies))
+	for i, latency := range snap.latencies {
+		for copies := snap.count*int64(i+1)/kept - snap.count*int64(i)/kept; copies > 0; copies-- {
+			s.record(latency, c.bucketBounds)
+		}
+	}
+	s.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if startTime.Before(c.startTime) {
+		c.startTime = startTime
+	}
+	if endTime.After(c.endTime) {
+		c.endTime = endTime
+	}
 }
 
 // Stats represents the computed statistics from collected metrics.
@@ -94,6 +224,16 @@ type Stats struct {
 	ErrorRate        float64 `json:"error_rate_percent"`
 	RejectionRate    float64 `json:"rejection_rate_percent"`
 
+	// Failed requests whose deadline passed before they completed; the
+	// rest met it, which makes this the measure of SLO attainment
+	DeadlineMisses int64 `json:"deadline_misses"`
+
+	// Requests being served when the stats were taken
+	InFlight int64 `json:"in_flight"`
+
+	// Error counts keyed by category (e.g. "timeout", "not_found")
+	ErrorsByCategory map[string]int64 `json:"errors_by_category,omitempty"`
+
 	// Latency statistics (in milliseconds)
 	MinLatency    float64 `json:"min_latency_ms"`
 	MaxLatency    float64 `json:"max_latency_ms"`
@@ -101,6 +241,13 @@ type Stats struct {
 	MedianLatency float64 `json:"median_latency_ms"`
 	P95Latency    float64 `json:"p95_latency_ms"`
 	P99Latency    float64 `json:"p99_latency_ms"`
+	P999Latency   float64 `json:"p999_latency_ms"`
+
+	// Latencies the percentiles were taken from; see HasReliablePercentiles
+	LatencySamples int64 `json:"latency_samples"`
+
+	// Latency histogram, overflow (+Inf) bucket last
+	LatencyBuckets []Bucket `json:"latency_buckets,omitempty"`
 
 	// Throughput
 	Duration       float64 `json:"duration_seconds"`
@@ -113,78 +260,101 @@ type Stats struct {
 }
 
 // GetStats computes and returns statistics from the collected metrics.
+// Percentiles sort a copy of every recorded latency; use GetQuickStats when
+// polling frequently under load.
 func (c *Collector) GetStats() Stats {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	snap := c.snapshot(true)
+	stats := c.quickStats(snap)
 
+	// The snapshot's category breakdown is already a copy, so callers
+	// can't race with recording
+	if len(snap.errorsByCategory) > 0 {
+		stats.ErrorsByCategory = snap.errorsByCategory
+	}
+
+	// Calculate latency percentiles
+	if len(snap.latencies) > 0 {
+		// Sort the merged copy for percentile calculations
+		sort.Slice(snap.latencies, func(i, j int) bool {
+			return snap.latencies[i] < snap.latencies[j]
+		})
+
+		stats.MedianLatency = toMs(percentile(snap.latencies, 50))
+		stats.P95Latency = toMs(percentile(snap.latencies, 95))
+		stats.P99Latency = toMs(percentile(snap.latencies, 99))
+		stats.P999Latency = toMs(percentile(snap.latencies, 99.9))
+
+		stats.LatencyBuckets = snap.latencyBuckets()
+	}
+
+	return stats
+}
+
+// GetQuickStats returns counts, rates, throughput and the mean, min and max
+// latency without touching the recorded latencies.
+// The percentiles, histogram and error breakdown are left zero.
+func (c *Collector) GetQuickStats() Stats {
+	return c.quickStats(c.snapshot(false))
+}
+
+// quickStats computes everything in Stats that the counters and the
+// running latency aggregates cover.
+func (c *Collector) quickStats(snap latencySnapshot) Stats {
 	stats := Stats{
-		TotalRequests:     c.totalRequests,
-		SuccessRequests:   c.successRequests,
-		ErrorRequests:     c.errorRequests,
-		RejectedRequests:  c.rejectedRequests,
-		MemoryAllocations: c.memoryAllocations,
-		MemoryBytes:       c.memoryBytes,
+		TotalRequests:     c.totalRequests.Load(),
+		SuccessRequests:   c.successRequests.Load(),
+		ErrorRequests:     c.errorRequests.Load(),
+		RejectedRequests:  c.rejectedRequests.Load(),
+		DeadlineMisses:    c.deadlineMisses.Load(),
+		InFlight:          c.inFlight.Load(),
+		MemoryAllocations: c.memoryAllocations.Load(),
+		MemoryBytes:       c.memoryBytes.Load(),
 	}
 
 	// Calculate rates
-	if c.totalRequests > 0 {
-		stats.ErrorRate = float64(c.errorRequests) / float64(c.totalRequests) * 100
-		stats.RejectionRate = float64(c.rejectedRequests) / float64(c.totalRequests) * 100
+	if stats.TotalRequests > 0 {
+		stats.ErrorRate = float64(stats.ErrorRequests) / float64(stats.TotalRequests) * 100
+		stats.RejectionRate = float64(stats.RejectedRequests) / float64(stats.TotalRequests) * 100
 	}
 
 	// Calculate memory in MB
-	if c.memoryBytes > 0 {
-		stats.MemoryMB = float64(c.memoryBytes) / 1024 / 1024
+	if stats.MemoryBytes > 0 {
+		stats.MemoryMB = float64(stats.MemoryBytes) / 1024 / 1024
 	}
 
 	// Calculate duration
-	endTime := c.endTime
+	c.mu.RLock()
+	startTime, endTime := c.startTime, c.endTime
+	c.mu.RUnlock()
 	if endTime.IsZero() {
-		endTime = time.Now()
+		endTime = c.clock.Now()
 	}
-	duration := endTime.Sub(c.startTime)
+	duration := endTime.Sub(startTime)
 	stats.Duration = duration.Seconds()
 
 	// Calculate throughput
 	if stats.Duration > 0 {
-		stats.RequestsPerSec = float64(c.totalRequests) / stats.Duration
+		stats.RequestsPerSec = float64(stats.TotalRequests) / stats.Duration
 	}
 
-	// Calculate latency statistics
-	if len(c.latencies) > 0 {
-		// Make a copy and sort for percentile calculations
-		latenciesCopy := make([]time.Duration, len(c.latencies))
-		copy(latenciesCopy, c.latencies)
-		sort.Slice(latenciesCopy, func(i, j int) bool {
-			return latenciesCopy[i] < latenciesCopy[j]
-		})
-
-		// Convert to milliseconds
-		toMs := func(d time.Duration) float64 {
-			return float64(d) / float64(time.Millisecond)
-		}
-
-		stats.MinLatency = toMs(latenciesCopy[0])
-		stats.MaxLatency = toMs(latenciesCopy[len(latenciesCopy)-1])
-
-		// Calculate mean
-		var sum time.Duration
-		for _, lat := range latenciesCopy {
-			sum += lat
-		}
-		stats.MeanLatency = toMs(sum / time.Duration(len(latenciesCopy)))
-
-		// Calculate percentiles
-		stats.MedianLatency = toMs(percentile(latenciesCopy, 50))
-		stats.P95Latency = toMs(percentile(latenciesCopy, 95))
-		stats.P99Latency = toMs(percentile(latenciesCopy, 99))
+	// Latency statistics from the running aggregates
+	if snap.count > 0 {
+		stats.LatencySamples = snap.retained
+		stats.MinLatency = toMs(snap.min)
+		stats.MaxLatency = toMs(snap.max)
+		stats.MeanLatency = toMs(snap.sum / time.Duration(snap.count))
 	}
 
 	return stats
 }
 
-// percentile calculates the nth percentile from a sorted slice.
-func percentile(sorted []time.Duration, p int) time.Duration {
+// toMs converts a duration to fractional milliseconds.
+func toMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// percentile calculates the pth percentile from a sorted slice.
+func percentile(sorted []time.Duration, p float64) time.Duration {
 	if len(sorted) == 0 {
 		return 0
 	}
@@ -192,7 +362,7 @@ func percentile(sorted []time.Duration, p int) time.Duration {
 	// Calculate the index
 	// Using nearest-rank method
 	n := len(sorted)
-	rank := int(float64(p) / 100.0 * float64(n))
+	rank := int(p / 100.0 * float64(n))
 
 	// Ensure we don't go out of bounds
 	if rank >= n {
@@ -202,6 +372,38 @@ func percentile(sorted []time.Duration, p int) time.Duration {
 	return sorted[rank]
 }
 
+// MinSamplesForPercentile returns how many latencies the pth percentile
+// needs before it means anything: enough that the tail beyond it holds at
+// least one whole sample. That is 20 for P95, 100 for P99 and 1000 for
+// P99.9. Below the floor the nearest rank is always the largest sample, so
+// the "percentile" is just the maximum; even at the floor it rests on one
+// or two outliers, so treat it as rough until there are several times as
+// many.
+func MinSamplesForPercentile(p float64) int64 {
+	if p <= 0 || p >= 100 {
+		return 1
+	}
+	// The epsilon absorbs rounding in 100-p: 100/(100-99.9) is a hair
+	// over 1000
+	return int64(math.Ceil(100/(100-p) - 1e-9))
+}
+
+// HasReliablePercentiles reports whether there are enough latency samples
+// for the pth percentile to be reported; see MinSamplesForPercentile.
+func (s Stats) HasReliablePercentiles(p float64) bool {
+	return s.LatencySamples > 0 && s.LatencySamples >= MinSamplesForPercentile(p)
+}
+
+// sortedKeys returns the keys of m in sorted order for stable output.
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // PrintStats prints a human-readable summary of the statistics.
 func (c *Collector) PrintStats(patternName string) {
 	stats := c.GetStats()
@@ -212,9 +414,15 @@ func (c *Collector) PrintStats(patternName string) {
 	fmt.Printf("Failed:            %d\n", stats.ErrorRequests)
 	fmt.Printf("Rejected:          %d\n", stats.RejectedRequests)
 	fmt.Printf("Error Rate:        %.2f%%\n", stats.ErrorRate)
+	for _, category := range sortedKeys(stats.ErrorsByCategory) {
+		fmt.Printf("  %-16s %d\n", category+":", stats.ErrorsByCategory[category])
+	}
 	if stats.RejectedRequests > 0 {
 		fmt.Printf("Rejection Rate:    %.2f%%\n", stats.RejectionRate)
 	}
+	if stats.DeadlineMisses > 0 {
+		fmt.Printf("Deadline Misses:   %d\n", stats.DeadlineMisses)
+	}
 	fmt.Printf("\n")
 	fmt.Printf("Duration:          %.2fs\n", stats.Duration)
 	fmt.Printf("Requests/sec:      %.2f\n", stats.RequestsPerSec)
@@ -225,6 +433,7 @@ func (c *Collector) PrintStats(patternName string) {
 	fmt.Printf("  Median:          %.2f\n", stats.MedianLatency)
 	fmt.Printf("  P95:             %.2f\n", stats.P95Latency)
 	fmt.Printf("  P99:             %.2f\n", stats.P99Latency)
+	fmt.Printf("  P99.9:           %.2f\n", stats.P999Latency)
 	fmt.Printf("  Max:             %.2f\n", stats.MaxLatency)
 
 	if stats.MemoryMB > 0 {
@@ -242,9 +451,13 @@ func (c *Collector) ExportJSON() ([]byte, error) {
 
 // ExportPrometheus exports metrics in Prometheus text format.
 // This allows integration with Prometheus monitoring systems.
+//
+// The pattern is baked into each metric name, which makes the output hard to
+// aggregate in PromQL; for a scrape endpoint, register a PrometheusCollector
+// instead.
 func (c *Collector) ExportPrometheus(namespace, pattern string) string {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	stats := c.GetStats()
+	snap := c.snapshot(false)
 
 	var output string
 
@@ -256,44 +469,80 @@ func (c *Collector) ExportPrometheus(namespace, pattern string) string {
 	// Counters
 	output += fmt.Sprintf("# HELP %s Total number of requests\n", metric("requests_total"))
 	output += fmt.Sprintf("# TYPE %s counter\n", metric("requests_total"))
-	output += fmt.Sprintf("%s %d\n", metric("requests_total"), c.totalRequests)
+	output += fmt.Sprintf("%s %d\n", metric("requests_total"), c.totalRequests.Load())
 	output += "\n"
 
 	output += fmt.Sprintf("# HELP %s Number of successful requests\n", metric("requests_success"))
 	output += fmt.Sprintf("# TYPE %s counter\n", metric("requests_success"))
-	output += fmt.Sprintf("%s %d\n", metric("requests_success"), c.successRequests)
+	output += fmt.Sprintf("%s %d\n", metric("requests_success"), c.successRequests.Load())
 	output += "\n"
 
 	output += fmt.Sprintf("# HELP %s Number of failed requests\n", metric("requests_error"))
 	output += fmt.Sprintf("# TYPE %s counter\n", metric("requests_error"))
-	output += fmt.Sprintf("%s %d\n", metric("requests_error"), c.errorRequests)
+	output += fmt.Sprintf("%s %d\n", metric("requests_error"), c.errorRequests.Load())
 	output += "\n"
 
-	// Calculate latency percentiles for histogram
-	stats := c.GetStats()
+	output += fmt.Sprintf("# HELP %s Number of requests that missed their deadline\n", metric("deadline_misses_total"))
+	output += fmt.Sprintf("# TYPE %s counter\n", metric("deadline_misses_total"))
+	output += fmt.Sprintf("%s %d\n", metric("deadline_misses_total"), c.deadlineMisses.Load())
+	output += "\n"
+
+	// Gauges
+	output += fmt.Sprintf("# HELP %s Number of requests being served\n", metric("in_flight"))
+	output += fmt.Sprintf("# TYPE %s gauge\n", metric("in_flight"))
+	output += fmt.Sprintf("%s %d\n", metric("in_flight"), c.inFlight.Load())
+	output += "\n"
+
+	output += fmt.Sprintf("# HELP %s Number of failed requests by error category\n", metric("errors_total"))
+	output += fmt.Sprintf("# TYPE %s counter\n", metric("errors_total"))
+	for _, category := range sortedKeys(snap.errorsByCategory) {
+		output += fmt.Sprintf("%s{category=%q} %d\n", metric("errors_total"), category, snap.errorsByCategory[category])
+	}
+	output += "\n"
 
 	output += fmt.Sprintf("# HELP %s Request latency in milliseconds\n", metric("latency_ms"))
 	output += fmt.Sprintf("# TYPE %s summary\n", metric("latency_ms"))
 	output += fmt.Sprintf("%s{quantile=\"0.5\"} %.2f\n", metric("latency_ms"), stats.MedianLatency)
 	output += fmt.Sprintf("%s{quantile=\"0.95\"} %.2f\n", metric("latency_ms"), stats.P95Latency)
 	output += fmt.Sprintf("%s{quantile=\"0.99\"} %.2f\n", metric("latency_ms"), stats.P99Latency)
+	output += fmt.Sprintf("%s{quantile=\"0.999\"} %.2f\n", metric("latency_ms"), stats.P999Latency)
+	output += "\n"
+
+	output += fmt.Sprintf("# HELP %s Request latency in seconds\n", metric("latency_seconds"))
+	output += fmt.Sprintf("# TYPE %s histogram\n", metric("latency_seconds"))
+	var cumulative int64
+	for i, bound := range snap.bucketBounds {
+		cumulative += snap.bucketCounts[i]
+		output += fmt.Sprintf("%s_bucket{le=\"%g\"} %d\n", metric("latency_seconds"), bound.Seconds(), cumulative)
+	}
+	output += fmt.Sprintf("%s_bucket{le=\"+Inf\"} %d\n", metric("latency_seconds"), snap.count)
+	output += fmt.Sprintf("%s_sum %g\n", metric("latency_seconds"), snap.sum.Seconds())
+	output += fmt.Sprintf("%s_count %d\n", metric("latency_seconds"), snap.count)
 	output += "\n"
 
 	return output
 }
 
 // Reset clears all collected metrics.
+// Requests recorded while Reset runs may be partly kept; stop recording
+// first for an exact reset.
 func (c *Collector) Reset() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.totalRequests = 0
-	c.successRequests = 0
-	c.errorRequests = 0
-	c.rejectedRequests = 0
-	c.latencies = make([]time.Duration, 0, 10000)
-	c.memoryAllocations = 0
-	c.memoryBytes = 0
-	c.startTime = time.Now()
+	c.totalRequests.Store(0)
+	c.successRequests.Store(0)
+	c.errorRequests.Store(0)
+	c.rejectedRequests.Store(0)
+	c.deadlineMisses.Store(0)
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		s.reset(len(c.bucketBounds)+1, s.capacity(len(c.shards)))
+		s.mu.Unlock()
+	}
+	c.memoryAllocations.Store(0)
+	c.memoryBytes.Store(0)
+	c.startTime = c.clock.Now()
 	c.endTime = time.Time{}
 }
@@ -0,0 +1,379 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/clock"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+func TestRecordErrorBreakdown(t *testing.T) {
+	c := NewCollector()
+
+	c.RecordRequest(time.Millisecond, true)
+	for _, category := range []string{"timeout", "timeout", "not_found"} {
+		c.RecordRequest(time.Millisecond, false)
+		c.RecordError(category)
+	}
+
+	stats := c.GetStats()
+	if stats.ErrorsByCategory["timeout"] != 2 || stats.ErrorsByCategory["not_found"] != 1 {
+		t.Errorf("ErrorsByCategory = %v, want timeout:2 not_found:1", stats.ErrorsByCategory)
+	}
+
+	// The returned map is a snapshot, not the collector's own state.
+	stats.ErrorsByCategory["timeout"] = 100
+	if got := c.GetStats().ErrorsByCategory["timeout"]; got != 2 {
+		t.Errorf("mutating Stats changed the collector: timeout = %d", got)
+	}
+
+	c.Reset()
+	if got := c.GetStats().ErrorsByCategory; len(got) != 0 {
+		t.Errorf("ErrorsByCategory after Reset = %v, want empty", got)
+	}
+}
+
+func TestRecordRequestWithErrorCategoriesSumToErrors(t *testing.T) {
+	c := NewCollector()
+
+	errs := []error{
+		nil,
+		fmt.Errorf("db: %w", simulator.ErrConnectionTimeout),
+		fmt.Errorf("db: %w", simulator.ErrConnectionTimeout),
+		simulator.ErrPoolExhausted,
+		fmt.Errorf("db: %w", simulator.ErrPatientNotFound),
+		context.DeadlineExceeded,
+		errors.New("queue full: request rejected"),
+		nil,
+	}
+	for _, err := range errs {
+		c.RecordRequestWithError(time.Millisecond, err)
+	}
+
+	stats := c.GetStats()
+	if stats.SuccessRequests != 2 || stats.ErrorRequests != 6 {
+		t.Fatalf("success/error = %d/%d, want 2/6", stats.SuccessRequests, stats.ErrorRequests)
+	}
+
+	var sum int64
+	for _, count := range stats.ErrorsByCategory {
+		sum += count
+	}
+	if sum != stats.ErrorRequests {
+		t.Errorf("category counts sum to %d, want %d (ErrorRequests)", sum, stats.ErrorRequests)
+	}
+
+	want := map[string]int64{
+		simulator.CategoryTimeout:       2,
+		simulator.CategoryPoolExhausted: 1,
+		simulator.CategoryNotFound:      1,
+		simulator.CategoryCancelled:     1,
+		simulator.CategoryOther:         1,
+	}
+	for category, count := range want {
+		if got := stats.ErrorsByCategory[category]; got != count {
+			t.Errorf("ErrorsByCategory[%q] = %d, want %d", category, got, count)
+		}
+	}
+}
+
+func TestExportPrometheusErrorsByCategory(t *testing.T) {
+	c := NewCollector()
+	c.RecordRequestWithError(time.Millisecond, simulator.ErrConnectionTimeout)
+	c.RecordRequestWithError(time.Millisecond, simulator.ErrConnectionTimeout)
+	c.RecordRequestWithError(time.Millisecond, simulator.ErrPoolExhausted)
+
+	output := c.ExportPrometheus("healthcare_api", "current")
+
+	for _, line := range []string{
+		"# TYPE healthcare_api_current_errors_total counter",
+		`healthcare_api_current_errors_total{category="timeout"} 2`,
+		`healthcare_api_current_errors_total{category="pool_exhausted"} 1`,
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("output missing %q\n%s", line, output)
+		}
+	}
+}
+
+func TestLatencyBucketsSumToTotalRequests(t *testing.T) {
+	c := NewCollector()
+	for i := 0; i < 200; i++ {
+		c.RecordRequest(time.Duration(i)*time.Millisecond, i%10 != 0)
+	}
+
+	stats := c.GetStats()
+	if len(stats.LatencyBuckets) != len(DefaultLatencyBuckets)+1 {
+		t.Fatalf("got %d buckets, want %d", len(stats.LatencyBuckets), len(DefaultLatencyBuckets)+1)
+	}
+
+	var sum int64
+	for _, bucket := range stats.LatencyBuckets {
+		sum += bucket.Count
+	}
+	if sum != stats.TotalRequests {
+		t.Errorf("bucket counts sum to %d, want %d", sum, stats.TotalRequests)
+	}
+
+	c.Reset()
+	c.RecordRequest(time.Millisecond, true)
+	if got := c.GetStats().LatencyBuckets[0].Count; got != 1 {
+		t.Errorf("first bucket after Reset = %d, want 1", got)
+	}
+}
+
+func TestLatencyBucketsOverflow(t *testing.T) {
+	c := NewCollector(WithLatencyBuckets(10*time.Millisecond, 100*time.Millisecond))
+	c.RecordRequest(10*time.Millisecond, true) // on a bound: counts in that bucket
+	c.RecordRequest(50*time.Millisecond, true)
+	c.RecordRequest(time.Minute, true)
+
+	buckets := c.GetStats().LatencyBuckets
+	want := []Bucket{
+		{UpperBoundMs: 10, Count: 1},
+		{UpperBoundMs: 100, Count: 1},
+		{UpperBoundMs: math.Inf(1), Count: 1},
+	}
+	if len(buckets) != len(want) {
+		t.Fatalf("got %d buckets, want %d", len(buckets), len(want))
+	}
+	for i := range want {
+		if buckets[i] != want[i] {
+			t.Errorf("bucket %d = %+v, want %+v", i, buckets[i], want[i])
+		}
+	}
+
+	// +Inf has no JSON encoding of its own; it round-trips as a string
+	data, err := json.Marshal(buckets)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"upper_bound_ms":"+Inf"`) {
+		t.Errorf("overflow bucket not encoded as +Inf: %s", data)
+	}
+	var decoded []Bucket
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !math.IsInf(decoded[2].UpperBoundMs, 1) || decoded[0].UpperBoundMs != 10 {
+		t.Errorf("round trip = %+v", decoded)
+	}
+}
+
+func TestExportPrometheusHistogram(t *testing.T) {
+	c := NewCollector(WithLatencyBuckets(10*time.Millisecond, 100*time.Millisecond))
+	c.RecordRequest(5*time.Millisecond, true)
+	c.RecordRequest(50*time.Millisecond, true)
+	c.RecordRequest(time.Second, true)
+
+	output := c.ExportPrometheus("healthcare_api", "current")
+
+	for _, line := range []string{
+		"# TYPE healthcare_api_current_latency_seconds histogram",
+		`healthcare_api_current_latency_seconds_bucket{le="0.01"} 1`,
+		`healthcare_api_current_latency_seconds_bucket{le="0.1"} 2`,
+		`healthcare_api_current_latency_seconds_bucket{le="+Inf"} 3`,
+		"healthcare_api_current_latency_seconds_sum 1.055",
+		"healthcare_api_current_latency_seconds_count 3",
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("output missing %q\n%s", line, output)
+		}
+	}
+}
+
+func TestExponentialLatencyBuckets(t *testing.T) {
+	bounds := ExponentialLatencyBuckets(time.Millisecond, 5*time.Second, 14)
+	if len(bounds) != 14 || bounds[0] != time.Millisecond || bounds[13] != 5*time.Second {
+		t.Fatalf("bounds = %v, want 14 from 1ms to 5s", bounds)
+	}
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			t.Errorf("bounds not increasing at %d: %v", i, bounds)
+		}
+	}
+}
+
+func TestRunningLatencyMatchesSorted(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	c := NewCollector()
+	var recorded []time.Duration
+	for i := 0; i < 1000; i++ {
+		latency := time.Duration(rng.Int63n(int64(200 * time.Millisecond)))
+		recorded = append(recorded, latency)
+		c.RecordRequestWithError(latency, nil)
+	}
+	c.RecordRejection() // counted, but has no latency
+
+	sorted := append([]time.Duration(nil), recorded...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum time.Duration
+	for _, latency := range sorted {
+		sum += latency
+	}
+	wantMin := toMs(sorted[0])
+	wantMax := toMs(sorted[len(sorted)-1])
+	wantMean := toMs(sum / time.Duration(len(sorted)))
+
+	for name, stats := range map[string]Stats{"GetStats": c.GetStats(), "GetQuickStats": c.GetQuickStats()} {
+		if stats.MinLatency != wantMin || stats.MaxLatency != wantMax || stats.MeanLatency != wantMean {
+			t.Errorf("%s: min/mean/max = %v/%v/%v, want %v/%v/%v", name,
+				stats.MinLatency, stats.MeanLatency, stats.MaxLatency, wantMin, wantMean, wantMax)
+		}
+		if stats.TotalRequests != 1001 || stats.RejectedRequests != 1 {
+			t.Errorf("%s: total %d, rejected %d; want 1001, 1", name, stats.TotalRequests, stats.RejectedRequests)
+		}
+	}
+
+	quick := c.GetQuickStats()
+	if quick.P99Latency != 0 || quick.LatencyBuckets != nil {
+		t.Errorf("GetQuickStats computed percentiles: p99 %v, %d buckets", quick.P99Latency, len(quick.LatencyBuckets))
+	}
+
+	// A fresh minimum after Reset must not be compared with the old one
+	c.Reset()
+	c.RecordRequest(150*time.Millisecond, true)
+	if got := c.GetQuickStats(); got.MinLatency != 150 || got.MaxLatency != 150 || got.MeanLatency != 150 {
+		t.Errorf("after Reset: min/mean/max = %v/%v/%v, want 150 each", got.MinLatency, got.MeanLatency, got.MaxLatency)
+	}
+}
+
+func TestMergeEqualsSingleCollector(t *testing.T) {
+	start := time.Now()
+	end := start.Add(2 * time.Second)
+
+	single := NewCollector()
+	shards := []*Collector{NewCollector(), NewCollector()}
+
+	record := func(i int, c *Collector) {
+		latency := time.Duration(i%97+1) * time.Millisecond
+		switch {
+		case i%13 == 0:
+			c.RecordRejection()
+		case i%7 == 0:
+			c.RecordRequestWithError(latency, simulator.ErrConnectionTimeout)
+		case i%11 == 0:
+			c.RecordRequestWithError(latency, simulator.ErrPatientNotFound)
+		default:
+			c.RecordRequestWithError(latency, nil)
+		}
+		c.RecordMemory(1, 64)
+	}
+	for i := 0; i < 500; i++ {
+		record(i, single)
+		record(i, shards[i%2])
+	}
+
+	single.startTime, single.endTime = start, end
+	shards[0].startTime, shards[0].endTime = start.Add(time.Second), end
+	shards[1].startTime, shards[1].endTime = start, end.Add(-time.Second)
+
+	merged := NewCollector()
+	for _, shard := range shards {
+		merged.Merge(shard)
+	}
+
+	got, want := merged.GetStats(), single.GetStats()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged stats differ from a single collector\n got: %+v\nwant: %+v", got, want)
+	}
+
+	// Merging is additive and leaves the source untouched
+	if shards[0].GetStats().TotalRequests != 250 {
+		t.Errorf("Merge modified its argument")
+	}
+	merged.Merge(merged)
+	if merged.GetStats().TotalRequests != want.TotalRequests {
+		t.Errorf("merging a collector into itself changed its totals")
+	}
+}
+
+func TestDurationAndThroughputFollowTheClock(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	c := NewCollector(WithClock(clk))
+
+	for i := 0; i < 50; i++ {
+		c.RecordRequest(time.Millisecond, true)
+	}
+	clk.Advance(10 * time.Second)
+
+	if stats := c.GetStats(); stats.Duration != 10 || stats.RequestsPerSec != 5 {
+		t.Errorf("running: duration = %vs, throughput = %v/s; want 10s and 5/s", stats.Duration, stats.RequestsPerSec)
+	}
+
+	// Stop freezes the measurement period
+	c.Stop()
+	clk.Advance(time.Hour)
+	if stats := c.GetStats(); stats.Duration != 10 {
+		t.Errorf("stopped: duration = %vs, want 10s", stats.Duration)
+	}
+
+	// Reset starts a new one
+	c.Reset()
+	clk.Advance(2 * time.Second)
+	if stats := c.GetStats(); stats.Duration != 2 || stats.TotalRequests != 0 {
+		t.Errorf("after reset: duration = %vs, requests = %d; want 2s and 0", stats.Duration, stats.TotalRequests)
+	}
+}
+
+func TestPercentileReliabilityThresholds(t *testing.T) {
+	floors := map[float64]int64{50: 2, 95: 20, 99: 100, 99.9: 1000, 99.99: 10000}
+	for p, floor := range floors {
+		if got := MinSamplesForPercentile(p); got != floor {
+			t.Errorf("MinSamplesForPercentile(%g) = %d, want %d", p, got, floor)
+		}
+		if (Stats{LatencySamples: floor - 1}).HasReliablePercentiles(p) {
+			t.Errorf("P%g reported reliable with %d samples", p, floor-1)
+		}
+		if !(Stats{LatencySamples: floor}).HasReliablePercentiles(p) {
+			t.Errorf("P%g reported unreliable with %d samples", p, floor)
+		}
+	}
+	if (Stats{}).HasReliablePercentiles(0) {
+		t.Error("percentiles reported reliable with no samples")
+	}
+}
+
+func TestP999NeedsAThousandSamples(t *testing.T) {
+	c := NewCollector()
+	for i := 1; i <= 999; i++ {
+		c.RecordRequest(time.Duration(i)*time.Millisecond, true)
+	}
+	c.RecordRejection() // counted, but has no latency
+
+	// With 999 samples nothing lies above P99.9: it is just the maximum
+	stats := c.GetStats()
+	if stats.LatencySamples != 999 {
+		t.Fatalf("LatencySamples = %d, want 999", stats.LatencySamples)
+	}
+	if stats.P999Latency != stats.MaxLatency || stats.HasReliablePercentiles(99.9) {
+		t.Errorf("999 samples: P99.9 %v (max %v), reliable %v; want the max, unreliable",
+			stats.P999Latency, stats.MaxLatency, stats.HasReliablePercentiles(99.9))
+	}
+	if !stats.HasReliablePercentiles(99) {
+		t.Error("999 samples: P99 reported unreliable")
+	}
+
+	c.RecordRequest(time.Second, true)
+	if stats = c.GetStats(); !stats.HasReliablePercentiles(99.9) {
+		t.Error("1000 samples: P99.9 reported unreliable")
+	}
+
+	// Well past the floor, the tail separates from the maximum
+	for i := 0; i < 1000; i++ {
+		c.RecordRequest(time.Millisecond, true)
+	}
+	if stats = c.GetStats(); stats.P999Latency >= stats.MaxLatency {
+		t.Errorf("2000 samples: P99.9 %v, want below the %v max", stats.P999Latency, stats.MaxLatency)
+	}
+}
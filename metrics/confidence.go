@@ -0,0 +1,83 @@
+package metrics
+
+import "math"
+
+// Interval is a sample mean with the half-width of its 95% confidence
+// interval, so the true mean lies in [Mean-HalfWidth, Mean+HalfWidth] with
+// 95% confidence.
+type Interval struct {
+	Mean      float64 `json:"mean"`
+	HalfWidth float64 `json:"half_width"`
+}
+
+// Low returns the lower bound of the interval.
+func (i Interval) Low() float64 { return i.Mean - i.HalfWidth }
+
+// High returns the upper bound of the interval.
+func (i Interval) High() float64 { return i.Mean + i.HalfWidth }
+
+// Overlaps reports whether the two intervals share any values, in which
+// case the difference between their means is not significant.
+func (i Interval) Overlaps(other Interval) bool {
+	return i.Low() <= other.High() && other.Low() <= i.High()
+}
+
+// tCritical95 holds two-sided 95% critical values of Student's t
+// distribution, indexed by degrees of freedom minus one.
+var tCritical95 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// ConfidenceInterval95 returns the mean of samples with a 95% t-interval.
+// Beyond 30 degrees of freedom the normal approximation (1.96) is used.
+// With fewer than two samples the half-width is zero, since the spread is
+// unknown.
+func ConfidenceInterval95(samples []float64) Interval {
+	n := len(samples)
+	if n == 0 {
+		return Interval{}
+	}
+
+	mean := Mean(samples)
+	if n < 2 {
+		return Interval{Mean: mean}
+	}
+
+	t := 1.96
+	if df := n - 1; df <= len(tCritical95) {
+		t = tCritical95[df-1]
+	}
+	return Interval{
+		Mean:      mean,
+		HalfWidth: t * StdDev(samples) / math.Sqrt(float64(n)),
+	}
+}
+
+// Mean returns the arithmetic mean of samples, or zero if there are none.
+func Mean(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range samples {
+		sum += v
+	}
+	return sum / float64(len(samples))
+}
+
+// StdDev returns the sample standard deviation (with n-1 in the
+// denominator), or zero for fewer than two samples.
+func StdDev(samples []float64) float64 {
+	n := len(samples)
+	if n < 2 {
+		return 0
+	}
+	mean := Mean(samples)
+	var squares float64
+	for _, v := range samples {
+		squares += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(squares / float64(n-1))
+}
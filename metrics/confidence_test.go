@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConfidenceInterval95(t *testing.T) {
+	// Mean 5, sample variance 32/7; df 7 gives t = 2.365
+	samples := []float64{2, 4, 4, 4, 5, 5, 7, 9}
+
+	if got := Mean(samples); got != 5 {
+		t.Errorf("Mean = %v, want 5", got)
+	}
+	wantSD := math.Sqrt(32.0 / 7)
+	if got := StdDev(samples); math.Abs(got-wantSD) > 1e-12 {
+		t.Errorf("StdDev = %v, want %v", got, wantSD)
+	}
+
+	ci := ConfidenceInterval95(samples)
+	wantHalf := 2.365 * wantSD / math.Sqrt(8)
+	if ci.Mean != 5 || math.Abs(ci.HalfWidth-wantHalf) > 1e-12 {
+		t.Errorf("interval = %+v, want mean 5 ± %v", ci, wantHalf)
+	}
+	if math.Abs(ci.Low()-(5-wantHalf)) > 1e-12 || math.Abs(ci.High()-(5+wantHalf)) > 1e-12 {
+		t.Errorf("bounds = [%v, %v]", ci.Low(), ci.High())
+	}
+}
+
+func TestConfidenceIntervalEdgeCases(t *testing.T) {
+	if got := ConfidenceInterval95(nil); got != (Interval{}) {
+		t.Errorf("no samples: %+v, want zero", got)
+	}
+	if got := ConfidenceInterval95([]float64{42}); got != (Interval{Mean: 42}) {
+		t.Errorf("one sample: %+v, want mean 42 with no width", got)
+	}
+	if got := ConfidenceInterval95([]float64{3, 3, 3}); got != (Interval{Mean: 3}) {
+		t.Errorf("identical samples: %+v, want mean 3 with no width", got)
+	}
+
+	// Two samples use t = 12.706: mean 2, sd sqrt(2), so 12.706 * sqrt(2) / sqrt(2)
+	if got := ConfidenceInterval95([]float64{1, 3}); math.Abs(got.HalfWidth-12.706) > 1e-9 {
+		t.Errorf("two samples: half-width %v, want 12.706", got.HalfWidth)
+	}
+
+	// Past 30 degrees of freedom the normal value applies
+	many := make([]float64, 41)
+	for i := range many {
+		many[i] = float64(i % 2)
+	}
+	want := 1.96 * StdDev(many) / math.Sqrt(41)
+	if got := ConfidenceInterval95(many); math.Abs(got.HalfWidth-want) > 1e-12 {
+		t.Errorf("41 samples: half-width %v, want %v", got.HalfWidth, want)
+	}
+}
+
+func TestIntervalOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b Interval
+		want bool
+	}{
+		{Interval{100, 10}, Interval{115, 10}, true},
+		{Interval{100, 10}, Interval{120, 10}, true}, // touching bounds
+		{Interval{100, 10}, Interval{121, 10}, false},
+		{Interval{100, 0}, Interval{100, 0}, true},
+	}
+	for _, tc := range tests {
+		if got := tc.a.Overlaps(tc.b); got != tc.want {
+			t.Errorf("%+v.Overlaps(%+v) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+		if got := tc.b.Overlaps(tc.a); got != tc.want {
+			t.Errorf("Overlaps is not symmetric for %+v, %+v", tc.a, tc.b)
+		}
+	}
+}
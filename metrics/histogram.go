@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/clock"
+)
+
+// DefaultLatencyBuckets are the histogram upper bounds used when no
+// WithLatencyBuckets option is given: 14 exponential steps from 1ms to 5s.
+var DefaultLatencyBuckets = ExponentialLatencyBuckets(time.Millisecond, 5*time.Second, 14)
+
+// ExponentialLatencyBuckets returns count upper bounds growing by a constant
+// factor from start to end inclusive.
+func ExponentialLatencyBuckets(start, end time.Duration, count int) []time.Duration {
+	if count < 2 || start <= 0 || end <= start {
+		return []time.Duration{start}
+	}
+
+	factor := math.Pow(float64(end)/float64(start), 1/float64(count-1))
+	bounds := make([]time.Duration, count)
+	for i := range bounds {
+		bounds[i] = time.Duration(float64(start) * math.Pow(factor, float64(i))).Round(time.Microsecond)
+	}
+	bounds[count-1] = end
+	return bounds
+}
+
+// CollectorOption configures a Collector.
+type CollectorOption func(*Collector)
+
+// WithLatencyBuckets sets the upper bounds of the latency histogram.
+// An overflow bucket for anything slower than the largest bound is always
+// added, so outliers are never dropped.
+func WithLatencyBuckets(bounds ...time.Duration) CollectorOption {
+	return func(c *Collector) {
+		sorted := make([]time.Duration, len(bounds))
+		copy(sorted, bounds)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		c.bucketBounds = sorted
+	}
+}
+
+// WithClock makes the collector time its measurement period, and so its
+// duration and throughput, by c instead of the wall clock.
+func WithClock(c clock.Clock) CollectorOption {
+	return func(collector *Collector) {
+		collector.clock = c
+	}
+}
+
+// Bucket is one bar of the latency histogram. Count is the number of
+// requests slower than the previous bucket's bound and no slower than
+// UpperBoundMs. The last bucket's bound is +Inf, encoded in JSON as the
+// string "+Inf" since JSON has no infinity.
+type Bucket struct {
+	UpperBoundMs float64 `json:"upper_bound_ms"`
+	Count        int64   `json:"count"`
+}
+
+// MarshalJSON encodes the overflow bucket's bound as "+Inf".
+func (b Bucket) MarshalJSON() ([]byte, error) {
+	if math.IsInf(b.UpperBoundMs, 1) {
+		return json.Marshal(struct {
+			UpperBoundMs string `json:"upper_bound_ms"`
+			Count        int64  `json:"count"`
+		}{"+Inf", b.Count})
+	}
+	type plain Bucket // drops the methods, avoiding recursion
+	return json.Marshal(plain(b))
+}
+
+// UnmarshalJSON accepts the encoding produced by MarshalJSON.
+func (b *Bucket) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		UpperBoundMs interface{} `json:"upper_bound_ms"`
+		Count        int64       `json:"count"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	b.Count = raw.Count
+	switch bound := raw.UpperBoundMs.(type) {
+	case float64:
+		b.UpperBoundMs = bound
+	default:
+		b.UpperBoundMs = math.Inf(1)
+	}
+	return nil
+}
+
+// latencyBuckets returns the histogram, overflow bucket last.
+func (snap latencySnapshot) latencyBuckets() []Bucket {
+	buckets := make([]Bucket, len(snap.bucketCounts))
+	for i, count := range snap.bucketCounts {
+		bound := math.Inf(1)
+		if i < len(snap.bucketBounds) {
+			bound = float64(snap.bucketBounds[i]) / float64(time.Millisecond)
+		}
+		buckets[i] = Bucket{UpperBoundMs: bound, Count: count}
+	}
+	return buckets
+}
+
+// cumulativeBuckets returns the histogram in Prometheus form: upper bounds
+// in seconds mapped to the count of requests at or below them. The +Inf
+// bucket is implied by the total count.
+func (snap latencySnapshot) cumulativeBuckets() map[float64]uint64 {
+	buckets := make(map[float64]uint64, len(snap.bucketBounds))
+	var running uint64
+	for i, bound := range snap.bucketBounds {
+		running += uint64(snap.bucketCounts[i])
+		buckets[bound.Seconds()] = running
+	}
+	return buckets
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusCollector exposes a Collector's counters and latencies to a
+// Prometheus registry.
+//
+// Unlike ExportPrometheus, the pattern is a label rather than part of the
+// metric name, so PromQL can aggregate and compare across patterns:
+//
+//	sum by (pattern) (rate(healthcare_api_requests_total[1m]))
+type PrometheusCollector struct {
+	collector *Collector
+	pattern   string
+
+	requests       *prometheus.Desc
+	errors         *prometheus.Desc
+	deadlineMisses *prometheus.Desc
+	latency        *prometheus.Desc
+	inFlight       *prometheus.Desc
+}
+
+// NewPrometheusCollector wraps c for registration with a prometheus.Registry.
+// Every metric is prefixed with namespace and labelled with pattern.
+func NewPrometheusCollector(c *Collector, namespace, pattern string) *PrometheusCollector {
+	return &PrometheusCollector{
+		collector: c,
+		pattern:   pattern,
+		requests: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "requests_total"),
+			"Total number of requests.",
+			[]string{"pattern"}, nil,
+		),
+		errors: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "errors_total"),
+			"Number of failed requests by error category.",
+			[]string{"pattern", "category"}, nil,
+		),
+		deadlineMisses: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "deadline_misses_total"),
+			"Number of requests that missed their deadline.",
+			[]string{"pattern"}, nil,
+		),
+		latency: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "latency_seconds"),
+			"Request latency in seconds.",
+			[]string{"pattern"}, nil,
+		),
+		inFlight: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "in_flight"),
+			"Number of requests being served.",
+			[]string{"pattern"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (p *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.requests
+	ch <- p.errors
+	ch <- p.deadlineMisses
+	ch <- p.latency
+	ch <- p.inFlight
+}
+
+// Collect implements prometheus.Collector.
+// Failures recorded without a category are reported as "other", so the
+// errors_total series always sum to the number of failed requests.
+func (p *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
+	c := p.collector
+	snap := c.snapshot(false)
+
+	ch <- prometheus.MustNewConstMetric(p.requests, prometheus.CounterValue,
+		float64(c.totalRequests.Load()), p.pattern)
+
+	errorsByCategory := snap.errorsByCategory
+	var categorised int64
+	for _, count := range errorsByCategory {
+		categorised += count
+	}
+	if uncategorised := c.errorRequests.Load() - categorised; uncategorised > 0 {
+		errorsByCategory[simulator.CategoryOther] += uncategorised
+	}
+	for _, category := range sortedKeys(errorsByCategory) {
+		ch <- prometheus.MustNewConstMetric(p.errors, prometheus.CounterValue,
+			float64(errorsByCategory[category]), p.pattern, category)
+	}
+
+	ch <- prometheus.MustNewConstMetric(p.deadlineMisses, prometheus.CounterValue,
+		float64(c.deadlineMisses.Load()), p.pattern)
+
+	ch <- prometheus.MustNewConstHistogram(p.latency, uint64(snap.count),
+		snap.sum.Seconds(), snap.cumulativeBuckets(), p.pattern)
+
+	ch <- prometheus.MustNewConstMetric(p.inFlight, prometheus.GaugeValue,
+		float64(c.inFlight.Load()), p.pattern)
+}
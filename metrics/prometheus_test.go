@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPrometheusCollectorErrors(t *testing.T) {
+	c := NewCollector()
+	c.RecordRequestWithError(10*time.Millisecond, simulator.ErrPatientNotFound)
+	c.RecordRequest(10*time.Millisecond, false) // no category recorded
+	c.RecordRequest(10*time.Millisecond, true)
+
+	expected := `
+# HELP healthcare_api_errors_total Number of failed requests by error category.
+# TYPE healthcare_api_errors_total counter
+healthcare_api_errors_total{category="not_found",pattern="naive"} 1
+healthcare_api_errors_total{category="other",pattern="naive"} 1
+# HELP healthcare_api_requests_total Total number of requests.
+# TYPE healthcare_api_requests_total counter
+healthcare_api_requests_total{pattern="naive"} 3
+`
+	p := NewPrometheusCollector(c, "healthcare_api", "naive")
+	err := testutil.CollectAndCompare(p, strings.NewReader(expected),
+		"healthcare_api_errors_total", "healthcare_api_requests_total")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPrometheusCollectorHistogram(t *testing.T) {
+	c := NewCollector(WithLatencyBuckets(100*time.Millisecond, 10*time.Millisecond, time.Second))
+	for _, latency := range []time.Duration{
+		5 * time.Millisecond,
+		50 * time.Millisecond,
+		500 * time.Millisecond,
+		5 * time.Second,
+	} {
+		c.RecordRequest(latency, true)
+	}
+
+	// Prometheus buckets are cumulative; the 5s outlier only shows in +Inf
+	expected := `
+# HELP healthcare_api_latency_seconds Request latency in seconds.
+# TYPE healthcare_api_latency_seconds histogram
+healthcare_api_latency_seconds_bucket{pattern="naive",le="0.01"} 1
+healthcare_api_latency_seconds_bucket{pattern="naive",le="0.1"} 2
+healthcare_api_latency_seconds_bucket{pattern="naive",le="1"} 3
+healthcare_api_latency_seconds_bucket{pattern="naive",le="+Inf"} 4
+healthcare_api_latency_seconds_sum{pattern="naive"} 5.555
+healthcare_api_latency_seconds_count{pattern="naive"} 4
+`
+	p := NewPrometheusCollector(c, "healthcare_api", "naive")
+	err := testutil.CollectAndCompare(p, strings.NewReader(expected), "healthcare_api_latency_seconds")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPrometheusCollectorInFlight(t *testing.T) {
+	c := NewCollector()
+	c.IncInFlight()
+	c.IncInFlight()
+	c.DecInFlight()
+
+	expected := `
+# HELP healthcare_api_in_flight Number of requests being served.
+# TYPE healthcare_api_in_flight gauge
+healthcare_api_in_flight{pattern="naive"} 1
+`
+	p := NewPrometheusCollector(c, "healthcare_api", "naive")
+	if err := testutil.CollectAndCompare(p, strings.NewReader(expected), "healthcare_api_in_flight"); err != nil {
+		t.Error(err)
+	}
+
+	// Reset clears the totals but not requests still being served
+	c.Reset()
+	if inFlight := c.GetStats().InFlight; inFlight != 1 {
+		t.Errorf("InFlight after Reset = %d, want 1", inFlight)
+	}
+	c.DecInFlight()
+	if inFlight := c.GetStats().InFlight; inFlight != 0 {
+		t.Errorf("InFlight = %d, want 0", inFlight)
+	}
+}
+
+func TestPrometheusCollectorDeadlineMisses(t *testing.T) {
+	c := NewCollector()
+	c.RecordRequestWithError(time.Millisecond, context.DeadlineExceeded)
+	c.RecordRequestWithError(time.Millisecond, fmt.Errorf("query: %w", context.DeadlineExceeded))
+	c.RecordRequestWithError(time.Millisecond, context.Canceled)
+	c.RecordRequestWithError(time.Millisecond, nil)
+
+	expected := `
+# HELP healthcare_api_deadline_misses_total Number of requests that missed their deadline.
+# TYPE healthcare_api_deadline_misses_total counter
+healthcare_api_deadline_misses_total{pattern="workerpool"} 2
+`
+	p := NewPrometheusCollector(c, "healthcare_api", "workerpool")
+	if err := testutil.CollectAndCompare(p, strings.NewReader(expected), "healthcare_api_deadline_misses_total"); err != nil {
+		t.Error(err)
+	}
+
+	merged := NewCollector()
+	merged.Merge(c)
+	if misses := merged.GetStats().DeadlineMisses; misses != 2 {
+		t.Errorf("DeadlineMisses after Merge = %d, want 2", misses)
+	}
+	c.Reset()
+	if misses := c.GetStats().DeadlineMisses; misses != 0 {
+		t.Errorf("DeadlineMisses after Reset = %d, want 0", misses)
+	}
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// pushTimeout bounds how long PushPrometheus waits for the Pushgateway.
+const pushTimeout = 10 * time.Second
+
+// PushPrometheus pushes c's current metrics to a Prometheus Pushgateway,
+// for runs too short to be scraped. gatewayURL is the gateway's base
+// URL, such as http://localhost:9091.
+//
+// The metrics are those of the server's /metrics endpoint (see
+// PrometheusCollector), with job as the pattern label; push each pattern under its own job, since a push replaces
+// everything pushed earlier for the same job.
+func (c *Collector) PushPrometheus(gatewayURL, job string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+
+	err := push.New(gatewayURL, job).
+		Collector(NewPrometheusCollector(c, "healthcare_api", job)).
+		PushContext(ctx)
+	if err != nil {
+		return fmt.Errorf("pushing metrics for %s to %s: %w", job, gatewayURL, err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPushPrometheus(t *testing.T) {
+	var (
+		method, path string
+		body         []byte
+	)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	c := NewCollector()
+	c.RecordRequest(10*time.Millisecond, true)
+	c.RecordRequest(20*time.Millisecond, false)
+
+	if err := c.PushPrometheus(gateway.URL, "workerpool"); err != nil {
+		t.Fatalf("PushPrometheus: %v", err)
+	}
+
+	if method != http.MethodPut || path != "/metrics/job/workerpool" {
+		t.Errorf("pushed with %s %s, want PUT /metrics/job/workerpool", method, path)
+	}
+	for _, name := range []string{
+		"healthcare_api_requests_total",
+		"healthcare_api_errors_total",
+		"healthcare_api_latency_seconds",
+		"workerpool",
+	} {
+		if !bytes.Contains(body, []byte(name)) {
+			t.Errorf("pushed body is missing %q", name)
+		}
+	}
+}
+
+func TestPushPrometheusReportsFailure(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad push", http.StatusBadRequest)
+	}))
+	defer gateway.Close()
+
+	if err := NewCollector().PushPrometheus(gateway.URL, "naive"); err == nil {
+		t.Error("expected an error when the gateway rejects the push")
+	}
+
+	// An unreachable gateway is an error, not a hang or a panic
+	gateway.Close()
+	if err := NewCollector().PushPrometheus(gateway.URL, "naive"); err == nil {
+		t.Error("expected an error when the gateway is down")
+	}
+}
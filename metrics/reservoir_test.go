@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordUniform records latencies of 1µs to n µs, in increasing order so
+// that a reservoir favouring early or late latencies would show it.
+func recordUniform(c *Collector, n int) {
+	for i := 1; i <= n; i++ {
+		c.RecordRequest(time.Duration(i)*time.Microsecond, true)
+	}
+}
+
+func TestReservoirStaysAtCap(t *testing.T) {
+	const size = 1000
+
+	c := NewReservoirCollector(size)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recordUniform(c, 20000)
+		}()
+	}
+	wg.Wait()
+
+	kept := len(c.snapshot(true).latencies)
+	if kept > size {
+		t.Errorf("reservoir kept %d latencies, want at most %d", kept, size)
+	}
+
+	// Everything but the percentiles still covers every request
+	stats := c.GetStats()
+	if stats.TotalRequests != 160000 {
+		t.Errorf("recorded %d requests, want 160000", stats.TotalRequests)
+	}
+	if stats.LatencySamples != int64(kept) {
+		t.Errorf("LatencySamples = %d, want the %d latencies kept", stats.LatencySamples, kept)
+	}
+	if stats.MinLatency != 0.001 || stats.MaxLatency != 20 {
+		t.Errorf("min/max = %v/%vms, want the exact 0.001/20ms", stats.MinLatency, stats.MaxLatency)
+	}
+
+	c.Reset()
+	recordUniform(c, 5000)
+	if kept := len(c.snapshot(true).latencies); kept > size {
+		t.Errorf("after Reset, reservoir kept %d latencies, want at most %d", kept, size)
+	}
+}
+
+// TestReservoirPercentileReliability checks reliability is judged on the
+// latencies a reservoir kept rather than on every request recorded.
+func TestReservoirPercentileReliability(t *testing.T) {
+	c := NewReservoirCollector(50)
+	recordUniform(c, 5000)
+	stats := c.GetStats()
+
+	if stats.LatencySamples > 50 {
+		t.Errorf("LatencySamples = %d, want at most the 50 kept", stats.LatencySamples)
+	}
+	if stats.HasReliablePercentiles(99.9) {
+		t.Errorf("P99.9 from %d kept latencies reported reliable", stats.LatencySamples)
+	}
+}
+
+// TestReservoirPercentilesNearExact samples a uniform distribution whose
+// percentiles are known and checks the estimates against them, within
+// about four standard errors for a sample of 10000.
+func TestReservoirPercentilesNearExact(t *testing.T) {
+	const (
+		n    = 200000
+		size = 10000
+	)
+
+	c := NewReservoirCollector(size)
+	recordUniform(c, n)
+	stats := c.GetStats()
+
+	for _, tt := range []struct {
+		name string
+		p    float64
+		got  float64
+	}{
+		{"median", 50, stats.MedianLatency},
+		{"p95", 95, stats.P95Latency},
+		{"p99", 99, stats.P99Latency},
+	} {
+		exact := n * tt.p / 100 / 1000 // ms
+		tolerance := 4 * math.Sqrt(tt.p/100*(1-tt.p/100)/size) * n / 1000
+		if math.Abs(tt.got-exact) > tolerance {
+			t.Errorf("%s = %.2fms, want %.2f ± %.2fms", tt.name, tt.got, exact, tolerance)
+		}
+	}
+}
+
+// TestMergeReservoirCollector merges a sampled collector into one that
+// keeps everything and checks each kept latency counts as its share.
+func TestMergeReservoirCollector(t *testing.T) {
+	const n = 50000
+
+	sampled := NewReservoirCollector(5000)
+	recordUniform(sampled, n)
+	sampled.Stop()
+
+	merged := NewCollector()
+	merged.Merge(sampled)
+	stats := merged.GetStats()
+
+	if stats.TotalRequests != n || stats.LatencySamples != n {
+		t.Errorf("merged %d requests with %d latency samples, want %d of each", stats.TotalRequests, stats.LatencySamples, n)
+	}
+	if exact := float64(n) / 2 / 1000; math.Abs(stats.MedianLatency-exact) > exact*0.05 {
+		t.Errorf("merged median = %.2fms, want about %.2fms", stats.MedianLatency, exact)
+	}
+}
@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// shardsPerProc sets how many latency shards a Collector keeps per
+// GOMAXPROCS. More shards than processors keeps the chance of two
+// recorders picking the same shard at once low.
+const shardsPerProc = 4
+
+// initialLatencyCapacity is the number of latencies a Collector has room
+// for before its buffers need to grow, split evenly across the shards.
+const initialLatencyCapacity = 10000
+
+// latencyShard holds the latencies and error categories recorded by a
+// random subset of callers. Each shard has its own lock, so concurrent
+// recorders rarely wait on one another.
+type latencyShard struct {
+	mu sync.Mutex
+
+	latencies        []time.Duration
+	reservoir        int // Most latencies kept, 0 for all (see NewReservoirCollector)
+	bucketCounts     []int64
+	errorsByCategory map[string]int64
+
+	// Running aggregates, so counts, mean, min and max never need the slice
+	count         int64
+	sum, min, max time.Duration
+
+	_ [64]byte // Keeps neighbouring shards' locks off the same cache line
+}
+
+// newShards allocates the shards for a Collector with numBuckets histogram
+// buckets, splitting the pre-allocated latency capacity between them. With
+// a reservoirSize, the shards split that instead and keep no more; there
+// are never more shards than reservoir slots.
+func newShards(numBuckets, reservoirSize int) []latencyShard {
+	n := runtime.GOMAXPROCS(0) * shardsPerProc
+	if reservoirSize > 0 {
+		n = min(n, reservoirSize)
+	}
+
+	shards := make([]latencyShard, n)
+	for i := range shards {
+		shards[i].reservoir = reservoirSize / n
+		shards[i].reset(numBuckets, shards[i].capacity(n))
+	}
+	return shards
+}
+
+// capacity returns the number of latencies to pre-allocate room for in
+// one of numShards shards.
+func (s *latencyShard) capacity(numShards int) int {
+	if s.reservoir > 0 {
+		return s.reservoir
+	}
+	return initialLatencyCapacity / numShards
+}
+
+// shard picks a shard at random. The top-level math/rand functions are
+// lock-free unless rand.Seed has been called.
+func (c *Collector) shard() *latencyShard {
+	return &c.shards[rand.Intn(len(c.shards))]
+}
+
+// reset empties the shard. The caller must hold s.mu.
+func (s *latencyShard) reset(numBuckets, capacity int) {
+	s.latencies = make([]time.Duration, 0, capacity)
+	s.bucketCounts = make([]int64, numBuckets)
+	s.errorsByCategory = make(map[string]int64)
+	s.count = 0
+	s.sum, s.min, s.max = 0, 0, 0
+}
+
+// record adds a latency to the raw samples, the running aggregates and the
+// histogram with the given bucket bounds. The caller must hold s.mu.
+func (s *latencyShard) record(latency time.Duration, bounds []time.Duration) {
+	if s.reservoir == 0 || len(s.latencies) < s.reservoir {
+		s.latencies = append(s.latencies, latency)
+	} else if i := rand.Int63n(s.count + 1); i < int64(s.reservoir) {
+		// Algorithm R: the nth latency replaces a random sample with
+		// probability reservoir/n, which keeps every latency seen so far
+		// equally likely to be in the reservoir
+		s.latencies[i] = latency
+	}
+
+	if s.count == 0 || latency < s.min {
+		s.min = latency
+	}
+	if latency > s.max {
+		s.max = latency
+	}
+	s.count++
+	s.sum += latency
+
+	i := sort.Search(len(bounds), func(i int) bool {
+		return latency <= bounds[i]
+	})
+	s.bucketCounts[i]++
+}
+
+// latencySnapshot is the combined contents of every shard.
+type latencySnapshot struct {
+	bucketBounds     []time.Duration
+	bucketCounts     []int64
+	errorsByCategory map[string]int64
+
+	count         int64
+	sum, min, max time.Duration
+
+	retained  int64           // Latencies kept, fewer than count under a reservoir
+	latencies []time.Duration // Only filled in when requested
+}
+
+// snapshot combines every shard, copying the raw latencies only when
+// withLatencies is set. Shards are locked one at a time, so a snapshot
+// taken during recording may miss requests that land in an
+// already-visited shard.
+func (c *Collector) snapshot(withLatencies bool) latencySnapshot {
+	snap := latencySnapshot{
+		bucketBounds:     c.bucketBounds,
+		bucketCounts:     make([]int64, len(c.bucketBounds)+1),
+		errorsByCategory: make(map[string]int64),
+	}
+
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		if s.count > 0 {
+			if snap.count == 0 || s.min < snap.min {
+				snap.min = s.min
+			}
+			if s.max > snap.max {
+				snap.max = s.max
+			}
+		}
+		snap.count += s.count
+		snap.sum += s.sum
+		for j, count := range s.bucketCounts {
+			snap.bucketCounts[j] += count
+		}
+		for category, count := range s.errorsByCategory {
+			snap.errorsByCategory[category] += count
+		}
+		snap.retained += int64(len(s.latencies))
+		if withLatencies {
+			snap.latencies = append(snap.latencies, s.latencies...)
+		}
+		s.mu.Unlock()
+	}
+
+	return snap
+}
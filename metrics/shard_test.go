@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// TestConcurrentRecordingMergesExactly records from many goroutines while
+// others read, then checks the merged shards account for every request.
+// Run with -race.
+func TestConcurrentRecordingMergesExactly(t *testing.T) {
+	const (
+		goroutines = 64
+		perG       = 500
+	)
+
+	c := NewCollector()
+	var recorders, readers sync.WaitGroup
+	stop := make(chan struct{})
+
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					c.GetStats()
+					c.GetQuickStats()
+					c.ExportPrometheus("test", "naive")
+				}
+			}
+		}()
+	}
+
+	for g := 0; g < goroutines; g++ {
+		recorders.Add(1)
+		go func(g int) {
+			defer recorders.Done()
+			for i := 0; i < perG; i++ {
+				latency := time.Duration(g*perG+i+1) * time.Microsecond
+				switch i % 4 {
+				case 0:
+					c.RecordRequestWithError(latency, simulator.ErrConnectionTimeout)
+				case 1:
+					c.RecordRequestWithError(latency, errors.New("boom"))
+				case 2:
+					c.RecordRejection()
+				default:
+					c.RecordRequest(latency, true)
+				}
+			}
+		}(g)
+	}
+
+	recorders.Wait()
+	close(stop)
+	readers.Wait()
+	c.Stop()
+
+	const total = goroutines * perG
+	stats := c.GetStats()
+	if stats.TotalRequests != total {
+		t.Errorf("total = %d, want %d", stats.TotalRequests, total)
+	}
+	if stats.RejectedRequests != total/4 || stats.ErrorRequests != total/2 || stats.SuccessRequests != total/4 {
+		t.Errorf("success/error/rejected = %d/%d/%d, want %d/%d/%d",
+			stats.SuccessRequests, stats.ErrorRequests, stats.RejectedRequests, total/4, total/2, total/4)
+	}
+	if got := stats.ErrorsByCategory[simulator.CategoryTimeout]; got != total/4 {
+		t.Errorf("timeouts = %d, want %d", got, total/4)
+	}
+	if got := stats.ErrorsByCategory[simulator.CategoryOther]; got != total/4 {
+		t.Errorf("other errors = %d, want %d", got, total/4)
+	}
+
+	var bucketed int64
+	for _, bucket := range stats.LatencyBuckets {
+		bucketed += bucket.Count
+	}
+	if want := int64(total - total/4); bucketed != want {
+		t.Errorf("histogram holds %d latencies, want %d", bucketed, want)
+	}
+
+	// Latencies run from 1µs up to total µs, skipping rejections (i%4 == 2)
+	if stats.MinLatency != 0.001 {
+		t.Errorf("min = %vms, want 0.001", stats.MinLatency)
+	}
+	if want := float64(total) / 1000; stats.MaxLatency != want {
+		t.Errorf("max = %vms, want %v", stats.MaxLatency, want)
+	}
+}
+
+// BenchmarkRecordRequestParallel measures recording from every P at once.
+// Compare with BenchmarkRecordRequestParallelSingleMutex, which records the
+// the same data behind one lock, as the Collector used to. Contention only
+// shows on a multi-core machine, e.g. with -cpu=1,4,16.
+func BenchmarkRecordRequestParallel(b *testing.B) {
+	c := NewCollector()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.RecordRequest(time.Millisecond, true)
+		}
+	})
+}
+
+func BenchmarkRecordRequestParallelSingleMutex(b *testing.B) {
+	var (
+		single         latencyShard
+		total, success int64
+	)
+	single.reset(len(DefaultLatencyBuckets)+1, initialLatencyCapacity)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			single.mu.Lock()
+			total++
+			success++
+			single.record(time.Millisecond, DefaultLatencyBuckets)
+			single.mu.Unlock()
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import "time"
+
+// TheoreticalModel describes the ideal performance of a pattern that does
+// nothing but wait on the database.
+//
+// With N requests allowed in flight and a mean query latency L, no pattern
+// can exceed N / L requests per second, and no single request can complete
+// faster than the minimum query latency. Comparing measured results against
+// this ceiling separates real scheduling, queueing and allocation overhead
+// from the unavoidable cost of the simulated database.
+type TheoreticalModel struct {
+	Parallelism int           // Requests that can be in flight at once
+	MinLatency  time.Duration // Fastest possible query
+	MeanLatency time.Duration // Average query latency
+}
+
+// NewTheoreticalModel builds a model for a uniform latency distribution
+// between minLatency and maxLatency.
+func NewTheoreticalModel(parallelism int, minLatency, maxLatency time.Duration) TheoreticalModel {
+	return TheoreticalModel{
+		Parallelism: parallelism,
+		MinLatency:  minLatency,
+		MeanLatency: (minLatency + maxLatency) / 2,
+	}
+}
+
+// MaxThroughput returns the theoretical maximum requests per second.
+func (m TheoreticalModel) MaxThroughput() float64 {
+	if m.MeanLatency <= 0 {
+		return 0
+	}
+	return float64(m.Parallelism) / m.MeanLatency.Seconds()
+}
+
+// MinLatencyMs returns the theoretical minimum request latency in milliseconds.
+func (m TheoreticalModel) MinLatencyMs() float64 {
+	return float64(m.MinLatency) / float64(time.Millisecond)
+}
+
+// Efficiency returns achieved throughput as a percentage of the theoretical
+// maximum. 100% means the pattern added no measurable overhead.
+func (m TheoreticalModel) Efficiency(achievedRPS float64) float64 {
+	ceiling := m.MaxThroughput()
+	if ceiling <= 0 {
+		return 0
+	}
+	return achievedRPS / ceiling * 100
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTheoreticalModel(t *testing.T) {
+	// 20 workers against a 50-100ms database: mean 75ms, so 20 / 0.075s.
+	m := NewTheoreticalModel(20, 50*time.Millisecond, 100*time.Millisecond)
+
+	if m.MeanLatency != 75*time.Millisecond {
+		t.Errorf("MeanLatency = %v, want 75ms", m.MeanLatency)
+	}
+	if got, want := m.MaxThroughput(), 20/0.075; math.Abs(got-want) > 1e-9 {
+		t.Errorf("MaxThroughput() = %f, want %f", got, want)
+	}
+	if got := m.MinLatencyMs(); got != 50 {
+		t.Errorf("MinLatencyMs() = %f, want 50", got)
+	}
+}
+
+func TestTheoreticalModelEfficiency(t *testing.T) {
+	// 10 in flight at a constant 100ms: 100 req/s ceiling.
+	m := NewTheoreticalModel(10, 100*time.Millisecond, 100*time.Millisecond)
+
+	tests := []struct {
+		achieved float64
+		want     float64
+	}{
+		{100, 100},
+		{50, 50},
+		{80, 80},
+		{0, 0},
+	}
+
+	for _, tc := range tests {
+		if got := m.Efficiency(tc.achieved); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("Efficiency(%v) = %f, want %f", tc.achieved, got, tc.want)
+		}
+	}
+}
+
+func TestTheoreticalModelZeroLatency(t *testing.T) {
+	m := NewTheoreticalModel(10, 0, 0)
+
+	if got := m.MaxThroughput(); got != 0 {
+		t.Errorf("MaxThroughput() = %f, want 0", got)
+	}
+	if got := m.Efficiency(100); got != 0 {
+		t.Errorf("Efficiency() = %f, want 0", got)
+	}
+}
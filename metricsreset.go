@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/clock"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
+)
+
+// adminMetricsResetHandler returns a handler for POST /admin/metrics/reset,
+// which clears the collector so /metrics starts a fresh measurement period.
+// The response holds the statistics of the period just ended; requests that
+// finish while the reset runs may fall into neither period.
+func adminMetricsResetHandler(c *metrics.Collector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		previous := c.GetStats()
+		c.Reset()
+
+		patterns.WriteJSON(w, r, http.StatusOK, map[string]interface{}{
+			"reset_at": time.Now(),
+			"previous": previous,
+		})
+	}
+}
+
+// startMetricsWindow resets c every window, so that on a long-running
+// server /metrics describes recent traffic rather than everything since
+// startup. Call the returned function to stop.
+func startMetricsWindow(c *metrics.Collector, clk clock.Clock, window time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-clk.After(window):
+				c.Reset()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/clock"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+func TestAdminMetricsReset(t *testing.T) {
+	config := validConfig()
+	config.APIKeys = []string{"admin-key"}
+
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := metrics.NewCollector(metrics.WithClock(fake))
+	mux := newServeMux(config, http.NotFoundHandler(), simulator.NewDatabase(1, 2, 0), c, nil)
+
+	for i := 0; i < 3; i++ {
+		c.RecordRequest(10*time.Millisecond, i > 0)
+	}
+	fake.Advance(10 * time.Second)
+
+	post := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/metrics/reset", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post(""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("reset without a key: status = %d, want 401", rec.Code)
+	}
+	if got := c.GetStats().TotalRequests; got != 3 {
+		t.Fatalf("unauthorised reset cleared the metrics: total = %d", got)
+	}
+
+	rec := post("Bearer admin-key")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	var body struct {
+		Previous metrics.Stats `json:"previous"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Previous.TotalRequests != 3 || body.Previous.Duration != 10 {
+		t.Errorf("previous = %d requests over %vs, want 3 over 10s", body.Previous.TotalRequests, body.Previous.Duration)
+	}
+
+	stats := c.GetStats()
+	if stats.TotalRequests != 0 || stats.SuccessRequests != 0 || stats.ErrorRequests != 0 {
+		t.Errorf("counts after reset = %d/%d/%d, want all zero", stats.TotalRequests, stats.SuccessRequests, stats.ErrorRequests)
+	}
+	// The measurement period now starts at the reset, not at startup
+	if stats.Duration != 0 {
+		t.Errorf("duration after reset = %vs, want 0", stats.Duration)
+	}
+	fake.Advance(time.Second)
+	if got := c.GetStats().Duration; got != 1 {
+		t.Errorf("duration a second after reset = %vs, want 1", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/metrics/reset", nil)
+	req.Header.Set("Authorization", "Bearer admin-key")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want 405", rec.Code)
+	}
+}
+
+func TestMetricsWindowResetsOnInterval(t *testing.T) {
+	const window = time.Minute
+
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := metrics.NewCollector(metrics.WithClock(fake))
+	stop := startMetricsWindow(c, fake, window)
+	defer stop()
+
+	for round := 0; round < 2; round++ {
+		c.RecordRequest(10*time.Millisecond, true)
+
+		fake.BlockUntil(1)
+		fake.Advance(window / 2)
+		if got := c.GetStats().TotalRequests; got != 1 {
+			t.Fatalf("round %d: total = %d halfway through the window, want 1", round, got)
+		}
+
+		fake.Advance(window / 2)
+		// The loop resets before waiting on the clock again
+		fake.BlockUntil(1)
+		if stats := c.GetStats(); stats.TotalRequests != 0 || stats.Duration != 0 {
+			t.Fatalf("round %d: after the window, total = %d over %vs, want 0 over 0s", round, stats.TotalRequests, stats.Duration)
+		}
+	}
+}
+
+func TestValidateConfigMetricsWindow(t *testing.T) {
+	config := validConfig()
+	config.MetricsWindow = -time.Second
+	if err := validateConfig(config); err == nil {
+		t.Error("expected an error for a negative -metrics-window")
+	}
+
+	config.MetricsWindow = time.Minute
+	if err := validateConfig(config); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
+)
+
+// bufferedResponseWriter captures a handler's response so middleware can
+// inspect or rewrite it before anything reaches the client.
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{header: make(http.Header), status: http.StatusOK}
+}
+
+func (b *bufferedResponseWriter) Header() http.Header { return b.header }
+
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) { return b.body.Write(p) }
+
+func (b *bufferedResponseWriter) WriteHeader(status int) { b.status = status }
+
+// flush copies the captured headers, status and body to w.
+func (b *bufferedResponseWriter) flush(w http.ResponseWriter, body []byte) {
+	for key, values := range b.header {
+		w.Header()[key] = values
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(b.status)
+	w.Write(body)
+}
+
+// deidentifyMiddleware rewrites patient responses into their de-identified
+// form (see models.Patient.Deidentify) so that captured benchmark traffic
+// can be shared without exposing PHI. Anything that isn't a JSON patient
+// response (plain-text errors, for instance) passes through unchanged. The
+// Accept header is dropped before the handler sees it, since only JSON can
+// be rewritten; a protobuf response would otherwise carry PHI straight out.
+// A fields projection is refused, since its names are Patient's rather than
+// the de-identified record's.
+func deidentifyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("fields") {
+			http.Error(w, "fields is not supported with de-identified responses", http.StatusBadRequest)
+			return
+		}
+
+		r = r.Clone(r.Context())
+		r.Header.Del("Accept")
+
+		buf := newBufferedResponseWriter()
+		next.ServeHTTP(buf, r)
+
+		body := buf.body.Bytes()
+
+		var response models.PatientResponse
+		if err := json.Unmarshal(body, &response); err == nil {
+			if rewritten, err := patterns.MarshalJSON(r, models.NewDeidentifiedResponse(&response)); err == nil {
+				body = rewritten
+			}
+		}
+
+		buf.flush(w, body)
+	})
+}
+
+// deidentifyBatchMiddleware is deidentifyMiddleware for the batch endpoint,
+// rewriting every patient in a batch response.
+func deidentifyBatchMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := newBufferedResponseWriter()
+		next.ServeHTTP(buf, r)
+
+		body := buf.body.Bytes()
+
+		var response models.BatchResponse
+		if err := json.Unmarshal(body, &response); err == nil {
+			if rewritten, err := patterns.MarshalJSON(r, models.NewDeidentifiedBatchResponse(&response)); err == nil {
+				body = rewritten
+			}
+		}
+
+		buf.flush(w, body)
+	})
+}
+
+// deidentifySearchMiddleware is deidentifyMiddleware for the search
+// endpoint, rewriting every patient in a search response.
+func deidentifySearchMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := newBufferedResponseWriter()
+		next.ServeHTTP(buf, r)
+
+		body := buf.body.Bytes()
+
+		var response models.SearchResponse
+		if err := json.Unmarshal(body, &response); err == nil {
+			if rewritten, err := patterns.MarshalJSON(r, models.NewDeidentifiedSearchResponse(&response)); err == nil {
+				body = rewritten
+			}
+		}
+
+		buf.flush(w, body)
+	})
+}
+
+// deidentifyStreamWriter rewrites an NDJSON stream of patient responses one
+// line at a time, so each result still reaches the client as it completes.
+type deidentifyStreamWriter struct {
+	http.ResponseWriter
+	pending []byte // Bytes after the last complete line
+}
+
+func (d *deidentifyStreamWriter) Write(p []byte) (int, error) {
+	d.pending = append(d.pending, p...)
+	for {
+		i := bytes.IndexByte(d.pending, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := d.pending[:i+1]
+		d.pending = d.pending[i+1:]
+		if _, err := d.ResponseWriter.Write(deidentifyLine(line)); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// Flush sends the rewritten lines so far. A partial line stays pending.
+func (d *deidentifyStreamWriter) Flush() {
+	http.NewResponseController(d.ResponseWriter).Flush()
+}
+
+// deidentifyLine rewrites one JSON patient response, passing anything else
+// through unchanged.
+func deidentifyLine(line []byte) []byte {
+	var response models.PatientResponse
+	if err := json.Unmarshal(line, &response); err != nil {
+		return line
+	}
+	rewritten, err := patterns.MarshalJSON(nil, models.NewDeidentifiedResponse(&response))
+	if err != nil {
+		return line
+	}
+	return rewritten
+}
+
+// deidentifyStreamMiddleware is deidentifyMiddleware for the stream
+// endpoint. Unlike the others it cannot buffer the whole response without
+// defeating the stream, so it rewrites line by line as the handler writes.
+func deidentifyStreamMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dw := &deidentifyStreamWriter{ResponseWriter: w}
+		next.ServeHTTP(dw, r)
+
+		if len(dw.pending) > 0 {
+			w.Write(deidentifyLine(dw.pending))
+		}
+	})
+}
+
+// gzipWriters recycles gzip writers, whose compression state is large
+// enough to matter at benchmark request rates.
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(io.Discard) },
+}
+
+// gzipResponseWriter compresses the response body on its way to the
+// client. The gzip stream only starts with the first byte of body, so a
+// response without one (a 304, say) goes out empty and uncompressed.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+	compress    bool // Whether the status allows a body to compress
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+
+	if status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified {
+		g.compress = true
+		h := g.Header()
+		h.Del("Content-Length") // Of the uncompressed body
+		h.Set("Content-Encoding", "gzip")
+	}
+	g.ResponseWriter.WriteHeader(status)
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if !g.compress {
+		return g.ResponseWriter.Write(p)
+	}
+	if g.gz == nil {
+		g.gz = gzipWriters.Get().(*gzip.Writer)
+		g.gz.Reset(g.ResponseWriter)
+	}
+	return g.gz.Write(p)
+}
+
+// Flush sends everything compressed so far, so streamed lines still reach
+// the client as they are written.
+func (g *gzipResponseWriter) Flush() {
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	http.NewResponseController(g.ResponseWriter).Flush()
+}
+
+// close finishes the gzip stream and returns the writer to the pool.
+func (g *gzipResponseWriter) close() {
+	if g.gz == nil {
+		return
+	}
+	g.gz.Close()
+	gzipWriters.Put(g.gz)
+	g.gz = nil
+}
+
+// acceptsGzip reports whether r's Accept-Encoding allows gzip, honouring
+// q=0 as a refusal.
+func acceptsGzip(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(accept, ",") {
+			name, params, _ := strings.Cut(coding, ";")
+			if !strings.EqualFold(strings.TrimSpace(name), "gzip") {
+				continue
+			}
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+					continue
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// bodyLimitMiddleware reads the request body before next runs, answering
+// 413 if it is over maxBytes and 408 if the client takes longer than
+// timeout to send it. Only the bounded, fully received body reaches next,
+// so a huge upload can't exhaust memory and a trickling one can't hold a
+// worker. A zero timeout leaves the server's ReadTimeout in charge, as
+// does a ResponseWriter that doesn't support read deadlines.
+func bodyLimitMiddleware(maxBytes int64, timeout time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		deadline := timeout > 0 && rc.SetReadDeadline(time.Now().Add(timeout)) == nil
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if deadline && err == nil {
+			// Lifted again once the body is in, or net/http's background
+			// read would hit it and cancel a slow handler's context. On a
+			// failed read it stays, so the server's drain of the unread
+			// body gives up at once rather than waiting on the client.
+			rc.SetReadDeadline(time.Time{})
+		}
+
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			http.Error(w, fmt.Sprintf("request body over %d bytes", maxBytes), http.StatusRequestEntityTooLarge)
+			return
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			http.Error(w, fmt.Sprintf("request body not received within %v", timeout), http.StatusRequestTimeout)
+			return
+		case err != nil:
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestTimeoutMiddleware gives each request a deadline of timeout before
+// next runs, so queue waits and database calls give up in time for a clean
+// 504 rather than the server's write timeout cutting the connection. Each
+// pattern answers work cut short by its context with its own status (408,
+// 504, or 500 for a bare context error); once the deadline has passed those
+// all become 504. A zero timeout leaves requests without a deadline.
+func requestTimeoutMiddleware(timeout time.Duration, next http.Handler) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		tw := &timeoutResponseWriter{ResponseWriter: w, ctx: ctx, timeout: timeout}
+		next.ServeHTTP(tw, r.WithContext(ctx))
+	})
+}
+
+// timeoutResponseWriter swaps a timed-out request's error response for a
+// 504; see requestTimeoutMiddleware.
+type timeoutResponseWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	timeout     time.Duration
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (t *timeoutResponseWriter) WriteHeader(status int) {
+	if t.wroteHeader {
+		return
+	}
+	t.wroteHeader = true
+
+	switch status {
+	case http.StatusRequestTimeout, http.StatusInternalServerError, http.StatusGatewayTimeout:
+		if errors.Is(t.ctx.Err(), context.DeadlineExceeded) {
+			t.timedOut = true
+			t.Header().Del("Retry-After")
+			http.Error(t.ResponseWriter, fmt.Sprintf("request not served within %v", t.timeout), http.StatusGatewayTimeout)
+			return
+		}
+	}
+	t.ResponseWriter.WriteHeader(status)
+}
+
+// Write discards the body of a response WriteHeader replaced.
+func (t *timeoutResponseWriter) Write(p []byte) (int, error) {
+	if !t.wroteHeader {
+		t.WriteHeader(http.StatusOK)
+	}
+	if t.timedOut {
+		return len(p), nil
+	}
+	return t.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (t *timeoutResponseWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// compressMiddleware gzips responses for clients that accept it (-compress).
+// It sits outside de-identification, which rewrites plain JSON, and
+// flushes through to the client, so the stream endpoint still delivers
+// each line as it completes.
+func compressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// statusRecorder passes writes through while remembering the status code.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// streaming handlers can still flush through the middleware.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// metricsMiddleware records the latency and outcome of every request in c,
+// and counts it as in flight while it is served.
+// A 503 or 429 is the pattern shedding load and counts as a rejection;
+// any other 4xx or 5xx counts as a failed request. Failures after the
+// client went away are categorised as cancelled.
+func metricsMiddleware(c *metrics.Collector, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.IncInFlight()
+		defer c.DecInFlight()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+
+		if rec.status == http.StatusServiceUnavailable || rec.status == http.StatusTooManyRequests {
+			c.RecordRejection()
+			return
+		}
+		if err := r.Context().Err(); err != nil && rec.status >= http.StatusBadRequest {
+			c.RecordRequestWithError(time.Since(start), err)
+			return
+		}
+		c.RecordRequest(time.Since(start), rec.status < http.StatusBadRequest)
+	})
+}
+
+// newRequestLogger returns a logger that writes one line per request to w
+// in the given format ("json" or "text"), or nil for "off".
+func newRequestLogger(w io.Writer, format string) *slog.Logger {
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, nil))
+	case "text":
+		return slog.New(slog.NewTextHandler(w, nil))
+	default:
+		return nil
+	}
+}
+
+// loggingMiddleware logs the method, path, patient ID, status, latency and
+// request ID of every request once it completes.
+//
+// It only observes the status code on its way through, so it sees exactly
+// what the client sees and leaves the response body (including the
+// optimized handler's pooled responses) untouched.
+func loggingMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+
+		logger.LogAttrs(r.Context(), slog.LevelInfo, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("patient_id", r.URL.Query().Get("id")),
+			slog.Int("status", rec.status),
+			slog.Float64("latency_ms", float64(time.Since(start))/float64(time.Millisecond)),
+			slog.String("request_id", patterns.RequestIDFromContext(r.Context())),
+		)
+	})
+}
+
+// requestIDMiddleware gives every request an ID: the client's X-Request-ID
+// if it sent one, otherwise a freshly generated UUID. The ID is stored in
+// the request context, where the handlers pick it up for the response body,
+// and echoed in the X-Request-ID response header.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(patterns.RequestIDHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+
+		w.Header().Set(patterns.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(patterns.WithRequestID(r.Context(), id)))
+	})
+}
+
+// newRequestID returns a random (version 4) UUID.
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
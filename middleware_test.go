@@ -0,0 +1,654 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+func TestDeidentifyMiddleware(t *testing.T) {
+	patient := models.GeneratePatient("P00001")
+	patient.FirstName = "Zelda"
+	patient.MedicalRecordNumber = "MRN-1234567"
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.NewPatientResponse(patient, "req-1"))
+	})
+
+	rec := httptest.NewRecorder()
+	deidentifyMiddleware(inner).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil))
+
+	body := rec.Body.String()
+	for _, secret := range []string{"Zelda", "MRN-1234567", "first_name", "date_of_birth"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("response leaks %q: %s", secret, body)
+		}
+	}
+
+	var response models.DeidentifiedResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if response.Patient == nil || response.Patient.AgeBand == "" || response.RequestID != "req-1" {
+		t.Errorf("unexpected de-identified response: %+v", response)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+}
+
+// TestDeidentifyMiddlewareForcesJSON checks a client asking for protobuf
+// still gets a de-identified JSON response rather than the raw record.
+func TestDeidentifyMiddlewareForcesJSON(t *testing.T) {
+	db := simulator.NewDatabase(1, 2, 0)
+	handler := deidentifyMiddleware(patterns.NewNaiveHandler(db))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil)
+	req.Header.Set("Accept", patterns.ContentTypeProtobuf)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", got)
+	}
+	var response models.DeidentifiedResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || response.Patient == nil || response.Patient.AgeBand == "" {
+		t.Errorf("want a de-identified response, got %s (%v)", rec.Body.String(), err)
+	}
+}
+
+// TestDeidentifyMiddlewareRejectsFields checks a projection, which would
+// name Patient fields the de-identified record doesn't have, is a 400.
+func TestDeidentifyMiddlewareRejectsFields(t *testing.T) {
+	db := simulator.NewDatabase(1, 2, 0)
+	handler := deidentifyMiddleware(patterns.NewNaiveHandler(db))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001&fields=allergies", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "age_band") {
+		t.Errorf("got a patient response: %s", rec.Body.String())
+	}
+}
+
+func TestDeidentifyMiddlewarePassesThroughErrors(t *testing.T) {
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "patient ID required", http.StatusBadRequest)
+	})
+
+	rec := httptest.NewRecorder()
+	deidentifyMiddleware(inner).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients", nil))
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "patient ID required") {
+		t.Errorf("got %d %q, want 400 with the original message", rec.Code, rec.Body.String())
+	}
+}
+
+func TestBatchEndpointDeidentifies(t *testing.T) {
+	config := validConfig()
+	config.Deidentify = true
+	db := simulator.NewDatabase(1, 2, 0)
+	mux := newServeMux(config, http.NotFoundHandler(), db, metrics.NewCollector(), nil)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients/batch?ids=P00001,P00002&engine=fanout", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	body := rec.Body.String()
+	for _, field := range []string{"first_name", "last_name", "medical_record_number", "date_of_birth"} {
+		if strings.Contains(body, field) {
+			t.Errorf("response leaks %q: %s", field, body)
+		}
+	}
+
+	var response models.DeidentifiedBatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(response.Patients) != 2 || response.Patients[1].ID != "P00002" || response.Patients[1].AgeBand == "" {
+		t.Errorf("unexpected de-identified batch: %+v", response)
+	}
+	if response.Engine != "fanout" || response.RequestID == "" {
+		t.Errorf("engine = %q, request ID = %q; want fanout and a generated ID", response.Engine, response.RequestID)
+	}
+}
+
+func TestSearchEndpointDeidentifies(t *testing.T) {
+	config := validConfig()
+	config.Deidentify = true
+	corpus := []*models.Patient{
+		models.GeneratePatient("P00001"),
+		models.GeneratePatient("P00002"),
+	}
+	corpus[0].LastName, corpus[1].LastName = "Smith", "Smithers"
+	db := simulator.NewDatabase(1, 2, 0, simulator.WithSearchCorpus(corpus))
+	db.SetSearchLatency(1, 2)
+	mux := newServeMux(config, http.NotFoundHandler(), db, metrics.NewCollector(), nil)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients/search?last_name=Smith", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	body := rec.Body.String()
+	for _, field := range []string{"first_name", "last_name", "Smith", "medical_record_number", "date_of_birth"} {
+		if strings.Contains(body, field) {
+			t.Errorf("response leaks %q: %s", field, body)
+		}
+	}
+
+	var response models.DeidentifiedSearchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if response.Count != 2 || len(response.Patients) != 2 || response.Patients[0].AgeBand == "" {
+		t.Errorf("unexpected de-identified search: %+v", response)
+	}
+}
+
+func TestStreamEndpointDeidentifies(t *testing.T) {
+	config := validConfig()
+	config.Deidentify = true
+	db := simulator.NewDatabase(1, 2, 0)
+	var logs bytes.Buffer
+	mux := newServeMux(config, http.NotFoundHandler(), db, metrics.NewCollector(), newRequestLogger(&logs, "json"))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients/stream?ids=P00001,P00002,P00003", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	// Flushes must get through the logging and de-identification wrappers
+	if !rec.Flushed {
+		t.Error("stream was never flushed")
+	}
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3: %s", len(lines), rec.Body.String())
+	}
+	for _, line := range lines {
+		for _, field := range []string{"first_name", "last_name", "medical_record_number", "date_of_birth"} {
+			if strings.Contains(line, field) {
+				t.Errorf("line leaks %q: %s", field, line)
+			}
+		}
+
+		var response models.DeidentifiedResponse
+		if err := json.Unmarshal([]byte(line), &response); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		if !response.Success || response.Patient == nil || response.Patient.AgeBand == "" {
+			t.Errorf("unexpected de-identified line: %s", line)
+		}
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	c := metrics.NewCollector()
+	statuses := []int{http.StatusOK, http.StatusNotFound, http.StatusServiceUnavailable}
+
+	for _, status := range statuses {
+		inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		})
+		metricsMiddleware(c, inner).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/patients", nil))
+	}
+
+	stats := c.GetStats()
+	if stats.TotalRequests != 3 || stats.SuccessRequests != 1 || stats.ErrorRequests != 1 || stats.RejectedRequests != 1 {
+		t.Errorf("got total=%d success=%d error=%d rejected=%d, want 3/1/1/1",
+			stats.TotalRequests, stats.SuccessRequests, stats.ErrorRequests, stats.RejectedRequests)
+	}
+}
+
+// TestMetricsMiddlewareInFlight checks that the in-flight gauge counts
+// requests while they are served, rejected ones included, and returns to
+// zero once they have all completed.
+func TestMetricsMiddlewareInFlight(t *testing.T) {
+	const requests = 5
+
+	c := metrics.NewCollector()
+	release := make(chan struct{})
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		if r.URL.Query().Get("id") == "P00000" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	handler := metricsMiddleware(c, inner)
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path := fmt.Sprintf("/api/v1/patients?id=P%05d", i)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}(i)
+	}
+
+	for deadline := time.Now().Add(time.Second); c.GetQuickStats().InFlight != requests; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("in flight = %d, want %d", c.GetQuickStats().InFlight, requests)
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	if stats := c.GetStats(); stats.InFlight != 0 || stats.TotalRequests != requests {
+		t.Errorf("after completion: in flight = %d, total = %d; want 0, %d", stats.InFlight, stats.TotalRequests, requests)
+	}
+}
+
+func TestMetricsMiddlewareCountsClientCancellation(t *testing.T) {
+	c := metrics.NewCollector()
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		http.Error(w, "request timeout", http.StatusRequestTimeout)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // the client has already disconnected
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil).WithContext(ctx)
+	metricsMiddleware(c, inner).ServeHTTP(httptest.NewRecorder(), req)
+
+	stats := c.GetStats()
+	if stats.ErrorRequests != 1 || stats.ErrorsByCategory[simulator.CategoryCancelled] != 1 {
+		t.Errorf("errors = %d, by category = %v; want 1 cancelled", stats.ErrorRequests, stats.ErrorsByCategory)
+	}
+}
+
+func TestLoggingMiddlewareJSON(t *testing.T) {
+	var buf bytes.Buffer
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00042", nil)
+	req.Header.Set("X-Request-ID", "req-7")
+	requestIDMiddleware(loggingMiddleware(newRequestLogger(&buf, "json"), inner)).ServeHTTP(httptest.NewRecorder(), req)
+
+	var line struct {
+		Msg       string  `json:"msg"`
+		Method    string  `json:"method"`
+		Path      string  `json:"path"`
+		PatientID string  `json:"patient_id"`
+		Status    int     `json:"status"`
+		LatencyMs float64 `json:"latency_ms"`
+		RequestID string  `json:"request_id"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line is not JSON: %v\n%s", err, buf.String())
+	}
+
+	if line.Msg != "request" || line.Method != http.MethodGet || line.Path != "/api/v1/patients" ||
+		line.PatientID != "P00042" || line.Status != http.StatusNotFound || line.RequestID != "req-7" {
+		t.Errorf("unexpected log line: %+v", line)
+	}
+	if line.LatencyMs < 0 {
+		t.Errorf("latency_ms = %v, want >= 0", line.LatencyMs)
+	}
+}
+
+// TestLoggingWithOptimizedHandler runs a request through the full route so
+// logging, metrics and the optimized handler's pooled responses interact.
+func TestLoggingWithOptimizedHandler(t *testing.T) {
+	var buf bytes.Buffer
+	db := simulator.NewDatabase(1, 2, 0)
+	handler := patterns.NewOptimizedHandler(db, patterns.DefaultWorkerPoolConfig())
+	defer handler.Shutdown(context.Background())
+
+	c := metrics.NewCollector()
+	mux := newServeMux(validConfig(), handler, db, c, newRequestLogger(&buf, "json"))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil))
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"P00001"`) {
+		t.Fatalf("got %d %s, want 200 with the patient", rec.Code, rec.Body.String())
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 1 {
+		t.Errorf("got %d log lines, want 1:\n%s", lines, buf.String())
+	}
+	if !strings.Contains(buf.String(), `"status":200`) {
+		t.Errorf("log line missing status 200: %s", buf.String())
+	}
+	if got := c.GetStats().TotalRequests; got != 1 {
+		t.Errorf("collector recorded %d requests, want 1", got)
+	}
+}
+
+// TestMetricsEndpointReflectsTraffic sends requests through the full route
+// for each pool pattern and checks /metrics reports them.
+func TestMetricsEndpointReflectsTraffic(t *testing.T) {
+	db := simulator.NewDatabase(1, 2, 0)
+	handlers := map[string]Handler{
+		"workerpool": patterns.NewWorkerPoolHandler(db, patterns.DefaultWorkerPoolConfig()),
+		"optimized":  patterns.NewOptimizedHandler(db, patterns.DefaultWorkerPoolConfig()),
+	}
+
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			defer handler.Shutdown(context.Background())
+
+			c := metrics.NewCollector()
+			mux := newServeMux(validConfig(), handler, db, c, nil)
+
+			paths := []string{
+				"/api/v1/patients?id=P00001",
+				"/api/v1/patients?id=P00002",
+				"/api/v1/patients?id=P00003",
+				"/api/v1/patients", // no ID: 400
+			}
+			for _, path := range paths {
+				mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+			}
+			// Other routes don't count
+			mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics?format=json", nil))
+
+			var stats metrics.Stats
+			if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+				t.Fatalf("decode /metrics: %v", err)
+			}
+			if stats.TotalRequests != 4 || stats.SuccessRequests != 3 || stats.ErrorRequests != 1 {
+				t.Errorf("got total=%d success=%d error=%d, want 4/3/1",
+					stats.TotalRequests, stats.SuccessRequests, stats.ErrorRequests)
+			}
+			if stats.MeanLatency <= 0 {
+				t.Errorf("mean latency = %v, want the requests timed", stats.MeanLatency)
+			}
+		})
+	}
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	db := simulator.NewDatabase(1, 2, 0)
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	handlers := map[string]Handler{
+		"naive":      patterns.NewNaiveHandler(db),
+		"workerpool": patterns.NewWorkerPoolHandler(db, patterns.DefaultWorkerPoolConfig()),
+		"optimized":  patterns.NewOptimizedHandler(db, patterns.DefaultWorkerPoolConfig()),
+		"semaphore":  patterns.NewSemaphoreHandler(db, patterns.DefaultSemaphoreConfig()),
+		"pipeline":   patterns.NewPipelineHandler(db, patterns.DefaultPipelineConfig()),
+		"bulkhead":   patterns.NewBulkheadHandler(db, patterns.DefaultBulkheadConfig()),
+	}
+
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			defer handler.Shutdown(context.Background())
+			mux := newServeMux(validConfig(), handler, db, metrics.NewCollector(), nil)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil))
+
+			header := rec.Header().Get("X-Request-ID")
+			if !uuidPattern.MatchString(header) {
+				t.Fatalf("X-Request-ID = %q, want a generated UUID", header)
+			}
+
+			var response models.PatientResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if response.RequestID != header {
+				t.Errorf("body request_id = %q, header = %q; want them equal", response.RequestID, header)
+			}
+		})
+	}
+}
+
+func TestRequestIDFromClientIsKept(t *testing.T) {
+	var seen string
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = patterns.RequestIDFromContext(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P1", nil)
+	req.Header.Set("X-Request-ID", "client-supplied")
+	rec := httptest.NewRecorder()
+	requestIDMiddleware(inner).ServeHTTP(rec, req)
+
+	if seen != "client-supplied" || rec.Header().Get("X-Request-ID") != "client-supplied" {
+		t.Errorf("context ID %q, header %q; want the client's ID in both", seen, rec.Header().Get("X-Request-ID"))
+	}
+}
+
+// decodeStable decodes a JSON or NDJSON body, gunzipping it first if
+// compressed, and drops the per-request timestamp and request ID so two
+// responses for the same corpus patients compare equal. Values come back
+// sorted, since a stream delivers them in completion order.
+func decodeStable(t *testing.T, body []byte, compressed bool) []string {
+	t.Helper()
+
+	var r io.Reader = bytes.NewReader(body)
+	if compressed {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatalf("body isn't gzip: %v", err)
+		}
+		r = gz
+	}
+
+	var values []string
+	dec := json.NewDecoder(r)
+	for {
+		var value map[string]interface{}
+		if err := dec.Decode(&value); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		delete(value, "timestamp")
+		delete(value, "request_id")
+		encoded, _ := json.Marshal(value)
+		values = append(values, string(encoded))
+	}
+	sort.Strings(values)
+	return values
+}
+
+// TestCompressMiddleware requests each patient endpoint with and without
+// Accept-Encoding: gzip and checks the body is compressed only when asked
+// for, and decodes to the same JSON either way.
+func TestCompressMiddleware(t *testing.T) {
+	config := validConfig()
+	config.Compress = true
+	db := simulator.NewDatabase(1, 2, 0, simulator.WithCorpus(10, simulator.DefaultCorpusSeed))
+	handler := patterns.NewOptimizedHandler(db, patterns.WorkerPoolConfig{Workers: 2, QueueSize: 10})
+	defer handler.Shutdown(context.Background())
+	mux := newServeMux(config, handler, db, metrics.NewCollector(), nil)
+
+	for _, target := range []string{
+		"/api/v1/patients?id=P00001",
+		"/api/v1/patients/batch?ids=P00001,P00002",
+		"/api/v1/patients/stream?ids=P00001,P00002,P00003",
+	} {
+		plain := httptest.NewRecorder()
+		mux.ServeHTTP(plain, httptest.NewRequest(http.MethodGet, target, nil))
+		if plain.Code != http.StatusOK || plain.Header().Get("Content-Encoding") != "" {
+			t.Fatalf("%s without Accept-Encoding = %d, Content-Encoding %q; want 200 uncompressed",
+				target, plain.Code, plain.Header().Get("Content-Encoding"))
+		}
+
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
+		compressed := httptest.NewRecorder()
+		mux.ServeHTTP(compressed, req)
+		if compressed.Code != http.StatusOK || compressed.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("%s with Accept-Encoding: gzip = %d, Content-Encoding %q; want 200 gzip",
+				target, compressed.Code, compressed.Header().Get("Content-Encoding"))
+		}
+		if strings.Contains(target, "stream") && !compressed.Flushed {
+			t.Errorf("%s: compressed stream was never flushed", target)
+		}
+		if !strings.Contains(compressed.Header().Get("Vary"), "Accept-Encoding") {
+			t.Errorf("%s: Vary = %q, want Accept-Encoding", target, compressed.Header().Get("Vary"))
+		}
+
+		want := decodeStable(t, plain.Body.Bytes(), false)
+		got := decodeStable(t, compressed.Body.Bytes(), true)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: compressed body decodes differently:\n got %v\nwant %v", target, got, want)
+		}
+	}
+
+	// A refused gzip gets the plain body
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Accept-Encoding gzip;q=0: Content-Encoding = %q, want none", rec.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestBodyLimitRejectsOversizedBody(t *testing.T) {
+	config := validConfig()
+	db := simulator.NewDatabase(1, 2, 0)
+	db.SetWriteProfile(1, 2, 0)
+	handler, err := createHandler(config, db)
+	if err != nil {
+		t.Fatalf("createHandler: %v", err)
+	}
+	defer handler.Shutdown(context.Background())
+
+	body, err := json.Marshal(models.GeneratePatient("P00001"))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	mux := newServeMux(config, handler, db, metrics.NewCollector(), nil)
+	server := httptest.NewServer(bodyLimitMiddleware(int64(len(body)), time.Second, mux))
+	defer server.Close()
+
+	put := func(body []byte) int {
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/api/v1/patients", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("NewRequest: %v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := put(body); status != http.StatusOK {
+		t.Errorf("PUT at the limit = %d, want 200", status)
+	}
+	oversized := append(body[:len(body)-1:len(body)-1], []byte(`, "padding": "`+strings.Repeat("x", 1024)+`"}`)...)
+	if status := put(oversized); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("PUT over the limit = %d, want 413", status)
+	}
+}
+
+func TestBodyLimitTimesOutSlowBody(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	reached := make(chan struct{}, 1)
+	server := httptest.NewServer(bodyLimitMiddleware(1<<20, timeout, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached <- struct{}{}
+	})))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Promise a body, send only part of it, then stall
+	fmt.Fprintf(conn, "PUT /api/v1/patients HTTP/1.1\r\nHost: test\r\nContent-Length: 100\r\n\r\n{\"id\":")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	status, err := io.ReadAll(io.LimitReader(conn, int64(len("HTTP/1.1 408"))))
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	if string(status) != "HTTP/1.1 408" {
+		t.Errorf("response to a stalled body = %q, want HTTP/1.1 408", status)
+	}
+	select {
+	case <-reached:
+		t.Error("handler ran without the whole body")
+	default:
+	}
+}
+
+// TestRequestTimeoutAnswers504 sends a request that the database can't
+// answer within -request-timeout through each pattern, and checks it gets
+// a 504 once the timeout passes rather than waiting out the query.
+func TestRequestTimeoutAnswers504(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+
+	for _, pattern := range []string{"naive", "semaphore", "workerpool", "optimized", "pipeline", "bulkhead"} {
+		t.Run(pattern, func(t *testing.T) {
+			db := simulator.NewDatabase(1, 2, 0)
+			db.SetLatencySource(simulator.NewTraceLatencySource([]time.Duration{time.Second}))
+			config := validConfig()
+			config.Pattern = pattern
+			config.RequestTimeout = timeout
+			handler, err := createHandler(config, db)
+			if err != nil {
+				t.Fatalf("createHandler: %v", err)
+			}
+			defer handler.Shutdown(context.Background())
+			mux := newServeMux(config, handler, db, metrics.NewCollector(), nil)
+
+			rec := httptest.NewRecorder()
+			start := time.Now()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil))
+			elapsed := time.Since(start)
+
+			if rec.Code != http.StatusGatewayTimeout {
+				t.Errorf("status = %d %q, want 504", rec.Code, rec.Body.String())
+			}
+			if elapsed < timeout || elapsed > 10*timeout {
+				t.Errorf("answered after %v, want soon after the %v timeout", elapsed, timeout)
+			}
+		})
+	}
+}
+
+func TestRequestTimeoutPassesFastResponses(t *testing.T) {
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("handler ran without a deadline")
+		}
+		http.Error(w, "no such patient", http.StatusNotFound)
+	})
+
+	rec := httptest.NewRecorder()
+	requestTimeoutMiddleware(time.Second, inner).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "no such patient") {
+		t.Errorf("got %d %q, want the handler's own 404", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,97 @@
+package models
+
+import "sort"
+
+// cohortBands are the age bands used for cohort analytics, as
+// [lowest age, label] pairs in ascending order.
+var cohortBands = []struct {
+	from  int
+	label string
+}{
+	{0, "0-17"},
+	{18, "18-34"},
+	{35, "35-49"},
+	{50, "50-64"},
+	{65, "65-74"},
+	{75, "75-89"},
+	{90, "90+"},
+}
+
+// topDiagnosisCount is how many diagnosis codes CohortStats reports.
+const topDiagnosisCount = 5
+
+// AgeBand returns the patient's cohort age band: "0-17", "18-34",
+// "35-49", "50-64", "65-74", "75-89" or "90+". The age is computed by
+// GetAge, so a birthday later this year doesn't count yet.
+//
+// These are the usual analytics bands, coarser than the 10-year Safe
+// Harbor bands of the package-level AgeBand used for de-identification.
+func (p *Patient) AgeBand() string {
+	age := p.GetAge()
+
+	band := cohortBands[0].label
+	for _, b := range cohortBands {
+		if age >= b.from {
+			band = b.label
+		}
+	}
+	return band
+}
+
+// DiagnosisCount is how many patients in a cohort have a diagnosis code.
+type DiagnosisCount struct {
+	Code  string `json:"code"`
+	Count int    `json:"count"`
+}
+
+// CohortSummary describes the make-up of a group of patients.
+type CohortSummary struct {
+	Total     int            `json:"total"`
+	ByAgeBand map[string]int `json:"by_age_band"`
+	ByGender  map[string]int `json:"by_gender"`
+
+	// The most common diagnosis codes, most common first, ties in code
+	// order. A patient with the same code twice is counted once.
+	TopDiagnoses []DiagnosisCount `json:"top_diagnoses"`
+}
+
+// CohortStats summarises patients by age band (see Patient.AgeBand),
+// gender and most common diagnosis codes. It is mostly useful for checking
+// that generated data has a plausible distribution.
+func CohortStats(patients []*Patient) CohortSummary {
+	summary := CohortSummary{
+		Total:     len(patients),
+		ByAgeBand: make(map[string]int),
+		ByGender:  make(map[string]int),
+	}
+
+	diagnoses := make(map[string]int)
+	for _, p := range patients {
+		summary.ByAgeBand[p.AgeBand()]++
+		summary.ByGender[p.Gender]++
+
+		seen := make(map[string]bool, len(p.DiagnosisCodes))
+		for _, code := range p.DiagnosisCodes {
+			if !seen[code] {
+				seen[code] = true
+				diagnoses[code]++
+			}
+		}
+	}
+
+	for code, count := range diagnoses {
+		summary.TopDiagnoses = append(summary.TopDiagnoses, DiagnosisCount{Code: code, Count: count})
+	}
+	sort.Slice(summary.TopDiagnoses, func(i, j int) bool {
+		a, b := summary.TopDiagnoses[i], summary.TopDiagnoses[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Code < b.Code
+	})
+	if len(summary.TopDiagnoses) > topDiagnosisCount {
+		summary.TopDiagnoses = summary.TopDiagnoses[:topDiagnosisCount]
+	}
+
+	return summary
+}
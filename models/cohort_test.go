@@ -0,0 +1,92 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// bornYearsAgo returns a patient whose birthday is days from today, years ago.
+func bornYearsAgo(years, days int) *Patient {
+	return &Patient{DateOfBirth: time.Now().AddDate(-years, 0, days)}
+}
+
+func TestPatientAgeBand(t *testing.T) {
+	tests := []struct {
+		name  string
+		years int
+		days  int // Birthday offset from today; positive is still to come
+		want  string
+	}{
+		{"newborn", 0, 0, "0-17"},
+		{"eighteen today", 18, 0, "18-34"},
+		{"eighteen tomorrow", 18, 1, "0-17"},
+		{"eighteen yesterday", 18, -1, "18-34"},
+		{"thirty-four", 34, -100, "18-34"},
+		{"thirty-five", 35, 0, "35-49"},
+		{"fifty", 50, 0, "50-64"},
+		{"sixty-five", 65, 0, "65-74"},
+		{"seventy-five", 75, 0, "75-89"},
+		{"eighty-nine", 89, -200, "75-89"},
+		{"ninety tomorrow", 90, 1, "75-89"},
+		{"ninety today", 90, 0, "90+"},
+		{"hundred and five", 105, 0, "90+"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := bornYearsAgo(tc.years, tc.days).AgeBand(); got != tc.want {
+				t.Errorf("AgeBand() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCohortStats(t *testing.T) {
+	patient := func(years int, gender string, codes ...string) *Patient {
+		p := bornYearsAgo(years, -1)
+		p.Gender = gender
+		p.DiagnosisCodes = codes
+		return p
+	}
+	patients := []*Patient{
+		patient(10, "Female", "J45.909"),
+		patient(18, "Male", "I10", "I10"),
+		patient(40, "Female", "I10", "E11.9"),
+		patient(70, "Male", "I10", "E11.9", "E78.5"),
+		patient(90, "Female", "E78.5", "F41.9", "K21.9", "M54.5"),
+	}
+
+	got := CohortStats(patients)
+
+	if got.Total != 5 {
+		t.Errorf("Total = %d, want 5", got.Total)
+	}
+	wantBands := map[string]int{"0-17": 1, "18-34": 1, "35-49": 1, "65-74": 1, "90+": 1}
+	if !reflect.DeepEqual(got.ByAgeBand, wantBands) {
+		t.Errorf("ByAgeBand = %v, want %v", got.ByAgeBand, wantBands)
+	}
+	wantGenders := map[string]int{"Female": 3, "Male": 2}
+	if !reflect.DeepEqual(got.ByGender, wantGenders) {
+		t.Errorf("ByGender = %v, want %v", got.ByGender, wantGenders)
+	}
+
+	// I10 is listed twice for one patient but counts once for them
+	wantTop := []DiagnosisCount{
+		{"I10", 3},
+		{"E11.9", 2},
+		{"E78.5", 2},
+		{"F41.9", 1},
+		{"J45.909", 1},
+	}
+	if !reflect.DeepEqual(got.TopDiagnoses, wantTop) {
+		t.Errorf("TopDiagnoses = %v, want %v", got.TopDiagnoses, wantTop)
+	}
+}
+
+func TestCohortStatsEmpty(t *testing.T) {
+	got := CohortStats(nil)
+	if got.Total != 0 || len(got.ByAgeBand) != 0 || len(got.TopDiagnoses) != 0 {
+		t.Errorf("CohortStats(nil) = %+v, want an empty summary", got)
+	}
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// safeHarborMaxAge is the oldest age HIPAA Safe Harbor allows to be reported
+// exactly. Everyone older is collapsed into a single "90+" band, because very
+// old ages are rare enough to identify individuals.
+const safeHarborMaxAge = 89
+
+// DeidentifiedPatient is a patient record with direct identifiers removed,
+// following the HIPAA Safe Harbor method.
+//
+// Removed:
+// - Names and medical record number
+// - Exact date of birth (generalised to a 10-year age band, 90+ collapsed)
+// - Exact visit date (generalised to the year)
+//
+// Kept: clinical structure (diagnoses, medications, allergies) and
+// non-identifying attributes, so captured traffic stays realistic. The
+// patient ID is kept because the client supplied it in the request.
+type DeidentifiedPatient struct {
+	ID                string   `json:"id"`
+	AgeBand           string   `json:"age_band"`
+	Gender            string   `json:"gender"`
+	DiagnosisCodes    []string `json:"diagnosis_codes"`
+	Medications       []string `json:"medications"`
+	Allergies         []string `json:"allergies"`
+	LastVisitYear     int      `json:"last_visit_year"`
+	PrimaryPhysician  string   `json:"primary_physician"`
+	InsuranceProvider string   `json:"insurance_provider"`
+	BloodType         string   `json:"blood_type"`
+}
+
+// DeidentifiedResponse mirrors PatientResponse with a de-identified record.
+type DeidentifiedResponse struct {
+	Success   bool                 `json:"success"`
+	Patient   *DeidentifiedPatient `json:"patient,omitempty"`
+	Error     string               `json:"error,omitempty"`
+	Timestamp time.Time            `json:"timestamp"`
+	RequestID string               `json:"request_id"`
+}
+
+// DeidentifiedBatchResponse mirrors BatchResponse with de-identified records.
+type DeidentifiedBatchResponse struct {
+	Success   bool                   `json:"success"`
+	Engine    string                 `json:"engine"`
+	Patients  []*DeidentifiedPatient `json:"patients,omitempty"`
+	Missing   []string               `json:"missing,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	RequestID string                 `json:"request_id"`
+}
+
+// DeidentifiedSearchResponse mirrors SearchResponse with de-identified records.
+type DeidentifiedSearchResponse struct {
+	Success   bool                   `json:"success"`
+	Count     int                    `json:"count"`
+	Patients  []*DeidentifiedPatient `json:"patients"`
+	Error     string                 `json:"error,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	RequestID string                 `json:"request_id"`
+}
+
+// Deidentify returns a copy of the patient with identifying fields removed
+// or generalised. The original record is not modified.
+func (p *Patient) Deidentify() *DeidentifiedPatient {
+	d := &DeidentifiedPatient{
+		ID:                p.ID,
+		AgeBand:           AgeBand(p.GetAge()),
+		Gender:            p.Gender,
+		DiagnosisCodes:    append([]string(nil), p.DiagnosisCodes...),
+		Medications:       append([]string(nil), p.Medications...),
+		Allergies:         append([]string(nil), p.Allergies...),
+		PrimaryPhysician:  p.PrimaryPhysician,
+		InsuranceProvider: p.InsuranceProvider,
+		BloodType:         p.BloodType,
+	}
+
+	if !p.LastVisitDate.IsZero() {
+		d.LastVisitYear = p.LastVisitDate.Year()
+	}
+
+	return d
+}
+
+// NewDeidentifiedResponse converts a patient response to its de-identified form.
+func NewDeidentifiedResponse(r *PatientResponse) *DeidentifiedResponse {
+	d := &DeidentifiedResponse{
+		Success:   r.Success,
+		Error:     r.Error,
+		Timestamp: r.Timestamp,
+		RequestID: r.RequestID,
+	}
+
+	if r.Patient != nil {
+		d.Patient = r.Patient.Deidentify()
+	}
+
+	return d
+}
+
+// NewDeidentifiedBatchResponse converts a batch response to its de-identified form.
+func NewDeidentifiedBatchResponse(r *BatchResponse) *DeidentifiedBatchResponse {
+	d := &DeidentifiedBatchResponse{
+		Success:   r.Success,
+		Engine:    r.Engine,
+		Missing:   r.Missing,
+		Error:     r.Error,
+		Timestamp: r.Timestamp,
+		RequestID: r.RequestID,
+	}
+
+	for _, patient := range r.Patients {
+		d.Patients = append(d.Patients, patient.Deidentify())
+	}
+
+	return d
+}
+
+// NewDeidentifiedSearchResponse converts a search response to its de-identified form.
+func NewDeidentifiedSearchResponse(r *SearchResponse) *DeidentifiedSearchResponse {
+	d := &DeidentifiedSearchResponse{
+		Success:   r.Success,
+		Count:     r.Count,
+		Patients:  make([]*DeidentifiedPatient, 0, len(r.Patients)),
+		Error:     r.Error,
+		Timestamp: r.Timestamp,
+		RequestID: r.RequestID,
+	}
+
+	for _, patient := range r.Patients {
+		d.Patients = append(d.Patients, patient.Deidentify())
+	}
+
+	return d
+}
+
+// AgeBand generalises an age to a 10-year band such as "40-49".
+// Ages above 89 collapse into a single "90+" band per Safe Harbor.
+func AgeBand(age int) string {
+	if age < 0 {
+		age = 0
+	}
+	if age > safeHarborMaxAge {
+		return "90+"
+	}
+
+	low := age / 10 * 10
+	return fmt.Sprintf("%d-%d", low, low+9)
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAgeBand(t *testing.T) {
+	tests := []struct {
+		age  int
+		want string
+	}{
+		{0, "0-9"},
+		{9, "0-9"},
+		{10, "10-19"},
+		{45, "40-49"},
+		{89, "80-89"},
+		{90, "90+"},
+		{95, "90+"},
+		{120, "90+"},
+		{-1, "0-9"},
+	}
+
+	for _, tc := range tests {
+		if got := AgeBand(tc.age); got != tc.want {
+			t.Errorf("AgeBand(%d) = %q, want %q", tc.age, got, tc.want)
+		}
+	}
+}
+
+func TestDeidentifyRedactsIdentifiers(t *testing.T) {
+	p := GeneratePatient("P00123")
+	p.FirstName = "Zelda"
+	p.LastName = "Quimby"
+	p.MedicalRecordNumber = "MRN-7654321"
+	p.DateOfBirth = time.Now().AddDate(-93, 0, -1)
+	p.LastVisitDate = time.Date(2023, time.March, 14, 9, 30, 0, 0, time.UTC)
+
+	d := p.Deidentify()
+
+	if d.AgeBand != "90+" {
+		t.Errorf("AgeBand = %q, want %q", d.AgeBand, "90+")
+	}
+	if d.LastVisitYear != 2023 {
+		t.Errorf("LastVisitYear = %d, want 2023", d.LastVisitYear)
+	}
+	if len(d.DiagnosisCodes) != len(p.DiagnosisCodes) || len(d.Medications) != len(p.Medications) {
+		t.Errorf("clinical structure not preserved: %+v", d)
+	}
+
+	body, err := json.Marshal(NewDeidentifiedResponse(NewPatientResponse(p, "req-1")))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	for _, secret := range []string{
+		"Zelda", "Quimby", "MRN-7654321",
+		p.DateOfBirth.Format("2006-01-02"),
+		"2023-03-14",
+		"first_name", "last_name", "medical_record_number", "date_of_birth",
+	} {
+		if strings.Contains(string(body), secret) {
+			t.Errorf("de-identified JSON leaks %q: %s", secret, body)
+		}
+	}
+
+	// The original record must be left untouched.
+	if p.FirstName != "Zelda" || p.MedicalRecordNumber != "MRN-7654321" {
+		t.Errorf("Deidentify modified the original patient: %+v", p)
+	}
+}
+
+func TestDeidentifiedErrorResponse(t *testing.T) {
+	d := NewDeidentifiedResponse(&PatientResponse{Success: false, Error: "boom"})
+
+	if d.Patient != nil || d.Error != "boom" || d.Success {
+		t.Errorf("unexpected de-identified error response: %+v", d)
+	}
+}
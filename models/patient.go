@@ -1,8 +1,13 @@
 package models
 
 import (
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"math/rand"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -22,6 +27,14 @@ type Patient struct {
 	PrimaryPhysician   string    `json:"primary_physician"`
 	InsuranceProvider  string    `json:"insurance_provider"`
 	BloodType          string    `json:"blood_type"`
+
+	// Stale marks a record read from a lagging replica, which may be
+	// missing recent changes (see simulator.WithReplicationLag)
+	Stale bool `json:"stale,omitempty"`
+
+	// Degraded marks a last known good record served while the database
+	// is unreachable (see simulator.WithDegradedMode)
+	Degraded bool `json:"degraded,omitempty"`
 }
 
 // PatientResponse represents the API response structure for patient queries.
@@ -37,6 +50,30 @@ type PatientResponse struct {
 	RequestID string    `json:"request_id"`
 }
 
+// BatchResponse is the API response for a multi-patient lookup.
+// Patients are in the order their IDs were requested. A batch cut short
+// still returns the patients fetched, with the rest listed in Missing.
+type BatchResponse struct {
+	Success   bool       `json:"success"`
+	Engine    string     `json:"engine"`
+	Patients  []*Patient `json:"patients,omitempty"`
+	Missing   []string   `json:"missing,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Timestamp time.Time  `json:"timestamp"`
+	RequestID string     `json:"request_id"`
+}
+
+// SearchResponse is the API response for a patient search.
+// Patients are the matching records, in corpus order.
+type SearchResponse struct {
+	Success   bool       `json:"success"`
+	Count     int        `json:"count"`
+	Patients  []*Patient `json:"patients"`
+	Error     string     `json:"error,omitempty"`
+	Timestamp time.Time  `json:"timestamp"`
+	RequestID string     `json:"request_id"`
+}
+
 var (
 	// Sample data pools for generating realistic patient records
 	firstNames = []string{
@@ -96,74 +133,161 @@ var (
 // The random data generation helps create realistic load patterns for benchmarking.
 func GeneratePatient(id string) *Patient {
 	rand.Seed(time.Now().UnixNano() + hashString(id))
+	return generatePatient(id, rand.Intn)
+}
+
+// GeneratePatientFrom is GeneratePatient drawing from r instead of the
+// global source, so a seeded r produces the same records every run.
+func GeneratePatientFrom(id string, r *rand.Rand) *Patient {
+	return generatePatient(id, r.Intn)
+}
 
+// generatePatient builds a patient from the random numbers intn returns.
+func generatePatient(id string, intn func(n int) int) *Patient {
 	// Generate a realistic age (18-90 years old)
-	yearsOld := rand.Intn(72) + 18
-	dob := time.Now().AddDate(-yearsOld, -rand.Intn(12), -rand.Intn(28))
+	yearsOld := intn(72) + 18
+	dob := time.Now().AddDate(-yearsOld, -intn(12), -intn(28))
 
 	// Generate 1-3 diagnosis codes
-	diagnosisCount := rand.Intn(3) + 1
+	diagnosisCount := intn(3) + 1
 	selectedDiagnoses := make([]string, diagnosisCount)
 	for i := 0; i < diagnosisCount; i++ {
-		selectedDiagnoses[i] = diagnosisCodes[rand.Intn(len(diagnosisCodes))]
+		selectedDiagnoses[i] = diagnosisCodes[intn(len(diagnosisCodes))]
 	}
 
 	// Generate 0-4 medications
-	medCount := rand.Intn(5)
+	medCount := intn(5)
 	selectedMeds := make([]string, medCount)
 	for i := 0; i < medCount; i++ {
-		selectedMeds[i] = medications[rand.Intn(len(medications))]
+		selectedMeds[i] = medications[intn(len(medications))]
 	}
 
 	// Generate 0-2 allergies
-	allergyCount := rand.Intn(3)
+	allergyCount := intn(3)
 	if allergyCount == 0 {
 		allergyCount = 1 // Everyone gets at least "No known allergies"
 	}
 	selectedAllergies := make([]string, allergyCount)
 	for i := 0; i < allergyCount; i++ {
-		selectedAllergies[i] = allergies[rand.Intn(len(allergies))]
+		selectedAllergies[i] = allergies[intn(len(allergies))]
 	}
 
 	// Last visit within the past year
-	lastVisit := time.Now().AddDate(0, -rand.Intn(12), -rand.Intn(28))
+	lastVisit := time.Now().AddDate(0, -intn(12), -intn(28))
 
 	gender := "Male"
-	if rand.Intn(2) == 0 {
+	if intn(2) == 0 {
 		gender = "Female"
 	}
 
 	return &Patient{
 		ID:                 id,
-		MedicalRecordNumber: fmt.Sprintf("MRN-%07d", rand.Intn(9999999)),
-		FirstName:          firstNames[rand.Intn(len(firstNames))],
-		LastName:           lastNames[rand.Intn(len(lastNames))],
+		MedicalRecordNumber: fmt.Sprintf("MRN-%07d", intn(9999999)),
+		FirstName:          firstNames[intn(len(firstNames))],
+		LastName:           lastNames[intn(len(lastNames))],
 		DateOfBirth:        dob,
 		Gender:             gender,
 		DiagnosisCodes:     selectedDiagnoses,
 		Medications:        selectedMeds,
 		Allergies:          selectedAllergies,
 		LastVisitDate:      lastVisit,
-		PrimaryPhysician:   physicians[rand.Intn(len(physicians))],
-		InsuranceProvider:  insuranceProviders[rand.Intn(len(insuranceProviders))],
-		BloodType:          bloodTypes[rand.Intn(len(bloodTypes))],
+		PrimaryPhysician:   physicians[intn(len(physicians))],
+		InsuranceProvider:  insuranceProviders[intn(len(insuranceProviders))],
+		BloodType:          bloodTypes[intn(len(bloodTypes))],
 	}
 }
 
+var (
+	// genders are the accepted values of Patient.Gender
+	genders = []string{"Male", "Female", "Other", "Unknown"}
+
+	// icd10Code matches the shape of an ICD-10 code: a letter, two digits
+	// and an optional dot-decimal subcategory, e.g. "I10" or "J45.909"
+	icd10Code = regexp.MustCompile(`^[A-Z][0-9]{2}(\.[0-9]{1,4})?$`)
+
+	// medicalRecordNumber matches the MRN-####### format
+	medicalRecordNumber = regexp.MustCompile(`^MRN-[0-9]{7}$`)
+)
+
 // Validate performs basic validation on patient data.
 // In a real healthcare system, this would be much more comprehensive
 // and include checks for data integrity, consent, and authorization.
+//
+// ID and name are required. Blood type, gender, diagnosis codes and
+// medical record number may be left empty, but must be well formed when
+// set. Every problem found is reported, joined into one error.
 func (p *Patient) Validate() error {
+	var problems []error
+
 	if p.ID == "" {
-		return fmt.Errorf("patient ID is required")
+		problems = append(problems, fmt.Errorf("patient ID is required"))
 	}
 	if p.FirstName == "" || p.LastName == "" {
-		return fmt.Errorf("patient name is required")
+		problems = append(problems, fmt.Errorf("patient name is required"))
 	}
 	if p.DateOfBirth.After(time.Now()) {
-		return fmt.Errorf("date of birth cannot be in the future")
+		problems = append(problems, fmt.Errorf("date of birth cannot be in the future"))
+	}
+	if p.BloodType != "" && !slices.Contains(bloodTypes, p.BloodType) {
+		problems = append(problems, fmt.Errorf("blood type %q is not one of %s", p.BloodType, strings.Join(bloodTypes, ", ")))
+	}
+	if p.Gender != "" && !slices.Contains(genders, p.Gender) {
+		problems = append(problems, fmt.Errorf("gender %q is not one of %s", p.Gender, strings.Join(genders, ", ")))
+	}
+	for _, code := range p.DiagnosisCodes {
+		if !icd10Code.MatchString(code) {
+			problems = append(problems, fmt.Errorf("diagnosis code %q is not an ICD-10 code", code))
+		}
+	}
+	if p.MedicalRecordNumber != "" && !medicalRecordNumber.MatchString(p.MedicalRecordNumber) {
+		problems = append(problems, fmt.Errorf("medical record number %q is not in the MRN-####### format", p.MedicalRecordNumber))
+	}
+
+	return errors.Join(problems...)
+}
+
+// Clone returns a deep copy of p, so the copy's slices can be modified
+// without affecting p. Cloning nil returns nil.
+func (p *Patient) Clone() *Patient {
+	if p == nil {
+		return nil
+	}
+
+	c := *p
+	c.DiagnosisCodes = append([]string(nil), p.DiagnosisCodes...)
+	c.Medications = append([]string(nil), p.Medications...)
+	c.Allergies = append([]string(nil), p.Allergies...)
+	return &c
+}
+
+// ETag returns a strong entity tag for p's contents: a hash of every
+// field, so identical records share a tag and any change gives a new one.
+// It hashes the fields directly rather than an encoding, so checking a
+// conditional request never serializes the patient.
+func (p *Patient) ETag() string {
+	h := fnv.New64a()
+	for _, field := range []string{
+		p.ID, p.MedicalRecordNumber, p.FirstName, p.LastName,
+		p.DateOfBirth.UTC().Format(time.RFC3339Nano), p.Gender,
+		strings.Join(p.DiagnosisCodes, ","), strings.Join(p.Medications, ","), strings.Join(p.Allergies, ","),
+		p.LastVisitDate.UTC().Format(time.RFC3339Nano),
+		p.PrimaryPhysician, p.InsuranceProvider, p.BloodType,
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf(`"%016x"`, h.Sum64())
+}
+
+// Reset clears p for reuse. The slices are emptied but keep their
+// capacity, so a pooled patient can be refilled without reallocating.
+// Anything still holding one of p's slices will see it overwritten.
+func (p *Patient) Reset() {
+	*p = Patient{
+		DiagnosisCodes: p.DiagnosisCodes[:0],
+		Medications:    p.Medications[:0],
+		Allergies:      p.Allergies[:0],
 	}
-	return nil
 }
 
 // GetAge calculates the patient's current age in years.
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCloneIsDeep(t *testing.T) {
+	original := GeneratePatient("P00001")
+	original.DiagnosisCodes = []string{"I10", "E11.9"}
+	original.Medications = []string{"Metformin 500mg"}
+	original.Allergies = []string{"Penicillin"}
+	want := *original
+	want.DiagnosisCodes = []string{"I10", "E11.9"}
+	want.Medications = []string{"Metformin 500mg"}
+	want.Allergies = []string{"Penicillin"}
+
+	clone := original.Clone()
+	if !reflect.DeepEqual(clone, original) {
+		t.Fatalf("clone differs from original:\n%+v\n%+v", clone, original)
+	}
+
+	clone.LastName = "Changed"
+	clone.DiagnosisCodes[0] = "J45.909"
+	clone.Medications[0] = "Lisinopril 10mg"
+	clone.Allergies = append(clone.Allergies[:0], "Latex")
+
+	if !reflect.DeepEqual(*original, want) {
+		t.Errorf("modifying the clone changed the original:\n got %+v\nwant %+v", *original, want)
+	}
+}
+
+func TestCloneNil(t *testing.T) {
+	var p *Patient
+	if p.Clone() != nil {
+		t.Error("cloning a nil patient returned non-nil")
+	}
+}
+
+func TestETag(t *testing.T) {
+	p := GeneratePatient("P00001")
+	etag := p.ETag()
+
+	if p.Clone().ETag() != etag {
+		t.Error("a clone has a different ETag")
+	}
+
+	changed := p.Clone()
+	changed.Allergies = append(changed.Allergies, "Latex")
+	if changed.ETag() == etag {
+		t.Error("adding an allergy didn't change the ETag")
+	}
+
+	// Moving a value between fields changes the tag too
+	a, b := &Patient{FirstName: "Ann", LastName: "Lee"}, &Patient{FirstName: "AnnLee"}
+	if a.ETag() == b.ETag() {
+		t.Error("patients differing only in field boundaries share an ETag")
+	}
+}
+
+func TestReset(t *testing.T) {
+	p := GeneratePatient("P00001")
+	p.DiagnosisCodes = make([]string, 2, 8)
+	p.Reset()
+
+	if p.ID != "" || p.LastName != "" || !p.DateOfBirth.IsZero() || p.BloodType != "" {
+		t.Errorf("fields survived Reset: %+v", p)
+	}
+	if len(p.DiagnosisCodes) != 0 || len(p.Medications) != 0 || len(p.Allergies) != 0 {
+		t.Errorf("slices not emptied: %+v", p)
+	}
+	if cap(p.DiagnosisCodes) != 8 {
+		t.Errorf("DiagnosisCodes capacity = %d, want 8 kept for reuse", cap(p.DiagnosisCodes))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		if err := GeneratePatient("P00001").Validate(); err != nil {
+			t.Fatalf("generated patient is invalid: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(p *Patient)
+		want   string // Substring of the error
+	}{
+		{"missing ID", func(p *Patient) { p.ID = "" }, "patient ID is required"},
+		{"missing name", func(p *Patient) { p.LastName = "" }, "patient name is required"},
+		{"future birth", func(p *Patient) { p.DateOfBirth = time.Now().AddDate(1, 0, 0) }, "future"},
+		{"blood type", func(p *Patient) { p.BloodType = "C+" }, `blood type "C+"`},
+		{"blood type case", func(p *Patient) { p.BloodType = "ab+" }, `blood type "ab+"`},
+		{"gender", func(p *Patient) { p.Gender = "M" }, `gender "M"`},
+		{"diagnosis without digits", func(p *Patient) { p.DiagnosisCodes = []string{"I10", "ABC"} }, `diagnosis code "ABC"`},
+		{"diagnosis lower case", func(p *Patient) { p.DiagnosisCodes = []string{"e11.9"} }, `diagnosis code "e11.9"`},
+		{"diagnosis empty decimal", func(p *Patient) { p.DiagnosisCodes = []string{"E11."} }, `diagnosis code "E11."`},
+		{"MRN digits", func(p *Patient) { p.MedicalRecordNumber = "MRN-12345" }, `medical record number "MRN-12345"`},
+		{"MRN prefix", func(p *Patient) { p.MedicalRecordNumber = "1234567" }, `medical record number "1234567"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := GeneratePatient("P00001")
+			tc.modify(p)
+
+			err := p.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateOptionalFieldsMayBeEmpty(t *testing.T) {
+	p := &Patient{ID: "P1", FirstName: "Jane", LastName: "Doe"}
+	if err := p.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil for a patient with only ID and name", err)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	p := GeneratePatient("P00001")
+	p.ID = ""
+	p.BloodType = "Z"
+	p.Gender = "?"
+	p.DiagnosisCodes = []string{"bad"}
+	p.MedicalRecordNumber = "MRN"
+
+	err := p.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want errors")
+	}
+	for _, want := range []string{"patient ID", "blood type", "gender", "diagnosis code", "medical record number"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 5 {
+		t.Errorf("want 5 joined errors, got %v", err)
+	}
+}
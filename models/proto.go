@@ -0,0 +1,87 @@
+package models
+
+import (
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patientpb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ToProto converts the patient to its protobuf form. A nil patient
+// converts to nil.
+func (p *Patient) ToProto() *patientpb.Patient {
+	if p == nil {
+		return nil
+	}
+
+	return &patientpb.Patient{
+		Id:                  p.ID,
+		MedicalRecordNumber: p.MedicalRecordNumber,
+		FirstName:           p.FirstName,
+		LastName:            p.LastName,
+		DateOfBirth:         timestamppb.New(p.DateOfBirth),
+		Gender:              p.Gender,
+		DiagnosisCodes:      p.DiagnosisCodes,
+		Medications:         p.Medications,
+		Allergies:           p.Allergies,
+		LastVisitDate:       timestamppb.New(p.LastVisitDate),
+		PrimaryPhysician:    p.PrimaryPhysician,
+		InsuranceProvider:   p.InsuranceProvider,
+		BloodType:           p.BloodType,
+	}
+}
+
+// PatientFromProto converts a protobuf patient back to a Patient. Times
+// come back in UTC and empty lists as nil, as Clone leaves them. A nil
+// message converts to nil.
+func PatientFromProto(pb *patientpb.Patient) *Patient {
+	if pb == nil {
+		return nil
+	}
+
+	return &Patient{
+		ID:                  pb.GetId(),
+		MedicalRecordNumber: pb.GetMedicalRecordNumber(),
+		FirstName:           pb.GetFirstName(),
+		LastName:            pb.GetLastName(),
+		DateOfBirth:         pb.GetDateOfBirth().AsTime(),
+		Gender:              pb.GetGender(),
+		DiagnosisCodes:      pb.GetDiagnosisCodes(),
+		Medications:         pb.GetMedications(),
+		Allergies:           pb.GetAllergies(),
+		LastVisitDate:       pb.GetLastVisitDate().AsTime(),
+		PrimaryPhysician:    pb.GetPrimaryPhysician(),
+		InsuranceProvider:   pb.GetInsuranceProvider(),
+		BloodType:           pb.GetBloodType(),
+	}
+}
+
+// MarshalProto encodes the response as a patientpb.PatientResponse, the
+// body the API sends to clients that accept application/x-protobuf. The
+// encoding is a copy, so the response may be reused (or returned to a
+// pool) as soon as it returns.
+func (r *PatientResponse) MarshalProto() ([]byte, error) {
+	return proto.Marshal(&patientpb.PatientResponse{
+		Patient:   r.Patient.ToProto(),
+		Timestamp: timestamppb.New(r.Timestamp),
+		RequestId: r.RequestID,
+		Success:   r.Success,
+		Error:     r.Error,
+	})
+}
+
+// UnmarshalProto decodes a response encoded by MarshalProto into r.
+func (r *PatientResponse) UnmarshalProto(data []byte) error {
+	var pb patientpb.PatientResponse
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return err
+	}
+
+	*r = PatientResponse{
+		Success:   pb.GetSuccess(),
+		Patient:   PatientFromProto(pb.GetPatient()),
+		Error:     pb.GetError(),
+		Timestamp: pb.GetTimestamp().AsTime(),
+		RequestID: pb.GetRequestId(),
+	}
+	return nil
+}
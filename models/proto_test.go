@@ -0,0 +1,43 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPatientResponseProtoRoundTrip(t *testing.T) {
+	patient := GeneratePatient("P00001")
+	patient.DiagnosisCodes = []string{"I10", "E11.9"}
+	patient.Medications = []string{"Metformin 500mg"}
+	patient.Allergies = []string{"Penicillin"}
+
+	for _, response := range []*PatientResponse{
+		NewPatientResponse(patient, "req-1"),
+		{Success: false, Error: "patient not found", Timestamp: time.Now(), RequestID: "req-2"},
+	} {
+		data, err := response.MarshalProto()
+		if err != nil {
+			t.Fatalf("MarshalProto: %v", err)
+		}
+
+		var got PatientResponse
+		if err := got.UnmarshalProto(data); err != nil {
+			t.Fatalf("UnmarshalProto: %v", err)
+		}
+
+		if !got.Timestamp.Equal(response.Timestamp) {
+			t.Errorf("Timestamp = %v, want %v", got.Timestamp, response.Timestamp)
+		}
+		got.Timestamp = response.Timestamp
+		if got.Patient != nil {
+			if !got.Patient.DateOfBirth.Equal(patient.DateOfBirth) || !got.Patient.LastVisitDate.Equal(patient.LastVisitDate) {
+				t.Errorf("dates = %v, %v, want %v, %v", got.Patient.DateOfBirth, got.Patient.LastVisitDate, patient.DateOfBirth, patient.LastVisitDate)
+			}
+			got.Patient.DateOfBirth, got.Patient.LastVisitDate = patient.DateOfBirth, patient.LastVisitDate
+		}
+		if !reflect.DeepEqual(&got, response) {
+			t.Errorf("round trip changed the response:\n got %+v\nwant %+v", got.Patient, response.Patient)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// openAPISchemas lists the types published under components/schemas. A
+// field of one of these types refers to it rather than repeating it.
+var openAPISchemas = map[reflect.Type]string{
+	reflect.TypeOf(models.Patient{}):         "Patient",
+	reflect.TypeOf(models.PatientResponse{}): "PatientResponse",
+	reflect.TypeOf(metrics.Stats{}):          "Stats",
+}
+
+// openAPIOverrides gives the schemas of types with a custom JSON encoding,
+// which their struct fields don't describe.
+var openAPIOverrides = map[reflect.Type]map[string]interface{}{
+	// The overflow bucket's bound is "+Inf"
+	reflect.TypeOf(metrics.Bucket{}): {
+		"type": "object",
+		"properties": map[string]interface{}{
+			"upper_bound_ms": map[string]interface{}{
+				"oneOf": []interface{}{
+					map[string]interface{}{"type": "number"},
+					map[string]interface{}{"type": "string", "enum": []string{"+Inf"}},
+				},
+			},
+			"count": map[string]interface{}{"type": "integer"},
+		},
+		"required": []string{"upper_bound_ms", "count"},
+	},
+}
+
+// openAPIDocument returns an OpenAPI 3 description of the patient API,
+// /health and /metrics, for generating typed clients. The schemas are
+// derived from the Go structs, so property names always match their JSON
+// tags.
+func openAPIDocument(config Config) map[string]interface{} {
+	schemas := make(map[string]interface{}, len(openAPISchemas))
+	for t, name := range openAPISchemas {
+		schemas[name] = structSchema(t)
+	}
+
+	patientResponse := jsonContent(schemaRef("PatientResponse"))
+	errorResponse := func(description string) map[string]interface{} {
+		return map[string]interface{}{"description": description, "content": patientResponse}
+	}
+
+	patients := map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary":     "Read a patient",
+			"operationId": "getPatient",
+			"parameters": []interface{}{
+				map[string]interface{}{
+					"name":     "id",
+					"in":       "query",
+					"required": true,
+					"schema":   map[string]interface{}{"type": "string"},
+					"example":  "P00001",
+				},
+				map[string]interface{}{
+					"name":        "fields",
+					"in":          "query",
+					"description": "Comma-separated Patient fields to return; the rest are left out. Not supported with -deidentify",
+					"schema":      map[string]interface{}{"type": "string"},
+					"example":     "first_name,last_name,allergies",
+				},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{"description": "The patient", "content": patientResponse},
+				"304": map[string]interface{}{"description": "Unchanged since the ETag in If-None-Match"},
+				"400": map[string]interface{}{"description": "Missing patient ID, unknown field, or fields with -deidentify"},
+				"404": errorResponse("No such patient"),
+				"503": errorResponse("Overloaded; retry after the Retry-After header"),
+				"504": errorResponse("The database timed out"),
+			},
+		},
+		"put": map[string]interface{}{
+			"summary":     "Update a patient",
+			"operationId": "updatePatient",
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemaRef("Patient")),
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{"description": "The updated patient", "content": patientResponse},
+				"400": map[string]interface{}{"description": "Invalid patient JSON"},
+				"409": errorResponse("The record was locked by another write"),
+				"503": errorResponse("Overloaded; retry after the Retry-After header"),
+			},
+		},
+	}
+	if config.RejectStatus == http.StatusTooManyRequests {
+		for _, op := range []string{"get", "put"} {
+			responses := patients[op].(map[string]interface{})["responses"].(map[string]interface{})
+			responses["429"] = errorResponse("Overloaded; retry after the Retry-After header")
+			responses["503"] = errorResponse("Shutting down, or the database connection pool is exhausted")
+		}
+	}
+	if len(config.APIKeys) > 0 {
+		patients["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Healthcare API Concurrency Benchmark",
+			"version": "1.0.0",
+		},
+		"paths": map[string]interface{}{
+			"/api/v1/patients": patients,
+			"/health": map[string]interface{}{
+				"get": map[string]interface{}{
+					"summary":     "Database health and stats",
+					"operationId": "getHealth",
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{
+							"description": "Healthy, or degraded while a worker is stuck",
+							"content": jsonContent(map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"status":           map[string]interface{}{"type": "string", "enum": []string{"healthy", "degraded"}},
+									"database_queries": map[string]interface{}{"type": "integer"},
+									"database_errors":  map[string]interface{}{"type": "integer"},
+									"timestamp":        map[string]interface{}{"type": "string", "format": "date-time"},
+								},
+							}),
+						},
+						"503": map[string]interface{}{"description": "The database is unhealthy"},
+					},
+				},
+			},
+			"/metrics": map[string]interface{}{
+				"get": map[string]interface{}{
+					"summary":     "Request metrics",
+					"operationId": "getMetrics",
+					"parameters": []interface{}{map[string]interface{}{
+						"name":        "format",
+						"in":          "query",
+						"description": "json for summary statistics, quick for counts and mean/min/max only; Prometheus text format when absent",
+						"schema":      map[string]interface{}{"type": "string", "enum": []string{"json", "quick"}},
+					}},
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{
+							"description": "Metrics in the requested format",
+							"content": map[string]interface{}{
+								"text/plain":       map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+								"application/json": map[string]interface{}{"schema": schemaRef("Stats")},
+							},
+						},
+					},
+				},
+			},
+		},
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// openAPIHandler serves the OpenAPI document as JSON.
+func openAPIHandler(config Config) http.HandlerFunc {
+	document, err := json.MarshalIndent(openAPIDocument(config), "", "  ")
+	if err != nil {
+		panic(err) // Built from fixed types; can't fail
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(document)
+	}
+}
+
+// structSchema returns the object schema of struct type t. Properties are
+// named by JSON tag; those without omitempty are required.
+func structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = typeSchema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// typeSchema returns the schema of a field of type t.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if name, ok := openAPISchemas[t]; ok {
+		return schemaRef(name)
+	}
+	if schema, ok := openAPIOverrides[t]; ok {
+		return schema
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+// schemaRef refers to the named schema under components/schemas.
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// jsonContent describes an application/json body with the given schema.
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// TestOpenAPIDocument fetches /openapi.json and checks that it is valid
+// JSON describing the documented paths, with a Patient schema whose
+// properties are exactly Patient's JSON tags.
+func TestOpenAPIDocument(t *testing.T) {
+	db := simulator.NewDatabase(1, 2, 0)
+	mux := newServeMux(validConfig(), http.NotFoundHandler(), db, metrics.NewCollector(), nil)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("/openapi.json = %d (%s), want 200 JSON", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	var doc struct {
+		OpenAPI    string                     `json:"openapi"`
+		Paths      map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+				Required   []string                          `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("/openapi.json is not valid JSON: %v", err)
+	}
+
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want 3.x", doc.OpenAPI)
+	}
+	for _, path := range []string{"/api/v1/patients", "/health", "/metrics"} {
+		if _, ok := doc.Paths[path]; !ok {
+			t.Errorf("paths missing %s", path)
+		}
+	}
+
+	patient, ok := doc.Components.Schemas["Patient"]
+	if !ok {
+		t.Fatal("components/schemas missing Patient")
+	}
+	var want []string
+	fields := reflect.TypeOf(models.Patient{})
+	for i := 0; i < fields.NumField(); i++ {
+		name, _, _ := strings.Cut(fields.Field(i).Tag.Get("json"), ",")
+		want = append(want, name)
+	}
+	var got []string
+	for name := range patient.Properties {
+		got = append(got, name)
+	}
+	sort.Strings(want)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Patient properties = %v, want %v", got, want)
+	}
+	if typ := patient.Properties["date_of_birth"]["format"]; typ != "date-time" {
+		t.Errorf("date_of_birth format = %v, want date-time", typ)
+	}
+	if typ := patient.Properties["diagnosis_codes"]["type"]; typ != "array" {
+		t.Errorf("diagnosis_codes type = %v, want array", typ)
+	}
+
+	// The response refers to the patient schema, which is optional in it
+	response := doc.Components.Schemas["PatientResponse"]
+	if ref := response.Properties["patient"]["$ref"]; ref != "#/components/schemas/Patient" {
+		t.Errorf("PatientResponse.patient = %v, want a reference to Patient", response.Properties["patient"])
+	}
+	for _, name := range response.Required {
+		if name == "patient" || name == "error" {
+			t.Errorf("PatientResponse requires omitempty field %q", name)
+		}
+	}
+}
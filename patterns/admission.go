@@ -0,0 +1,105 @@
+package patterns
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Tuning for the adaptive admission controller.
+const (
+	sloWindow       = 40  // Recent processing latencies the P95 is taken over
+	sloAdjustEvery  = 10  // Samples between adjustments of the shed fraction
+	shedStep        = 0.1 // Change in shed fraction per adjustment
+	maxShedFraction = 0.9 // Always admit some traffic, so recovery is observed
+)
+
+// ErrLoadShed is returned when the admission controller rejects a request.
+var ErrLoadShed = errors.New("load shed: latency above target")
+
+// admissionController rejects a growing fraction of requests while recent
+// P95 processing latency is above a target, and admits more again as it
+// recovers.
+//
+// A full queue only sheds load once the damage is done: every queued
+// request is already late. Watching latency instead reacts as soon as the
+// database starts to slow down, the same idea as Netflix's
+// concurrency-limits. The controller adjusts additively every
+// sloAdjustEvery samples, so a brief spike sheds little and a sustained
+// one sheds up to maxShedFraction.
+//
+// A nil *admissionController admits everything.
+type admissionController struct {
+	target time.Duration
+
+	mu          sync.Mutex
+	window      [sloWindow]time.Duration
+	next        int // Ring buffer write position
+	filled      int
+	sinceAdjust int
+
+	shedBits atomic.Uint64 // math.Float64bits of the shed fraction
+}
+
+// newAdmissionController returns a controller targeting the given P95
+// processing latency, or nil when target is zero (shedding disabled).
+func newAdmissionController(target time.Duration) *admissionController {
+	if target <= 0 {
+		return nil
+	}
+	return &admissionController{target: target}
+}
+
+// shedFraction returns the fraction of requests currently rejected.
+func (a *admissionController) shedFraction() float64 {
+	if a == nil {
+		return 0
+	}
+	return math.Float64frombits(a.shedBits.Load())
+}
+
+// shouldShed reports whether to reject the next request.
+func (a *admissionController) shouldShed() bool {
+	fraction := a.shedFraction()
+	return fraction > 0 && rand.Float64() < fraction
+}
+
+// observe records how long a worker spent processing one job.
+func (a *admissionController) observe(latency time.Duration) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.window[a.next] = latency
+	a.next = (a.next + 1) % sloWindow
+	a.filled = min(a.filled+1, sloWindow)
+
+	a.sinceAdjust++
+	if a.sinceAdjust < sloAdjustEvery {
+		return
+	}
+	a.sinceAdjust = 0
+
+	fraction := a.shedFraction()
+	if a.p95() > a.target {
+		fraction = math.Min(fraction+shedStep, maxShedFraction)
+	} else {
+		fraction = math.Max(fraction-shedStep, 0)
+	}
+	a.shedBits.Store(math.Float64bits(fraction))
+}
+
+// p95 returns the 95th percentile of the window. Callers hold a.mu.
+func (a *admissionController) p95() time.Duration {
+	sorted := make([]time.Duration, a.filled)
+	copy(sorted, a.window[:a.filled])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(len(sorted)*95+99)/100-1]
+}
@@ -0,0 +1,107 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// driveUntil sends requests to h from several clients until done reports
+// true, failing the test if that takes longer than timeout. It returns how
+// many requests were shed along the way.
+func driveUntil(t *testing.T, h poolHandler, timeout time.Duration, done func(shedFraction float64) bool) (shed int) {
+	t.Helper()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		finished = make(chan struct{})
+		once     sync.Once
+	)
+	for c := 0; c < 8; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-finished:
+					return
+				default:
+				}
+
+				_, err := h.HandleRequest(context.Background(), "P00001")
+				if errors.Is(err, ErrLoadShed) {
+					mu.Lock()
+					shed++
+					mu.Unlock()
+				}
+
+				if fraction := h.GetStats().ShedFraction; done(fraction) {
+					once.Do(func() { close(finished) })
+				}
+			}
+		}()
+	}
+
+	select {
+	case <-finished:
+	case <-time.After(timeout):
+		once.Do(func() { close(finished) })
+		wg.Wait()
+		fraction := h.GetStats().ShedFraction
+		t.Fatalf("shed fraction stuck at %.1f after %v", fraction, timeout)
+	}
+	wg.Wait()
+	return shed
+}
+
+// TestLatencySLOShedsLoadAndRecovers injects tail latency above the SLO,
+// expects the shed fraction to climb, then removes it and expects the
+// controller to back off to admitting everything.
+func TestLatencySLOShedsLoadAndRecovers(t *testing.T) {
+	const slo = 10 * time.Millisecond
+	fast := simulator.NewTraceLatencySource([]time.Duration{time.Millisecond})
+	slow := simulator.NewTraceLatencySource([]time.Duration{3 * slo})
+
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFixedLatencyDatabase(time.Millisecond)
+			h := tc.new(db, WorkerPoolConfig{Workers: 8, QueueSize: 16, EnqueueTimeout: time.Second, LatencySLO: slo})
+			defer shutdownHandler(t, h)
+
+			// Within the SLO nothing is shed
+			for i := 0; i < 2*sloWindow; i++ {
+				if _, err := h.HandleRequest(context.Background(), "P00001"); err != nil {
+					t.Fatalf("request %d within the SLO: %v", i, err)
+				}
+			}
+			if fraction := h.GetStats().ShedFraction; fraction != 0 {
+				t.Fatalf("shed fraction = %.1f within the SLO, want 0", fraction)
+			}
+
+			db.SetLatencySource(slow)
+			shed := driveUntil(t, h, 5*time.Second, func(fraction float64) bool { return fraction >= 0.5 })
+			if shed == 0 {
+				t.Errorf("shed fraction rose but no request was rejected with ErrLoadShed")
+			}
+
+			db.SetLatencySource(fast)
+			driveUntil(t, h, 5*time.Second, func(fraction float64) bool { return fraction == 0 })
+		})
+	}
+}
+
+func TestLatencySLODisabledByDefault(t *testing.T) {
+	db := newFixedLatencyDatabase(20 * time.Millisecond)
+	h := NewWorkerPoolHandler(db, WorkerPoolConfig{Workers: 4, QueueSize: 100})
+	defer shutdownHandler(t, h)
+
+	rejected, _ := runBurst(h, 3*sloWindow)
+	if fraction := h.GetStats().ShedFraction; rejected != 0 || fraction != 0 {
+		t.Errorf("rejected %d requests, shed fraction %.1f; want no shedding without LatencySLO", rejected, fraction)
+	}
+}
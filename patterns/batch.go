@@ -0,0 +1,131 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// MaxBatchSize is the most patient IDs a single batch request may name.
+const MaxBatchSize = 100
+
+// Batch engines selectable with the engine query parameter.
+const (
+	EngineSequential = "sequential"
+	EngineFanOut     = "fanout"
+)
+
+// BatchHandler serves multi-patient lookups:
+//
+//	GET /api/v1/patients/batch?ids=P1,P2,P3&engine=fanout
+//
+// The sequential engine (the default) uses the store's
+// BatchQueryPatients if it is a BatchQuerier, and otherwise one query
+// after another. The fanout engine uses a FanOutHandler. Both
+// return the patients in the order requested. On an error or timeout the
+// sequential engine returns the patients it fetched before it stopped and
+// lists the rest as missing; the fanout engine fails the whole batch.
+type BatchHandler struct {
+	db     PatientStore
+	fanOut *FanOutHandler
+}
+
+// NewBatchHandler creates a batch handler whose fanout engine uses config.
+func NewBatchHandler(db PatientStore, config FanOutConfig) *BatchHandler {
+	return &BatchHandler{
+		db:     db,
+		fanOut: NewFanOutHandler(db, config),
+	}
+}
+
+// extractPatientIDs returns the non-empty entries of the comma-separated
+// ids query parameter.
+func extractPatientIDs(r *http.Request) []string {
+	var ids []string
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ServeHTTP implements http.Handler for the batch endpoint.
+func (h *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ids := extractPatientIDs(r)
+	if len(ids) == 0 {
+		http.Error(w, "ids is required", http.StatusBadRequest)
+		return
+	}
+	if len(ids) > MaxBatchSize {
+		http.Error(w, fmt.Sprintf("at most %d ids per batch", MaxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	engine := r.URL.Query().Get("engine")
+	switch engine {
+	case "":
+		engine = EngineSequential
+	case EngineSequential, EngineFanOut:
+	default:
+		http.Error(w, fmt.Sprintf("unknown engine %q (want %s or %s)", engine, EngineSequential, EngineFanOut), http.StatusBadRequest)
+		return
+	}
+
+	patients, err := h.HandleBatch(r.Context(), engine, ids)
+
+	response := &models.BatchResponse{
+		Success:   err == nil,
+		Engine:    engine,
+		Patients:  patients,
+		Timestamp: time.Now(),
+		RequestID: requestID(r),
+	}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	var partial *simulator.PartialResultError
+	if errors.As(err, &partial) {
+		response.Missing = partial.Missing
+	}
+
+	status := http.StatusOK
+	if err != nil {
+		status = statusForError(err)
+	}
+	WriteJSON(w, r, status, response)
+}
+
+// HandleBatch looks up ids with the named engine and returns the patients
+// in the order requested. The sequential engine may return some patients
+// alongside a *simulator.PartialResultError.
+func (h *BatchHandler) HandleBatch(ctx context.Context, engine string, ids []string) ([]*models.Patient, error) {
+	switch engine {
+	case EngineSequential:
+		return batchQuery(ctx, h.db, ids)
+	case EngineFanOut:
+		responses, err := h.fanOut.HandleBatch(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		patients := make([]*models.Patient, len(responses))
+		for i, response := range responses {
+			patients[i] = response.Patient
+		}
+		return patients, nil
+	default:
+		return nil, fmt.Errorf("unknown batch engine %q", engine)
+	}
+}
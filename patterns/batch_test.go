@@ -0,0 +1,94 @@
+package patterns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+func getBatch(t *testing.T, h http.Handler, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patients/batch?"+query, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBatchEnginesReturnPatientsInOrder(t *testing.T) {
+	h := NewBatchHandler(newFastDatabase(), DefaultFanOutConfig())
+	ids := batchIDs(25)
+
+	for _, engine := range []string{"", EngineSequential, EngineFanOut} {
+		t.Run(engine, func(t *testing.T) {
+			rec := getBatch(t, h, "engine="+engine+"&ids="+strings.Join(ids, ","))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+			}
+
+			var response models.BatchResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			want := engine
+			if want == "" {
+				want = EngineSequential
+			}
+			if !response.Success || response.Engine != want {
+				t.Errorf("success = %v, engine = %q; want true, %q", response.Success, response.Engine, want)
+			}
+			if len(response.Patients) != len(ids) {
+				t.Fatalf("got %d patients, want %d", len(response.Patients), len(ids))
+			}
+			for i, patient := range response.Patients {
+				if patient.ID != ids[i] {
+					t.Errorf("patient %d is %s, want %s", i, patient.ID, ids[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBatchRejectsBadRequests(t *testing.T) {
+	h := NewBatchHandler(newFastDatabase(), DefaultFanOutConfig())
+
+	tests := map[string]string{
+		"no ids":         "",
+		"only commas":    "ids=,,",
+		"unknown engine": "ids=P1&engine=parallel",
+		"too many ids":   "ids=" + strings.Join(batchIDs(MaxBatchSize+1), ","),
+	}
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			if rec := getBatch(t, h, query); rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+}
+
+func TestBatchReportsDatabaseErrors(t *testing.T) {
+	db := simulator.NewDatabase(1, 2, 1)
+	h := NewBatchHandler(db, DefaultFanOutConfig())
+
+	for _, engine := range []string{EngineSequential, EngineFanOut} {
+		t.Run(engine, func(t *testing.T) {
+			rec := getBatch(t, h, "engine="+engine+"&ids=P1,P2,P3")
+			if rec.Code != http.StatusGatewayTimeout {
+				t.Fatalf("status = %d, want 504", rec.Code)
+			}
+
+			var response models.BatchResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if response.Success || response.Error == "" || len(response.Patients) != 0 {
+				t.Errorf("unexpected failed response: %+v", response)
+			}
+		})
+	}
+}
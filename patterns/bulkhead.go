@@ -0,0 +1,274 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// BulkheadHandler isolates classes of traffic from one another by giving
+// each class its own worker pool.
+//
+// HOW IT DIFFERS FROM THE WORKER POOL:
+//
+// 1. Reserved Capacity:
+//    - Each class (e.g. "routine" and "stat") has its own workers and queue
+//    - A flood of routine lookups fills only the routine queue; stat
+//      requests still find their workers idle
+//    - Like watertight compartments in a ship's hull, a breach in one
+//      doesn't sink the others
+//
+// 2. Isolation, Not Ordering:
+//    - No class is served ahead of another; each is simply never starved
+//    - Slow queries or errors in one class can't tie up another's workers
+//
+// 3. Trade-offs:
+//    - Capacity reserved for a quiet class sits idle while a busy one
+//      rejects requests
+//    - Sizing each class needs some idea of its traffic
+//
+// WHEN TO USE:
+// - Urgent traffic (stat orders, ICU dashboards) sharing a service with
+//   bulk or background work
+// - Multi-tenant services where one tenant mustn't degrade the rest
+//
+// Requests pick their class with the X-Request-Class header or
+// WithRequestClass; those that don't use DefaultClass.
+type BulkheadHandler struct {
+	classes      map[string]*WorkerPoolHandler
+	defaultClass string
+}
+
+// BulkheadConfig holds configuration for the bulkhead handler.
+type BulkheadConfig struct {
+	// Classes gives each request class the configuration of its own
+	// worker pool.
+	Classes map[string]WorkerPoolConfig
+
+	// DefaultClass serves requests that name no class. If it isn't one of
+	// Classes, such requests fail with ErrUnknownClass.
+	DefaultClass string
+}
+
+// Request classes used by DefaultBulkheadConfig.
+const (
+	ClassRoutine = "routine"
+	ClassStat    = "stat"
+)
+
+// DefaultBulkheadConfig returns a routine class and a smaller stat class
+// for urgent requests, splitting the worker pool's default workers and
+// queue between them.
+func DefaultBulkheadConfig() BulkheadConfig {
+	return SplitBulkheadConfig(DefaultWorkerPoolConfig())
+}
+
+// SplitBulkheadConfig divides pool's workers and queue between a routine
+// class, the default, and a stat class that gets a quarter of each (at
+// least one). Both classes keep pool's other settings.
+func SplitBulkheadConfig(pool WorkerPoolConfig) BulkheadConfig {
+	stat := pool
+	stat.Workers = max(pool.Workers/4, 1)
+	stat.QueueSize = max(pool.QueueSize/4, 1)
+
+	routine := pool
+	routine.Workers = max(pool.Workers-stat.Workers, 1)
+	routine.QueueSize = max(pool.QueueSize-stat.QueueSize, 1)
+
+	return BulkheadConfig{
+		Classes:      map[string]WorkerPoolConfig{ClassRoutine: routine, ClassStat: stat},
+		DefaultClass: ClassRoutine,
+	}
+}
+
+// NewBulkheadHandler creates a bulkhead handler and starts every class's
+// workers.
+func NewBulkheadHandler(db PatientStore, config BulkheadConfig) *BulkheadHandler {
+	h := &BulkheadHandler{
+		classes:      make(map[string]*WorkerPoolHandler, len(config.Classes)),
+		defaultClass: config.DefaultClass,
+	}
+	for class, poolConfig := range config.Classes {
+		h.classes[class] = NewWorkerPoolHandler(db, poolConfig)
+	}
+	return h
+}
+
+// RequestClassHeader names a request's bulkhead class over HTTP.
+const RequestClassHeader = "X-Request-Class"
+
+// ErrUnknownClass is returned for a request whose class has no bulkhead.
+// It wraps ErrInvalidRequest, so it maps to 400 Bad Request.
+var ErrUnknownClass = fmt.Errorf("%w: unknown request class", ErrInvalidRequest)
+
+// requestClassKey is the context key for the request class.
+type requestClassKey struct{}
+
+// WithRequestClass returns a copy of ctx whose requests BulkheadHandler
+// serves from the given class.
+func WithRequestClass(ctx context.Context, class string) context.Context {
+	return context.WithValue(ctx, requestClassKey{}, class)
+}
+
+// RequestClassFromContext returns the class stored by WithRequestClass,
+// or "" if there is none.
+func RequestClassFromContext(ctx context.Context) string {
+	class, _ := ctx.Value(requestClassKey{}).(string)
+	return class
+}
+
+// pool returns the worker pool serving class, or DefaultClass's when
+// class is empty.
+func (h *BulkheadHandler) pool(class string) (*WorkerPoolHandler, error) {
+	if class == "" {
+		class = h.defaultClass
+	}
+	pool, ok := h.classes[class]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownClass, class)
+	}
+	return pool, nil
+}
+
+// ServeHTTP handles incoming HTTP requests in the worker pool of their
+// class, taken from the X-Request-Class header or else the context.
+// GET reads a patient; PUT writes the JSON body.
+func (h *BulkheadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, span := startSpan(r.Context(), "Bulkhead.ServeHTTP")
+	defer span.End()
+
+	if _, err := parseFields(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var update *models.Patient
+	patientID := extractPatientID(r)
+
+	if r.Method == http.MethodPut {
+		patient, err := decodePatient(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		update, patientID = patient, patient.ID
+	}
+
+	if patientID == "" {
+		http.Error(w, "patient ID required", http.StatusBadRequest)
+		return
+	}
+
+	class := r.Header.Get(RequestClassHeader)
+	if class == "" {
+		class = RequestClassFromContext(ctx)
+	}
+	pool, err := h.pool(class)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response, err := pool.submit(ctx, newJob(ctx, patientID, update))
+	switch {
+	case err == nil:
+		response.RequestID = requestID(r)
+		writeResponse(w, r, http.StatusOK, response)
+	case ctx.Err() != nil:
+		http.Error(w, "request cancelled", http.StatusRequestTimeout)
+	case errors.Is(err, ErrQueueFull), errors.Is(err, ErrLoadShed):
+		recordError(span, err)
+		rejectOverloaded(w, pool.rejectStatus)
+	default:
+		recordError(span, err)
+		writeError(w, r, err, pool.rejectStatus)
+	}
+}
+
+// HandleRequest is the non-HTTP interface for benchmarking. The class
+// comes from ctx; see WithRequestClass.
+func (h *BulkheadHandler) HandleRequest(ctx context.Context, patientID string) (response *models.PatientResponse, err error) {
+	ctx, span := startSpan(ctx, "Bulkhead.HandleRequest")
+	defer func() { endSpan(span, err) }()
+
+	return h.run(ctx, patientID, nil)
+}
+
+// HandleUpdate is the non-HTTP interface for benchmarking writes.
+func (h *BulkheadHandler) HandleUpdate(ctx context.Context, patient *models.Patient) (response *models.PatientResponse, err error) {
+	ctx, span := startSpan(ctx, "Bulkhead.HandleUpdate")
+	defer func() { endSpan(span, err) }()
+
+	return h.run(ctx, patient.ID, patient)
+}
+
+// run submits a read, or a write when update is non-nil, to the pool of
+// ctx's class.
+func (h *BulkheadHandler) run(ctx context.Context, patientID string, update *models.Patient) (*models.PatientResponse, error) {
+	pool, err := h.pool(RequestClassFromContext(ctx))
+	if err != nil {
+		return models.NewErrorResponse(err, ""), err
+	}
+	return pool.submit(ctx, newJob(ctx, patientID, update))
+}
+
+// GetName returns the name of this pattern for reporting.
+func (h *BulkheadHandler) GetName() string {
+	classes := make([]string, 0, len(h.classes))
+	for _, class := range h.sortedClasses() {
+		classes = append(classes, fmt.Sprintf("%s: %d workers", class, h.classes[class].workers))
+	}
+	return fmt.Sprintf("Bulkhead (%s)", strings.Join(classes, ", "))
+}
+
+// BulkheadClassStats describes one class's worker pool.
+type BulkheadClassStats struct {
+	Workers        int
+	ActiveJobs     int64
+	QueuedJobs     int64
+	QueueCapacity  int
+	DeadlineMisses int64
+}
+
+// GetStats returns the state of each class's worker pool, keyed by class.
+func (h *BulkheadHandler) GetStats() map[string]BulkheadClassStats {
+	stats := make(map[string]BulkheadClassStats, len(h.classes))
+	for class, pool := range h.classes {
+		stats[class] = BulkheadClassStats{
+			Workers:        pool.workers,
+			ActiveJobs:     atomic.LoadInt64(&pool.activeJobs),
+			QueuedJobs:     atomic.LoadInt64(&pool.queuedJobs),
+			QueueCapacity:  pool.queueSize,
+			DeadlineMisses: atomic.LoadInt64(&pool.deadlineMisses),
+		}
+	}
+	return stats
+}
+
+// Shutdown shuts down every class's worker pool; see
+// WorkerPoolHandler.Shutdown.
+func (h *BulkheadHandler) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, class := range h.sortedClasses() {
+		if err := h.classes[class].Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("class %s: %w", class, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sortedClasses returns the class names in order, for stable output.
+func (h *BulkheadHandler) sortedClasses() []string {
+	classes := make([]string, 0, len(h.classes))
+	for class := range h.classes {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	return classes
+}
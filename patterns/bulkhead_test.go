@@ -0,0 +1,112 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestBulkhead returns a bulkhead with one single-worker pool per class.
+func newTestBulkhead(latency time.Duration) *BulkheadHandler {
+	class := WorkerPoolConfig{Workers: 1, QueueSize: 2, EnqueueTimeout: time.Millisecond}
+	return NewBulkheadHandler(newFixedLatencyDatabase(latency), BulkheadConfig{
+		Classes:      map[string]WorkerPoolConfig{ClassRoutine: class, ClassStat: class},
+		DefaultClass: ClassRoutine,
+	})
+}
+
+// TestBulkheadIsolatesClasses floods the routine class far past its
+// capacity and checks that stat requests all succeed as fast as an idle
+// pool serves them.
+func TestBulkheadIsolatesClasses(t *testing.T) {
+	const latency = 20 * time.Millisecond
+
+	h := newTestBulkhead(latency)
+	defer shutdownHandler(t, h)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		rejected int
+		stop     = make(chan struct{})
+	)
+	routine := WithRequestClass(context.Background(), ClassRoutine)
+	for c := 0; c < 8; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := h.HandleRequest(routine, "P00001"); errors.Is(err, ErrQueueFull) {
+					mu.Lock()
+					rejected++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	waitFor(t, func() bool { return h.GetStats()[ClassRoutine].QueuedJobs == 2 })
+
+	stat := WithRequestClass(context.Background(), ClassStat)
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		if _, err := h.HandleRequest(stat, "P00002"); err != nil {
+			t.Errorf("stat request %d during the flood: %v", i, err)
+		}
+		if elapsed := time.Since(start); elapsed > 3*latency {
+			t.Errorf("stat request %d took %v during the flood, want under %v", i, elapsed, 3*latency)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+	if rejected == 0 {
+		t.Error("the flood never filled the routine class")
+	}
+}
+
+// TestBulkheadRequestClass checks requests land in the class named by the
+// header or the context, unnamed ones in the default class, and unknown
+// ones are rejected.
+func TestBulkheadRequestClass(t *testing.T) {
+	h := newTestBulkhead(200 * time.Millisecond)
+	defer shutdownHandler(t, h)
+
+	get := func(class string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil)
+		if class != "" {
+			req.Header.Set(RequestClassHeader, class)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	go get(ClassStat)
+	waitFor(t, func() bool { return h.GetStats()[ClassStat].ActiveJobs == 1 })
+	go get("")
+	waitFor(t, func() bool { return h.GetStats()[ClassRoutine].ActiveJobs == 1 })
+
+	go h.HandleRequest(WithRequestClass(context.Background(), ClassStat), "P00001")
+	waitFor(t, func() bool { return h.GetStats()[ClassStat].QueuedJobs == 1 })
+	if stats := h.GetStats()[ClassRoutine]; stats.QueuedJobs != 0 {
+		t.Errorf("routine class queued %d jobs, want 0", stats.QueuedJobs)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil)
+	req.Header.Set(RequestClassHeader, "elective")
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown class over HTTP: status = %d, want 400", rec.Code)
+	}
+	ctx := WithRequestClass(context.Background(), "elective")
+	if _, err := h.HandleRequest(ctx, "P00001"); !errors.Is(err, ErrUnknownClass) || !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("unknown class: got %v, want ErrUnknownClass", err)
+	}
+}
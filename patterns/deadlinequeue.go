@@ -0,0 +1,99 @@
+package patterns
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// deadlineQueue holds the queued jobs of a worker pool running with EDF
+// scheduling and hands out the job whose context deadline is nearest.
+//
+// Under FIFO a job with a tight deadline waits behind every job queued
+// before it, however much slack they have, and may expire in the queue.
+// Serving the earliest deadline first spends that slack instead, so
+// under load fewer jobs miss their deadlines. Jobs without a deadline can
+// wait indefinitely and come last; jobs with equal deadlines run in
+// arrival order.
+//
+// Like fairQueue, the pool sends a nil placeholder on jobQueue for each
+// job and the worker that receives it pops the job from here; slots keeps
+// the queue no larger than jobQueue.
+//
+// A nil *deadlineQueue is disabled.
+type deadlineQueue[J any] struct {
+	slots chan struct{} // One token per queued job; full when the queue is full
+
+	mu   sync.Mutex
+	jobs deadlineHeap[J]
+	seq  uint64 // Arrival order, to break ties
+}
+
+// newDeadlineQueue returns a deadline queue holding up to capacity jobs,
+// or nil when EDF scheduling is disabled.
+func newDeadlineQueue[J any](enabled bool, capacity int) *deadlineQueue[J] {
+	if !enabled {
+		return nil
+	}
+	return &deadlineQueue[J]{slots: make(chan struct{}, capacity)}
+}
+
+// push queues j under ctx's deadline. The caller must already hold a slot.
+func (q *deadlineQueue[J]) push(ctx context.Context, j J) {
+	deadline, ok := ctx.Deadline()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	heap.Push(&q.jobs, deadlineEntry[J]{deadline: deadline, hasDeadline: ok, seq: q.seq, job: j})
+}
+
+// pop removes the job with the earliest deadline and frees its slot.
+func (q *deadlineQueue[J]) pop() J {
+	q.mu.Lock()
+	j := heap.Pop(&q.jobs).(deadlineEntry[J]).job
+	q.mu.Unlock()
+
+	<-q.slots
+	return j
+}
+
+// deadlineEntry is a queued job and the key it is ordered by.
+type deadlineEntry[J any] struct {
+	deadline    time.Time
+	hasDeadline bool
+	seq         uint64
+	job         J
+}
+
+// deadlineHeap orders entries by deadline, with no deadline last and ties
+// in arrival order. It implements heap.Interface.
+type deadlineHeap[J any] []deadlineEntry[J]
+
+func (h deadlineHeap[J]) Len() int { return len(h) }
+
+func (h deadlineHeap[J]) Less(i, j int) bool {
+	a, b := h[i], h[j]
+	if a.hasDeadline != b.hasDeadline {
+		return a.hasDeadline
+	}
+	if a.hasDeadline && !a.deadline.Equal(b.deadline) {
+		return a.deadline.Before(b.deadline)
+	}
+	return a.seq < b.seq
+}
+
+func (h deadlineHeap[J]) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *deadlineHeap[J]) Push(x any) { *h = append(*h, x.(deadlineEntry[J])) }
+
+func (h *deadlineHeap[J]) Pop() any {
+	old := *h
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = deadlineEntry[J]{} // Don't keep the job reachable
+	*h = old[:n-1]
+	return entry
+}
@@ -0,0 +1,146 @@
+package patterns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// getPatient fetches id from h with the given Accept header and decodes
+// the response according to its Content-Type.
+func getPatient(t *testing.T, h http.Handler, id, accept string) *models.PatientResponse {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id="+id, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s (Accept %q) = %d: %s", id, accept, rec.Code, rec.Body.String())
+	}
+
+	var response models.PatientResponse
+	switch contentType := rec.Header().Get("Content-Type"); contentType {
+	case ContentTypeProtobuf:
+		if err := response.UnmarshalProto(rec.Body.Bytes()); err != nil {
+			t.Fatalf("decode protobuf: %v", err)
+		}
+	case "application/json":
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("decode JSON: %v", err)
+		}
+	default:
+		t.Fatalf("GET %s (Accept %q): Content-Type = %q", id, accept, contentType)
+	}
+	return &response
+}
+
+// TestContentNegotiation fetches the same corpus patients as JSON and as
+// protobuf from every pattern and checks both decode to the same patient.
+// Requests alternate encodings so the optimized pattern reuses each pooled
+// response across them.
+func TestContentNegotiation(t *testing.T) {
+	for _, tc := range allHandlers {
+		t.Run(tc.name, func(t *testing.T) {
+			db := simulator.NewDatabase(1, 2, 0, simulator.WithCorpus(10, simulator.DefaultCorpusSeed))
+			h := tc.new(db)
+			defer shutdownHandler(t, h)
+
+			for _, id := range []string{"P00001", "P00002", "P00003"} {
+				asJSON := getPatient(t, h, id, "application/json")
+				asProto := getPatient(t, h, id, ContentTypeProtobuf+", application/json;q=0.5")
+
+				if !asProto.Success || asProto.Error != "" {
+					t.Errorf("%s: protobuf response not successful: %+v", id, asProto)
+				}
+				if asProto.Patient == nil || asJSON.Patient == nil {
+					t.Fatalf("%s: missing patient: JSON %+v, protobuf %+v", id, asJSON, asProto)
+				}
+				if asProto.Patient.ID != id {
+					t.Errorf("%s: protobuf patient has ID %q", id, asProto.Patient.ID)
+				}
+
+				// Compare as JSON, which is what clients see either way
+				want, _ := json.Marshal(asJSON.Patient)
+				got, _ := json.Marshal(asProto.Patient)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s: protobuf patient differs from JSON:\n got %s\nwant %s", id, got, want)
+				}
+			}
+
+			// No Accept, or one that refuses protobuf, gets JSON
+			getPatient(t, h, "P00004", "")
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00004", nil)
+			req.Header.Set("Accept", ContentTypeProtobuf+";q=0, application/json")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Accept with protobuf q=0: Content-Type = %q, want application/json", got)
+			}
+		})
+	}
+}
+
+// TestConditionalGet checks every pattern tags a corpus patient with an
+// ETag that holds across requests, answers a matching If-None-Match with
+// an empty 304, and tags the protobuf representation differently.
+func TestConditionalGet(t *testing.T) {
+	for _, tc := range allHandlers {
+		t.Run(tc.name, func(t *testing.T) {
+			db := simulator.NewDatabase(1, 2, 0, simulator.WithCorpus(10, simulator.DefaultCorpusSeed))
+			h := tc.new(db)
+			defer shutdownHandler(t, h)
+
+			get := func(accept, ifNoneMatch string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil)
+				if accept != "" {
+					req.Header.Set("Accept", accept)
+				}
+				if ifNoneMatch != "" {
+					req.Header.Set("If-None-Match", ifNoneMatch)
+				}
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				return rec
+			}
+
+			first := get("", "")
+			etag := first.Header().Get("ETag")
+			if first.Code != http.StatusOK || etag == "" {
+				t.Fatalf("first GET = %d with ETag %q, want 200 with an ETag", first.Code, etag)
+			}
+			if again := get("", "").Header().Get("ETag"); again != etag {
+				t.Errorf("ETag changed between requests: %q then %q", etag, again)
+			}
+
+			conditional := get("", `"stale", `+etag)
+			if conditional.Code != http.StatusNotModified {
+				t.Fatalf("GET with matching If-None-Match = %d, want 304", conditional.Code)
+			}
+			if conditional.Body.Len() != 0 || conditional.Header().Get("ETag") != etag {
+				t.Errorf("304 has body %q and ETag %q, want no body and %q", conditional.Body.String(), conditional.Header().Get("ETag"), etag)
+			}
+
+			if rec := get("", `"stale"`); rec.Code != http.StatusOK {
+				t.Errorf("GET with non-matching If-None-Match = %d, want 200", rec.Code)
+			}
+
+			// The protobuf body is a different representation, so the JSON
+			// tag mustn't validate it
+			protobuf := get(ContentTypeProtobuf, etag)
+			if protobuf.Code != http.StatusOK || protobuf.Header().Get("ETag") == etag {
+				t.Errorf("protobuf GET with the JSON ETag = %d with ETag %q, want 200 and a different tag", protobuf.Code, protobuf.Header().Get("ETag"))
+			}
+			if rec := get(ContentTypeProtobuf, protobuf.Header().Get("ETag")); rec.Code != http.StatusNotModified {
+				t.Errorf("protobuf GET with its own ETag = %d, want 304", rec.Code)
+			}
+		})
+	}
+}
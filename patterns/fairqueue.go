@@ -0,0 +1,89 @@
+package patterns
+
+import "sync"
+
+// fairQueue holds the queued jobs of a worker pool running with Fairness,
+// one sub-queue per patient ID, and hands them out round-robin across IDs.
+//
+// A plain FIFO serves a hotspot in arrival order: a flood of requests for
+// one patient delays every other patient queued behind it. Taking one job
+// from each ID in turn bounds the wait of a quiet ID by the number of
+// distinct busy IDs rather than the length of the queue.
+//
+// The pool still sends on jobQueue for every job, so admission, waiting
+// for space and shutdown work as before, but it sends a nil placeholder:
+// the worker that receives it pops the job itself from here. slots keeps
+// the fair queue no larger than jobQueue, and is taken before a job is
+// pushed, so every placeholder a worker receives has a job waiting.
+//
+// A nil *fairQueue is disabled.
+type fairQueue[J any] struct {
+	slots chan struct{} // One token per queued job; full when the queue is
+
+	mu     sync.Mutex
+	queues map[string][]J
+	keys   []string // IDs with queued jobs, in the order they are served
+}
+
+// newFairQueue returns a fair queue holding up to capacity jobs, or nil
+// when fairness is disabled.
+func newFairQueue[J any](enabled bool, capacity int) *fairQueue[J] {
+	if !enabled {
+		return nil
+	}
+	return &fairQueue[J]{
+		slots:  make(chan struct{}, capacity),
+		queues: make(map[string][]J),
+	}
+}
+
+// push queues j behind any other jobs for key. The caller must already
+// hold a slot.
+func (q *fairQueue[J]) push(key string, j J) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.queues[key]; !ok {
+		q.keys = append(q.keys, key)
+	}
+	q.queues[key] = append(q.queues[key], j)
+}
+
+// pop removes the oldest job of the next ID in turn, moves that ID to the
+// back of the line, and frees the job's slot.
+func (q *fairQueue[J]) pop() J {
+	q.mu.Lock()
+	key := q.keys[0]
+	queue := q.queues[key]
+	j := queue[0]
+
+	var zero J
+	queue[0] = zero // Don't keep the job reachable
+	q.keys = q.keys[1:]
+	if len(queue) == 1 {
+		delete(q.queues, key)
+	} else {
+		q.queues[key] = queue[1:]
+		q.keys = append(q.keys, key)
+	}
+	q.mu.Unlock()
+
+	<-q.slots
+	return j
+}
+
+// depths returns how many jobs are queued for each ID.
+func (q *fairQueue[J]) depths() map[string]int {
+	if q == nil {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	depths := make(map[string]int, len(q.queues))
+	for key, queue := range q.queues {
+		depths[key] = len(queue)
+	}
+	return depths
+}
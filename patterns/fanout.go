@@ -0,0 +1,180 @@
+package patterns
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// FanOutHandler looks up many patients at once by fanning the IDs out to a
+// bounded set of goroutines and fanning the results back in, in order.
+//
+// HOW IT DIFFERS FROM simulator.BatchQueryPatients:
+//
+// 1. Parallel, Not Sequential:
+//    - BatchQueryPatients queries one ID after another, so a batch of N
+//      takes N query latencies
+//    - Here up to MaxParallel queries run at once, so the same batch takes
+//      about N / MaxParallel query latencies
+//
+// 2. Bounded:
+//    - At most MaxParallel goroutines exist per batch, however large it is
+//    - Each goroutine pulls the next index until the batch is done
+//
+// 3. Fail Fast:
+//    - The first failed lookup cancels the rest, as does cancelling ctx
+//    - Every goroutine has exited by the time HandleBatch returns
+//
+// WHEN TO USE:
+// - Ward census, care-team rosters and other multi-record reads where
+//   lookups are independent and latency matters more than database load
+type FanOutHandler struct {
+	db          PatientStore
+	maxParallel int
+	inFlight    int64
+}
+
+// FanOutConfig holds configuration for the fan-out handler.
+type FanOutConfig struct {
+	MaxParallel int // Lookups in flight at once per batch
+}
+
+// DefaultFanOutConfig returns a moderate per-batch parallelism.
+func DefaultFanOutConfig() FanOutConfig {
+	return FanOutConfig{
+		MaxParallel: 10,
+	}
+}
+
+// NewFanOutHandler creates a new fan-out batch handler.
+func NewFanOutHandler(db PatientStore, config FanOutConfig) *FanOutHandler {
+	return &FanOutHandler{
+		db:          db,
+		maxParallel: max(config.MaxParallel, 1),
+	}
+}
+
+// HandleBatch queries every ID and returns the responses in the same order
+// as ids. If any lookup fails, or ctx is done, the remaining lookups are
+// cancelled and the error is returned.
+func (h *FanOutHandler) HandleBatch(ctx context.Context, ids []string) (responses []*models.PatientResponse, err error) {
+	ctx, span := startSpan(ctx, "FanOut.HandleBatch")
+	defer func() { endSpan(span, err) }()
+
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	responses = make([]*models.PatientResponse, len(ids))
+	var (
+		next     atomic.Int64 // Index of the next ID to claim
+		firstErr error
+		once     sync.Once
+		wg       sync.WaitGroup
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for w := 0; w < min(h.maxParallel, len(ids)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			atomic.AddInt64(&h.inFlight, 1)
+			defer atomic.AddInt64(&h.inFlight, -1)
+
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(ids) || batchCtx.Err() != nil {
+					return
+				}
+
+				patient, err := h.db.QueryPatient(batchCtx, ids[i])
+				if err != nil {
+					fail(fmt.Errorf("failed to query patient %s: %w", ids[i], err))
+					return
+				}
+				responses[i] = models.NewPatientResponse(patient, "")
+			}
+		}()
+	}
+	wg.Wait()
+
+	// A cancelled caller takes precedence over the lookup it interrupted
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return responses, nil
+}
+
+// HandleStream queries every ID like HandleBatch, but passes each result to
+// emit as soon as its lookup completes instead of waiting for the whole
+// batch. Results arrive in completion order, and a failed lookup is passed
+// to emit rather than cancelling the rest.
+//
+// emit is only called from the calling goroutine. HandleStream returns once
+// every ID has been emitted, or with ctx's error if ctx is done first.
+func (h *FanOutHandler) HandleStream(ctx context.Context, ids []string, emit func(id string, patient *models.Patient, err error)) (err error) {
+	ctx, span := startSpan(ctx, "FanOut.HandleStream")
+	defer func() { endSpan(span, err) }()
+
+	type result struct {
+		id      string
+		patient *models.Patient
+		err     error
+	}
+	results := make(chan result)
+
+	var (
+		next atomic.Int64 // Index of the next ID to claim
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < min(h.maxParallel, len(ids)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			atomic.AddInt64(&h.inFlight, 1)
+			defer atomic.AddInt64(&h.inFlight, -1)
+
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(ids) || ctx.Err() != nil {
+					return
+				}
+
+				patient, err := h.db.QueryPatient(ctx, ids[i])
+				results <- result{id: ids[i], patient: patient, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		// Once ctx is done, drain the lookups it interrupted without emitting them
+		if ctx.Err() == nil {
+			emit(r.id, r.patient, r.err)
+		}
+	}
+	return ctx.Err()
+}
+
+// GetStats returns the number of fan-out goroutines currently running.
+func (h *FanOutHandler) GetStats() (inFlight int64, maxParallel int) {
+	return atomic.LoadInt64(&h.inFlight), h.maxParallel
+}
+
+// GetName returns the name of this pattern for reporting.
+func (h *FanOutHandler) GetName() string {
+	return fmt.Sprintf("Fan-Out (%d parallel lookups per batch)", h.maxParallel)
+}
@@ -0,0 +1,145 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+func batchIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("P%05d", i)
+	}
+	return ids
+}
+
+func TestFanOutPreservesOrder(t *testing.T) {
+	// Random latencies make later IDs finish before earlier ones
+	db := simulator.NewDatabase(1, 10, 0)
+	h := NewFanOutHandler(db, FanOutConfig{MaxParallel: 8})
+
+	ids := batchIDs(50)
+	responses, err := h.HandleBatch(context.Background(), ids)
+	if err != nil {
+		t.Fatalf("HandleBatch: %v", err)
+	}
+	if len(responses) != len(ids) {
+		t.Fatalf("got %d responses, want %d", len(responses), len(ids))
+	}
+	for i, resp := range responses {
+		if resp == nil || resp.Patient == nil {
+			t.Fatalf("response %d is empty", i)
+		}
+		if resp.Patient.ID != ids[i] {
+			t.Errorf("response %d is patient %s, want %s", i, resp.Patient.ID, ids[i])
+		}
+	}
+}
+
+func TestFanOutCancelsMidFlight(t *testing.T) {
+	db := newFixedLatencyDatabase(20 * time.Millisecond)
+	h := NewFanOutHandler(db, FanOutConfig{MaxParallel: 2})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(30*time.Millisecond, cancel)
+
+	start := time.Now()
+	responses, err := h.HandleBatch(ctx, batchIDs(100))
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if responses != nil {
+		t.Errorf("got %d responses from a cancelled batch, want none", len(responses))
+	}
+
+	// The whole batch would take a second; cancellation should stop it
+	// within one query latency
+	if elapsed > 200*time.Millisecond {
+		t.Errorf("HandleBatch returned after %v, want it to stop soon after cancel", elapsed)
+	}
+	if queries, _ := db.GetStats(); queries >= 10 {
+		t.Errorf("%d queries completed, want the rest of the batch abandoned", queries)
+	}
+	if inFlight, _ := h.GetStats(); inFlight != 0 {
+		t.Errorf("%d goroutines still running after HandleBatch returned", inFlight)
+	}
+}
+
+func TestFanOutFailsFast(t *testing.T) {
+	db := newFixedLatencyDatabase(5 * time.Millisecond)
+	h := NewFanOutHandler(db, FanOutConfig{MaxParallel: 2})
+
+	ids := batchIDs(40)
+	ids[1] = "" // matches no patient
+
+	_, err := h.HandleBatch(context.Background(), ids)
+	if !errors.Is(err, simulator.ErrPatientNotFound) {
+		t.Fatalf("err = %v, want ErrPatientNotFound", err)
+	}
+	if queries, _ := db.GetStats(); queries >= int64(len(ids)) {
+		t.Errorf("%d queries ran, want the batch abandoned after the failure", queries)
+	}
+}
+
+func TestFanOutRespectsParallelismBound(t *testing.T) {
+	const maxParallel = 4
+
+	// A large pool never blocks, so connections in use counts live queries
+	db := simulator.NewDatabase(simulator.MinQueryLatency, simulator.MaxQueryLatency, 0,
+		simulator.WithMaxConnections(100))
+	db.SetLatencySource(simulator.NewTraceLatencySource([]time.Duration{5 * time.Millisecond}))
+	h := NewFanOutHandler(db, FanOutConfig{MaxParallel: maxParallel})
+
+	var peak atomic.Int64
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			inUse, _, _ := db.GetPoolStats()
+			if inUse > peak.Load() {
+				peak.Store(inUse)
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+
+	_, err := h.HandleBatch(context.Background(), batchIDs(60))
+	close(done)
+	<-sampled
+	if err != nil {
+		t.Fatalf("HandleBatch: %v", err)
+	}
+
+	if got := peak.Load(); got > maxParallel {
+		t.Errorf("peak concurrent queries = %d, want at most %d", got, maxParallel)
+	}
+	if got := peak.Load(); got < 2 {
+		t.Errorf("peak concurrent queries = %d, want lookups to overlap", got)
+	}
+}
+
+func TestFanOutEmptyBatch(t *testing.T) {
+	h := NewFanOutHandler(newFastDatabase(), DefaultFanOutConfig())
+
+	responses, err := h.HandleBatch(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("HandleBatch: %v", err)
+	}
+	if len(responses) != 0 {
+		t.Errorf("got %d responses, want none", len(responses))
+	}
+}
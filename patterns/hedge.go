@@ -0,0 +1,242 @@
+package patterns
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// Tuning for adaptive hedging.
+const (
+	hedgeTuneEvery   = 100 // Reads between retunings of the hedge delay
+	hedgeBudgetBurst = 10  // Hedges that may be sent back to back under the rate cap
+)
+
+// HedgedStore wraps a PatientStore with request hedging, also known as
+// backup requests (Dean and Barroso, "The Tail at Scale"): a read that
+// hasn't returned within the hedge delay is sent again, and whichever
+// attempt answers first wins. The loser is cancelled.
+//
+// WHY IT CUTS TAIL LATENCY:
+//
+// 1. Slow Reads Are Usually Unlucky, Not Doomed:
+//    - A tail spike comes from the one replica, lock or GC pause a read hit
+//    - A second attempt rarely hits the same one, so it usually finishes
+//      in ordinary time
+//
+// 2. Cheap When the Delay Is a High Percentile:
+//    - With the delay at the P95, only about 5% of reads send a backup
+//    - In exchange the P99 drops towards the P95 plus one ordinary read
+//    - Adaptive keeps the delay at the recent P95 as latency drifts, and
+//      MaxHedgeRate caps the extra load if the tail suddenly fattens
+//
+// 3. One Answer per Read:
+//    - The caller sees one result, however many attempts were made
+//    - A read fails only if every attempt fails, and then returns one
+//      error, the first, so error counts aren't doubled
+//    - A read that fails before the hedge delay is not hedged; hedging
+//      is for slow reads, not a retry policy
+//
+// WHEN TO USE:
+// - Idempotent reads against replicated data, where a duplicate costs
+//   little. Writes are never hedged.
+//
+// The cancelled loser still reaches the underlying store, whose own
+// statistics may count it as a cancelled query.
+type HedgedStore struct {
+	PatientStore
+	adaptive bool
+	maxRate  float64
+
+	delay atomic.Int64 // Current hedge delay, in nanoseconds
+
+	// Latencies of recent first attempts, for retuning the delay. A first
+	// attempt that lost is recorded as the time until the read was
+	// answered, a lower bound, so slow reads stay in the tail.
+	recent    *metrics.Collector
+	sinceTune atomic.Int64
+	tuneMu    sync.Mutex
+	budgetMu  sync.Mutex
+	budget    float64 // Hedges allowed right now under MaxHedgeRate
+
+	queries    atomic.Int64
+	hedged     atomic.Int64
+	backupWins atomic.Int64
+	errors     atomic.Int64
+}
+
+// HedgeConfig holds configuration for a HedgedStore.
+type HedgeConfig struct {
+	Delay        time.Duration // Hedge reads still running after this long; the starting point when Adaptive
+	Adaptive     bool          // Retune Delay to the P95 of recent reads
+	MaxHedgeRate float64       // Largest fraction of reads that may be hedged (0 for no cap)
+}
+
+// DefaultHedgeConfig returns an adaptive configuration that hedges at
+// most 5% of reads, starting from a 100ms delay.
+func DefaultHedgeConfig() HedgeConfig {
+	return HedgeConfig{
+		Delay:        100 * time.Millisecond,
+		Adaptive:     true,
+		MaxHedgeRate: 0.05,
+	}
+}
+
+// HedgeStats counts a HedgedStore's reads.
+type HedgeStats struct {
+	Queries    int64         // Reads served
+	Hedged     int64         // Reads that sent a backup attempt
+	BackupWins int64         // Hedged reads answered by the backup
+	Errors     int64         // Reads that failed, each counted once
+	Delay      time.Duration // Current hedge delay
+	HedgeRate  float64       // Fraction of reads hedged
+}
+
+// NewHedgedStore wraps store so that a read still running after the hedge
+// delay is hedged with a second attempt.
+func NewHedgedStore(store PatientStore, config HedgeConfig) *HedgedStore {
+	s := &HedgedStore{
+		PatientStore: store,
+		adaptive:     config.Adaptive,
+		maxRate:      config.MaxHedgeRate,
+		budget:       hedgeBudgetBurst,
+	}
+	s.delay.Store(int64(config.Delay))
+	if config.Adaptive {
+		s.recent = metrics.NewCollector()
+	}
+	return s
+}
+
+// hedgeResult is one attempt's answer.
+type hedgeResult struct {
+	patient *models.Patient
+	err     error
+	backup  bool
+}
+
+// QueryPatient reads a patient, sending a backup attempt if the first
+// hasn't answered within the hedge delay, and returns the first success.
+// If every attempt fails, the first error is returned.
+func (s *HedgedStore) QueryPatient(ctx context.Context, patientID string) (*models.Patient, error) {
+	s.queries.Add(1)
+	s.earnBudget()
+
+	// Returning cancels whichever attempt is still running
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered so the losing attempt never blocks
+	results := make(chan hedgeResult, 2)
+	attempt := func(backup bool) {
+		start := time.Now()
+		patient, err := s.PatientStore.QueryPatient(ctx, patientID)
+		if !backup {
+			s.observe(time.Since(start))
+		}
+		results <- hedgeResult{patient: patient, err: err, backup: backup}
+	}
+	go attempt(false)
+	inFlight := 1
+
+	timer := time.NewTimer(time.Duration(s.delay.Load()))
+	defer timer.Stop()
+
+	var firstErr error
+	for {
+		select {
+		case r := <-results:
+			inFlight--
+			if r.err == nil {
+				if r.backup {
+					s.backupWins.Add(1)
+				}
+				return r.patient, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if inFlight == 0 {
+				s.errors.Add(1)
+				return nil, firstErr
+			}
+		case <-timer.C:
+			if ctx.Err() == nil && s.spendBudget() {
+				s.hedged.Add(1)
+				go attempt(true)
+				inFlight++
+			}
+		}
+	}
+}
+
+// earnBudget adds one read's share of the hedge budget, so that over time
+// at most MaxHedgeRate of reads are hedged.
+func (s *HedgedStore) earnBudget() {
+	if s.maxRate <= 0 {
+		return
+	}
+	s.budgetMu.Lock()
+	s.budget = math.Min(s.budget+s.maxRate, hedgeBudgetBurst)
+	s.budgetMu.Unlock()
+}
+
+// spendBudget reports whether a hedge may be sent, and if so uses up one
+// hedge's worth of budget.
+func (s *HedgedStore) spendBudget() bool {
+	if s.maxRate <= 0 {
+		return true
+	}
+	s.budgetMu.Lock()
+	defer s.budgetMu.Unlock()
+	if s.budget < 1 {
+		return false
+	}
+	s.budget--
+	return true
+}
+
+// observe records a first attempt's latency and, every hedgeTuneEvery
+// reads, moves the hedge delay to the P95 of those since the last move.
+func (s *HedgedStore) observe(latency time.Duration) {
+	if !s.adaptive {
+		return
+	}
+	s.recent.RecordRequest(latency, true)
+	if s.sinceTune.Add(1) < hedgeTuneEvery {
+		return
+	}
+
+	s.tuneMu.Lock()
+	defer s.tuneMu.Unlock()
+	if s.sinceTune.Load() < hedgeTuneEvery {
+		return // Another read retuned first
+	}
+	s.sinceTune.Store(0)
+
+	stats := s.recent.GetStats()
+	s.recent.Reset()
+	if stats.HasReliablePercentiles(95) {
+		s.delay.Store(int64(stats.P95Latency * float64(time.Millisecond)))
+	}
+}
+
+// Stats returns the store's read counts and current hedge delay.
+func (s *HedgedStore) Stats() HedgeStats {
+	stats := HedgeStats{
+		Queries:    s.queries.Load(),
+		Hedged:     s.hedged.Load(),
+		BackupWins: s.backupWins.Load(),
+		Errors:     s.errors.Load(),
+		Delay:      time.Duration(s.delay.Load()),
+	}
+	if stats.Queries > 0 {
+		stats.HedgeRate = float64(stats.Hedged) / float64(stats.Queries)
+	}
+	return stats
+}
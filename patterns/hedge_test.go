@@ -0,0 +1,171 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// TestHedgedStoreFastBackupWins scripts a slow first attempt and a fast
+// second one: the hedge should answer in about the hedge delay plus the
+// fast read, not wait out the slow one.
+func TestHedgedStoreFastBackupWins(t *testing.T) {
+	db := simulator.NewScriptedDatabase([]simulator.Step{
+		{Latency: 2 * time.Second},
+		{Latency: time.Millisecond},
+	})
+	store := NewHedgedStore(db, HedgeConfig{Delay: 20 * time.Millisecond})
+
+	start := time.Now()
+	patient, err := store.QueryPatient(context.Background(), "P00001")
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("QueryPatient: %v", err)
+	}
+	if patient.ID != "P00001" {
+		t.Errorf("patient ID = %s, want P00001", patient.ID)
+	}
+	if elapsed > time.Second {
+		t.Errorf("hedged read took %v, want the fast backup's answer well under the slow attempt's 2s", elapsed)
+	}
+
+	stats := store.Stats()
+	if stats.Hedged != 1 || stats.BackupWins != 1 || stats.Errors != 0 {
+		t.Errorf("stats = %+v, want 1 hedged read won by the backup and no errors", stats)
+	}
+}
+
+func TestHedgedStoreFastReadNotHedged(t *testing.T) {
+	db := simulator.NewScriptedDatabase([]simulator.Step{{Latency: time.Millisecond}})
+	store := NewHedgedStore(db, HedgeConfig{Delay: 200 * time.Millisecond})
+
+	if _, err := store.QueryPatient(context.Background(), "P00001"); err != nil {
+		t.Fatalf("QueryPatient: %v", err)
+	}
+	if stats := store.Stats(); stats.Queries != 1 || stats.Hedged != 0 {
+		t.Errorf("stats = %+v, want 1 read and no hedge", stats)
+	}
+}
+
+// TestHedgedStoreErrorsCountedOnce checks that a read is only an error
+// when every attempt fails, and then counts once with the first error.
+func TestHedgedStoreErrorsCountedOnce(t *testing.T) {
+	tests := []struct {
+		name       string
+		steps      []simulator.Step
+		wantErr    error
+		wantHedged int64
+	}{
+		{
+			name: "both attempts fail",
+			steps: []simulator.Step{
+				{Latency: 50 * time.Millisecond, Err: simulator.ErrConnectionTimeout},
+				{Latency: 50 * time.Millisecond, Err: simulator.ErrLockTimeout},
+			},
+			wantErr:    simulator.ErrConnectionTimeout,
+			wantHedged: 1,
+		},
+		{
+			name: "primary fails before the hedge delay",
+			steps: []simulator.Step{
+				{Latency: time.Millisecond, Err: simulator.ErrConnectionTimeout},
+			},
+			wantErr:    simulator.ErrConnectionTimeout,
+			wantHedged: 0,
+		},
+		{
+			name: "primary fails after the backup was sent",
+			steps: []simulator.Step{
+				{Latency: 30 * time.Millisecond, Err: simulator.ErrConnectionTimeout},
+				{Latency: 40 * time.Millisecond},
+			},
+			wantHedged: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewHedgedStore(simulator.NewScriptedDatabase(tt.steps), HedgeConfig{Delay: 10 * time.Millisecond})
+
+			_, err := store.QueryPatient(context.Background(), "P00001")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("QueryPatient error = %v, want %v", err, tt.wantErr)
+			}
+
+			wantErrors := int64(0)
+			if tt.wantErr != nil {
+				wantErrors = 1
+			}
+			stats := store.Stats()
+			if stats.Errors != wantErrors || stats.Hedged != tt.wantHedged {
+				t.Errorf("stats = %+v, want %d errors and %d hedged", stats, wantErrors, tt.wantHedged)
+			}
+		})
+	}
+}
+
+// readConcurrently makes n reads through store, several at a time.
+func readConcurrently(t *testing.T, store PatientStore, n int) {
+	t.Helper()
+
+	const parallel = 20
+	var wg sync.WaitGroup
+	for g := 0; g < parallel; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n/parallel; i++ {
+				if _, err := store.QueryPatient(context.Background(), "P00001"); err != nil {
+					t.Errorf("QueryPatient: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestHedgedStoreDelayTracksLatency shifts the database's latency from
+// 2ms to 30ms and checks that the adaptive hedge delay follows it from
+// its 500ms starting point, down and then up.
+func TestHedgedStoreDelayTracksLatency(t *testing.T) {
+	db := simulator.NewDatabase(2, 2, 0)
+	store := NewHedgedStore(db, HedgeConfig{Delay: 500 * time.Millisecond, Adaptive: true, MaxHedgeRate: 0.05})
+
+	readConcurrently(t, store, 4*hedgeTuneEvery)
+	if delay := store.Stats().Delay; delay < 2*time.Millisecond || delay > 15*time.Millisecond {
+		t.Errorf("at 2ms latency, hedge delay = %v, want about 2ms", delay)
+	}
+
+	db.SetLatencySource(simulator.NewTraceLatencySource([]time.Duration{30 * time.Millisecond}))
+	readConcurrently(t, store, 4*hedgeTuneEvery)
+	if delay := store.Stats().Delay; delay < 30*time.Millisecond || delay > 60*time.Millisecond {
+		t.Errorf("at 30ms latency, hedge delay = %v, want about 30ms", delay)
+	}
+}
+
+// TestHedgedStoreCapsHedgeRate makes every read slower than the hedge
+// delay, which would hedge them all without the cap.
+func TestHedgedStoreCapsHedgeRate(t *testing.T) {
+	db := simulator.NewDatabase(5, 5, 0)
+	store := NewHedgedStore(db, HedgeConfig{Delay: time.Millisecond, MaxHedgeRate: 0.05})
+
+	const reads = 400
+	readConcurrently(t, store, reads)
+
+	stats := store.Stats()
+	if stats.Queries != reads {
+		t.Fatalf("Queries = %d, want %d", stats.Queries, reads)
+	}
+	// The budget starts with a burst of hedges, then earns 5% of reads
+	if limit := int64(hedgeBudgetBurst + reads*0.05); stats.Hedged > limit {
+		t.Errorf("hedged %d of %d reads, want at most %d", stats.Hedged, reads, limit)
+	}
+	if stats.HedgeRate != float64(stats.Hedged)/reads {
+		t.Errorf("HedgeRate = %v, want %v", stats.HedgeRate, float64(stats.Hedged)/reads)
+	}
+}
@@ -0,0 +1,66 @@
+package patterns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteJSONDoesNotEscapeHTML(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P1", nil)
+	WriteJSON(rec, req, http.StatusOK, map[string]string{"physician": "Dr. Smith & <Partners>"})
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "Dr. Smith & <Partners>") || strings.Contains(body, `\u0026`) {
+		t.Errorf("body = %s, want & < > unescaped", body)
+	}
+	if strings.Contains(body, "\n  ") {
+		t.Errorf("body = %q, want compact output without ?pretty", body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P1&pretty=1", nil)
+	WriteJSON(rec, req, http.StatusOK, map[string]string{"physician": "Dr. Smith"})
+	if want := "{\n  \"physician\": \"Dr. Smith\"\n}\n"; rec.Body.String() != want {
+		t.Errorf("pretty body = %q, want %q", rec.Body.String(), want)
+	}
+}
+
+// TestResponsesUseRFC3339Times reads a patient from each pattern and checks
+// the response timestamp and the patient's dates are RFC 3339.
+func TestResponsesUseRFC3339Times(t *testing.T) {
+	for _, tc := range allHandlers {
+		t.Run(tc.name, func(t *testing.T) {
+			h := tc.new(newFastDatabase())
+			defer shutdownHandler(t, h)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001&pretty=1", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+
+			var response struct {
+				Timestamp string `json:"timestamp"`
+				Patient   struct {
+					DateOfBirth string `json:"date_of_birth"`
+				} `json:"patient"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			for name, value := range map[string]string{"timestamp": response.Timestamp, "date_of_birth": response.Patient.DateOfBirth} {
+				if _, err := time.Parse(time.RFC3339, value); err != nil {
+					t.Errorf("%s = %q, want RFC 3339: %v", name, value, err)
+				}
+			}
+		})
+	}
+}
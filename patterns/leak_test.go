@@ -0,0 +1,87 @@
+package patterns
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// leakSettleTimeout bounds how long assertNoGoroutineLeak waits for
+// goroutines that are exiting, or runtime helpers that are winding down,
+// before calling them leaked.
+const leakSettleTimeout = 2 * time.Second
+
+// assertNoGoroutineLeak runs fn and fails the test if more goroutines are
+// running afterwards than before. Goroutines take a moment to be reaped
+// once they return, so it retries with backoff before failing, and then
+// dumps every stack to show what was left behind.
+func assertNoGoroutineLeak(t *testing.T, fn func()) {
+	t.Helper()
+
+	before := runtime.NumGoroutine()
+	fn()
+
+	deadline := time.Now().Add(leakSettleTimeout)
+	for backoff := time.Millisecond; ; backoff *= 2 {
+		after := runtime.NumGoroutine()
+		if after <= before {
+			return
+		}
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			buf = buf[:runtime.Stack(buf, true)]
+			t.Fatalf("%d goroutines before, %d after; still running:\n%s", before, after, buf)
+		}
+		time.Sleep(min(backoff, 100*time.Millisecond))
+	}
+}
+
+// TestNoGoroutineLeaks constructs every pattern, serves requests over both
+// interfaces, shuts it down, and checks nothing it started is still
+// running.
+func TestNoGoroutineLeaks(t *testing.T) {
+	for _, tc := range allHandlers {
+		t.Run(tc.name, func(t *testing.T) {
+			assertNoGoroutineLeak(t, func() {
+				h := tc.new(newFastDatabase())
+
+				for i := 0; i < 5; i++ {
+					rec := httptest.NewRecorder()
+					h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil))
+					if rec.Code != http.StatusOK {
+						t.Errorf("GET = %d, want 200", rec.Code)
+					}
+				}
+				if reader, ok := h.(interface {
+					HandleRequest(context.Context, string) (*models.PatientResponse, error)
+				}); ok {
+					if _, err := reader.HandleRequest(context.Background(), "P00001"); err != nil {
+						t.Errorf("HandleRequest: %v", err)
+					}
+				}
+
+				shutdownHandler(t, h)
+			})
+		})
+	}
+
+	// The fan-out has no Shutdown; its goroutines must be gone once a
+	// batch returns, including one cut short by an error
+	t.Run("FanOut", func(t *testing.T) {
+		assertNoGoroutineLeak(t, func() {
+			h := NewFanOutHandler(newFastDatabase(), DefaultFanOutConfig())
+			if _, err := h.HandleBatch(context.Background(), []string{"P00001", "P00002", "P00003"}); err != nil {
+				t.Errorf("HandleBatch: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			h.HandleBatch(ctx, []string{"P00001", "P00002"})
+		})
+	})
+}
@@ -3,12 +3,15 @@ package patterns
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync/atomic"
+	"time"
 
 	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
 	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // NaiveHandler implements the naive approach: spawning a new goroutine for every request.
@@ -48,20 +51,86 @@ import (
 // This implementation is intentionally naive to demonstrate the problem.
 // DO NOT use this pattern in production healthcare systems.
 type NaiveHandler struct {
-	db              *simulator.Database
+	db              PatientStore
 	activeGoroutines int64 // Track concurrent goroutines for metrics
+	peakGoroutines   int64 // Most goroutines seen running at once
+	maxGoroutines    int64 // Safety cap; 0 for unbounded
+	rejectStatus     int
 }
 
+// NaiveConfig holds optional settings for the naive handler.
+type NaiveConfig struct {
+	// MaxGoroutines is a safety cap on the goroutines the handler spawns
+	// at once. Requests beyond it are rejected with ErrGoroutineLimit (503
+	// over HTTP) instead of spawning more, so the pattern can be pushed to
+	// high concurrency without taking the host down with it. Zero leaves
+	// it unbounded, which is the behaviour being demonstrated.
+	MaxGoroutines int
+
+	// RejectStatus is the HTTP status for requests turned away at the cap:
+	// 503 Service Unavailable when zero, or 429 Too Many Requests.
+	RejectStatus int
+}
+
+// ErrGoroutineLimit is returned when the naive handler already has
+// MaxGoroutines goroutines running.
+var ErrGoroutineLimit = errors.New("goroutine limit reached: request rejected")
+
 // NewNaiveHandler creates a new naive pattern handler.
-func NewNaiveHandler(db *simulator.Database) *NaiveHandler {
+func NewNaiveHandler(db PatientStore) *NaiveHandler {
+	return NewNaiveHandlerWithConfig(db, NaiveConfig{})
+}
+
+// NewNaiveHandlerWithConfig creates a naive pattern handler with the given
+// settings.
+func NewNaiveHandlerWithConfig(db PatientStore, config NaiveConfig) *NaiveHandler {
 	return &NaiveHandler{
-		db: db,
+		db:            db,
+		maxGoroutines: int64(config.MaxGoroutines),
+		rejectStatus:  config.RejectStatus,
+	}
+}
+
+// acquire counts a goroutine about to be spawned, or returns
+// ErrGoroutineLimit if that would exceed MaxGoroutines. The caller must
+// decrement activeGoroutines once the goroutine is done.
+func (h *NaiveHandler) acquire() error {
+	n := atomic.AddInt64(&h.activeGoroutines, 1)
+	if h.maxGoroutines > 0 && n > h.maxGoroutines {
+		atomic.AddInt64(&h.activeGoroutines, -1)
+		return ErrGoroutineLimit
+	}
+	recordPeak(&h.peakGoroutines, n)
+	return nil
+}
+
+// recordPeak raises *peak to n if n is higher.
+func recordPeak(peak *int64, n int64) {
+	for {
+		current := atomic.LoadInt64(peak)
+		if n <= current || atomic.CompareAndSwapInt64(peak, current, n) {
+			return
+		}
 	}
 }
 
 // ServeHTTP handles incoming HTTP requests by spawning a new goroutine for each.
 // This is the problematic pattern we're demonstrating.
 func (h *NaiveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, span := startSpan(r.Context(), "Naive.ServeHTTP")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	if _, err := parseFields(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		h.serveUpdate(w, r)
+		return
+	}
+
 	// Extract patient ID from URL path
 	patientID := extractPatientID(r)
 	if patientID == "" {
@@ -76,11 +145,21 @@ func (h *NaiveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// - 1,000 req/sec = 1,000 concurrent goroutines (if each takes 1s)
 	// - 10,000 req/sec = 10,000 concurrent goroutines
 	// - This quickly overwhelms the system
-	go h.processRequest(w, r, patientID)
+	if err := h.acquire(); err != nil {
+		recordError(span, err)
+		rejectOverloaded(w, h.rejectStatus)
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.processRequest(w, r, patientID)
+	}()
 
-	// Track active goroutines for monitoring
-	// Note: This is racy and for demonstration only
-	atomic.AddInt64(&h.activeGoroutines, 1)
+	// net/http finalises the response as soon as ServeHTTP returns, so the
+	// request goroutine must wait for the spawned one to write it. The extra
+	// goroutine buys nothing - which is exactly the point of this pattern.
+	<-done
 }
 
 // processRequest handles the actual patient data retrieval.
@@ -101,59 +180,120 @@ func (h *NaiveHandler) processRequest(w http.ResponseWriter, r *http.Request, pa
 	patient, err := h.db.QueryPatient(ctx, patientID)
 
 	var response *models.PatientResponse
+	status := http.StatusOK
 	if err != nil {
-		response = models.NewErrorResponse(err, r.Header.Get("X-Request-ID"))
+		recordError(trace.SpanFromContext(ctx), err)
+		response = models.NewErrorResponse(err, requestID(r))
+		status = statusForError(err)
 	} else {
-		response = models.NewPatientResponse(patient, r.Header.Get("X-Request-ID"))
+		response = models.NewPatientResponse(patient, requestID(r))
 	}
 
-	// Serialize response to JSON
-	// PROBLEM: Each goroutine allocates memory for JSON serialization
+	// Serialize response to JSON (or protobuf, if the client asks)
+	// PROBLEM: Each goroutine allocates memory for serialization
 	// With thousands of concurrent requests, this creates GC pressure
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "failed to encode response", http.StatusInternalServerError)
-	}
+	writeResponse(w, r, status, response)
 }
 
 // HandleRequest is the non-HTTP interface for benchmarking.
 // This allows us to benchmark the pattern without HTTP overhead.
-func (h *NaiveHandler) HandleRequest(ctx context.Context, patientID string) (*models.PatientResponse, error) {
-	// Even in this interface, we spawn a goroutine to match the HTTP behavior
-	resultChan := make(chan *models.PatientResponse, 1)
-	errChan := make(chan error, 1)
+func (h *NaiveHandler) HandleRequest(ctx context.Context, patientID string) (response *models.PatientResponse, err error) {
+	ctx, span := startSpan(ctx, "Naive.HandleRequest")
+	defer func() { endSpan(span, err) }()
 
+	// Even in this interface, we spawn a goroutine to match the HTTP behavior.
+	// The goroutine sends exactly once, on a channel with room for that one
+	// send, so it never blocks: if ctx ends first, nobody is left to
+	// receive, and an unbuffered send would leak the goroutine.
+	type queryResult struct {
+		patient *models.Patient
+		err     error
+	}
+	results := make(chan queryResult, 1)
+
+	if err := h.acquire(); err != nil {
+		return models.NewErrorResponse(err, ""), err
+	}
 	go func() {
-		atomic.AddInt64(&h.activeGoroutines, 1)
 		defer atomic.AddInt64(&h.activeGoroutines, -1)
 
 		patient, err := h.db.QueryPatient(ctx, patientID)
-		if err != nil {
-			errChan <- err
-			return
+		results <- queryResult{patient: patient, err: err}
+	}()
+
+	// Wait for result or context cancellation
+	select {
+	case result := <-results:
+		if result.err != nil {
+			return models.NewErrorResponse(result.err, ""), result.err
 		}
+		return models.NewPatientResponse(result.patient, ""), nil
+	case <-ctx.Done():
+		return models.NewErrorResponse(ctx.Err(), ""), ctx.Err()
+	}
+}
+
+// HandleUpdate is the non-HTTP interface for benchmarking writes.
+// Like HandleRequest, it spawns a goroutine per call.
+func (h *NaiveHandler) HandleUpdate(ctx context.Context, patient *models.Patient) (response *models.PatientResponse, err error) {
+	ctx, span := startSpan(ctx, "Naive.HandleUpdate")
+	defer func() { endSpan(span, err) }()
+
+	// Buffered for the goroutine's one send, as in HandleRequest
+	errChan := make(chan error, 1)
+
+	if err := h.acquire(); err != nil {
+		return models.NewErrorResponse(err, ""), err
+	}
+	go func() {
+		defer atomic.AddInt64(&h.activeGoroutines, -1)
 
-		response := models.NewPatientResponse(patient, "")
-		resultChan <- response
+		errChan <- h.db.UpdatePatient(ctx, patient)
 	}()
 
-	// Wait for result or context cancellation
 	select {
-	case response := <-resultChan:
-		return response, nil
 	case err := <-errChan:
-		return models.NewErrorResponse(err, ""), err
+		if err != nil {
+			return models.NewErrorResponse(err, ""), err
+		}
+		return models.NewPatientResponse(patient, ""), nil
 	case <-ctx.Done():
 		return models.NewErrorResponse(ctx.Err(), ""), ctx.Err()
 	}
 }
 
+// serveUpdate handles PUT requests.
+// Unlike reads, it waits for the spawned goroutine so the write's outcome
+// can be reported to the client.
+func (h *NaiveHandler) serveUpdate(w http.ResponseWriter, r *http.Request) {
+	patient, err := decodePatient(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.HandleUpdate(r.Context(), patient)
+	if err != nil {
+		writeError(w, r, err, h.rejectStatus)
+		return
+	}
+	response.RequestID = requestID(r)
+	writeResponse(w, r, http.StatusOK, response)
+}
+
 // GetActiveGoroutines returns the current count of active goroutines.
 // This is useful for monitoring and demonstrating the problem.
 func (h *NaiveHandler) GetActiveGoroutines() int64 {
 	return atomic.LoadInt64(&h.activeGoroutines)
 }
 
+// GetPeakGoroutines returns the most goroutines the handler has had running
+// at once. Rejected requests never count towards it, so with MaxGoroutines
+// set it never exceeds the cap.
+func (h *NaiveHandler) GetPeakGoroutines() int64 {
+	return atomic.LoadInt64(&h.peakGoroutines)
+}
+
 // extractPatientID extracts the patient ID from the request.
 // In a real system, this might use a router like chi, gorilla/mux, or gin.
 func extractPatientID(r *http.Request) string {
@@ -161,6 +301,91 @@ func extractPatientID(r *http.Request) string {
 	return r.URL.Query().Get("id")
 }
 
+// decodePatient reads a Patient from the request body and validates it.
+func decodePatient(r *http.Request) (*models.Patient, error) {
+	var patient models.Patient
+	if err := json.NewDecoder(r.Body).Decode(&patient); err != nil {
+		return nil, fmt.Errorf("invalid patient JSON: %w", err)
+	}
+
+	if err := patient.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid patient: %w", err)
+	}
+
+	return &patient, nil
+}
+
+// rejectOverloaded turns a request away for lack of capacity with status,
+// or 503 if it is zero. Retry-After must be set before http.Error writes
+// the header, or it is never sent.
+func rejectOverloaded(w http.ResponseWriter, status int) {
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "service overloaded, please retry", rejectStatusOrDefault(status))
+}
+
+// rejectStatusOrDefault returns the configured status for a rejected
+// request, or 503 Service Unavailable if none is configured.
+func rejectStatusOrDefault(status int) int {
+	if status == 0 {
+		return http.StatusServiceUnavailable
+	}
+	return status
+}
+
+// writeError writes err as an error response. A request rejected for lack
+// of capacity gets Retry-After and the configured rejection status instead
+// of statusForError's.
+func writeError(w http.ResponseWriter, r *http.Request, err error, rejectStatus int) {
+	status := statusForError(err)
+	if errors.Is(err, ErrQueueFull) || errors.Is(err, ErrJobDropped) ||
+		errors.Is(err, ErrGoroutineLimit) || errors.Is(err, ErrSemaphoreFull) ||
+		errors.Is(err, ErrLoadShed) {
+		w.Header().Set("Retry-After", "1")
+		status = rejectStatusOrDefault(rejectStatus)
+	}
+	writeResponse(w, r, status, models.NewErrorResponse(err, requestID(r)))
+}
+
+// statusForError maps a simulator error category to an HTTP status code,
+// so clients can tell a missing record from an overloaded or slow backend.
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, simulator.ErrPatientNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, simulator.ErrPoolExhausted),
+		errors.Is(err, simulator.ErrCircuitOpen),
+		errors.Is(err, ErrShuttingDown),
+		errors.Is(err, ErrQueueFull),
+		errors.Is(err, ErrJobDropped),
+		errors.Is(err, ErrGoroutineLimit),
+		errors.Is(err, ErrSemaphoreFull),
+		errors.Is(err, ErrLoadShed):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, simulator.ErrConnectionTimeout),
+		errors.Is(err, simulator.ErrQueryCancelled):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, simulator.ErrLockTimeout):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// runJob performs a read, or a write when update is non-nil, and returns the
+// resulting patient record. Shared by the queue-based patterns.
+func runJob(ctx context.Context, db PatientStore, patientID string, update *models.Patient) (*models.Patient, error) {
+	if update != nil {
+		if err := db.UpdatePatient(ctx, update); err != nil {
+			return nil, err
+		}
+		return update, nil
+	}
+
+	return db.QueryPatient(ctx, patientID)
+}
+
 // GetName returns the name of this pattern for reporting.
 func (h *NaiveHandler) GetName() string {
 	return "Naive (Goroutine per request)"
@@ -172,6 +397,9 @@ func (h *NaiveHandler) GetName() string {
 func (h *NaiveHandler) Shutdown(ctx context.Context) error {
 	// Wait for active goroutines to complete or context timeout
 	// This is a best-effort approach
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
 	for {
 		active := atomic.LoadInt64(&h.activeGoroutines)
 		if active == 0 {
@@ -181,9 +409,8 @@ func (h *NaiveHandler) Shutdown(ctx context.Context) error {
 		select {
 		case <-ctx.Done():
 			return fmt.Errorf("shutdown timeout: %d goroutines still active", active)
-		default:
-			// Brief sleep to avoid tight loop
-			continue
+		case <-ticker.C:
+			// Poll rather than spin, which would burn a CPU until they finish
 		}
 	}
 }
@@ -0,0 +1,289 @@
+package patterns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// TestNaiveMaxGoroutinesRejects checks that once MaxGoroutines requests are
+// running, the naive handler rejects further ones, over both interfaces,
+// rather than spawning more goroutines.
+func TestNaiveMaxGoroutinesRejects(t *testing.T) {
+	const limit = 2
+
+	db := newFixedLatencyDatabase(200 * time.Millisecond)
+	h := NewNaiveHandlerWithConfig(db, NaiveConfig{MaxGoroutines: limit})
+
+	running := make(chan error, limit)
+	for i := 0; i < limit; i++ {
+		go func() {
+			_, err := h.HandleRequest(context.Background(), "P00001")
+			running <- err
+		}()
+	}
+	waitFor(t, func() bool { return h.GetActiveGoroutines() == limit })
+
+	start := time.Now()
+	if _, err := h.HandleRequest(context.Background(), "P00002"); !errors.Is(err, ErrGoroutineLimit) {
+		t.Errorf("HandleRequest over the cap: got %v, want ErrGoroutineLimit", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("HandleRequest over the cap took %v, want an immediate rejection", elapsed)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00002", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("ServeHTTP over the cap: got %d (Retry-After %q), want 503 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	for i := 0; i < limit; i++ {
+		if err := <-running; err != nil {
+			t.Errorf("request under the cap: unexpected error: %v", err)
+		}
+	}
+	if peak := h.GetPeakGoroutines(); peak != limit {
+		t.Errorf("GetPeakGoroutines() = %d, want %d", peak, limit)
+	}
+	if queries, _ := db.GetStats(); queries != limit {
+		t.Errorf("database saw %d queries, want %d", queries, limit)
+	}
+
+	// Once they finish, there's room again
+	if _, err := h.HandleRequest(context.Background(), "P00003"); err != nil {
+		t.Errorf("HandleRequest after the others finished: unexpected error: %v", err)
+	}
+}
+
+// TestNaiveResponseCompleteOnReturn checks that the naive handler has
+// written the whole response by the time ServeHTTP returns, since
+// net/http finishes the response as soon as it does.
+func TestNaiveResponseCompleteOnReturn(t *testing.T) {
+	h := NewNaiveHandler(newFixedLatencyDatabase(20 * time.Millisecond))
+	defer shutdownHandler(t, h)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status on return: got %d, want %d", rec.Code, http.StatusOK)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"P00001"`) {
+		t.Errorf("body on return: got %q, want the patient P00001", body)
+	}
+}
+
+// TestNaivePeakGoroutinesRecordBurst checks that the peak goroutine count
+// matches a burst of concurrent requests once they have all finished.
+func TestNaivePeakGoroutinesRecordBurst(t *testing.T) {
+	const burst = 8
+
+	h := NewNaiveHandler(newFixedLatencyDatabase(100 * time.Millisecond))
+
+	results := make(chan error, burst)
+	for i := 0; i < burst; i++ {
+		go func() {
+			_, err := h.HandleRequest(context.Background(), "P00001")
+			results <- err
+		}()
+	}
+	for i := 0; i < burst; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("burst request: unexpected error: %v", err)
+		}
+	}
+
+	if active := h.GetActiveGoroutines(); active != 0 {
+		t.Errorf("GetActiveGoroutines() = %d after the burst, want 0", active)
+	}
+	if peak := h.GetPeakGoroutines(); peak != burst {
+		t.Errorf("GetPeakGoroutines() = %d, want %d", peak, burst)
+	}
+}
+
+// TestRejectionsCarryRetryAfter floods each pattern, sized to a single
+// request at a time, over a real server and checks every rejection arrives
+// with Retry-After and the configured status.
+func TestRejectionsCarryRetryAfter(t *testing.T) {
+	saturated := []struct {
+		name string
+		new  func(db *simulator.Database, status int) httpHandler
+	}{
+		{"Naive", func(db *simulator.Database, status int) httpHandler {
+			return NewNaiveHandlerWithConfig(db, NaiveConfig{MaxGoroutines: 1, RejectStatus: status})
+		}},
+		{"WorkerPool", func(db *simulator.Database, status int) httpHandler {
+			return NewWorkerPoolHandler(db, WorkerPoolConfig{Workers: 1, QueueSize: 1, RejectStatus: status})
+		}},
+		{"Optimized", func(db *simulator.Database, status int) httpHandler {
+			return NewOptimizedHandler(db, WorkerPoolConfig{Workers: 1, QueueSize: 1, RejectStatus: status})
+		}},
+		{"Semaphore", func(db *simulator.Database, status int) httpHandler {
+			return NewSemaphoreHandler(db, SemaphoreConfig{MaxConcurrent: 1, AcquireTimeout: time.Millisecond, RejectStatus: status})
+		}},
+		{"Pipeline", func(db *simulator.Database, status int) httpHandler {
+			return NewPipelineHandler(db, PipelineConfig{ValidateWorkers: 1, QueryWorkers: 1, SerializeWorkers: 1, QueueSize: 1, RejectStatus: status})
+		}},
+		{"Bulkhead", func(db *simulator.Database, status int) httpHandler {
+			return NewBulkheadHandler(db, SplitBulkheadConfig(WorkerPoolConfig{Workers: 1, QueueSize: 1, RejectStatus: status}))
+		}},
+	}
+
+	for _, tc := range saturated {
+		for _, status := range []int{0, http.StatusTooManyRequests} {
+			want := status
+			if want == 0 {
+				want = http.StatusServiceUnavailable
+			}
+			t.Run(fmt.Sprintf("%s/%d", tc.name, want), func(t *testing.T) {
+				h := tc.new(newFixedLatencyDatabase(50*time.Millisecond), status)
+				defer shutdownHandler(t, h)
+				server := httptest.NewServer(h)
+				defer server.Close()
+
+				const requests = 20
+				var (
+					wg       sync.WaitGroup
+					mu       sync.Mutex
+					rejected int
+				)
+				for i := 0; i < requests; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						resp, err := http.Get(server.URL + "/api/v1/patients?id=P00001")
+						if err != nil {
+							t.Errorf("GET: %v", err)
+							return
+						}
+						resp.Body.Close()
+						if resp.StatusCode == http.StatusOK {
+							return
+						}
+						if resp.StatusCode != want || resp.Header.Get("Retry-After") == "" {
+							t.Errorf("rejection = %d (Retry-After %q), want %d with Retry-After", resp.StatusCode, resp.Header.Get("Retry-After"), want)
+						}
+						mu.Lock()
+						rejected++
+						mu.Unlock()
+					}()
+				}
+				wg.Wait()
+
+				if rejected == 0 {
+					t.Errorf("none of %d concurrent requests was rejected", requests)
+				}
+			})
+		}
+	}
+}
+
+// TestNaiveUpdateRejectionCarriesRetryAfter checks a PUT turned away at
+// MaxGoroutines gets Retry-After and the configured status, like a GET.
+func TestNaiveUpdateRejectionCarriesRetryAfter(t *testing.T) {
+	h := NewNaiveHandlerWithConfig(newFixedLatencyDatabase(200*time.Millisecond), NaiveConfig{MaxGoroutines: 1, RejectStatus: http.StatusTooManyRequests})
+
+	running := make(chan error, 1)
+	go func() {
+		_, err := h.HandleRequest(context.Background(), "P00001")
+		running <- err
+	}()
+	waitFor(t, func() bool { return h.GetActiveGoroutines() == 1 })
+
+	body, err := json.Marshal(models.GeneratePatient("P00002"))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	rec := putPatient(t, h, body)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("PUT over the cap: got %d (Retry-After %q), want 429 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	if err := <-running; err != nil {
+		t.Errorf("request under the cap: unexpected error: %v", err)
+	}
+}
+
+// TestNaiveCancelledRequestExitsMidQuery cancels HandleRequest part-way
+// through a slow query and checks that it returns at once and its
+// goroutine exits with the query, rather than outliving the request.
+func TestNaiveCancelledRequestExitsMidQuery(t *testing.T) {
+	assertNoGoroutineLeak(t, func() {
+		h := NewNaiveHandler(newFixedLatencyDatabase(time.Second))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		start := time.Now()
+		if _, err := h.HandleRequest(ctx, "P00001"); !errors.Is(err, context.Canceled) {
+			t.Errorf("HandleRequest error = %v, want context.Canceled", err)
+		}
+		waitFor(t, func() bool { return h.GetActiveGoroutines() == 0 })
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("goroutine outlived the cancelled request by %v", elapsed)
+		}
+	})
+}
+
+// unresponsiveStore is a PatientStore whose reads and writes ignore their
+// context and block until release is closed, like a driver stuck on the
+// network.
+type unresponsiveStore struct {
+	PatientStore
+	release chan struct{}
+}
+
+func (s *unresponsiveStore) QueryPatient(ctx context.Context, patientID string) (*models.Patient, error) {
+	<-s.release
+	return s.PatientStore.QueryPatient(context.Background(), patientID)
+}
+
+func (s *unresponsiveStore) UpdatePatient(ctx context.Context, patient *models.Patient) error {
+	<-s.release
+	return s.PatientStore.UpdatePatient(context.Background(), patient)
+}
+
+// TestNaiveAbandonedQueryDoesNotLeak has the caller give up on a query
+// that ignores cancellation. When the query finally returns, nobody is
+// waiting for its result; the goroutine must still be able to finish.
+func TestNaiveAbandonedQueryDoesNotLeak(t *testing.T) {
+	assertNoGoroutineLeak(t, func() {
+		store := &unresponsiveStore{
+			PatientStore: newMemoryStore(models.GeneratePatient("P00001")),
+			release:      make(chan struct{}),
+		}
+		h := NewNaiveHandler(store)
+
+		for _, call := range []func(ctx context.Context) error{
+			func(ctx context.Context) error {
+				_, err := h.HandleRequest(ctx, "P00001")
+				return err
+			},
+			func(ctx context.Context) error {
+				_, err := h.HandleUpdate(ctx, models.GeneratePatient("P00001"))
+				return err
+			},
+		} {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			err := call(ctx)
+			cancel()
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("abandoned call error = %v, want context.DeadlineExceeded", err)
+			}
+		}
+
+		close(store.release)
+		waitFor(t, func() bool { return h.GetActiveGoroutines() == 0 })
+	})
+}
@@ -2,7 +2,6 @@ package patterns
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"sync"
@@ -10,7 +9,6 @@ import (
 	"time"
 
 	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
-	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
 )
 
 // OptimizedHandler implements the worker pool pattern with sync.Pool optimization.
@@ -63,45 +61,20 @@ import (
 //
 // This pattern represents production-grade optimization.
 type OptimizedHandler struct {
-	db          *simulator.Database
-	workers     int
-	queueSize   int
-	jobQueue    chan *optimizedJob
-	wg          sync.WaitGroup
-	ctx         context.Context
-	cancel      context.CancelFunc
-	activeJobs  int64
-	queuedJobs  int64
+	*workerQueue
 
 	// sync.Pool for PatientResponse objects
 	// This pool allows us to reuse response objects across requests
 	responsePool sync.Pool
 
 	// Stats for pool effectiveness
-	poolHits   int64 // How many times we got an object from pool
-	poolMisses int64 // How many times we had to allocate new
-}
-
-// optimizedJob represents a unit of work with pooled response objects.
-type optimizedJob struct {
-	ctx        context.Context
-	patientID  string
-	resultChan chan *models.PatientResponse
-	errChan    chan error
+	poolGets   int64 // How many times we asked the pool for an object
+	poolMisses int64 // How many of those it had to allocate new
 }
 
 // NewOptimizedHandler creates a new optimized worker pool handler.
-func NewOptimizedHandler(db *simulator.Database, config WorkerPoolConfig) *OptimizedHandler {
-	ctx, cancel := context.WithCancel(context.Background())
-
-	h := &OptimizedHandler{
-		db:        db,
-		workers:   config.Workers,
-		queueSize: config.QueueSize,
-		jobQueue:  make(chan *optimizedJob, config.QueueSize),
-		ctx:       ctx,
-		cancel:    cancel,
-	}
+func NewOptimizedHandler(db PatientStore, config WorkerPoolConfig) *OptimizedHandler {
+	h := &OptimizedHandler{}
 
 	// Initialize the response pool
 	// The New function is called when the pool is empty and Get() is called
@@ -114,15 +87,16 @@ func NewOptimizedHandler(db *simulator.Database, config WorkerPoolConfig) *Optim
 		},
 	}
 
-	h.startWorkers()
+	h.workerQueue = newWorkerQueue(db, config, h.respond)
 	return h
 }
 
 // getResponse gets a response object from the pool.
 // This is much faster than allocating a new object each time.
 func (h *OptimizedHandler) getResponse() *models.PatientResponse {
+	// Counted before Get, so a miss it causes never outnumbers the gets
+	atomic.AddInt64(&h.poolGets, 1)
 	resp := h.responsePool.Get().(*models.PatientResponse)
-	atomic.AddInt64(&h.poolHits, 1)
 
 	// Important: Reset the object to clean state
 	// This ensures we don't have data leakage between requests
@@ -147,46 +121,13 @@ func (h *OptimizedHandler) putResponse(resp *models.PatientResponse) {
 	h.responsePool.Put(resp)
 }
 
-// startWorkers spawns the fixed number of worker goroutines.
-func (h *OptimizedHandler) startWorkers() {
-	for i := 0; i < h.workers; i++ {
-		h.wg.Add(1)
-		go h.worker(i)
-	}
-}
-
-// worker is the main loop for each worker goroutine.
-func (h *OptimizedHandler) worker(id int) {
-	defer h.wg.Done()
-
-	for {
-		select {
-		case <-h.ctx.Done():
-			return
-
-		case job, ok := <-h.jobQueue:
-			if !ok {
-				return
-			}
-
-			h.processJob(job)
-		}
-	}
-}
-
-// processJob handles a single patient query job using pooled objects.
-func (h *OptimizedHandler) processJob(j *optimizedJob) {
-	atomic.AddInt64(&h.activeJobs, 1)
-	atomic.AddInt64(&h.queuedJobs, -1)
-	defer atomic.AddInt64(&h.activeJobs, -1)
-
+// respond sends the outcome of a job to its caller in a response object
+// from the pool.
+func (h *OptimizedHandler) respond(j *job, patient *models.Patient, err error) {
 	// Get a response object from the pool
 	// This is the key optimization
 	response := h.getResponse()
 
-	// Query the database
-	patient, err := h.db.QueryPatient(j.ctx, j.patientID)
-
 	// Populate the pooled response object
 	response.Timestamp = time.Now()
 
@@ -218,87 +159,36 @@ func (h *OptimizedHandler) processJob(j *optimizedJob) {
 }
 
 // ServeHTTP handles incoming HTTP requests using the optimized worker pool.
+// GET reads a patient; PUT writes the JSON body through the same queue.
 func (h *OptimizedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	patientID := extractPatientID(r)
-	if patientID == "" {
-		http.Error(w, "patient ID required", http.StatusBadRequest)
-		return
-	}
-
-	// Create a job
-	j := &optimizedJob{
-		ctx:        r.Context(),
-		patientID:  patientID,
-		resultChan: make(chan *models.PatientResponse, 1),
-		errChan:    make(chan error, 1),
-	}
-
-	// Try to enqueue the job
-	select {
-	case h.jobQueue <- j:
-		atomic.AddInt64(&h.queuedJobs, 1)
-	case <-r.Context().Done():
-		http.Error(w, "request cancelled", http.StatusRequestTimeout)
-		return
-	default:
-		http.Error(w, "service overloaded, please retry", http.StatusServiceUnavailable)
-		w.Header().Set("Retry-After", "1")
-		return
-	}
-
-	// Wait for the result
-	select {
-	case response := <-j.resultChan:
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-
-		// IMPORTANT: Return response to pool after use
-		// This is what makes the optimization work
-		h.putResponse(response)
+	// IMPORTANT: Return each response to the pool once it is written
+	// This is what makes the optimization work. Either encoding is
+	// done with it by then, and getResponse clears every field.
+	h.serveHTTP(w, r, "Optimized.ServeHTTP", h.putResponse)
+}
 
-	case err := <-j.errChan:
-		// Error responses use a fresh allocation (rare path)
-		response := models.NewErrorResponse(err, r.Header.Get("X-Request-ID"))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
+// HandleRequest is the non-HTTP interface for benchmarking.
+func (h *OptimizedHandler) HandleRequest(ctx context.Context, patientID string) (response *models.PatientResponse, err error) {
+	ctx, span := startSpan(ctx, "Optimized.HandleRequest")
+	defer func() { endSpan(span, err) }()
 
-	case <-r.Context().Done():
-		http.Error(w, "request timeout", http.StatusRequestTimeout)
-	}
+	return h.submit(ctx, newJob(ctx, patientID, nil))
 }
 
-// HandleRequest is the non-HTTP interface for benchmarking.
-func (h *OptimizedHandler) HandleRequest(ctx context.Context, patientID string) (*models.PatientResponse, error) {
-	j := &optimizedJob{
-		ctx:        ctx,
-		patientID:  patientID,
-		resultChan: make(chan *models.PatientResponse, 1),
-		errChan:    make(chan error, 1),
-	}
+// HandleUpdate is the non-HTTP interface for benchmarking writes.
+func (h *OptimizedHandler) HandleUpdate(ctx context.Context, patient *models.Patient) (response *models.PatientResponse, err error) {
+	ctx, span := startSpan(ctx, "Optimized.HandleUpdate")
+	defer func() { endSpan(span, err) }()
 
-	// Try to enqueue with timeout
-	select {
-	case h.jobQueue <- j:
-		atomic.AddInt64(&h.queuedJobs, 1)
-	case <-ctx.Done():
-		return models.NewErrorResponse(ctx.Err(), ""), ctx.Err()
-	case <-time.After(100 * time.Millisecond):
-		err := fmt.Errorf("queue full: request rejected")
-		return models.NewErrorResponse(err, ""), err
-	}
+	return h.submit(ctx, newJob(ctx, patient.ID, patient))
+}
 
-	// Wait for result
-	select {
-	case response := <-j.resultChan:
-		// Note: In benchmarking, we return the response without putting it back
-		// The benchmark harness handles this
-		return response, nil
-	case err := <-j.errChan:
-		return models.NewErrorResponse(err, ""), err
-	case <-ctx.Done():
-		return models.NewErrorResponse(ctx.Err(), ""), ctx.Err()
-	}
+// ReleaseResponse returns a response from HandleRequest or HandleUpdate to
+// the pool once the caller has finished with it. The response must not be
+// used afterwards. Releasing is optional; an unreleased response is simply
+// garbage collected.
+func (h *OptimizedHandler) ReleaseResponse(response *models.PatientResponse) {
+	h.putResponse(response)
 }
 
 // GetName returns the name of this pattern for reporting.
@@ -306,19 +196,14 @@ func (h *OptimizedHandler) GetName() string {
 	return fmt.Sprintf("Optimized Pool (%d workers + sync.Pool)", h.workers)
 }
 
-// GetStats returns current worker pool and sync.Pool statistics.
-func (h *OptimizedHandler) GetStats() (activeJobs, queuedJobs int64, queueCapacity int) {
-	return atomic.LoadInt64(&h.activeJobs),
-		atomic.LoadInt64(&h.queuedJobs),
-		h.queueSize
-}
-
 // GetPoolStats returns statistics about pool effectiveness.
 // High hit rate (hits / (hits + misses)) indicates effective pooling.
 // In production, aim for >90% hit rate.
 func (h *OptimizedHandler) GetPoolStats() (hits, misses int64, hitRate float64) {
-	hits = atomic.LoadInt64(&h.poolHits)
+	// A get is a hit unless New ran for it. Load the misses first, so
+	// every miss loaded has its get counted too.
 	misses = atomic.LoadInt64(&h.poolMisses)
+	hits = atomic.LoadInt64(&h.poolGets) - misses
 
 	total := hits + misses
 	if total > 0 {
@@ -328,25 +213,16 @@ func (h *OptimizedHandler) GetPoolStats() (hits, misses int64, hitRate float64)
 	return hits, misses, hitRate
 }
 
-// Shutdown gracefully shuts down the optimized worker pool.
+// Shutdown gracefully shuts down the optimized worker pool, draining
+// queued jobs first; see WorkerPoolHandler.Shutdown.
 func (h *OptimizedHandler) Shutdown(ctx context.Context) error {
-	close(h.jobQueue)
-	h.cancel()
-
-	workersDone := make(chan struct{})
-	go func() {
-		h.wg.Wait()
-		close(workersDone)
-	}()
-
-	select {
-	case <-workersDone:
-		// Log pool statistics on shutdown
-		hits, misses, hitRate := h.GetPoolStats()
-		fmt.Printf("sync.Pool stats: %d hits, %d misses, %.2f%% hit rate\n",
-			hits, misses, hitRate)
-		return nil
-	case <-ctx.Done():
-		return fmt.Errorf("shutdown timeout: workers still processing")
+	if err := h.shutdown(ctx); err != nil {
+		return err
 	}
+
+	// Log pool statistics on shutdown
+	hits, misses, hitRate := h.GetPoolStats()
+	fmt.Printf("sync.Pool stats: %d hits, %d misses, %.2f%% hit rate\n",
+		hits, misses, hitRate)
+	return nil
 }
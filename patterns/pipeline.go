@@ -0,0 +1,398 @@
+package patterns
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PipelineHandler splits each request into stages connected by channels:
+//
+//	validate → query → serialize
+//
+// HOW IT DIFFERS FROM THE WORKER POOL:
+//
+// 1. One Pool Per Stage:
+//   - Each stage has its own goroutines reading from its own channel
+//   - Stages are sized independently: a few goroutines for the cheap CPU
+//     stages, many for the query stage that waits on the database
+//   - A request moves stage to stage; no goroutine sees it end to end
+//
+// 2. Backpressure Between Stages:
+//   - Every channel is bounded, so a slow stage fills its input and
+//     stalls the stage before it, back up to the first channel
+//   - Only the first channel rejects requests (ErrQueueFull); inside the
+//     pipeline, stages wait for each other
+//
+// 3. Trade-offs:
+//   - A hand-off per stage adds latency and allocations to every request
+//   - The slowest stage bounds throughput, so stages need tuning together
+//   - Shutdown must drain each stage in order before closing the next
+//
+// WHEN TO USE:
+// - Work with distinct phases that need different amounts of concurrency
+// - CPU-heavy phases (encoding, validation) beside I/O-bound ones
+// - When you want to measure where in the request time is spent
+type PipelineHandler struct {
+	db PatientStore
+
+	validateWorkers  int
+	queryWorkers     int
+	serializeWorkers int
+	enqueueTimeout   time.Duration
+	rejectStatus     int
+
+	validateQueue  chan *pipelineItem
+	queryQueue     chan *pipelineItem
+	serializeQueue chan *pipelineItem
+
+	queueMu      sync.RWMutex  // Held for reading while sending to validateQueue
+	stopping     chan struct{} // Closed when Shutdown starts refusing requests
+	abandoned    chan struct{} // Closed when Shutdown times out; stages then skip work
+	drained      chan struct{} // Closed once the last stage has exited
+	shutdownOnce sync.Once
+	abandonOnce  sync.Once
+}
+
+// PipelineConfig holds configuration for the pipeline handler.
+type PipelineConfig struct {
+	ValidateWorkers  int           // Goroutines checking requests before they reach the database
+	QueryWorkers     int           // Goroutines querying the database
+	SerializeWorkers int           // Goroutines building and encoding responses
+	QueueSize        int           // Buffer in front of each stage
+	EnqueueTimeout   time.Duration // How long HandleRequest waits for space in the first stage
+	RejectStatus     int           // Status for rejected requests: 503 when zero, or 429
+}
+
+// DefaultPipelineConfig returns defaults with as many query workers as the
+// worker pool has workers, so the two are directly comparable.
+func DefaultPipelineConfig() PipelineConfig {
+	return PipelineConfig{
+		ValidateWorkers:  2,
+		QueryWorkers:     20,
+		SerializeWorkers: 2,
+		QueueSize:        100,
+		EnqueueTimeout:   DefaultEnqueueTimeout,
+	}
+}
+
+// ErrInvalidRequest is returned when the validate stage rejects a request
+// before it reaches the database.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// pipelineItem is one request on its way through the stages. Each stage
+// fills in its part; done is closed once the serialize stage has finished.
+type pipelineItem struct {
+	ctx       context.Context
+	patientID string
+	update    *models.Patient
+	queueSpan trace.Span
+
+	// Set for HTTP requests, whose headers decide the encoding
+	req       *http.Request
+	requestID string
+
+	patient  *models.Patient
+	err      error
+	response *models.PatientResponse
+	encoded  *encodedResponse // HTTP requests only
+
+	done chan struct{}
+}
+
+// encodedResponse is an http.ResponseWriter that keeps what is written,
+// so the serialize stage can encode a response before the request's own
+// goroutine sends it.
+type encodedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (e *encodedResponse) Header() http.Header         { return e.header }
+func (e *encodedResponse) WriteHeader(status int)      { e.status = status }
+func (e *encodedResponse) Write(p []byte) (int, error) { return e.body.Write(p) }
+
+// writeTo sends the encoded response to w.
+func (e *encodedResponse) writeTo(w http.ResponseWriter) {
+	for key, values := range e.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(e.status)
+	w.Write(e.body.Bytes())
+}
+
+// NewPipelineHandler creates a pipeline handler and starts every stage.
+func NewPipelineHandler(db PatientStore, config PipelineConfig) *PipelineHandler {
+	enqueueTimeout := config.EnqueueTimeout
+	if enqueueTimeout <= 0 {
+		enqueueTimeout = DefaultEnqueueTimeout
+	}
+
+	h := &PipelineHandler{
+		db:               db,
+		validateWorkers:  config.ValidateWorkers,
+		queryWorkers:     config.QueryWorkers,
+		serializeWorkers: config.SerializeWorkers,
+		enqueueTimeout:   enqueueTimeout,
+		rejectStatus:     config.RejectStatus,
+		validateQueue:    make(chan *pipelineItem, config.QueueSize),
+		queryQueue:       make(chan *pipelineItem, config.QueueSize),
+		serializeQueue:   make(chan *pipelineItem, config.QueueSize),
+		stopping:         make(chan struct{}),
+		abandoned:        make(chan struct{}),
+		drained:          make(chan struct{}),
+	}
+
+	// Each stage closes the next one's channel once its own workers have
+	// exited, so nothing is ever sent on a closed channel and Shutdown
+	// drains the stages in order.
+	h.startStage(h.validateWorkers, h.validateQueue, h.queryQueue, h.validate)
+	h.startStage(h.queryWorkers, h.queryQueue, h.serializeQueue, h.query)
+	h.startStage(h.serializeWorkers, h.serializeQueue, nil, h.serialize)
+
+	return h
+}
+
+// startStage starts workers goroutines applying process to each item from
+// in and passing it to out. Once in is closed and drained, out is closed,
+// or for the last stage (a nil out), drained.
+func (h *PipelineHandler) startStage(workers int, in <-chan *pipelineItem, out chan<- *pipelineItem, process func(*pipelineItem)) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range in {
+				process(item)
+				if out != nil {
+					out <- item
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		if out != nil {
+			close(out)
+		} else {
+			close(h.drained)
+		}
+	}()
+}
+
+// skip reports whether an item should skip the stage's work, recording
+// why: an earlier stage failed it, its caller gave up, or Shutdown timed
+// out.
+func (h *PipelineHandler) skip(item *pipelineItem) bool {
+	if item.err != nil {
+		return true
+	}
+	select {
+	case <-h.abandoned:
+		item.err = ErrShuttingDown
+	default:
+		item.err = item.ctx.Err()
+	}
+	return item.err != nil
+}
+
+// validate is the first stage: it rejects requests that could never
+// succeed, so they don't take up a query worker.
+func (h *PipelineHandler) validate(item *pipelineItem) {
+	endSpan(item.queueSpan, nil)
+	if h.skip(item) {
+		return
+	}
+
+	switch {
+	case item.patientID == "":
+		item.err = fmt.Errorf("%w: patient ID required", ErrInvalidRequest)
+	case item.update != nil:
+		if err := item.update.Validate(); err != nil {
+			item.err = fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+	}
+}
+
+// query is the second stage: the database read or write.
+func (h *PipelineHandler) query(item *pipelineItem) {
+	if h.skip(item) {
+		return
+	}
+	item.patient, item.err = runJob(item.ctx, h.db, item.patientID, item.update)
+}
+
+// serialize is the last stage: it builds the response and, for HTTP
+// requests, encodes it, then hands the item back to its caller.
+func (h *PipelineHandler) serialize(item *pipelineItem) {
+	defer close(item.done)
+
+	status := http.StatusOK
+	if item.err != nil {
+		item.response = models.NewErrorResponse(item.err, item.requestID)
+		status = statusForError(item.err)
+	} else {
+		item.response = models.NewPatientResponse(item.patient, item.requestID)
+	}
+
+	if item.req != nil {
+		item.encoded = &encodedResponse{header: make(http.Header)}
+		writeResponse(item.encoded, item.req, status, item.response)
+	}
+}
+
+// enqueue sends item into the first stage, waiting at most wait for space.
+func (h *PipelineHandler) enqueue(item *pipelineItem, wait time.Duration) error {
+	h.queueMu.RLock()
+	defer h.queueMu.RUnlock()
+
+	// The queue wait span is ended by the validate stage
+	_, item.queueSpan = startSpan(item.ctx, "queue.wait")
+	if err := sendJob(item.ctx, h.validateQueue, item, h.stopping, wait); err != nil {
+		endSpan(item.queueSpan, err)
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP handles incoming HTTP requests by passing them through the
+// stages. GET reads a patient; PUT writes the JSON body.
+func (h *PipelineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, span := startSpan(r.Context(), "Pipeline.ServeHTTP")
+	defer span.End()
+
+	if _, err := parseFields(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	item := &pipelineItem{
+		ctx:       ctx,
+		patientID: extractPatientID(r),
+		req:       r,
+		requestID: requestID(r),
+		done:      make(chan struct{}),
+	}
+
+	if r.Method == http.MethodPut {
+		patient, err := decodePatient(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		item.update, item.patientID = patient, patient.ID
+	}
+
+	if item.patientID == "" {
+		http.Error(w, "patient ID required", http.StatusBadRequest)
+		return
+	}
+
+	// Like the worker pool, reject at once when the first stage is full
+	if err := h.enqueue(item, 0); err != nil {
+		switch {
+		case errors.Is(err, ErrQueueFull):
+			rejectOverloaded(w, h.rejectStatus)
+		case errors.Is(err, ErrShuttingDown):
+			http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		default:
+			http.Error(w, "request cancelled", http.StatusRequestTimeout)
+		}
+		return
+	}
+
+	select {
+	case <-item.done:
+		if item.err != nil {
+			recordError(span, item.err)
+		}
+		item.encoded.writeTo(w)
+	case <-ctx.Done():
+		http.Error(w, "request cancelled", http.StatusRequestTimeout)
+	}
+}
+
+// HandleRequest is the non-HTTP interface for benchmarking.
+func (h *PipelineHandler) HandleRequest(ctx context.Context, patientID string) (response *models.PatientResponse, err error) {
+	ctx, span := startSpan(ctx, "Pipeline.HandleRequest")
+	defer func() { endSpan(span, err) }()
+
+	return h.run(ctx, patientID, nil)
+}
+
+// HandleUpdate is the non-HTTP interface for benchmarking writes.
+func (h *PipelineHandler) HandleUpdate(ctx context.Context, patient *models.Patient) (response *models.PatientResponse, err error) {
+	ctx, span := startSpan(ctx, "Pipeline.HandleUpdate")
+	defer func() { endSpan(span, err) }()
+
+	return h.run(ctx, patient.ID, patient)
+}
+
+// run passes a read, or a write when update is non-nil, through the
+// stages and waits for the result.
+func (h *PipelineHandler) run(ctx context.Context, patientID string, update *models.Patient) (*models.PatientResponse, error) {
+	wait, ok := enqueueBudget(ctx, h.enqueueTimeout)
+	if !ok {
+		return models.NewErrorResponse(context.DeadlineExceeded, ""), context.DeadlineExceeded
+	}
+
+	item := &pipelineItem{
+		ctx:       ctx,
+		patientID: patientID,
+		update:    update,
+		done:      make(chan struct{}),
+	}
+	if err := h.enqueue(item, wait); err != nil {
+		return models.NewErrorResponse(err, ""), err
+	}
+
+	select {
+	case <-item.done:
+		return item.response, item.err
+	case <-ctx.Done():
+		return models.NewErrorResponse(ctx.Err(), ""), ctx.Err()
+	}
+}
+
+// GetName returns the name of this pattern for reporting.
+func (h *PipelineHandler) GetName() string {
+	return fmt.Sprintf("Pipeline (%d/%d/%d workers)", h.validateWorkers, h.queryWorkers, h.serializeWorkers)
+}
+
+// GetStats returns how many requests are waiting in front of each stage,
+// and the capacity of each stage's queue.
+func (h *PipelineHandler) GetStats() (validateQueued, queryQueued, serializeQueued, queueCapacity int) {
+	return len(h.validateQueue), len(h.queryQueue), len(h.serializeQueue), cap(h.validateQueue)
+}
+
+// Shutdown stops accepting requests and drains every stage in order: the
+// validate stage exits once its queue is empty, which closes the query
+// stage's queue, and so on. If ctx ends first, the stages stop doing work
+// and fail whatever is still in the pipeline with ErrShuttingDown, so no
+// caller is left waiting. Shutdown may be called more than once, including
+// concurrently.
+func (h *PipelineHandler) Shutdown(ctx context.Context) error {
+	h.shutdownOnce.Do(func() {
+		close(h.stopping)
+		h.queueMu.Lock()
+		close(h.validateQueue)
+		h.queueMu.Unlock()
+	})
+
+	select {
+	case <-h.drained:
+		return nil
+	case <-ctx.Done():
+		h.abandonOnce.Do(func() { close(h.abandoned) })
+		return fmt.Errorf("shutdown timeout: pipeline stages still draining")
+	}
+}
@@ -0,0 +1,140 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// TestPipelineEndToEnd sends reads and a write through every stage and
+// checks each caller gets its own patient back.
+func TestPipelineEndToEnd(t *testing.T) {
+	db := newFastDatabase()
+	h := NewPipelineHandler(db, PipelineConfig{ValidateWorkers: 1, QueryWorkers: 4, SerializeWorkers: 1, QueueSize: 4})
+	defer shutdownHandler(t, h)
+
+	const requests = 20
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		id := fmt.Sprintf("P%05d", i)
+		go func() {
+			response, err := h.HandleRequest(context.Background(), id)
+			switch {
+			case err != nil:
+				errs <- fmt.Errorf("%s: %w", id, err)
+			case !response.Success || response.Patient == nil || response.Patient.ID != id:
+				errs <- fmt.Errorf("%s: unexpected response %+v", id, response)
+			default:
+				errs <- nil
+			}
+		}()
+	}
+	for i := 0; i < requests; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+
+	patient := models.GeneratePatient("P00001")
+	response, err := h.HandleUpdate(context.Background(), patient)
+	if err != nil || response.Patient == nil || response.Patient.ID != "P00001" {
+		t.Errorf("HandleUpdate = %+v, %v; want the updated patient", response, err)
+	}
+}
+
+// TestPipelineValidateStageRejects checks invalid requests fail in the
+// validate stage without reaching the database.
+func TestPipelineValidateStageRejects(t *testing.T) {
+	db := newFastDatabase()
+	h := NewPipelineHandler(db, DefaultPipelineConfig())
+	defer shutdownHandler(t, h)
+
+	if _, err := h.HandleRequest(context.Background(), ""); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("empty patient ID: got %v, want ErrInvalidRequest", err)
+	}
+	if _, err := h.HandleUpdate(context.Background(), &models.Patient{ID: "P00001"}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("update without a name: got %v, want ErrInvalidRequest", err)
+	}
+	if queries, _ := db.GetStats(); queries != 0 {
+		t.Errorf("database saw %d queries, want 0", queries)
+	}
+}
+
+// TestPipelineShutdownDrainsStages starts more requests than the query
+// stage can run at once, shuts down while they are in flight, and checks
+// every one completes and every stage goroutine exits.
+func TestPipelineShutdownDrainsStages(t *testing.T) {
+	assertNoGoroutineLeak(t, func() {
+		h := NewPipelineHandler(newFixedLatencyDatabase(50*time.Millisecond),
+			PipelineConfig{ValidateWorkers: 2, QueryWorkers: 2, SerializeWorkers: 2, QueueSize: 10})
+
+		const requests = 8
+		errs := make(chan error, requests)
+		for i := 0; i < requests; i++ {
+			go func() {
+				_, err := h.HandleRequest(context.Background(), "P00001")
+				errs <- err
+			}()
+		}
+		waitFor(t, func() bool {
+			validating, querying, _, _ := h.GetStats()
+			return validating+querying > 0
+		})
+
+		shutdownHandler(t, h)
+		for i := 0; i < requests; i++ {
+			if err := <-errs; err != nil {
+				t.Errorf("request in flight at shutdown: %v", err)
+			}
+		}
+
+		if _, err := h.HandleRequest(context.Background(), "P00001"); !errors.Is(err, ErrShuttingDown) {
+			t.Errorf("request after shutdown: got %v, want ErrShuttingDown", err)
+		}
+	})
+}
+
+// TestPipelineShutdownTimeoutFailsQueued checks that when Shutdown times
+// out, requests still in the pipeline fail with ErrShuttingDown rather
+// than waiting for the database.
+func TestPipelineShutdownTimeoutFailsQueued(t *testing.T) {
+	h := NewPipelineHandler(newFixedLatencyDatabase(200*time.Millisecond),
+		PipelineConfig{ValidateWorkers: 1, QueryWorkers: 1, SerializeWorkers: 1, QueueSize: 10})
+
+	const requests = 5
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			_, err := h.HandleRequest(context.Background(), "P00001")
+			errs <- err
+		}()
+	}
+	waitFor(t, func() bool {
+		_, querying, _, _ := h.GetStats()
+		return querying > 0
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := h.Shutdown(ctx); err == nil {
+		t.Fatal("Shutdown returned nil with requests still queued")
+	}
+
+	abandoned := 0
+	for i := 0; i < requests; i++ {
+		if err := <-errs; errors.Is(err, ErrShuttingDown) {
+			abandoned++
+		} else if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if abandoned == 0 {
+		t.Error("no request failed with ErrShuttingDown")
+	}
+
+	shutdownHandler(t, h) // The stages still drain
+}
@@ -0,0 +1,96 @@
+package patterns
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// patientFields maps each of Patient's JSON field names to its struct
+// field index, read once from the json tags so it can't drift from them.
+var patientFields, patientFieldNames = func() (map[string]int, []string) {
+	t := reflect.TypeOf(models.Patient{})
+	fields := make(map[string]int, t.NumField())
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = i
+		names = append(names, name)
+	}
+	return fields, names
+}()
+
+// fieldSet is a projection of Patient: bit i selects struct field i. The
+// zero set means no projection, i.e. every field.
+type fieldSet uint64
+
+// parseFields reads the comma-separated fields query parameter, e.g.
+// ?fields=first_name,last_name,allergies, rejecting names that aren't
+// Patient JSON fields.
+func parseFields(r *http.Request) (fieldSet, error) {
+	var set fieldSet
+	for _, name := range strings.Split(r.URL.Query().Get("fields"), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		i, ok := patientFields[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown field %q (valid fields: %s)", name, strings.Join(patientFieldNames, ", "))
+		}
+		set |= 1 << i
+	}
+	return set, nil
+}
+
+// project returns a copy of p holding only the selected fields, for
+// encodings (protobuf) that have no way to leave fields out.
+func (s fieldSet) project(p *models.Patient) *models.Patient {
+	src := reflect.ValueOf(p).Elem()
+	projected := &models.Patient{}
+	dst := reflect.ValueOf(projected).Elem()
+	for _, i := range patientFields {
+		if s&(1<<i) != 0 {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+	return projected
+}
+
+// projectJSON returns the selected fields of p keyed by JSON name, so the
+// encoded patient leaves every other field out entirely.
+func (s fieldSet) projectJSON(p *models.Patient) map[string]interface{} {
+	v := reflect.ValueOf(p).Elem()
+	projected := make(map[string]interface{})
+	for name, i := range patientFields {
+		if s&(1<<i) != 0 {
+			projected[name] = v.Field(i).Interface()
+		}
+	}
+	return projected
+}
+
+// projectedResponse is a PatientResponse whose patient is projected. The
+// outer Patient shadows the embedded one when encoded.
+type projectedResponse struct {
+	*models.PatientResponse
+	Patient map[string]interface{} `json:"patient,omitempty"`
+}
+
+// encodeJSON writes response to r as JSON, projected to s.
+func (s fieldSet) encodeJSON(w http.ResponseWriter, r *http.Request, response *models.PatientResponse) {
+	enc := NewJSONEncoder(w, r)
+	if s == 0 || response.Patient == nil {
+		enc.Encode(response)
+		return
+	}
+	enc.Encode(projectedResponse{
+		PatientResponse: response,
+		Patient:         s.projectJSON(response.Patient),
+	})
+}
@@ -0,0 +1,89 @@
+package patterns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// TestFieldProjection checks every pattern returns only the fields asked
+// for, with the same values as the full record, and rejects unknown ones.
+func TestFieldProjection(t *testing.T) {
+	for _, tc := range allHandlers {
+		t.Run(tc.name, func(t *testing.T) {
+			db := simulator.NewDatabase(1, 2, 0, simulator.WithCorpus(10, simulator.DefaultCorpusSeed))
+			h := tc.new(db)
+			defer shutdownHandler(t, h)
+
+			get := func(query string) *httptest.ResponseRecorder {
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001"+query, nil))
+				return rec
+			}
+
+			var full struct {
+				Patient map[string]interface{} `json:"patient"`
+			}
+			if err := json.Unmarshal(get("").Body.Bytes(), &full); err != nil {
+				t.Fatalf("decode full response: %v", err)
+			}
+
+			rec := get("&fields=first_name,last_name,allergies")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("projected GET = %d: %s", rec.Code, rec.Body.String())
+			}
+			var projected struct {
+				Success bool                   `json:"success"`
+				Patient map[string]interface{} `json:"patient"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &projected); err != nil {
+				t.Fatalf("decode projected response: %v", err)
+			}
+			if !projected.Success {
+				t.Errorf("projected response not successful: %s", rec.Body.String())
+			}
+
+			var got []string
+			for name := range projected.Patient {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if want := []string{"allergies", "first_name", "last_name"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("projected fields = %v, want %v", got, want)
+			}
+			for name, value := range projected.Patient {
+				if !reflect.DeepEqual(value, full.Patient[name]) {
+					t.Errorf("%s = %v, want %v as in the full record", name, value, full.Patient[name])
+				}
+			}
+
+			// Protobuf can't omit fields, so the others come back empty
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001&fields=last_name", nil)
+			req.Header.Set("Accept", ContentTypeProtobuf)
+			rec = httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			var response models.PatientResponse
+			if err := response.UnmarshalProto(rec.Body.Bytes()); err != nil {
+				t.Fatalf("decode protobuf: %v", err)
+			}
+			if response.Patient == nil || response.Patient.LastName != full.Patient["last_name"] || response.Patient.FirstName != "" {
+				t.Errorf("protobuf projected to last_name = %+v", response.Patient)
+			}
+
+			rec = get("&fields=first_name,ssn")
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"ssn"`) {
+				t.Errorf("unknown field: got %d %q, want 400 naming it", rec.Code, rec.Body.String())
+			}
+			if queries, _ := db.GetStats(); queries != 3 {
+				t.Errorf("database saw %d queries, want 3 (none for the rejected request)", queries)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package patterns
+
+import (
+	"context"
+	"net/http"
+)
+
+// RequestIDHeader carries a request's correlation ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the context key for the request ID.
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the given request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored by WithRequestID,
+// or "" if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestID returns the ID to echo in a response. It prefers the context,
+// where the server's middleware puts a generated ID when the client sent
+// none, and falls back to the header for callers that skip the middleware.
+func requestID(r *http.Request) string {
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		return id
+	}
+	return r.Header.Get(RequestIDHeader)
+}
@@ -0,0 +1,72 @@
+package patterns
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// SearchHandler serves patient searches by criteria:
+//
+//	GET /api/v1/patients/search?last_name=Smi&physician=Dr.%20Patel&diagnosis=I10
+//
+// Every parameter is optional but at least one is required; a patient must
+// match all of them (see simulator.SearchCriteria). Searches run straight
+// against the database, one scan per request.
+type SearchHandler struct {
+	db PatientSearcher
+}
+
+// NewSearchHandler creates a search handler.
+func NewSearchHandler(db PatientSearcher) *SearchHandler {
+	return &SearchHandler{db: db}
+}
+
+// extractSearchCriteria reads the search filters from the query string.
+func extractSearchCriteria(r *http.Request) simulator.SearchCriteria {
+	query := r.URL.Query()
+	return simulator.SearchCriteria{
+		LastNamePrefix: query.Get("last_name"),
+		Physician:      query.Get("physician"),
+		DiagnosisCode:  query.Get("diagnosis"),
+	}
+}
+
+// ServeHTTP implements http.Handler for the search endpoint.
+func (h *SearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	criteria := extractSearchCriteria(r)
+	if criteria.IsEmpty() {
+		http.Error(w, "at least one of last_name, physician or diagnosis is required", http.StatusBadRequest)
+		return
+	}
+
+	patients, err := h.db.SearchPatients(r.Context(), criteria)
+
+	response := &models.SearchResponse{
+		Success:   err == nil,
+		Count:     len(patients),
+		Patients:  patients,
+		Timestamp: time.Now(),
+		RequestID: requestID(r),
+	}
+	if response.Patients == nil {
+		response.Patients = []*models.Patient{}
+	}
+	if err != nil {
+		response.Error = err.Error()
+	}
+
+	status := http.StatusOK
+	if err != nil {
+		status = statusForError(err)
+	}
+	WriteJSON(w, r, status, response)
+}
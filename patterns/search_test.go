@@ -0,0 +1,63 @@
+package patterns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+func TestSearchHandler(t *testing.T) {
+	corpus := []*models.Patient{
+		{ID: "P1", LastName: "Smith", PrimaryPhysician: "Dr. Patel"},
+		{ID: "P2", LastName: "Smith", PrimaryPhysician: "Dr. Chen"},
+		{ID: "P3", LastName: "Jones", PrimaryPhysician: "Dr. Patel"},
+	}
+	db := simulator.NewDatabase(1, 2, 0, simulator.WithSearchCorpus(corpus))
+	db.SetSearchLatency(1, 2)
+	h := NewSearchHandler(db)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patients/search?last_name=Smith&physician=Dr.%20Patel", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var response models.SearchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !response.Success || response.Count != 1 || len(response.Patients) != 1 || response.Patients[0].ID != "P1" {
+		t.Errorf("unexpected search response: %+v", response)
+	}
+
+	// No matches is an empty list, not an error
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/patients/search?last_name=Garcia", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !json.Valid(rec.Body.Bytes()) {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var raw map[string]json.RawMessage
+	json.Unmarshal(rec.Body.Bytes(), &raw)
+	if string(raw["patients"]) != "[]" {
+		t.Errorf("patients = %s, want []", raw["patients"])
+	}
+}
+
+func TestSearchHandlerRequiresAFilter(t *testing.T) {
+	h := NewSearchHandler(newFastDatabase())
+
+	for _, query := range []string{"", "?id=P1", "?last_name="} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/patients/search"+query, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// SemaphoreHandler bounds concurrency with a buffered channel semaphore.
+//
+// HOW IT DIFFERS FROM THE WORKER POOL:
+//
+// 1. No Persistent Goroutines:
+//    - Each request runs on the caller's goroutine (the net/http goroutine)
+//    - A slot is acquired from a chan struct{} of size N before querying
+//    - The slot is released as soon as the query returns
+//    - Nothing to start up, nothing idle between bursts
+//
+// 2. No Job Queue:
+//    - Waiting callers block on the semaphore instead of sitting in a buffer
+//    - No job structs or result channels are allocated per request
+//    - Backpressure comes from the acquire timeout alone
+//
+// 3. Trade-offs:
+//    - Lighter weight and simpler to reason about
+//    - Waiting requests still hold a goroutine each (unlike a queued job)
+//    - No FIFO ordering guarantee between waiters
+//    - Harder to add per-worker health checks or prioritisation
+//
+// WHEN TO USE:
+// - Services where request goroutines already exist (HTTP handlers)
+// - Limiting access to a downstream resource (DB connections, external APIs)
+// - When the cost of a worker pool's plumbing shows up in profiles
+//
+// Benchmark this against the worker pool to compare overhead and GC behaviour.
+type SemaphoreHandler struct {
+	db             PatientStore
+	maxConcurrent  int
+	acquireTimeout time.Duration
+	rejectStatus   int
+	slots          chan struct{}
+	inFlight       int64
+}
+
+// SemaphoreConfig holds configuration for the semaphore handler.
+type SemaphoreConfig struct {
+	MaxConcurrent  int           // Number of semaphore slots
+	AcquireTimeout time.Duration // How long to wait for a slot before rejecting
+	RejectStatus   int           // Status for rejected requests: 503 when zero, or 429
+}
+
+// DefaultSemaphoreConfig returns defaults that match the worker pool's
+// concurrency level and enqueue timeout, so the two are directly comparable.
+func DefaultSemaphoreConfig() SemaphoreConfig {
+	return SemaphoreConfig{
+		MaxConcurrent:  20,
+		AcquireTimeout: 100 * time.Millisecond,
+	}
+}
+
+// NewSemaphoreHandler creates a new semaphore-bounded handler.
+func NewSemaphoreHandler(db PatientStore, config SemaphoreConfig) *SemaphoreHandler {
+	return &SemaphoreHandler{
+		db:             db,
+		maxConcurrent:  config.MaxConcurrent,
+		acquireTimeout: config.AcquireTimeout,
+		rejectStatus:   config.RejectStatus,
+		slots:          make(chan struct{}, config.MaxConcurrent),
+	}
+}
+
+// ErrSemaphoreFull is returned when no slot frees up within the acquire
+// timeout.
+var ErrSemaphoreFull = errors.New("semaphore full: request rejected")
+
+// acquire blocks until a slot is free, the context is done, or the acquire
+// timeout elapses.
+func (h *SemaphoreHandler) acquire(ctx context.Context) (err error) {
+	_, span := startSpan(ctx, "semaphore.acquire")
+	defer func() { endSpan(span, err) }()
+
+	timer := time.NewTimer(h.acquireTimeout)
+	defer timer.Stop()
+
+	select {
+	case h.slots <- struct{}{}:
+		atomic.AddInt64(&h.inFlight, 1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return ErrSemaphoreFull
+	}
+}
+
+// release returns a slot to the semaphore.
+func (h *SemaphoreHandler) release() {
+	atomic.AddInt64(&h.inFlight, -1)
+	<-h.slots
+}
+
+// ServeHTTP handles incoming HTTP requests, querying inline once a slot is held.
+// GET reads a patient; PUT writes the JSON body.
+func (h *SemaphoreHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, span := startSpan(r.Context(), "Semaphore.ServeHTTP")
+	defer span.End()
+
+	if _, err := parseFields(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var update *models.Patient
+	patientID := extractPatientID(r)
+
+	if r.Method == http.MethodPut {
+		patient, err := decodePatient(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		update, patientID = patient, patient.ID
+	}
+
+	if patientID == "" {
+		http.Error(w, "patient ID required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.acquire(ctx); err != nil {
+		if ctx.Err() != nil {
+			http.Error(w, "request cancelled", http.StatusRequestTimeout)
+			return
+		}
+		rejectOverloaded(w, h.rejectStatus)
+		return
+	}
+	defer h.release()
+
+	patient, err := runJob(ctx, h.db, patientID, update)
+	if err != nil {
+		recordError(span, err)
+		writeResponse(w, r, statusForError(err), models.NewErrorResponse(err, requestID(r)))
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, models.NewPatientResponse(patient, requestID(r)))
+}
+
+// HandleRequest is the non-HTTP interface for benchmarking.
+func (h *SemaphoreHandler) HandleRequest(ctx context.Context, patientID string) (response *models.PatientResponse, err error) {
+	ctx, span := startSpan(ctx, "Semaphore.HandleRequest")
+	defer func() { endSpan(span, err) }()
+
+	return h.run(ctx, patientID, nil)
+}
+
+// HandleUpdate is the non-HTTP interface for benchmarking writes.
+func (h *SemaphoreHandler) HandleUpdate(ctx context.Context, patient *models.Patient) (response *models.PatientResponse, err error) {
+	ctx, span := startSpan(ctx, "Semaphore.HandleUpdate")
+	defer func() { endSpan(span, err) }()
+
+	return h.run(ctx, patient.ID, patient)
+}
+
+// run acquires a slot and performs a read, or a write when update is non-nil.
+func (h *SemaphoreHandler) run(ctx context.Context, patientID string, update *models.Patient) (*models.PatientResponse, error) {
+	if err := h.acquire(ctx); err != nil {
+		return models.NewErrorResponse(err, ""), err
+	}
+	defer h.release()
+
+	patient, err := runJob(ctx, h.db, patientID, update)
+	if err != nil {
+		return models.NewErrorResponse(err, ""), err
+	}
+
+	return models.NewPatientResponse(patient, ""), nil
+}
+
+// GetName returns the name of this pattern for reporting.
+func (h *SemaphoreHandler) GetName() string {
+	return fmt.Sprintf("Semaphore (%d slots)", h.maxConcurrent)
+}
+
+// GetStats returns the number of requests currently holding a slot and the
+// total number of slots.
+func (h *SemaphoreHandler) GetStats() (inFlight int64, capacity int) {
+	return atomic.LoadInt64(&h.inFlight), h.maxConcurrent
+}
+
+// Shutdown waits for in-flight requests to release their slots.
+// There are no background goroutines to stop.
+func (h *SemaphoreHandler) Shutdown(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		active := atomic.LoadInt64(&h.inFlight)
+		if active == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("shutdown timeout: %d requests still in flight", active)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package patterns
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// PatientStore is the database the patterns read and write patients
+// through. *simulator.Database is the implementation the benchmarks use;
+// implementing PatientStore plugs a real database in instead, with every
+// pattern unchanged.
+//
+// Errors should wrap the simulator's sentinel errors where one applies
+// (simulator.ErrPatientNotFound for a missing record, and so on), since
+// that is how the patterns choose a status code.
+type PatientStore interface {
+	QueryPatient(ctx context.Context, patientID string) (*models.Patient, error)
+	UpdatePatient(ctx context.Context, patient *models.Patient) error
+	HealthCheck(ctx context.Context) error
+	Close() error
+}
+
+// The simulator implements every store interface
+var _ interface {
+	PatientStore
+	BatchQuerier
+	PatientSearcher
+} = (*simulator.Database)(nil)
+
+// BatchQuerier is implemented by stores that can fetch several patients
+// more cheaply than one QueryPatient each, such as with a single
+// WHERE id IN (...) query. BatchHandler's sequential engine uses it when
+// the store has it. It follows simulator.Database.BatchQueryPatients:
+// patients in request order, and a *simulator.PartialResultError
+// alongside those fetched if it stops early.
+type BatchQuerier interface {
+	BatchQueryPatients(ctx context.Context, patientIDs []string) ([]*models.Patient, error)
+}
+
+// PatientSearcher is implemented by stores that can search patients by
+// criteria, which SearchHandler needs.
+type PatientSearcher interface {
+	SearchPatients(ctx context.Context, criteria simulator.SearchCriteria) ([]*models.Patient, error)
+}
+
+// batchQuery fetches patientIDs from store in request order, in one batch
+// if store is a BatchQuerier and one query after another otherwise. Like
+// BatchQueryPatients, it stops at the first failure and returns the
+// patients fetched so far with a *simulator.PartialResultError.
+func batchQuery(ctx context.Context, store PatientStore, patientIDs []string) ([]*models.Patient, error) {
+	if batcher, ok := store.(BatchQuerier); ok {
+		return batcher.BatchQueryPatients(ctx, patientIDs)
+	}
+
+	patients := make([]*models.Patient, 0, len(patientIDs))
+	for i, id := range patientIDs {
+		patient, err := store.QueryPatient(ctx, id)
+		if err != nil {
+			return patients, &simulator.PartialResultError{
+				Missing: append([]string(nil), patientIDs[i:]...),
+				Err:     fmt.Errorf("failed to query patient %s: %w", id, err),
+			}
+		}
+		patients = append(patients, patient)
+	}
+	return patients, nil
+}
@@ -0,0 +1,127 @@
+package patterns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// memoryStore is a PatientStore kept in a map, with no latency and no
+// simulated failures, standing in for a real database.
+type memoryStore struct {
+	mu       sync.Mutex
+	patients map[string]*models.Patient
+}
+
+func newMemoryStore(patients ...*models.Patient) *memoryStore {
+	s := &memoryStore{patients: make(map[string]*models.Patient)}
+	for _, p := range patients {
+		s.patients[p.ID] = p.Clone()
+	}
+	return s
+}
+
+func (s *memoryStore) QueryPatient(ctx context.Context, patientID string) (*models.Patient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.patients[patientID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", simulator.ErrPatientNotFound, patientID)
+	}
+	return p.Clone(), nil
+}
+
+func (s *memoryStore) UpdatePatient(ctx context.Context, patient *models.Patient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.patients[patient.ID] = patient.Clone()
+	return nil
+}
+
+func (s *memoryStore) HealthCheck(ctx context.Context) error { return nil }
+func (s *memoryStore) Close() error                          { return nil }
+
+// TestPatternsRunOnAnyStore serves reads and writes from an in-memory
+// store through each pattern, so none of them depends on the simulator.
+func TestPatternsRunOnAnyStore(t *testing.T) {
+	constructors := []struct {
+		name string
+		new  func(store PatientStore) httpHandler
+	}{
+		{"Naive", func(store PatientStore) httpHandler { return NewNaiveHandler(store) }},
+		{"WorkerPool", func(store PatientStore) httpHandler {
+			return NewWorkerPoolHandler(store, WorkerPoolConfig{Workers: 2, QueueSize: 4})
+		}},
+		{"Optimized", func(store PatientStore) httpHandler {
+			return NewOptimizedHandler(store, WorkerPoolConfig{Workers: 2, QueueSize: 4})
+		}},
+	}
+
+	for _, tc := range constructors {
+		t.Run(tc.name, func(t *testing.T) {
+			original := models.GeneratePatient("P00001")
+			store := newMemoryStore(original)
+			h := tc.new(store)
+			defer shutdownHandler(t, h)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil))
+			var got models.PatientResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+				t.Fatalf("GET = %d %s, want 200 with a patient", rec.Code, rec.Body)
+			}
+			if got.Patient.MedicalRecordNumber != original.MedicalRecordNumber {
+				t.Errorf("GET returned MRN %s, want the store's %s", got.Patient.MedicalRecordNumber, original.MedicalRecordNumber)
+			}
+
+			updated := original.Clone()
+			updated.Allergies = []string{"Latex"}
+			body, _ := json.Marshal(updated)
+			rec = httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/v1/patients", bytes.NewReader(body)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("PUT = %d %s, want 200", rec.Code, rec.Body)
+			}
+			if stored, _ := store.QueryPatient(context.Background(), "P00001"); len(stored.Allergies) != 1 || stored.Allergies[0] != "Latex" {
+				t.Errorf("store holds allergies %v after PUT, want [Latex]", stored.Allergies)
+			}
+
+			rec = httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P99999", nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("GET of a missing patient = %d, want 404", rec.Code)
+			}
+		})
+	}
+}
+
+// TestBatchWithoutBatchQuerier checks the sequential batch engine falls
+// back to one query per ID on a store without BatchQueryPatients, still
+// returning a partial result when one fails.
+func TestBatchWithoutBatchQuerier(t *testing.T) {
+	store := newMemoryStore(models.GeneratePatient("P00001"), models.GeneratePatient("P00002"))
+	h := NewBatchHandler(store, FanOutConfig{MaxParallel: 2})
+
+	patients, err := h.HandleBatch(context.Background(), EngineSequential, []string{"P00002", "P00001"})
+	if err != nil || len(patients) != 2 || patients[0].ID != "P00002" || patients[1].ID != "P00001" {
+		t.Fatalf("HandleBatch = %v, %v; want P00002, P00001", patients, err)
+	}
+
+	patients, err = h.HandleBatch(context.Background(), EngineSequential, []string{"P00001", "P99999", "P00002"})
+	var partial *simulator.PartialResultError
+	if !errors.As(err, &partial) || !errors.Is(err, simulator.ErrPatientNotFound) {
+		t.Fatalf("error = %v, want a PartialResultError wrapping ErrPatientNotFound", err)
+	}
+	if len(patients) != 1 || len(partial.Missing) != 2 || partial.Missing[0] != "P99999" {
+		t.Errorf("got %d patients, missing %v; want 1 and [P99999 P00002]", len(patients), partial.Missing)
+	}
+}
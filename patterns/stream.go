@@ -0,0 +1,77 @@
+package patterns
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// MaxStreamSize is the most patient IDs a single stream request may name.
+// Streaming never holds the whole result in memory, so it allows larger
+// lookups than the batch endpoint.
+const MaxStreamSize = 1000
+
+// StreamHandler serves large multi-patient lookups as newline-delimited
+// JSON:
+//
+//	GET /api/v1/patients/stream?ids=P1,P2,P3
+//
+// Each line is a models.PatientResponse, written and flushed as soon as
+// that lookup completes, so clients get early results instead of waiting
+// for the slowest one. Lines arrive in completion order, not request
+// order. A failed lookup is reported on its own line (Success false, the
+// ID in Error) and does not stop the others.
+type StreamHandler struct {
+	fanOut *FanOutHandler
+}
+
+// NewStreamHandler creates a stream handler that fans lookups out with config.
+func NewStreamHandler(db PatientStore, config FanOutConfig) *StreamHandler {
+	return &StreamHandler{
+		fanOut: NewFanOutHandler(db, config),
+	}
+}
+
+// ServeHTTP implements http.Handler for the stream endpoint.
+func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ids := extractPatientIDs(r)
+	if len(ids) == 0 {
+		http.Error(w, "ids is required", http.StatusBadRequest)
+		return
+	}
+	if len(ids) > MaxStreamSize {
+		http.Error(w, fmt.Sprintf("at most %d ids per stream", MaxStreamSize), http.StatusBadRequest)
+		return
+	}
+
+	// Without flush support the stream still arrives intact, just all at once
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	enc := NewJSONEncoder(w, nil) // One line per patient, so never pretty
+	id := requestID(r)
+	h.fanOut.HandleStream(r.Context(), ids, func(patientID string, patient *models.Patient, err error) {
+		response := &models.PatientResponse{
+			Success:   err == nil,
+			Patient:   patient,
+			Timestamp: time.Now(),
+			RequestID: id,
+		}
+		if err != nil {
+			response.Error = fmt.Sprintf("failed to query patient %s: %v", patientID, err)
+		}
+
+		enc.Encode(response)
+		rc.Flush()
+	})
+}
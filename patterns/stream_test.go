@@ -0,0 +1,156 @@
+package patterns
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// readStream decodes every NDJSON line of a stream response.
+func readStream(t *testing.T, body string) []models.PatientResponse {
+	t.Helper()
+
+	var responses []models.PatientResponse
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		var response models.PatientResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			t.Fatalf("line %d is not a patient response: %v: %s", len(responses)+1, err, scanner.Text())
+		}
+		responses = append(responses, response)
+	}
+	return responses
+}
+
+func TestStreamReturnsEachIDOnce(t *testing.T) {
+	// Random latencies make lookups complete out of order
+	db := simulator.NewDatabase(1, 10, 0)
+	server := httptest.NewServer(NewStreamHandler(db, FanOutConfig{MaxParallel: 8}))
+	defer server.Close()
+
+	ids := batchIDs(60)
+	resp, err := http.Get(server.URL + "/api/v1/patients/stream?ids=" + strings.Join(ids, ","))
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", ct)
+	}
+
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var response models.PatientResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			t.Fatalf("bad line %q: %v", scanner.Text(), err)
+		}
+		if !response.Success || response.Patient == nil {
+			t.Fatalf("unexpected failure: %+v", response)
+		}
+		seen[response.Patient.ID]++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("reading stream: %v", err)
+	}
+
+	if len(seen) != len(ids) {
+		t.Errorf("got %d distinct IDs, want %d", len(seen), len(ids))
+	}
+	for _, id := range ids {
+		if seen[id] != 1 {
+			t.Errorf("%s appeared %d times, want exactly once", id, seen[id])
+		}
+	}
+}
+
+func TestStreamFlushesEarlyResults(t *testing.T) {
+	db := simulator.NewDatabase(simulator.MinQueryLatency, simulator.MaxQueryLatency, 0)
+	db.SetLatencySource(simulator.NewTraceLatencySource([]time.Duration{
+		5 * time.Millisecond, 500 * time.Millisecond,
+	}))
+	server := httptest.NewServer(NewStreamHandler(db, FanOutConfig{MaxParallel: 2}))
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := http.Get(server.URL + "/api/v1/patients/stream?ids=P1,P2")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	if _, err := reader.ReadBytes('\n'); err != nil {
+		t.Fatalf("reading first line: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("first result took %v; it waited for the slow lookup", elapsed)
+	}
+}
+
+func TestStreamReportsFailuresPerLine(t *testing.T) {
+	db := simulator.NewDatabase(1, 2, 1)
+	h := NewStreamHandler(db, DefaultFanOutConfig())
+
+	ids := batchIDs(5)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patients/stream?ids="+strings.Join(ids, ","), nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	responses := readStream(t, rec.Body.String())
+	if len(responses) != len(ids) {
+		t.Fatalf("got %d lines, want one per ID (%d)", len(responses), len(ids))
+	}
+	for _, id := range ids {
+		found := 0
+		for _, response := range responses {
+			if response.Success || response.Patient != nil {
+				t.Fatalf("expected only failures, got %+v", response)
+			}
+			if strings.Contains(response.Error, id+":") {
+				found++
+			}
+		}
+		if found != 1 {
+			t.Errorf("%s named in %d error lines, want 1", id, found)
+		}
+	}
+}
+
+func TestStreamRejectsBadRequests(t *testing.T) {
+	h := NewStreamHandler(newFastDatabase(), DefaultFanOutConfig())
+
+	tests := map[string]string{
+		"no ids":       "",
+		"only commas":  "ids=,,",
+		"too many ids": "ids=" + strings.Join(batchIDs(MaxStreamSize+1), ","),
+	}
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/patients/stream?"+query, nil)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/patients/stream?ids=P1", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,32 @@
+package patterns
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies spans created by the handler patterns.
+const tracerName = "github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
+
+// startSpan starts a span using the globally registered tracer provider.
+// Until a provider is installed (see the -otel-endpoint flag) this is a no-op.
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name)
+}
+
+// recordError marks span as failed when err is non-nil.
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// endSpan records err on span and ends it.
+func endSpan(span trace.Span, err error) {
+	recordError(span, err)
+	span.End()
+}
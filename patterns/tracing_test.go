@@ -0,0 +1,138 @@
+package patterns
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs an in-memory tracer provider for the duration of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		provider.Shutdown(context.Background())
+	})
+
+	return recorder
+}
+
+// spansByName indexes ended spans by name, failing on duplicates.
+func spansByName(t *testing.T, recorder *tracetest.SpanRecorder) map[string]sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		if _, dup := byName[span.Name()]; dup {
+			t.Fatalf("span %q recorded more than once", span.Name())
+		}
+		byName[span.Name()] = span
+	}
+	return byName
+}
+
+func TestServeHTTPSpanHierarchy(t *testing.T) {
+	// Spans each pattern records between the request span and the query span
+	waitSpans := map[string]string{
+		"WorkerPool": "queue.wait",
+		"Optimized":  "queue.wait",
+		"Semaphore":  "semaphore.acquire",
+		"Pipeline":   "queue.wait",
+		"Bulkhead":   "queue.wait",
+	}
+
+	for _, tc := range allHandlers {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := recordSpans(t)
+			h := tc.new(newFastDatabase())
+			defer shutdownHandler(t, h)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P1", nil)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+
+			spans := spansByName(t, recorder)
+			root, ok := spans[tc.name+".ServeHTTP"]
+			if !ok {
+				t.Fatalf("no %s.ServeHTTP span; got %v", tc.name, names(spans))
+			}
+			if root.Parent().IsValid() {
+				t.Errorf("%s has a parent, want a root span", root.Name())
+			}
+
+			children := []string{"simulator.QueryPatient"}
+			if wait, ok := waitSpans[tc.name]; ok {
+				children = append(children, wait)
+			}
+			for _, name := range children {
+				child, ok := spans[name]
+				if !ok {
+					t.Errorf("no %s span; got %v", name, names(spans))
+					continue
+				}
+				if child.Parent().SpanID() != root.SpanContext().SpanID() {
+					t.Errorf("%s is not a child of %s", name, root.Name())
+				}
+				if child.SpanContext().TraceID() != root.SpanContext().TraceID() {
+					t.Errorf("%s is in a different trace from %s", name, root.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestHandleRequestQueueWaitSpan(t *testing.T) {
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := recordSpans(t)
+			h := tc.new(newFastDatabase(), DefaultWorkerPoolConfig())
+			defer shutdownHandler(t, h)
+
+			if _, err := h.HandleRequest(context.Background(), "P1"); err != nil {
+				t.Fatalf("HandleRequest: %v", err)
+			}
+
+			spans := spansByName(t, recorder)
+			root := spans[tc.name+".HandleRequest"]
+			wait := spans["queue.wait"]
+			query := spans["simulator.QueryPatient"]
+			if root == nil || wait == nil || query == nil {
+				t.Fatalf("missing spans; got %v", names(spans))
+			}
+
+			for _, child := range []sdktrace.ReadOnlySpan{wait, query} {
+				if child.Parent().SpanID() != root.SpanContext().SpanID() {
+					t.Errorf("%s is not a child of %s", child.Name(), root.Name())
+				}
+			}
+
+			// The job leaves the queue before the query starts
+			if wait.EndTime().After(query.StartTime()) {
+				t.Errorf("queue.wait ended at %v, after the query started at %v",
+					wait.EndTime(), query.StartTime())
+			}
+		})
+	}
+}
+
+func names(spans map[string]sdktrace.ReadOnlySpan) []string {
+	out := make([]string, 0, len(spans))
+	for name := range spans {
+		out = append(out, name)
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+package patterns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// httpHandler is implemented by every pattern handler.
+type httpHandler interface {
+	http.Handler
+	shutdowner
+}
+
+// allHandlers lists every pattern so HTTP behaviour is tested uniformly.
+var allHandlers = []struct {
+	name string
+	new  func(db *simulator.Database) httpHandler
+}{
+	{"Naive", func(db *simulator.Database) httpHandler {
+		return NewNaiveHandler(db)
+	}},
+	{"WorkerPool", func(db *simulator.Database) httpHandler {
+		return NewWorkerPoolHandler(db, DefaultWorkerPoolConfig())
+	}},
+	{"Optimized", func(db *simulator.Database) httpHandler {
+		return NewOptimizedHandler(db, DefaultWorkerPoolConfig())
+	}},
+	{"Semaphore", func(db *simulator.Database) httpHandler {
+		return NewSemaphoreHandler(db, DefaultSemaphoreConfig())
+	}},
+	{"Pipeline", func(db *simulator.Database) httpHandler {
+		return NewPipelineHandler(db, DefaultPipelineConfig())
+	}},
+	{"Bulkhead", func(db *simulator.Database) httpHandler {
+		return NewBulkheadHandler(db, DefaultBulkheadConfig())
+	}},
+}
+
+// newFastDatabase returns an error-free database with short read and write latencies.
+func newFastDatabase() *simulator.Database {
+	db := newFixedLatencyDatabase(time.Millisecond)
+	db.SetWriteProfile(1, 2, 0)
+	return db
+}
+
+func putPatient(t *testing.T, h http.Handler, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/patients", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestUpdateRejectsInvalidPatient(t *testing.T) {
+	invalid := map[string]string{
+		"malformed JSON": `{"id": "P1",`,
+		"missing ID":     `{"first_name": "Jane", "last_name": "Doe"}`,
+		"missing name":   `{"id": "P1", "first_name": "Jane"}`,
+		"future birth":   `{"id": "P1", "first_name": "Jane", "last_name": "Doe", "date_of_birth": "2999-01-01T00:00:00Z"}`,
+		"bad blood type": `{"id": "P1", "first_name": "Jane", "last_name": "Doe", "blood_type": "Q+"}`,
+		"bad MRN":        `{"id": "P1", "first_name": "Jane", "last_name": "Doe", "medical_record_number": "12"}`,
+	}
+
+	for _, tc := range allHandlers {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFastDatabase()
+			h := tc.new(db)
+			defer shutdownHandler(t, h)
+
+			for name, body := range invalid {
+				rec := putPatient(t, h, []byte(body))
+				if rec.Code != http.StatusBadRequest {
+					t.Errorf("%s: status = %d, want %d", name, rec.Code, http.StatusBadRequest)
+				}
+			}
+
+			if queries, _ := db.GetStats(); queries != 0 {
+				t.Errorf("invalid updates reached the database: %d queries", queries)
+			}
+		})
+	}
+}
+
+func TestUpdatePatient(t *testing.T) {
+	for _, tc := range allHandlers {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFastDatabase()
+			h := tc.new(db)
+			defer shutdownHandler(t, h)
+
+			patient := models.GeneratePatient("P00042")
+			patient.FirstName = "Updated"
+			body, err := json.Marshal(patient)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+
+			rec := putPatient(t, h, body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d; body: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+
+			var response models.PatientResponse
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if !response.Success || response.Patient == nil || response.Patient.FirstName != "Updated" {
+				t.Errorf("unexpected response: %+v", response)
+			}
+
+			stored, err := db.QueryPatient(context.Background(), "P00042")
+			if err != nil {
+				t.Fatalf("query after update: %v", err)
+			}
+			if stored.FirstName != "Updated" {
+				t.Errorf("stored first name = %q, want %q", stored.FirstName, "Updated")
+			}
+		})
+	}
+}
+
+// TestScriptedErrorsMapToStatus drives every pattern through a scripted
+// sequence of database outcomes and checks each request gets the status
+// its outcome maps to, in order.
+func TestScriptedErrorsMapToStatus(t *testing.T) {
+	script := []struct {
+		step simulator.Step
+		want int
+	}{
+		{simulator.Step{Latency: time.Millisecond}, http.StatusOK},
+		{simulator.Step{Err: simulator.ErrConnectionTimeout}, http.StatusGatewayTimeout},
+		{simulator.Step{Err: simulator.ErrPatientNotFound}, http.StatusNotFound},
+		{simulator.Step{Err: simulator.ErrLockTimeout}, http.StatusConflict},
+		{simulator.Step{Err: simulator.ErrPoolExhausted}, http.StatusServiceUnavailable},
+		{simulator.Step{Latency: time.Millisecond}, http.StatusOK},
+	}
+	steps := make([]simulator.Step, len(script))
+	for i, s := range script {
+		steps[i] = s.step
+	}
+
+	for _, tc := range allHandlers {
+		t.Run(tc.name, func(t *testing.T) {
+			h := tc.new(simulator.NewScriptedDatabase(steps))
+			defer shutdownHandler(t, h)
+
+			// One request at a time, so each takes the next step
+			for i, s := range script {
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil))
+				if rec.Code != s.want {
+					t.Errorf("request %d: status = %d, want %d", i+1, rec.Code, s.want)
+				}
+			}
+		})
+	}
+}
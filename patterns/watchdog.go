@@ -0,0 +1,96 @@
+package patterns
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultStuckThreshold is how long a worker may spend on a single job
+// before it is reported as stuck when no StuckThreshold is configured. It
+// is twice simulator.ContextTimeout, so a healthy query has long since
+// timed out by then.
+const DefaultStuckThreshold = 10 * time.Second
+
+// WorkerStatus describes one worker of a pool.
+type WorkerStatus struct {
+	ID int `json:"id"`
+
+	// LastActivity is when the worker last picked up a job; zero if it
+	// hasn't had one yet
+	LastActivity time.Time `json:"last_activity"`
+
+	Busy   bool    `json:"busy"`
+	BusyMs float64 `json:"busy_ms"` // Time spent on the current job
+	Stuck  bool    `json:"stuck"`   // BusyMs is beyond the stuck threshold
+}
+
+// WorkerHealth is a snapshot of every worker in a pool.
+type WorkerHealth struct {
+	Workers          []WorkerStatus `json:"workers"`
+	Stuck            int            `json:"stuck"`
+	StuckThresholdMs float64        `json:"stuck_threshold_ms"`
+}
+
+// workerWatchdog tracks when each worker of a pool started its current
+// job, so that a worker blocked on a hung database call shows up in
+// /health rather than just as a pool that has quietly lost capacity.
+// Workers are only ever read here; a stuck one is reported, not replaced.
+type workerWatchdog struct {
+	threshold time.Duration
+
+	// Unix nanoseconds, indexed by worker ID
+	lastPickup []int64
+	busySince  []int64 // Zero while the worker is idle
+}
+
+// newWorkerWatchdog returns a watchdog for workers workers, falling back to
+// DefaultStuckThreshold when threshold is unset.
+func newWorkerWatchdog(workers int, threshold time.Duration) *workerWatchdog {
+	if threshold <= 0 {
+		threshold = DefaultStuckThreshold
+	}
+	return &workerWatchdog{
+		threshold:  threshold,
+		lastPickup: make([]int64, workers),
+		busySince:  make([]int64, workers),
+	}
+}
+
+// begin records that worker id has picked up a job.
+func (w *workerWatchdog) begin(id int) {
+	now := time.Now().UnixNano()
+	atomic.StoreInt64(&w.lastPickup[id], now)
+	atomic.StoreInt64(&w.busySince[id], now)
+}
+
+// end records that worker id has finished its job.
+func (w *workerWatchdog) end(id int) {
+	atomic.StoreInt64(&w.busySince[id], 0)
+}
+
+// health returns the status of every worker as of now.
+func (w *workerWatchdog) health() WorkerHealth {
+	now := time.Now()
+	health := WorkerHealth{
+		Workers:          make([]WorkerStatus, len(w.busySince)),
+		StuckThresholdMs: float64(w.threshold) / float64(time.Millisecond),
+	}
+
+	for id := range w.busySince {
+		status := WorkerStatus{ID: id}
+		if last := atomic.LoadInt64(&w.lastPickup[id]); last != 0 {
+			status.LastActivity = time.Unix(0, last)
+		}
+		if since := atomic.LoadInt64(&w.busySince[id]); since != 0 {
+			busy := now.Sub(time.Unix(0, since))
+			status.Busy = true
+			status.BusyMs = float64(busy) / float64(time.Millisecond)
+			status.Stuck = busy > w.threshold
+		}
+		if status.Stuck {
+			health.Stuck++
+		}
+		health.Workers[id] = status
+	}
+	return health
+}
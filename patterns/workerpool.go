@@ -2,15 +2,15 @@ package patterns
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"sync"
+	"strconv"
 	"sync/atomic"
 	"time"
 
 	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
-	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // WorkerPoolHandler implements the worker pool pattern.
@@ -56,29 +56,137 @@ import (
 //
 // This is the recommended pattern for most Go services.
 type WorkerPoolHandler struct {
-	db          *simulator.Database
-	workers     int
-	queueSize   int
-	jobQueue    chan *job
-	wg          sync.WaitGroup
-	ctx         context.Context
-	cancel      context.CancelFunc
-	activeJobs  int64
-	queuedJobs  int64
+	*workerQueue
 }
 
-// job represents a unit of work for the worker pool.
-type job struct {
-	ctx        context.Context
-	patientID  string
-	resultChan chan *models.PatientResponse
-	errChan    chan error
-}
+// DefaultEnqueueTimeout is how long HandleRequest waits for queue space
+// before rejecting a request when no EnqueueTimeout is configured.
+const DefaultEnqueueTimeout = 100 * time.Millisecond
+
+// ErrQueueFull is returned when a job cannot be enqueued in time.
+var ErrQueueFull = errors.New("queue full: request rejected")
+
+// ErrShuttingDown is returned to requests that arrive once Shutdown has
+// started, and to callers whose job was still queued when Shutdown gave up
+// waiting for the queue to drain.
+var ErrShuttingDown = errors.New("worker pool shutting down")
+
+// ErrJobDropped is returned to a caller whose queued job was evicted to make
+// room for a newer one under the DropOldest queue policy.
+var ErrJobDropped = errors.New("queued job dropped for a newer request")
+
+// QueuePolicy decides what happens to a job that finds the queue full.
+type QueuePolicy int
+
+const (
+	// RejectNewest turns the new job away with ErrQueueFull once
+	// EnqueueTimeout passes without space freeing up. This is the default.
+	RejectNewest QueuePolicy = iota
+
+	// DropOldest makes room at once by evicting the job that has been
+	// queued longest, whose caller gets ErrJobDropped. It suits traffic
+	// where a fresh request is worth more than a stale one, such as
+	// dashboards polling the same patients.
+	DropOldest
+)
+
+// Scheduling decides which queued job a free worker takes next.
+type Scheduling int
+
+const (
+	// FIFO serves jobs in arrival order (or round-robin across patient
+	// IDs with Fairness). This is the default.
+	FIFO Scheduling = iota
+
+	// EDF serves the job whose caller's deadline is nearest first, so a
+	// job with little slack isn't stuck behind jobs with plenty. Jobs
+	// without a deadline come last.
+	EDF
+)
 
 // WorkerPoolConfig holds configuration for the worker pool.
 type WorkerPoolConfig struct {
 	Workers   int // Number of worker goroutines
 	QueueSize int // Size of the job queue buffer
+
+	// EnqueueTimeout bounds how long HandleRequest waits for queue space.
+	// This is an admission timeout only: the overall request deadline comes
+	// from the caller's context, and a caller with less time left than this
+	// waits correspondingly less (see enqueueBudget). Zero means
+	// DefaultEnqueueTimeout.
+	EnqueueTimeout time.Duration
+
+	// LatencySLO is the target P95 processing latency. When recent jobs
+	// are slower, a growing fraction of requests is rejected with 503
+	// before it reaches the queue (see admissionController). Zero
+	// disables latency-based shedding.
+	LatencySLO time.Duration
+
+	// OverflowSize is the capacity of a second-tier queue that takes jobs
+	// when the main queue is full, to absorb bursts. Workers only take
+	// from it while the main queue is empty. Zero disables the overflow
+	// queue.
+	OverflowSize int
+
+	// OverflowTimeout is the grace period a job may wait in the overflow
+	// queue for a worker before it is rejected with ErrQueueFull. Zero
+	// means DefaultOverflowTimeout.
+	OverflowTimeout time.Duration
+
+	// Fairness serves queued jobs round-robin across patient IDs instead
+	// of first come, first served, so a flood of requests for one patient
+	// doesn't hold up everyone else (see fairQueue). Jobs for the same ID
+	// still run in arrival order. The overflow queue stays FIFO.
+	Fairness bool
+
+	// StuckThreshold is how long a worker may spend on a single job before
+	// WorkerHealth reports it as stuck. Zero means DefaultStuckThreshold.
+	StuckThreshold time.Duration
+
+	// QueuePolicy chooses between rejecting new jobs and evicting old ones
+	// when the queue is full. Under DropOldest the main queue always takes
+	// a new job, so the overflow queue is never used; with Fairness or
+	// EDF, the job evicted is the one that would have been served next.
+	QueuePolicy QueuePolicy
+
+	// Scheduling chooses the order queued jobs are served in: FIFO, or
+	// EDF to serve the nearest deadline first (see deadlineQueue). EDF
+	// replaces Fairness when both are set. The overflow queue stays FIFO.
+	Scheduling Scheduling
+
+	// RejectStatus is the HTTP status for requests turned away because
+	// the queue is full or the load is being shed: 503 Service
+	// Unavailable when zero, or 429 Too Many Requests. Both carry
+	// Retry-After.
+	RejectStatus int
+
+	// LoadHeaders adds X-Queue-Depth, X-Active-Workers and
+	// X-Queue-Capacity to every HTTP response, so clients and load
+	// balancers can see how busy the pool is when deciding whether to
+	// retry. Off by default, as it exposes internals.
+	LoadHeaders bool
+}
+
+// DefaultOverflowTimeout is how long a job may wait in the overflow queue
+// when no OverflowTimeout is configured.
+const DefaultOverflowTimeout = time.Second
+
+// overflowTimeoutOrDefault returns the configured overflow grace period,
+// falling back to DefaultOverflowTimeout when unset.
+func (c WorkerPoolConfig) overflowTimeoutOrDefault() time.Duration {
+	if c.OverflowTimeout <= 0 {
+		return DefaultOverflowTimeout
+	}
+	return c.OverflowTimeout
+}
+
+// enqueueTimeoutOrDefault returns the configured enqueue timeout,
+// falling back to DefaultEnqueueTimeout when unset.
+func (c WorkerPoolConfig) enqueueTimeoutOrDefault() time.Duration {
+	if c.EnqueueTimeout <= 0 {
+		return DefaultEnqueueTimeout
+	}
+	return c.EnqueueTimeout
 }
 
 // DefaultWorkerPoolConfig returns sensible defaults for a worker pool.
@@ -92,73 +200,78 @@ type WorkerPoolConfig struct {
 // - Too small: requests rejected during spikes
 // - Too large: memory usage, slow degradation visible
 // - Rule of thumb: 2-5x worker count
+//
+// IOBoundConfig and CPUBoundConfig apply these rules to the machine at hand.
 func DefaultWorkerPoolConfig() WorkerPoolConfig {
 	return WorkerPoolConfig{
-		Workers:   20,
-		QueueSize: 100,
+		Workers:        20,
+		QueueSize:      100,
+		EnqueueTimeout: DefaultEnqueueTimeout,
 	}
 }
 
-// NewWorkerPoolHandler creates a new worker pool handler and starts the workers.
-func NewWorkerPoolHandler(db *simulator.Database, config WorkerPoolConfig) *WorkerPoolHandler {
-	ctx, cancel := context.WithCancel(context.Background())
-
-	h := &WorkerPoolHandler{
-		db:        db,
-		workers:   config.Workers,
-		queueSize: config.QueueSize,
-		jobQueue:  make(chan *job, config.QueueSize),
-		ctx:       ctx,
-		cancel:    cancel,
-	}
+// Workload shapes accepted by WorkloadConfig.
+const (
+	WorkloadIO  = "io"
+	WorkloadCPU = "cpu"
+)
 
-	// Start worker goroutines
-	// These run continuously, waiting for jobs from the queue
-	h.startWorkers()
+const (
+	// ioWorkersPerCPU is the top of the 2-4 range: a worker waiting on the
+	// database uses no CPU, so more of them keep the cores busy
+	ioWorkersPerCPU = 4
 
-	return h
-}
+	// queuePerWorker is the top of the 2-5 range, matching the default
+	// 20 workers and 100 queue slots
+	queuePerWorker = 5
+)
 
-// startWorkers spawns the fixed number of worker goroutines.
-func (h *WorkerPoolHandler) startWorkers() {
-	for i := 0; i < h.workers; i++ {
-		h.wg.Add(1)
-		go h.worker(i)
-	}
+// IOBoundConfig returns a worker pool config sized for work that mostly
+// waits, like database queries, on numCPU cores: four workers per core.
+// numCPU below 1 counts as 1.
+func IOBoundConfig(numCPU int) WorkerPoolConfig {
+	return sizedConfig(max(numCPU, 1) * ioWorkersPerCPU)
 }
 
-// worker is the main loop for each worker goroutine.
-// It pulls jobs from the queue and processes them.
-func (h *WorkerPoolHandler) worker(id int) {
-	defer h.wg.Done()
+// CPUBoundConfig returns a worker pool config sized for work that keeps a
+// core busy throughout, on numCPU cores: one worker per core, since more
+// would only take turns. numCPU below 1 counts as 1.
+func CPUBoundConfig(numCPU int) WorkerPoolConfig {
+	return sizedConfig(max(numCPU, 1))
+}
 
-	for {
-		select {
-		case <-h.ctx.Done():
-			// Shutdown signal received
-			return
-
-		case job, ok := <-h.jobQueue:
-			if !ok {
-				// Channel closed, shutdown
-				return
-			}
-
-			// Process the job
-			h.processJob(job)
-		}
+// WorkloadConfig returns IOBoundConfig or CPUBoundConfig for workload,
+// WorkloadIO or WorkloadCPU.
+func WorkloadConfig(workload string, numCPU int) (WorkerPoolConfig, error) {
+	switch workload {
+	case WorkloadIO:
+		return IOBoundConfig(numCPU), nil
+	case WorkloadCPU:
+		return CPUBoundConfig(numCPU), nil
+	default:
+		return WorkerPoolConfig{}, fmt.Errorf("unknown workload %q: must be %s or %s", workload, WorkloadIO, WorkloadCPU)
 	}
 }
 
-// processJob handles a single patient query job.
-func (h *WorkerPoolHandler) processJob(j *job) {
-	atomic.AddInt64(&h.activeJobs, 1)
-	atomic.AddInt64(&h.queuedJobs, -1)
-	defer atomic.AddInt64(&h.activeJobs, -1)
+// sizedConfig returns the default config with the given number of workers
+// and a queue to match.
+func sizedConfig(workers int) WorkerPoolConfig {
+	config := DefaultWorkerPoolConfig()
+	config.Workers = workers
+	config.QueueSize = workers * queuePerWorker
+	return config
+}
 
-	// Query the database
-	patient, err := h.db.QueryPatient(j.ctx, j.patientID)
+// NewWorkerPoolHandler creates a new worker pool handler and starts the workers.
+func NewWorkerPoolHandler(db PatientStore, config WorkerPoolConfig) *WorkerPoolHandler {
+	h := &WorkerPoolHandler{}
+	h.workerQueue = newWorkerQueue(db, config, h.respond)
+	return h
+}
 
+// respond sends the outcome of a job to its caller in a newly allocated
+// response.
+func (h *WorkerPoolHandler) respond(j *job, patient *models.Patient, err error) {
 	if err != nil {
 		select {
 		case j.errChan <- err:
@@ -178,87 +291,106 @@ func (h *WorkerPoolHandler) processJob(j *job) {
 }
 
 // ServeHTTP handles incoming HTTP requests using the worker pool.
+// GET reads a patient; PUT writes the JSON body through the same queue.
 func (h *WorkerPoolHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	patientID := extractPatientID(r)
-	if patientID == "" {
-		http.Error(w, "patient ID required", http.StatusBadRequest)
+	h.serveHTTP(w, r, "WorkerPool.ServeHTTP", nil)
+}
+
+// HandleRequest is the non-HTTP interface for benchmarking.
+func (h *WorkerPoolHandler) HandleRequest(ctx context.Context, patientID string) (response *models.PatientResponse, err error) {
+	ctx, span := startSpan(ctx, "WorkerPool.HandleRequest")
+	defer func() { endSpan(span, err) }()
+
+	return h.submit(ctx, newJob(ctx, patientID, nil))
+}
+
+// HandleUpdate is the non-HTTP interface for benchmarking writes.
+func (h *WorkerPoolHandler) HandleUpdate(ctx context.Context, patient *models.Patient) (response *models.PatientResponse, err error) {
+	ctx, span := startSpan(ctx, "WorkerPool.HandleUpdate")
+	defer func() { endSpan(span, err) }()
+
+	return h.submit(ctx, newJob(ctx, patient.ID, patient))
+}
+
+// MinEnqueueBudget is the least time a request must have left before its
+// deadline for HandleRequest to queue it. With less, the job could only
+// expire in the queue, so it is rejected at once with
+// context.DeadlineExceeded.
+const MinEnqueueBudget = time.Millisecond
+
+// recordDeadlineMiss adds one to *misses if a request failed with err
+// because ctx's deadline passed. A deadline too close to queue at all
+// counts too, though ctx has not expired yet.
+func recordDeadlineMiss(ctx context.Context, misses *int64, err error) {
+	if err == nil {
 		return
 	}
-
-	// Create a job for this request
-	j := &job{
-		ctx:        r.Context(),
-		patientID:  patientID,
-		resultChan: make(chan *models.PatientResponse, 1),
-		errChan:    make(chan error, 1),
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		atomic.AddInt64(misses, 1)
 	}
+}
 
-	// Try to enqueue the job
-	// This provides backpressure: if queue is full, we reject the request
-	select {
-	case h.jobQueue <- j:
-		atomic.AddInt64(&h.queuedJobs, 1)
-		// Job queued successfully
-	case <-r.Context().Done():
-		http.Error(w, "request cancelled", http.StatusRequestTimeout)
-		return
-	default:
-		// Queue is full - reject the request
-		// In production, you might:
-		// - Return 503 Service Unavailable with Retry-After header
-		// - Implement priority queuing for critical requests
-		// - Add request to overflow queue with longer timeout
-		http.Error(w, "service overloaded, please retry", http.StatusServiceUnavailable)
-		w.Header().Set("Retry-After", "1") // Suggest retry after 1 second
-		return
+// enqueueBudget returns how long a job may wait for queue space: wait, cut
+// down so that the job still has MinEnqueueBudget left of ctx's deadline
+// if it gets in. Waiting until the deadline itself would only queue jobs
+// that time out. ok is false when the deadline is too close to queue at
+// all.
+func enqueueBudget(ctx context.Context, wait time.Duration) (budget time.Duration, ok bool) {
+	deadline, hasDeadline := ctx.Deadline()
+	if !hasDeadline {
+		return wait, true
 	}
 
-	// Wait for the result
-	select {
-	case response := <-j.resultChan:
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-	case err := <-j.errChan:
-		response := models.NewErrorResponse(err, r.Header.Get("X-Request-ID"))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
-	case <-r.Context().Done():
-		http.Error(w, "request timeout", http.StatusRequestTimeout)
+	remaining := time.Until(deadline) - MinEnqueueBudget
+	if remaining <= 0 {
+		return 0, false
 	}
+	if remaining < wait {
+		return remaining, true
+	}
+	return wait, true
 }
 
-// HandleRequest is the non-HTTP interface for benchmarking.
-func (h *WorkerPoolHandler) HandleRequest(ctx context.Context, patientID string) (*models.PatientResponse, error) {
-	// Create a job
-	j := &job{
-		ctx:        ctx,
-		patientID:  patientID,
-		resultChan: make(chan *models.PatientResponse, 1),
-		errChan:    make(chan error, 1),
-	}
+// claimOverflowJob reports whether the caller is first to claim an overflow
+// job: either the worker that takes it from the queue, or its grace timer.
+// errChan is only written once, since only the claimant may write to it.
+func claimOverflowJob(claimed *int32) bool {
+	return atomic.CompareAndSwapInt32(claimed, 0, 1)
+}
 
-	// Try to enqueue with timeout
+// sendJob sends j on queue for the queue-based patterns. It returns
+// ErrShuttingDown once stopping is closed, ctx's error if the caller gives
+// up, or ErrQueueFull if no space frees up within wait.
+func sendJob[J any](ctx context.Context, queue chan<- J, j J, stopping <-chan struct{}, wait time.Duration) error {
 	select {
-	case h.jobQueue <- j:
-		atomic.AddInt64(&h.queuedJobs, 1)
-		// Queued successfully
+	case <-stopping:
+		return ErrShuttingDown
 	case <-ctx.Done():
-		return models.NewErrorResponse(ctx.Err(), ""), ctx.Err()
-	case <-time.After(100 * time.Millisecond):
-		// Queue full timeout
-		err := fmt.Errorf("queue full: request rejected")
-		return models.NewErrorResponse(err, ""), err
+		return ctx.Err()
+	default:
 	}
 
-	// Wait for result
+	if wait <= 0 {
+		select {
+		case queue <- j:
+			return nil
+		default:
+			return ErrQueueFull
+		}
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
 	select {
-	case response := <-j.resultChan:
-		return response, nil
-	case err := <-j.errChan:
-		return models.NewErrorResponse(err, ""), err
+	case queue <- j:
+		return nil
+	case <-stopping:
+		return ErrShuttingDown
 	case <-ctx.Done():
-		return models.NewErrorResponse(ctx.Err(), ""), ctx.Err()
+		return ctx.Err()
+	case <-timer.C:
+		return ErrQueueFull
 	}
 }
 
@@ -267,37 +399,84 @@ func (h *WorkerPoolHandler) GetName() string {
 	return fmt.Sprintf("Worker Pool (%d workers)", h.workers)
 }
 
-// GetStats returns current worker pool statistics.
-func (h *WorkerPoolHandler) GetStats() (activeJobs, queuedJobs int64, queueCapacity int) {
-	return atomic.LoadInt64(&h.activeJobs),
-		atomic.LoadInt64(&h.queuedJobs),
-		h.queueSize
+// PoolStats describes a worker pool's state; see WorkerPoolHandler.GetStats.
+type PoolStats struct {
+	ActiveJobs int64
+	QueuedJobs int64
+
+	// OverflowJobs counts jobs in the overflow queue, which are not
+	// included in QueuedJobs or QueueCapacity.
+	OverflowJobs int64
+
+	// ExpiredJobs counts queued jobs that were dropped because their
+	// caller's deadline had already passed by the time a worker picked
+	// them up.
+	ExpiredJobs int64
+
+	// DroppedJobs counts queued jobs evicted for newer ones under
+	// DropOldest.
+	DroppedJobs int64
+
+	QueueCapacity int
+
+	// ShedFraction is the share of requests currently rejected because
+	// recent latency is above LatencySLO.
+	ShedFraction float64
+
+	// KeyDepths maps each patient ID with queued jobs to how many it has;
+	// it is nil unless Fairness is set.
+	KeyDepths map[string]int
+
+	// PeakActiveJobs and PeakQueuedJobs are the most jobs seen running
+	// and queued at once since the pool started, so transient saturation
+	// between two polls still shows up.
+	PeakActiveJobs int64
+	PeakQueuedJobs int64
+
+	// DeadlineMisses counts requests that failed because the caller's
+	// deadline passed first, whether they were turned away, expired in
+	// the queue or ran out of time mid-query.
+	DeadlineMisses int64
+}
+
+// queueUtilizationPct returns queued as a percentage of capacity, clamped
+// to [0, 100]. The queued count is updated just after each send and
+// receive, so a job a worker has taken but not yet uncounted can briefly
+// push it past capacity.
+func queueUtilizationPct(queued int64, capacity int) float64 {
+	if capacity <= 0 || queued <= 0 {
+		return 0
+	}
+	return min(float64(queued)/float64(capacity)*100, 100)
 }
 
 // Shutdown gracefully shuts down the worker pool.
 // This is critical for healthcare systems to ensure:
 // - In-flight patient queries complete
+// - Queued patient queries complete too, since their callers are waiting
 // - No data loss or corruption
 // - Proper resource cleanup
 // - Audit log completion
+//
+// New jobs are refused at once with ErrShuttingDown; the workers then
+// drain the queue and exit. If ctx ends first, every job still queued fails
+// with ErrShuttingDown rather than leaving its caller waiting. Shutdown may
+// be called more than once, including concurrently.
 func (h *WorkerPoolHandler) Shutdown(ctx context.Context) error {
-	// Stop accepting new jobs
-	close(h.jobQueue)
-
-	// Signal workers to stop after completing current jobs
-	h.cancel()
+	return h.shutdown(ctx)
+}
 
-	// Wait for workers to finish with timeout
-	workersDone := make(chan struct{})
-	go func() {
-		h.wg.Wait()
-		close(workersDone)
-	}()
+// setLoadHeaders reports a pool's load on a response, as of when the
+// request arrived; see WorkerPoolConfig.LoadHeaders.
+func setLoadHeaders(w http.ResponseWriter, queueDepth, activeWorkers int64, queueCapacity int) {
+	w.Header().Set("X-Queue-Depth", strconv.FormatInt(queueDepth, 10))
+	w.Header().Set("X-Active-Workers", strconv.FormatInt(activeWorkers, 10))
+	w.Header().Set("X-Queue-Capacity", strconv.Itoa(queueCapacity))
+}
 
-	select {
-	case <-workersDone:
-		return nil
-	case <-ctx.Done():
-		return fmt.Errorf("shutdown timeout: workers still processing")
-	}
+// abandonJob fails a job that was still queued when Shutdown timed out.
+// errChan is buffered and only ever written once, so this never blocks.
+func abandonJob(queueSpan trace.Span, errChan chan<- error) {
+	endSpan(queueSpan, ErrShuttingDown)
+	errChan <- ErrShuttingDown
 }
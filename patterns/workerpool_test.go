@@ -0,0 +1,1147 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// poolHandler is the subset of behaviour shared by the queue-based patterns.
+type poolHandler interface {
+	HandleRequest(ctx context.Context, patientID string) (*models.PatientResponse, error)
+	GetStats() PoolStats
+	QueueUtilizationPct() float64
+	WorkerHealth() WorkerHealth
+	Shutdown(ctx context.Context) error
+}
+
+// poolConstructors lists every queue-based pattern so each test covers them all.
+var poolConstructors = []struct {
+	name string
+	new  func(db *simulator.Database, config WorkerPoolConfig) poolHandler
+}{
+	{"WorkerPool", func(db *simulator.Database, config WorkerPoolConfig) poolHandler {
+		return NewWorkerPoolHandler(db, config)
+	}},
+	{"Optimized", func(db *simulator.Database, config WorkerPoolConfig) poolHandler {
+		return NewOptimizedHandler(db, config)
+	}},
+}
+
+// newFixedLatencyDatabase returns an error-free database where every query
+// takes exactly the given latency.
+func newFixedLatencyDatabase(latency time.Duration) *simulator.Database {
+	db := simulator.NewDatabase(simulator.MinQueryLatency, simulator.MaxQueryLatency, 0)
+	db.SetLatencySource(simulator.NewTraceLatencySource([]time.Duration{latency}))
+	return db
+}
+
+// shutdowner is implemented by every pattern handler.
+type shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// shutdownHandler shuts a handler down, failing the test on timeout.
+func shutdownHandler(t *testing.T, h shutdowner) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+}
+
+// TestExpiredJobsAreSkipped saturates a single-worker pool, queues jobs whose
+// deadlines pass while they wait, and checks the database only sees the
+// job that was still wanted.
+func TestExpiredJobsAreSkipped(t *testing.T) {
+	const expiring = 5
+
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFixedLatencyDatabase(100 * time.Millisecond)
+			h := tc.new(db, WorkerPoolConfig{Workers: 1, QueueSize: 10})
+			defer shutdownHandler(t, h)
+
+			// Occupy the only worker.
+			var blocker sync.WaitGroup
+			blocker.Add(1)
+			go func() {
+				defer blocker.Done()
+				if _, err := h.HandleRequest(context.Background(), "P00001"); err != nil {
+					t.Errorf("blocking request: unexpected error: %v", err)
+				}
+			}()
+			time.Sleep(10 * time.Millisecond)
+
+			// Queue requests that will expire long before the worker is free.
+			var wg sync.WaitGroup
+			for i := 0; i < expiring; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+					defer cancel()
+					if _, err := h.HandleRequest(ctx, "P00002"); err == nil {
+						t.Errorf("expiring request: expected an error")
+					}
+				}()
+			}
+			wg.Wait()
+			blocker.Wait()
+
+			deadline := time.Now().Add(2 * time.Second)
+			for {
+				stats := h.GetStats()
+				if stats.QueuedJobs == 0 && stats.ExpiredJobs == expiring {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("expired = %d, queued = %d; want %d expired, 0 queued", stats.ExpiredJobs, stats.QueuedJobs, expiring)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			queries, errors := db.GetStats()
+			if queries != 1 || errors != 0 {
+				t.Errorf("database saw %d queries, %d errors; want 1 query, 0 errors", queries, errors)
+			}
+		})
+	}
+}
+
+// runBurst fires n concurrent requests at h and returns how many were
+// rejected and the slowest successful latency.
+func runBurst(h poolHandler, n int) (rejected int, slowest time.Duration) {
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			_, err := h.HandleRequest(context.Background(), "P00001")
+			latency := time.Since(start)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				rejected++
+				return
+			}
+			if latency > slowest {
+				slowest = latency
+			}
+		}()
+	}
+
+	wg.Wait()
+	return rejected, slowest
+}
+
+// TestEnqueueTimeout checks that a short admission timeout turns overload into
+// rejections while a long one turns it into queueing delay.
+func TestEnqueueTimeout(t *testing.T) {
+	const (
+		latency = 50 * time.Millisecond
+		burst   = 6
+	)
+
+	for _, tc := range poolConstructors {
+		t.Run(tc.name+"/Short", func(t *testing.T) {
+			db := newFixedLatencyDatabase(latency)
+			h := tc.new(db, WorkerPoolConfig{Workers: 1, QueueSize: 1, EnqueueTimeout: time.Millisecond})
+			defer shutdownHandler(t, h)
+
+			rejected, _ := runBurst(h, burst)
+			if rejected == 0 {
+				t.Errorf("expected rejections with a 1ms enqueue timeout, got none")
+			}
+		})
+
+		t.Run(tc.name+"/Long", func(t *testing.T) {
+			db := newFixedLatencyDatabase(latency)
+			h := tc.new(db, WorkerPoolConfig{Workers: 1, QueueSize: 1, EnqueueTimeout: 5 * time.Second})
+			defer shutdownHandler(t, h)
+
+			rejected, slowest := runBurst(h, burst)
+			if rejected != 0 {
+				t.Errorf("expected no rejections with a 5s enqueue timeout, got %d", rejected)
+			}
+			// With one worker, the last request waits behind all the others.
+			if want := time.Duration(burst-1) * latency; slowest < want {
+				t.Errorf("slowest request took %v, want at least %v of queueing delay", slowest, want)
+			}
+		})
+	}
+}
+
+// enqueueJobs starts n requests against a single-worker pool and waits
+// until all but the one in flight are sitting in the queue. The returned
+// channel yields each request's error once it completes.
+func enqueueJobs(t *testing.T, h poolHandler, n int) <-chan error {
+	t.Helper()
+
+	results := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := h.HandleRequest(context.Background(), "P00001")
+			results <- err
+		}()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if queued := h.GetStats().QueuedJobs; queued == int64(n-1) {
+			return results
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs never filled the queue")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// collectResults waits for n results, failing the test if any caller is
+// left hanging.
+func collectResults(t *testing.T, results <-chan error, n int) []error {
+	t.Helper()
+
+	errs := make([]error, 0, n)
+	timeout := time.After(5 * time.Second)
+	for len(errs) < n {
+		select {
+		case err := <-results:
+			errs = append(errs, err)
+		case <-timeout:
+			t.Fatalf("only %d of %d callers got a result", len(errs), n)
+		}
+	}
+	return errs
+}
+
+// TestShutdownDrainsQueuedJobs checks that Shutdown lets queued jobs run
+// rather than abandoning callers who are already waiting.
+func TestShutdownDrainsQueuedJobs(t *testing.T) {
+	const jobs = 6
+
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFixedLatencyDatabase(20 * time.Millisecond)
+			h := tc.new(db, WorkerPoolConfig{Workers: 1, QueueSize: jobs, EnqueueTimeout: time.Second})
+			results := enqueueJobs(t, h, jobs)
+
+			shutdownHandler(t, h)
+
+			for i, err := range collectResults(t, results, jobs) {
+				if err != nil {
+					t.Errorf("job %d: %v, want every queued job served", i, err)
+				}
+			}
+			if queries, _ := db.GetStats(); queries != jobs {
+				t.Errorf("database saw %d queries, want %d", queries, jobs)
+			}
+		})
+	}
+}
+
+// TestShutdownTimeoutFailsQueuedJobs checks that when the queue cannot be
+// drained in time, every job left in it fails with ErrShuttingDown.
+func TestShutdownTimeoutFailsQueuedJobs(t *testing.T) {
+	const jobs = 6
+
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFixedLatencyDatabase(50 * time.Millisecond)
+			h := tc.new(db, WorkerPoolConfig{Workers: 1, QueueSize: jobs, EnqueueTimeout: time.Second})
+			results := enqueueJobs(t, h, jobs)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 75*time.Millisecond)
+			defer cancel()
+			if err := h.Shutdown(ctx); err == nil {
+				t.Fatalf("Shutdown returned nil, want a timeout")
+			}
+
+			var served, abandoned int
+			for _, err := range collectResults(t, results, jobs) {
+				switch {
+				case err == nil:
+					served++
+				case errors.Is(err, ErrShuttingDown):
+					abandoned++
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+			if served == 0 || abandoned == 0 {
+				t.Errorf("served %d, abandoned %d; want some of each", served, abandoned)
+			}
+			if served+abandoned != jobs {
+				t.Errorf("served %d + abandoned %d, want %d", served, abandoned, jobs)
+			}
+		})
+	}
+}
+
+// TestShutdownTwiceDuringTraffic calls Shutdown twice concurrently while
+// requests are still arriving. Neither call may panic, and every request
+// must either be served or be refused cleanly.
+func TestShutdownTwiceDuringTraffic(t *testing.T) {
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFixedLatencyDatabase(5 * time.Millisecond)
+			h := tc.new(db, WorkerPoolConfig{Workers: 2, QueueSize: 4, EnqueueTimeout: 10 * time.Millisecond})
+			server := h.(http.Handler)
+
+			stop := make(chan struct{})
+			var traffic sync.WaitGroup
+			for c := 0; c < 8; c++ {
+				traffic.Add(2)
+				go func() {
+					defer traffic.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						_, err := h.HandleRequest(context.Background(), "P00001")
+						if err != nil && !errors.Is(err, ErrShuttingDown) && !errors.Is(err, ErrQueueFull) {
+							t.Errorf("HandleRequest: unexpected error: %v", err)
+						}
+					}
+				}()
+				go func() {
+					defer traffic.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						rec := httptest.NewRecorder()
+						server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil))
+						if rec.Code != http.StatusOK && rec.Code != http.StatusServiceUnavailable {
+							t.Errorf("ServeHTTP: status %d: %s", rec.Code, rec.Body.String())
+						}
+					}
+				}()
+			}
+			time.Sleep(20 * time.Millisecond)
+
+			var shutdowns sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				shutdowns.Add(1)
+				go func() {
+					defer shutdowns.Done()
+					shutdownHandler(t, h)
+				}()
+			}
+			shutdowns.Wait()
+
+			// Requests after shutdown are refused rather than panicking
+			if _, err := h.HandleRequest(context.Background(), "P00001"); !errors.Is(err, ErrShuttingDown) {
+				t.Errorf("HandleRequest after Shutdown: err = %v, want ErrShuttingDown", err)
+			}
+			shutdownHandler(t, h)
+
+			close(stop)
+			traffic.Wait()
+		})
+	}
+}
+
+// cancellingHandler is a queue-based pattern that also serves HTTP.
+type cancellingHandler interface {
+	poolHandler
+	http.Handler
+	GetCancelledJobs() int64
+}
+
+// TestCancelledRequestSkipsQuery disconnects a client right after its job
+// is queued and checks the job never reaches the database.
+func TestCancelledRequestSkipsQuery(t *testing.T) {
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFixedLatencyDatabase(50 * time.Millisecond)
+			h := tc.new(db, WorkerPoolConfig{Workers: 1, QueueSize: 10}).(cancellingHandler)
+			defer shutdownHandler(t, h)
+
+			// Occupy the only worker.
+			blocked := make(chan error, 1)
+			go func() {
+				_, err := h.HandleRequest(context.Background(), "P00001")
+				blocked <- err
+			}()
+			time.Sleep(10 * time.Millisecond)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			served := make(chan struct{})
+			go func() {
+				defer close(served)
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00002", nil).WithContext(ctx)
+				h.ServeHTTP(httptest.NewRecorder(), req)
+			}()
+
+			waitFor(t, func() bool { return h.GetStats().QueuedJobs == 1 })
+			cancel()
+			<-served
+
+			if err := <-blocked; err != nil {
+				t.Fatalf("blocking request: %v", err)
+			}
+			waitFor(t, func() bool { return h.GetCancelledJobs() == 1 })
+
+			if queries, _ := db.GetStats(); queries != 1 {
+				t.Errorf("database saw %d queries, want only the blocking one", queries)
+			}
+			if expired := h.GetStats().ExpiredJobs; expired != 0 {
+				t.Errorf("expired = %d, want a cancelled job counted as cancelled", expired)
+			}
+		})
+	}
+}
+
+// TestCancelledRequestStopsMidQuery disconnects a client while its query
+// is running and checks the query is abandoned rather than completed.
+func TestCancelledRequestStopsMidQuery(t *testing.T) {
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFixedLatencyDatabase(time.Second)
+			h := tc.new(db, WorkerPoolConfig{Workers: 1, QueueSize: 10}).(cancellingHandler)
+			defer shutdownHandler(t, h)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(20*time.Millisecond, cancel)
+
+			start := time.Now()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil).WithContext(ctx)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			// The worker is freed as soon as the client goes away
+			waitFor(t, func() bool { return h.GetCancelledJobs() == 1 })
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("cancelled job held the worker for %v", elapsed)
+			}
+			if queries, _ := db.GetStats(); queries != 0 {
+				t.Errorf("database completed %d queries, want the cancelled one abandoned", queries)
+			}
+		})
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after two seconds.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met within 2s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestOverflowQueueAbsorbsBursts checks that a burst the single queue would
+// reject is held in the overflow queue and served within its grace period.
+func TestOverflowQueueAbsorbsBursts(t *testing.T) {
+	const (
+		latency = 20 * time.Millisecond
+		burst   = 6
+	)
+
+	for _, tc := range poolConstructors {
+		t.Run(tc.name+"/SingleQueue", func(t *testing.T) {
+			h := tc.new(newFixedLatencyDatabase(latency), WorkerPoolConfig{
+				Workers: 1, QueueSize: 1, EnqueueTimeout: time.Millisecond,
+			})
+			defer shutdownHandler(t, h)
+
+			if rejected, _ := runBurst(h, burst); rejected == 0 {
+				t.Errorf("expected the single queue to reject part of the burst")
+			}
+		})
+
+		t.Run(tc.name+"/Overflow", func(t *testing.T) {
+			h := tc.new(newFixedLatencyDatabase(latency), WorkerPoolConfig{
+				Workers: 1, QueueSize: 1, EnqueueTimeout: time.Millisecond,
+				OverflowSize: burst, OverflowTimeout: 5 * time.Second,
+			})
+			defer shutdownHandler(t, h)
+
+			if rejected, _ := runBurst(h, burst); rejected != 0 {
+				t.Errorf("%d requests rejected, want the overflow queue to hold them all", rejected)
+			}
+			if stats := h.GetStats(); stats.QueuedJobs != 0 || stats.OverflowJobs != 0 {
+				t.Errorf("queued = %d, overflow = %d after the burst, want both 0", stats.QueuedJobs, stats.OverflowJobs)
+			}
+		})
+	}
+}
+
+// startRequest sends one request to h in the background. The returned
+// channel yields its error once it completes.
+func startRequest(h poolHandler, patientID string) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		_, err := h.HandleRequest(context.Background(), patientID)
+		result <- err
+	}()
+	return result
+}
+
+// fillMainQueue puts one job in flight and one in the main queue of a
+// single-worker pool, one at a time so neither spills into the overflow
+// queue.
+func fillMainQueue(t *testing.T, h poolHandler) (inFlight, queued <-chan error) {
+	t.Helper()
+
+	inFlight = startRequest(h, "P00001")
+	waitFor(t, func() bool { return h.GetStats().ActiveJobs == 1 })
+	queued = startRequest(h, "P00001")
+	waitFor(t, func() bool { return h.GetStats().QueuedJobs == 1 })
+	return inFlight, queued
+}
+
+// TestOverflowGracePeriodExpires checks that a job left in the overflow
+// queue past its grace period is rejected with ErrQueueFull rather than
+// waiting indefinitely.
+func TestOverflowGracePeriodExpires(t *testing.T) {
+	const grace = 20 * time.Millisecond
+
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFixedLatencyDatabase(500 * time.Millisecond)
+			h := tc.new(db, WorkerPoolConfig{
+				Workers: 1, QueueSize: 1, EnqueueTimeout: time.Millisecond,
+				OverflowSize: 1, OverflowTimeout: grace,
+			})
+			defer shutdownHandler(t, h)
+
+			inFlight, queued := fillMainQueue(t, h)
+
+			start := time.Now()
+			_, err := h.HandleRequest(context.Background(), "P00002")
+			if !errors.Is(err, ErrQueueFull) {
+				t.Fatalf("err = %v, want ErrQueueFull", err)
+			}
+			if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+				t.Errorf("rejection took %v, want about the %v grace period", elapsed, grace)
+			}
+
+			for _, result := range []<-chan error{inFlight, queued} {
+				if err := <-result; err != nil {
+					t.Errorf("queued job failed: %v", err)
+				}
+			}
+			if queries, _ := db.GetStats(); queries != 2 {
+				t.Errorf("database saw %d queries, want 2: the expired overflow job must be skipped", queries)
+			}
+		})
+	}
+}
+
+// TestOverflowDrainsAfterMainQueue checks that overflow jobs are counted in
+// GetStats and only run while the main queue is empty.
+func TestOverflowDrainsAfterMainQueue(t *testing.T) {
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			h := tc.new(newFixedLatencyDatabase(100*time.Millisecond), WorkerPoolConfig{
+				Workers: 1, QueueSize: 1, EnqueueTimeout: time.Millisecond,
+				OverflowSize: 2, OverflowTimeout: 5 * time.Second,
+			})
+			defer shutdownHandler(t, h)
+
+			fillMainQueue(t, h)
+
+			overflowDone := make(chan time.Time, 1)
+			go func() {
+				h.HandleRequest(context.Background(), "P00002")
+				overflowDone <- time.Now()
+			}()
+			waitFor(t, func() bool { return h.GetStats().OverflowJobs == 1 })
+
+			// Once the worker takes the queued job, a new job goes to the
+			// main queue, and should run ahead of the one in overflow
+			waitFor(t, func() bool { return h.GetStats().QueuedJobs == 0 })
+			mainDone := make(chan time.Time, 1)
+			go func() {
+				h.HandleRequest(context.Background(), "P00003")
+				mainDone <- time.Now()
+			}()
+
+			if overflowAt, mainAt := <-overflowDone, <-mainDone; overflowAt.Before(mainAt) {
+				t.Errorf("overflow job finished before a later main-queue job")
+			}
+		})
+	}
+}
+
+// TestOverflowOverHTTP checks that ServeHTTP, which never waits for queue
+// space, serves a burst from the overflow queue instead of returning 503.
+func TestOverflowOverHTTP(t *testing.T) {
+	const burst = 6
+
+	h := NewWorkerPoolHandler(newFixedLatencyDatabase(10*time.Millisecond), WorkerPoolConfig{
+		Workers: 1, QueueSize: 1, OverflowSize: burst, OverflowTimeout: 5 * time.Second,
+	})
+	defer shutdownHandler(t, h)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, burst)
+	for i := 0; i < burst; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil))
+			codes <- rec.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("status = %d, want 200", code)
+		}
+	}
+}
+
+// TestFairnessBoundsQuietIDLatency floods a single-worker pool with
+// requests for one patient and checks that, with Fairness, a request for a
+// second patient runs next rather than waiting behind the whole flood.
+func TestFairnessBoundsQuietIDLatency(t *testing.T) {
+	const (
+		latency = 10 * time.Millisecond
+		flood   = 30
+	)
+
+	for _, tc := range poolConstructors {
+		for _, fair := range []bool{false, true} {
+			name := tc.name + "/FIFO"
+			if fair {
+				name = tc.name + "/Fair"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				h := tc.new(newFixedLatencyDatabase(latency), WorkerPoolConfig{
+					Workers: 1, QueueSize: flood + 1, EnqueueTimeout: time.Second, Fairness: fair,
+				})
+				defer shutdownHandler(t, h)
+
+				for i := 0; i < flood; i++ {
+					startRequest(h, "P00001")
+				}
+				waitFor(t, func() bool { return h.GetStats().QueuedJobs >= flood-2 })
+
+				start := time.Now()
+				if _, err := h.HandleRequest(context.Background(), "P00002"); err != nil {
+					t.Fatalf("quiet ID failed: %v", err)
+				}
+				elapsed := time.Since(start)
+
+				// Fair: at most the job in flight and one flood job run first
+				bound := 5 * latency
+				if fair && elapsed > bound {
+					t.Errorf("quiet ID waited %v behind the flood, want under %v", elapsed, bound)
+				}
+				if !fair && elapsed < bound {
+					t.Errorf("quiet ID took %v without fairness, want it queued behind the flood", elapsed)
+				}
+			})
+		}
+	}
+}
+
+// TestFairnessReportsKeyDepths checks GetStats reports queued jobs per
+// patient ID with Fairness, and nothing without it.
+func TestFairnessReportsKeyDepths(t *testing.T) {
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			h := tc.new(newFixedLatencyDatabase(200*time.Millisecond), WorkerPoolConfig{
+				Workers: 1, QueueSize: 10, Fairness: true,
+			})
+			defer shutdownHandler(t, h)
+
+			startRequest(h, "P00009")
+			waitFor(t, func() bool { return h.GetStats().ActiveJobs == 1 })
+			for _, id := range []string{"P00001", "P00001", "P00001", "P00002"} {
+				startRequest(h, id)
+			}
+			waitFor(t, func() bool { return h.GetStats().QueuedJobs == 4 })
+
+			depths := h.GetStats().KeyDepths
+			if depths["P00001"] != 3 || depths["P00002"] != 1 || len(depths) != 2 {
+				t.Errorf("key depths = %v, want map[P00001:3 P00002:1]", depths)
+			}
+
+			unfair := tc.new(newFastDatabase(), WorkerPoolConfig{Workers: 1, QueueSize: 10})
+			defer shutdownHandler(t, unfair)
+			if depths := unfair.GetStats().KeyDepths; depths != nil {
+				t.Errorf("key depths = %v without Fairness, want nil", depths)
+			}
+		})
+	}
+}
+
+// TestFairnessDrainsOnShutdown checks that Shutdown still runs every job
+// queued in the fair queue.
+func TestFairnessDrainsOnShutdown(t *testing.T) {
+	const jobs = 6
+
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFixedLatencyDatabase(10 * time.Millisecond)
+			h := tc.new(db, WorkerPoolConfig{Workers: 1, QueueSize: jobs, Fairness: true})
+
+			results := make([]<-chan error, jobs)
+			for i := range results {
+				results[i] = startRequest(h, fmt.Sprintf("P%05d", i%2))
+			}
+			waitFor(t, func() bool { return h.GetStats().QueuedJobs >= jobs-1 })
+
+			shutdownHandler(t, h)
+			for _, result := range results {
+				if err := <-result; err != nil {
+					t.Errorf("queued job failed: %v", err)
+				}
+			}
+			if queries, _ := db.GetStats(); queries != jobs {
+				t.Errorf("database saw %d queries, want %d", queries, jobs)
+			}
+		})
+	}
+}
+
+// TestWorkerHealthDetectsStuckWorker checks that a worker held up by a slow
+// query is reported as stuck once it passes StuckThreshold, and recovers
+// when the query finishes.
+func TestWorkerHealthDetectsStuckWorker(t *testing.T) {
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFixedLatencyDatabase(300 * time.Millisecond)
+			h := tc.new(db, WorkerPoolConfig{Workers: 2, QueueSize: 10, StuckThreshold: 50 * time.Millisecond})
+			defer shutdownHandler(t, h)
+
+			health := h.WorkerHealth()
+			if len(health.Workers) != 2 || health.Stuck != 0 {
+				t.Fatalf("idle pool: got %d workers, %d stuck; want 2, 0", len(health.Workers), health.Stuck)
+			}
+			for _, worker := range health.Workers {
+				if worker.Busy || !worker.LastActivity.IsZero() {
+					t.Errorf("worker %d busy or active before any job: %+v", worker.ID, worker)
+				}
+			}
+
+			before := time.Now()
+			result := startRequest(h, "P00001")
+			waitFor(t, func() bool { return h.WorkerHealth().Stuck == 1 })
+
+			health = h.WorkerHealth()
+			var stuck *WorkerStatus
+			for i := range health.Workers {
+				if health.Workers[i].Stuck {
+					stuck = &health.Workers[i]
+				}
+			}
+			if !stuck.Busy || stuck.BusyMs < 50 {
+				t.Errorf("stuck worker: got busy=%v for %.1fms, want busy for over 50ms", stuck.Busy, stuck.BusyMs)
+			}
+			if stuck.LastActivity.Before(before) {
+				t.Errorf("stuck worker: last activity %v predates the request at %v", stuck.LastActivity, before)
+			}
+
+			if err := <-result; err != nil {
+				t.Fatalf("slow request: unexpected error: %v", err)
+			}
+			waitFor(t, func() bool { return h.WorkerHealth().Stuck == 0 })
+			if worker := h.WorkerHealth().Workers[stuck.ID]; worker.Busy || worker.LastActivity.IsZero() {
+				t.Errorf("after the job: got %+v, want idle with a last activity", worker)
+			}
+		})
+	}
+}
+
+// TestEnqueueWaitBoundedByDeadline checks that a request with little time
+// left waits for queue space only as long as its deadline allows, and that
+// one with almost none is rejected without being queued.
+func TestEnqueueWaitBoundedByDeadline(t *testing.T) {
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFixedLatencyDatabase(300 * time.Millisecond)
+			h := tc.new(db, WorkerPoolConfig{Workers: 1, QueueSize: 1, EnqueueTimeout: 100 * time.Millisecond})
+			defer shutdownHandler(t, h)
+
+			inFlight, queued := fillMainQueue(t, h)
+
+			// The queue is full: give up on it before the deadline, rather
+			// than waiting the full EnqueueTimeout
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err := h.HandleRequest(ctx, "P00002")
+			if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+				t.Errorf("10ms deadline: rejected after %v, want well under the 100ms enqueue timeout", elapsed)
+			}
+			if !errors.Is(err, ErrQueueFull) {
+				t.Errorf("10ms deadline: got %v, want ErrQueueFull", err)
+			}
+
+			// Too little budget left to queue at all
+			ctx, cancel = context.WithTimeout(context.Background(), MinEnqueueBudget/2)
+			defer cancel()
+			if _, err := h.HandleRequest(ctx, "P00002"); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expiring deadline: got %v, want context.DeadlineExceeded", err)
+			}
+
+			<-inFlight
+			<-queued
+			if queries, _ := db.GetStats(); queries != 2 {
+				t.Errorf("database saw %d queries, want only the 2 that filled the queue", queries)
+			}
+		})
+	}
+}
+
+// TestDropOldestEvictsEarliestJobs saturates the queue under DropOldest and
+// checks that each new job evicts the one queued longest, whose caller gets
+// ErrJobDropped, while the newest jobs run.
+func TestDropOldestEvictsEarliestJobs(t *testing.T) {
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFixedLatencyDatabase(200 * time.Millisecond)
+			h := tc.new(db, WorkerPoolConfig{Workers: 1, QueueSize: 2, QueuePolicy: DropOldest})
+			defer shutdownHandler(t, h)
+
+			inFlight := startRequest(h, "P00000")
+			waitFor(t, func() bool { return h.GetStats().ActiveJobs == 1 })
+
+			// Queue jobs one at a time so their order is known
+			results := make([]<-chan error, 5)
+			for i := range results {
+				results[i] = startRequest(h, fmt.Sprintf("P%05d", i+1))
+				want := int64(min(i+1, 2))
+				waitFor(t, func() bool {
+					stats := h.GetStats()
+					return stats.QueuedJobs == want && stats.DroppedJobs == int64(max(i-1, 0))
+				})
+			}
+
+			if dropped := h.GetStats().DroppedJobs; dropped != 3 {
+				t.Errorf("dropped = %d, want 3", dropped)
+			}
+			for i, result := range results {
+				err := <-result
+				if i < 3 && !errors.Is(err, ErrJobDropped) {
+					t.Errorf("job %d (among the earliest): got %v, want ErrJobDropped", i+1, err)
+				}
+				if i >= 3 && err != nil {
+					t.Errorf("job %d (among the newest): unexpected error: %v", i+1, err)
+				}
+			}
+			if err := <-inFlight; err != nil {
+				t.Errorf("in-flight job: unexpected error: %v", err)
+			}
+			if queries, _ := db.GetStats(); queries != 3 {
+				t.Errorf("database saw %d queries, want 3", queries)
+			}
+		})
+	}
+}
+
+// TestPeakStatsRecordBurst drives a burst bigger than the pool and checks
+// that the high-water marks match it, and outlast it.
+func TestPeakStatsRecordBurst(t *testing.T) {
+	const workers, queued = 4, 6
+
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newFixedLatencyDatabase(100 * time.Millisecond)
+			h := tc.new(db, WorkerPoolConfig{Workers: workers, QueueSize: 10})
+			defer shutdownHandler(t, h)
+
+			// Fill the workers before queueing the rest, so no job is ever
+			// counted as queued on its way to an idle worker
+			results := make([]<-chan error, workers+queued)
+			for i := range results {
+				if i == workers {
+					waitFor(t, func() bool { return h.GetStats().ActiveJobs == workers })
+				}
+				results[i] = startRequest(h, fmt.Sprintf("P%05d", i))
+			}
+			for _, result := range results {
+				if err := <-result; err != nil {
+					t.Fatalf("burst request: unexpected error: %v", err)
+				}
+			}
+
+			stats := h.GetStats()
+			if stats.ActiveJobs != 0 || stats.QueuedJobs != 0 {
+				t.Errorf("after the burst: %d active, %d queued, want 0, 0", stats.ActiveJobs, stats.QueuedJobs)
+			}
+			if stats.PeakActiveJobs != workers || stats.PeakQueuedJobs != queued {
+				t.Errorf("peaks = %d active, %d queued, want %d, %d", stats.PeakActiveJobs, stats.PeakQueuedJobs, workers, queued)
+			}
+			if got := h.QueueUtilizationPct(); got != 60 {
+				t.Errorf("QueueUtilizationPct = %.1f after queueing %d of 10, want 60", got, queued)
+			}
+		})
+	}
+}
+
+// TestPoolStatsColdPool checks that a response the pool had to allocate
+// counts as a miss and not also as a hit: with nothing released, every
+// request misses.
+func TestPoolStatsColdPool(t *testing.T) {
+	const requests = 20
+
+	h := NewOptimizedHandler(newFixedLatencyDatabase(time.Millisecond), DefaultWorkerPoolConfig())
+	defer shutdownHandler(t, h)
+
+	for i := 0; i < requests; i++ {
+		if _, err := h.HandleRequest(context.Background(), "P00001"); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+	}
+
+	hits, misses, hitRate := h.GetPoolStats()
+	if hits != 0 || misses != requests || hitRate != 0 {
+		t.Errorf("GetPoolStats() = (%d, %d, %.2f), want (0, %d, 0)", hits, misses, hitRate, requests)
+	}
+}
+
+func TestWorkloadConfigSizing(t *testing.T) {
+	tests := []struct {
+		workload  string
+		numCPU    int
+		workers   int
+		queueSize int
+	}{
+		{WorkloadIO, 1, 4, 20},
+		{WorkloadIO, 4, 16, 80},
+		{WorkloadIO, 5, 20, 100}, // The defaults
+		{WorkloadIO, 16, 64, 320},
+		{WorkloadIO, 0, 4, 20}, // At least one core
+		{WorkloadCPU, 1, 1, 5},
+		{WorkloadCPU, 8, 8, 40},
+		{WorkloadCPU, 64, 64, 320},
+		{WorkloadCPU, -2, 1, 5},
+	}
+	for _, tt := range tests {
+		config, err := WorkloadConfig(tt.workload, tt.numCPU)
+		if err != nil {
+			t.Fatalf("WorkloadConfig(%q, %d): %v", tt.workload, tt.numCPU, err)
+		}
+		if config.Workers != tt.workers || config.QueueSize != tt.queueSize {
+			t.Errorf("WorkloadConfig(%q, %d) = %d workers, queue %d; want %d, %d",
+				tt.workload, tt.numCPU, config.Workers, config.QueueSize, tt.workers, tt.queueSize)
+		}
+		if config.EnqueueTimeout != DefaultEnqueueTimeout {
+			t.Errorf("WorkloadConfig(%q, %d) enqueue timeout = %v, want the default", tt.workload, tt.numCPU, config.EnqueueTimeout)
+		}
+	}
+
+	if _, err := WorkloadConfig("gpu", 8); err == nil {
+		t.Error("WorkloadConfig accepted an unknown workload")
+	}
+}
+
+func TestQueueUtilizationPct(t *testing.T) {
+	tests := []struct {
+		queued   int64
+		capacity int
+		want     float64
+	}{
+		{0, 10, 0},
+		{3, 10, 30},
+		{1, 3, 100.0 / 3},
+		{10, 10, 100},
+		{12, 10, 100}, // Briefly over capacity; clamped
+		{5, 0, 0},     // No queue to fill
+	}
+	for _, tt := range tests {
+		if got := queueUtilizationPct(tt.queued, tt.capacity); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("queueUtilizationPct(%d, %d) = %v, want %v", tt.queued, tt.capacity, got, tt.want)
+		}
+	}
+}
+
+// TestEDFServesTightDeadlinesFirst queues jobs with loose deadlines ahead
+// of jobs with tight ones and checks that EDF scheduling lets fewer of the
+// tight jobs miss their deadlines than FIFO does.
+func TestEDFServesTightDeadlinesFirst(t *testing.T) {
+	const (
+		latency       = 50 * time.Millisecond
+		loose, tight  = 5, 4
+		tightDeadline = 300 * time.Millisecond
+	)
+
+	// tightMisses runs the scenario and counts the tight jobs that failed
+	// with context.DeadlineExceeded. Under FIFO the tight jobs only start
+	// after the loose ones, at least 350ms in; under EDF they all finish by
+	// about 250ms.
+	tightMisses := func(t *testing.T, new func(*simulator.Database, WorkerPoolConfig) poolHandler, scheduling Scheduling) int {
+		h := new(newFixedLatencyDatabase(latency), WorkerPoolConfig{Workers: 1, QueueSize: loose + tight, Scheduling: scheduling})
+		defer shutdownHandler(t, h)
+
+		inFlight := startRequest(h, "P00000")
+		waitFor(t, func() bool { return h.GetStats().ActiveJobs == 1 })
+
+		start := func(id string, timeout time.Duration) <-chan error {
+			result := make(chan error, 1)
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+				_, err := h.HandleRequest(ctx, id)
+				result <- err
+			}()
+			return result
+		}
+
+		var looseResults, tightResults []<-chan error
+		for i := 0; i < loose+tight; i++ {
+			id := fmt.Sprintf("P%05d", i+1)
+			if i < loose {
+				looseResults = append(looseResults, start(id, 5*time.Second))
+			} else {
+				tightResults = append(tightResults, start(id, tightDeadline))
+			}
+			want := int64(i + 1)
+			waitFor(t, func() bool { return h.GetStats().QueuedJobs >= want })
+		}
+
+		misses := 0
+		for _, result := range tightResults {
+			if err := <-result; errors.Is(err, context.DeadlineExceeded) {
+				misses++
+			} else if err != nil {
+				t.Errorf("tight job: unexpected error: %v", err)
+			}
+		}
+		for _, result := range append(looseResults, inFlight) {
+			if err := <-result; err != nil {
+				t.Errorf("loose job: unexpected error: %v", err)
+			}
+		}
+		return misses
+	}
+
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			fifo := tightMisses(t, tc.new, FIFO)
+			edf := tightMisses(t, tc.new, EDF)
+			if edf >= fifo {
+				t.Errorf("tight-deadline misses: EDF %d, FIFO %d; want fewer under EDF", edf, fifo)
+			}
+		})
+	}
+}
+
+// TestDeadlineMissesMatchTimeouts saturates a single-worker pool with
+// requests whose deadlines can't all be met, and checks GetStats counts
+// exactly the requests whose callers saw them time out.
+func TestDeadlineMissesMatchTimeouts(t *testing.T) {
+	const requests = 10
+
+	for _, tc := range poolConstructors {
+		t.Run(tc.name, func(t *testing.T) {
+			h := tc.new(newFixedLatencyDatabase(50*time.Millisecond), WorkerPoolConfig{Workers: 1, QueueSize: requests})
+			defer shutdownHandler(t, h)
+
+			timedOut := make(chan bool, requests)
+			for i := 0; i < requests; i++ {
+				go func() {
+					ctx, cancel := context.WithTimeout(context.Background(), 175*time.Millisecond)
+					defer cancel()
+					_, err := h.HandleRequest(ctx, "P00001")
+					missed := errors.Is(err, context.DeadlineExceeded) ||
+						(err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded))
+					if err != nil && !missed {
+						t.Errorf("unexpected error: %v", err)
+					}
+					timedOut <- missed
+				}()
+			}
+
+			var timeouts int64
+			for i := 0; i < requests; i++ {
+				if <-timedOut {
+					timeouts++
+				}
+			}
+			if timeouts == 0 || timeouts == requests {
+				t.Fatalf("%d of %d requests timed out, want some but not all", timeouts, requests)
+			}
+
+			if misses := h.GetStats().DeadlineMisses; misses != timeouts {
+				t.Errorf("deadlineMisses = %d, want %d (the observed timeouts)", misses, timeouts)
+			}
+		})
+	}
+}
+
+// TestLoadHeadersReflectStats loads a pool with busy workers and queued
+// jobs and checks that, with LoadHeaders, a response reports the same
+// load GetStats does, and that without it no load headers are sent.
+func TestLoadHeadersReflectStats(t *testing.T) {
+	const workers, queued = 2, 3
+
+	for _, tc := range poolConstructors {
+		for _, enabled := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s/%v", tc.name, enabled), func(t *testing.T) {
+				db := newFixedLatencyDatabase(200 * time.Millisecond)
+				h := tc.new(db, WorkerPoolConfig{Workers: workers, QueueSize: 10, LoadHeaders: enabled})
+				defer shutdownHandler(t, h)
+
+				results := make([]<-chan error, workers+queued)
+				for i := range results {
+					if i == workers {
+						waitFor(t, func() bool { return h.GetStats().ActiveJobs == workers })
+					}
+					results[i] = startRequest(h, fmt.Sprintf("P%05d", i))
+				}
+				waitFor(t, func() bool { return h.GetStats().QueuedJobs == queued })
+				stats := h.GetStats()
+
+				rec := httptest.NewRecorder()
+				h.(http.Handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00099", nil))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, want 200", rec.Code)
+				}
+				for _, result := range results {
+					if err := <-result; err != nil {
+						t.Fatalf("load request: unexpected error: %v", err)
+					}
+				}
+
+				want := map[string]string{
+					"X-Queue-Depth":    fmt.Sprint(stats.QueuedJobs),
+					"X-Active-Workers": fmt.Sprint(stats.ActiveJobs),
+					"X-Queue-Capacity": fmt.Sprint(stats.QueueCapacity),
+				}
+				for header, value := range want {
+					got := rec.Header().Get(header)
+					if !enabled {
+						value = ""
+					}
+					if got != value {
+						t.Errorf("%s = %q, want %q", header, got, value)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,556 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// workerQueue is the queue and admission machinery shared by the worker
+// pool patterns, WorkerPoolHandler and OptimizedHandler, which embed it:
+// the main queue with its scheduling (FIFO, Fairness or EDF) and queue
+// policy, the overflow queue and its grace period, load shedding, the
+// workers that drain the queues, and graceful shutdown.
+//
+// The handlers differ only in how a worker answers a finished job, which
+// they supply as respond.
+type workerQueue struct {
+	db             PatientStore
+	workers        int
+	queueSize      int
+	jobQueue       chan *job
+	wg             sync.WaitGroup
+	ctx            context.Context
+	cancel         context.CancelFunc
+	stopping       chan struct{} // Closed when Shutdown starts
+	queueMu        sync.RWMutex  // Held for reading while sending to jobQueue or overflowQueue
+	shutdownOnce   sync.Once
+	enqueueTimeout time.Duration
+	rejectStatus   int
+	loadHeaders    bool
+	activeJobs     int64
+	queuedJobs     int64
+	peakActiveJobs int64 // High-water marks of activeJobs and queuedJobs
+	peakQueuedJobs int64
+	overflowJobs   int64 // Jobs sitting in overflowQueue, including ones whose grace period has run out
+	expiredJobs    int64 // Jobs dropped because the caller's deadline passed while queued
+	droppedJobs    int64 // Jobs evicted from a full queue under DropOldest
+	cancelledJobs  int64 // Jobs skipped or cut short because the caller cancelled
+	deadlineMisses int64 // Requests that failed because the caller's deadline passed
+
+	// Latency-based load shedding; nil unless LatencySLO is set
+	admission *admissionController
+
+	// Second-tier queue for bursts; nil unless OverflowSize is set
+	overflowQueue   chan *job
+	overflowTimeout time.Duration
+
+	// Per-patient round-robin scheduling; nil unless Fairness is set
+	fair *fairQueue[*job]
+
+	// Earliest-deadline-first scheduling; nil unless Scheduling is EDF
+	edf *deadlineQueue[*job]
+
+	// Per-worker job start times, for WorkerHealth
+	watchdog *workerWatchdog
+
+	queuePolicy QueuePolicy
+	dropMu      sync.Mutex // Serialises evict-then-send under DropOldest
+
+	// respond hands the outcome of a job a worker has run to its caller
+	respond func(j *job, patient *models.Patient, err error)
+}
+
+// job represents a unit of work for the worker pool.
+type job struct {
+	ctx        context.Context
+	patientID  string
+	update     *models.Patient // Non-nil for write jobs
+	resultChan chan *models.PatientResponse
+	errChan    chan error
+	queueSpan  trace.Span // Ends when a worker picks the job up
+
+	// For overflow jobs, set by whichever of a worker and the grace timer
+	// takes the job first; see claimOverflowJob
+	claimed int32
+}
+
+// newJob returns a job for a read, or a write when update is non-nil.
+func newJob(ctx context.Context, patientID string, update *models.Patient) *job {
+	return &job{
+		ctx:        ctx,
+		patientID:  patientID,
+		update:     update,
+		resultChan: make(chan *models.PatientResponse, 1),
+		errChan:    make(chan error, 1),
+	}
+}
+
+// newWorkerQueue creates the queues described by config and starts the
+// workers, which answer each job they run with respond.
+func newWorkerQueue(db PatientStore, config WorkerPoolConfig, respond func(j *job, patient *models.Patient, err error)) *workerQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	q := &workerQueue{
+		db:             db,
+		workers:        config.Workers,
+		queueSize:      config.QueueSize,
+		jobQueue:       make(chan *job, config.QueueSize),
+		ctx:            ctx,
+		cancel:         cancel,
+		stopping:       make(chan struct{}),
+		enqueueTimeout: config.enqueueTimeoutOrDefault(),
+		admission:      newAdmissionController(config.LatencySLO),
+		queuePolicy:    config.QueuePolicy,
+		rejectStatus:   config.RejectStatus,
+		loadHeaders:    config.LoadHeaders,
+		respond:        respond,
+	}
+	if config.OverflowSize > 0 {
+		q.overflowQueue = make(chan *job, config.OverflowSize)
+		q.overflowTimeout = config.overflowTimeoutOrDefault()
+	}
+	q.fair = newFairQueue[*job](config.Fairness && config.Scheduling != EDF, config.QueueSize)
+	q.edf = newDeadlineQueue[*job](config.Scheduling == EDF, config.QueueSize)
+	q.watchdog = newWorkerWatchdog(config.Workers, config.StuckThreshold)
+
+	// Start worker goroutines
+	// These run continuously, waiting for jobs from the queue
+	q.startWorkers()
+
+	return q
+}
+
+// startWorkers spawns the fixed number of worker goroutines.
+func (q *workerQueue) startWorkers() {
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.worker(i)
+	}
+}
+
+// worker is the main loop for each worker goroutine.
+// It pulls jobs from the queue, or from the overflow queue while the main
+// queue is empty, and processes them until Shutdown closes the queues and
+// every job already in them has been taken.
+func (q *workerQueue) worker(id int) {
+	defer q.wg.Done()
+
+	// A nil channel never receives, so a closed (or absent) queue drops
+	// out of the selects below
+	jobs, overflow := q.jobQueue, q.overflowQueue
+	for jobs != nil || overflow != nil {
+		// Prefer the main queue
+		select {
+		case j, ok := <-jobs:
+			if !ok {
+				jobs = nil
+				continue
+			}
+			atomic.AddInt64(&q.queuedJobs, -1)
+			q.processJob(id, q.resolveJob(j))
+			continue
+		default:
+		}
+
+		select {
+		case j, ok := <-jobs:
+			if !ok {
+				jobs = nil
+				continue
+			}
+			atomic.AddInt64(&q.queuedJobs, -1)
+			q.processJob(id, q.resolveJob(j))
+		case j, ok := <-overflow:
+			if !ok {
+				overflow = nil
+				continue
+			}
+			atomic.AddInt64(&q.overflowJobs, -1)
+			// Skip jobs already rejected when their grace period ran out
+			if claimOverflowJob(&j.claimed) {
+				q.processJob(id, j)
+			}
+		}
+	}
+}
+
+// processJob handles a single patient query job.
+func (q *workerQueue) processJob(id int, j *job) {
+	// Skip jobs whose caller has already given up.
+	// When the queue backs up, a job can sit long enough for its deadline
+	// to pass, or for the client to disconnect; querying the database for
+	// it would only waste a worker.
+	if err := j.ctx.Err(); err != nil {
+		if errors.Is(err, context.Canceled) {
+			atomic.AddInt64(&q.cancelledJobs, 1)
+		} else {
+			atomic.AddInt64(&q.expiredJobs, 1)
+		}
+		endSpan(j.queueSpan, err)
+		return
+	}
+
+	// Shutdown timed out while this job was queued
+	if q.ctx.Err() != nil {
+		abandonJob(j.queueSpan, j.errChan)
+		return
+	}
+	j.queueSpan.End()
+
+	recordPeak(&q.peakActiveJobs, atomic.AddInt64(&q.activeJobs, 1))
+	defer atomic.AddInt64(&q.activeJobs, -1)
+	q.watchdog.begin(id)
+	defer q.watchdog.end(id)
+
+	// Query (or update) the database
+	// The query watches j.ctx too, so a client that disconnects mid-query
+	// frees the worker (and the connection) straight away
+	start := time.Now()
+	patient, err := runJob(j.ctx, q.db, j.patientID, j.update)
+	if err != nil && errors.Is(j.ctx.Err(), context.Canceled) {
+		// A query cut short says nothing about database latency
+		atomic.AddInt64(&q.cancelledJobs, 1)
+	} else {
+		q.admission.observe(time.Since(start))
+	}
+
+	q.respond(j, patient, err)
+}
+
+// serveHTTP handles incoming HTTP requests for a worker pool handler,
+// tracing them as spanName. GET reads a patient; PUT writes the JSON body
+// through the same queue. release, if non-nil, is handed each successful
+// response once it has been written.
+func (q *workerQueue) serveHTTP(w http.ResponseWriter, r *http.Request, spanName string, release func(*models.PatientResponse)) {
+	ctx, span := startSpan(r.Context(), spanName)
+	defer span.End()
+
+	if q.loadHeaders {
+		setLoadHeaders(w, atomic.LoadInt64(&q.queuedJobs), atomic.LoadInt64(&q.activeJobs), q.queueSize)
+	}
+
+	if _, err := parseFields(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var update *models.Patient
+	patientID := extractPatientID(r)
+
+	if r.Method == http.MethodPut {
+		patient, err := decodePatient(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		update, patientID = patient, patient.ID
+	}
+
+	if patientID == "" {
+		http.Error(w, "patient ID required", http.StatusBadRequest)
+		return
+	}
+
+	// Shed load early while the database is slower than the SLO
+	if q.admission.shouldShed() {
+		recordError(span, ErrLoadShed)
+		rejectOverloaded(w, q.rejectStatus)
+		return
+	}
+
+	// Create a job for this request
+	j := newJob(ctx, patientID, update)
+
+	// The queue wait span is ended by the worker that picks the job up
+	_, j.queueSpan = startSpan(ctx, "queue.wait")
+
+	// Try to enqueue the job without waiting
+	// This provides backpressure: if queue is full, we reject the request
+	if err := q.enqueue(ctx, j, 0); err != nil {
+		endSpan(j.queueSpan, err)
+		switch {
+		case errors.Is(err, ErrQueueFull):
+			// Queue is full - reject the request
+			// In production, you might:
+			// - Return 503 Service Unavailable with Retry-After header
+			// - Implement priority queuing for critical requests
+			// - Set OverflowSize to hold bursts for a grace period
+			rejectOverloaded(w, q.rejectStatus) // Suggests a retry after 1 second
+		case errors.Is(err, ErrShuttingDown):
+			http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		default:
+			recordDeadlineMiss(ctx, &q.deadlineMisses, err)
+			http.Error(w, "request cancelled", http.StatusRequestTimeout)
+		}
+		return
+	}
+
+	// Wait for the result
+	select {
+	case response := <-j.resultChan:
+		response.RequestID = requestID(r)
+		writeResponse(w, r, http.StatusOK, response)
+		if release != nil {
+			release(response)
+		}
+	case err := <-j.errChan:
+		recordError(span, err)
+		recordDeadlineMiss(ctx, &q.deadlineMisses, err)
+		// A full overflow queue or an evicted job is still a rejection
+		writeError(w, r, err, q.rejectStatus)
+	case <-ctx.Done():
+		recordDeadlineMiss(ctx, &q.deadlineMisses, ctx.Err())
+		http.Error(w, "request timeout", http.StatusRequestTimeout)
+	}
+}
+
+// submit enqueues a job and waits for its result.
+func (q *workerQueue) submit(ctx context.Context, j *job) (response *models.PatientResponse, err error) {
+	defer func() { recordDeadlineMiss(ctx, &q.deadlineMisses, err) }()
+
+	if q.admission.shouldShed() {
+		return models.NewErrorResponse(ErrLoadShed, ""), ErrLoadShed
+	}
+
+	// Don't queue a job that could only expire in the queue
+	wait, ok := enqueueBudget(ctx, q.enqueueTimeout)
+	if !ok {
+		return models.NewErrorResponse(context.DeadlineExceeded, ""), context.DeadlineExceeded
+	}
+
+	// The queue wait span is ended by the worker that picks the job up
+	_, j.queueSpan = startSpan(ctx, "queue.wait")
+
+	// Try to enqueue, waiting at most the configured admission timeout,
+	// cut short by the caller's deadline.
+	if err := q.enqueue(ctx, j, wait); err != nil {
+		endSpan(j.queueSpan, err)
+		return models.NewErrorResponse(err, ""), err
+	}
+
+	// Wait for result
+	select {
+	case response := <-j.resultChan:
+		return response, nil
+	case err := <-j.errChan:
+		return models.NewErrorResponse(err, ""), err
+	case <-ctx.Done():
+		return models.NewErrorResponse(ctx.Err(), ""), ctx.Err()
+	}
+}
+
+// enqueue adds j to the job queue, waiting up to wait for space, or not at
+// all when wait is zero. The read lock stops Shutdown closing the queue
+// mid-send; Shutdown closes stopping first, so waiting senders let go.
+//
+// With an overflow queue, a job that finds the main queue full goes to the
+// overflow queue instead (the wait then applies to that), and is failed
+// with ErrQueueFull if no worker takes it within the grace period.
+func (q *workerQueue) enqueue(ctx context.Context, j *job, wait time.Duration) error {
+	q.queueMu.RLock()
+	defer q.queueMu.RUnlock()
+
+	if q.overflowQueue == nil {
+		if err := q.sendMain(ctx, j, wait); err != nil {
+			return err
+		}
+		recordPeak(&q.peakQueuedJobs, atomic.AddInt64(&q.queuedJobs, 1))
+		return nil
+	}
+
+	err := q.sendMain(ctx, j, 0)
+	if err == nil {
+		recordPeak(&q.peakQueuedJobs, atomic.AddInt64(&q.queuedJobs, 1))
+		return nil
+	}
+	if !errors.Is(err, ErrQueueFull) {
+		return err
+	}
+
+	// Count the job before a worker can take it and uncount it
+	atomic.AddInt64(&q.overflowJobs, 1)
+	if err := sendJob(ctx, q.overflowQueue, j, q.stopping, wait); err != nil {
+		atomic.AddInt64(&q.overflowJobs, -1)
+		return err
+	}
+	time.AfterFunc(q.overflowTimeout, func() {
+		if claimOverflowJob(&j.claimed) {
+			endSpan(j.queueSpan, ErrQueueFull)
+			j.errChan <- ErrQueueFull
+		}
+	})
+	return nil
+}
+
+// sendMain sends j to the main queue, applying the queue policy when it is
+// full.
+func (q *workerQueue) sendMain(ctx context.Context, j *job, wait time.Duration) error {
+	if q.queuePolicy == DropOldest {
+		return q.sendDropOldest(ctx, j)
+	}
+	return q.sendQueue(ctx, j, wait)
+}
+
+// sendDropOldest sends j to the main queue, evicting the longest-queued job
+// if it is full. dropMu makes the eviction and the send one step, so every
+// job admitted under DropOldest evicts at most one other.
+func (q *workerQueue) sendDropOldest(ctx context.Context, j *job) error {
+	q.dropMu.Lock()
+	defer q.dropMu.Unlock()
+
+	for {
+		err := q.sendQueue(ctx, j, 0)
+		if !errors.Is(err, ErrQueueFull) {
+			return err
+		}
+
+		select {
+		case oldest := <-q.jobQueue:
+			q.dropJob(q.resolveJob(oldest))
+		default:
+			// A worker took it first; there's room now
+		}
+	}
+}
+
+// dropJob fails a job evicted from the queue under DropOldest.
+func (q *workerQueue) dropJob(j *job) {
+	atomic.AddInt64(&q.queuedJobs, -1)
+	atomic.AddInt64(&q.droppedJobs, 1)
+	endSpan(j.queueSpan, ErrJobDropped)
+	j.errChan <- ErrJobDropped
+}
+
+// sendQueue sends j to the main queue. With Fairness or EDF, j goes to
+// the fair or deadline queue and jobQueue carries a placeholder; see
+// fairQueue.
+func (q *workerQueue) sendQueue(ctx context.Context, j *job, wait time.Duration) error {
+	switch {
+	case q.fair != nil:
+		if err := sendJob(ctx, q.fair.slots, struct{}{}, q.stopping, wait); err != nil {
+			return err
+		}
+		q.fair.push(j.patientID, j)
+	case q.edf != nil:
+		if err := sendJob(ctx, q.edf.slots, struct{}{}, q.stopping, wait); err != nil {
+			return err
+		}
+		q.edf.push(j.ctx, j)
+	default:
+		return sendJob(ctx, q.jobQueue, j, q.stopping, wait)
+	}
+
+	// Can't block: jobQueue holds no more placeholders than there are slots
+	q.jobQueue <- nil
+	return nil
+}
+
+// resolveJob returns the job to run for a value received from jobQueue:
+// the value itself, or with Fairness or EDF, the next job from the fair
+// or deadline queue.
+func (q *workerQueue) resolveJob(j *job) *job {
+	switch {
+	case q.fair != nil:
+		return q.fair.pop()
+	case q.edf != nil:
+		return q.edf.pop()
+	default:
+		return j
+	}
+}
+
+// GetCancelledJobs returns how many jobs were skipped in the queue, or cut
+// short mid-query, because their caller cancelled (typically an HTTP client
+// disconnecting).
+func (q *workerQueue) GetCancelledJobs() int64 {
+	return atomic.LoadInt64(&q.cancelledJobs)
+}
+
+// GetStats returns current worker pool statistics.
+func (q *workerQueue) GetStats() PoolStats {
+	return PoolStats{
+		ActiveJobs:     atomic.LoadInt64(&q.activeJobs),
+		QueuedJobs:     atomic.LoadInt64(&q.queuedJobs),
+		OverflowJobs:   atomic.LoadInt64(&q.overflowJobs),
+		ExpiredJobs:    atomic.LoadInt64(&q.expiredJobs),
+		DroppedJobs:    atomic.LoadInt64(&q.droppedJobs),
+		QueueCapacity:  q.queueSize,
+		ShedFraction:   q.admission.shedFraction(),
+		KeyDepths:      q.fair.depths(),
+		PeakActiveJobs: atomic.LoadInt64(&q.peakActiveJobs),
+		PeakQueuedJobs: atomic.LoadInt64(&q.peakQueuedJobs),
+		DeadlineMisses: atomic.LoadInt64(&q.deadlineMisses),
+	}
+}
+
+// QueueUtilizationPct returns the queue's high-water mark as a percentage
+// of its capacity: how close the pool has come to turning requests away
+// since it started. See queueUtilizationPct.
+func (q *workerQueue) QueueUtilizationPct() float64 {
+	return queueUtilizationPct(atomic.LoadInt64(&q.peakQueuedJobs), q.queueSize)
+}
+
+// WorkerHealth reports when each worker last picked up a job, how long it
+// has been on its current one, and how many workers have been on theirs
+// for longer than StuckThreshold.
+func (q *workerQueue) WorkerHealth() WorkerHealth {
+	return q.watchdog.health()
+}
+
+// shutdown refuses new jobs, then waits for the workers to drain the
+// queues and exit; see WorkerPoolHandler.Shutdown. If ctx ends first,
+// every job still queued fails with ErrShuttingDown.
+func (q *workerQueue) shutdown(ctx context.Context) error {
+	// Stop accepting new jobs; workers exit once the queue is empty
+	q.shutdownOnce.Do(q.closeQueue)
+
+	// Wait for workers to finish with timeout
+	workersDone := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(workersDone)
+	}()
+
+	select {
+	case <-workersDone:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		// Stop workers starting queued jobs, and fail whatever is left
+		q.cancel()
+		for j := range q.jobQueue {
+			atomic.AddInt64(&q.queuedJobs, -1)
+			j = q.resolveJob(j)
+			abandonJob(j.queueSpan, j.errChan)
+		}
+		if q.overflowQueue != nil {
+			for j := range q.overflowQueue {
+				atomic.AddInt64(&q.overflowJobs, -1)
+				if claimOverflowJob(&j.claimed) {
+					abandonJob(j.queueSpan, j.errChan)
+				}
+			}
+		}
+		return fmt.Errorf("shutdown timeout: workers still processing")
+	}
+}
+
+// closeQueue refuses new jobs and closes the queue once no sender is
+// mid-send.
+func (q *workerQueue) closeQueue() {
+	close(q.stopping)
+	q.queueMu.Lock()
+	close(q.jobQueue)
+	if q.overflowQueue != nil {
+		close(q.overflowQueue)
+	}
+	q.queueMu.Unlock()
+}
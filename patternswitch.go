@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
+)
+
+// switchableHandler serves requests through the active pattern handler and
+// allows it to be replaced at runtime (see POST /admin/pattern), so
+// patterns can be compared without restarting the server.
+type switchableHandler struct {
+	config  Config // Pattern is ignored; the rest configures new handlers
+	db      patterns.PatientStore
+	swapMu  sync.Mutex   // Serialises Switch calls
+	current atomic.Value // *activeHandler
+}
+
+// activeHandler is a pattern handler plus the bookkeeping needed to retire
+// it safely: each in-flight request holds a read lock, so the swap can wait
+// for them all before shutting the handler down.
+type activeHandler struct {
+	Handler
+	pattern string
+
+	mu      sync.RWMutex
+	retired bool
+}
+
+// newSwitchableHandler creates a handler serving config.Pattern.
+func newSwitchableHandler(config Config, db patterns.PatientStore) (*switchableHandler, error) {
+	s := &switchableHandler{config: config, db: db}
+
+	handler, err := createHandler(config, db)
+	if err != nil {
+		return nil, err
+	}
+	s.current.Store(&activeHandler{Handler: handler, pattern: config.Pattern})
+
+	return s, nil
+}
+
+// acquire returns the active handler with its read lock held.
+// A request that loses the race with Switch simply retries on the new one.
+func (s *switchableHandler) acquire() *activeHandler {
+	for {
+		active := s.current.Load().(*activeHandler)
+		active.mu.RLock()
+		if !active.retired {
+			return active
+		}
+		active.mu.RUnlock()
+	}
+}
+
+// ServeHTTP serves the request on whichever handler is active when it
+// arrives. A request always finishes on the handler it started on.
+func (s *switchableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	active := s.acquire()
+	defer active.mu.RUnlock()
+
+	active.ServeHTTP(w, r)
+}
+
+// HandleRequest reads a patient on whichever handler is active, like
+// ServeHTTP. It serves the gRPC API.
+func (s *switchableHandler) HandleRequest(ctx context.Context, patientID string) (*models.PatientResponse, error) {
+	active := s.acquire()
+	defer active.mu.RUnlock()
+
+	return active.HandleRequest(ctx, patientID)
+}
+
+// Pattern returns the name of the active pattern, e.g. "workerpool".
+func (s *switchableHandler) Pattern() string {
+	return s.current.Load().(*activeHandler).pattern
+}
+
+// GetName returns the active handler's reporting name.
+func (s *switchableHandler) GetName() string {
+	return s.current.Load().(*activeHandler).GetName()
+}
+
+// Switch makes a new handler for pattern the active one, waits for requests
+// still running on the old handler, and then shuts it down. It returns the
+// previous pattern.
+func (s *switchableHandler) Switch(ctx context.Context, pattern string) (previous string, err error) {
+	s.swapMu.Lock()
+	defer s.swapMu.Unlock()
+
+	config := s.config
+	config.Pattern = pattern
+	handler, err := createHandler(config, s.db)
+	if err != nil {
+		return "", err
+	}
+
+	old := s.current.Swap(&activeHandler{Handler: handler, pattern: pattern}).(*activeHandler)
+
+	// Blocks until every in-flight request on the old handler has finished
+	old.mu.Lock()
+	old.retired = true
+	old.mu.Unlock()
+
+	return old.pattern, old.Shutdown(ctx)
+}
+
+// Shutdown shuts down the active handler.
+func (s *switchableHandler) Shutdown(ctx context.Context) error {
+	s.swapMu.Lock()
+	defer s.swapMu.Unlock()
+
+	return s.current.Load().(*activeHandler).Shutdown(ctx)
+}
+
+// adminPatternHandler serves POST /admin/pattern {"pattern":"optimized"}.
+func adminPatternHandler(s *switchableHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var body struct {
+			Pattern string `json:"pattern"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		if !validPatterns[body.Pattern] {
+			http.Error(w, fmt.Sprintf("unknown pattern %q", body.Pattern), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), shutdownTimeout)
+		defer cancel()
+
+		previous, err := s.Switch(ctx, body.Pattern)
+		if err != nil && previous == "" {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// The switch happened even if the old handler shut down untidily
+		response := map[string]string{
+			"pattern":  body.Pattern,
+			"previous": previous,
+			"name":     s.GetName(),
+		}
+		if err != nil {
+			response["shutdown_error"] = err.Error()
+		}
+
+		patterns.WriteJSON(w, r, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// newSwitchableServer starts a test server with a switchable handler.
+func newSwitchableServer(t *testing.T, config Config) (*httptest.Server, *switchableHandler) {
+	t.Helper()
+
+	db := simulator.NewDatabase(2, 5, 0)
+	handler, err := newSwitchableHandler(config, db)
+	if err != nil {
+		t.Fatalf("newSwitchableHandler: %v", err)
+	}
+	t.Cleanup(func() { handler.Shutdown(context.Background()) })
+
+	server := httptest.NewServer(newServeMux(config, handler, db, metrics.NewCollector(), nil))
+	t.Cleanup(server.Close)
+
+	return server, handler
+}
+
+func postPattern(t *testing.T, url, pattern, apiKey string) *http.Response {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]string{"pattern": pattern})
+	req, _ := http.NewRequest(http.MethodPost, url+"/admin/pattern", bytes.NewReader(body))
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /admin/pattern: %v", err)
+	}
+	return resp
+}
+
+func TestSwitchPatternMidTraffic(t *testing.T) {
+	config := validConfig()
+	config.Pattern = "naive"
+	config.APIKeys = []string{"admin-key"}
+	server, handler := newSwitchableServer(t, config)
+
+	var (
+		stop     atomic.Bool
+		served   atomic.Int64
+		failures atomic.Int64
+		wg       sync.WaitGroup
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !stop.Load() {
+				req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/patients?id=P00001", nil)
+				req.Header.Set("Authorization", "Bearer admin-key")
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					failures.Add(1)
+					continue
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					failures.Add(1)
+				}
+				served.Add(1)
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	resp := postPattern(t, server.URL, "workerpool", "admin-key")
+	var result map[string]string
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	stop.Store(true)
+	wg.Wait()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("switch status = %d, want 200", resp.StatusCode)
+	}
+	if result["previous"] != "naive" || result["pattern"] != "workerpool" {
+		t.Errorf("switch response = %v, want naive -> workerpool", result)
+	}
+	if got := handler.Pattern(); got != "workerpool" {
+		t.Errorf("active pattern = %q, want workerpool", got)
+	}
+	if failures.Load() != 0 {
+		t.Errorf("%d of %d requests failed during the switch", failures.Load(), served.Load())
+	}
+	if served.Load() == 0 {
+		t.Error("no requests were served")
+	}
+}
+
+func TestSwitchPatternRejectsBadRequests(t *testing.T) {
+	config := validConfig()
+	config.APIKeys = []string{"admin-key"}
+	server, handler := newSwitchableServer(t, config)
+
+	tests := []struct {
+		name    string
+		pattern string
+		apiKey  string
+		want    int
+	}{
+		{"missing API key", "optimized", "", http.StatusUnauthorized},
+		{"unknown pattern", "fastest", "admin-key", http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		resp := postPattern(t, server.URL, tc.pattern, tc.apiKey)
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, resp.StatusCode, tc.want)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/admin/pattern", nil)
+	req.Header.Set("Authorization", "Bearer admin-key")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want 405", resp.StatusCode)
+	}
+
+	if got := handler.Pattern(); got != config.Pattern {
+		t.Errorf("active pattern = %q after rejected switches, want %q", got, config.Pattern)
+	}
+}
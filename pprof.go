@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// registerPprof mounts the net/http/pprof handlers under /debug/pprof/.
+//
+// The index serves the named profiles (goroutine, heap, allocs, block, mutex,
+// threadcreate); /debug/pprof/profile captures a CPU profile. For example,
+// to watch the naive pattern's goroutine count explode under load:
+//
+//	go tool pprof http://localhost:8080/debug/pprof/goroutine
+//
+// Importing net/http/pprof also registers these on http.DefaultServeMux,
+// which this server never serves, so they are reachable only through here.
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// newPprofServer returns an admin server that serves only the pprof handlers.
+// It has no write timeout, so CPU profiles and execution traces can run for
+// their full duration; the API server's 15s write timeout caps them at less.
+func newPprofServer(port int) *http.Server {
+	mux := http.NewServeMux()
+	registerPprof(mux)
+
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 15 * time.Second,
+	}
+}
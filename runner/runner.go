@@ -0,0 +1,804 @@
+// Package runner drives a load test against one or more handler patterns.
+// It is shared by the cmd/loadtest CLI and the server's /admin/benchmark
+// endpoint.
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// Config holds configuration for the load test.
+type Config struct {
+	TotalRequests  int
+	Concurrency    int
+	Workers        int
+	QueueSize      int
+	EnqueueTimeout time.Duration
+	WriteRatio     float64 // Fraction of requests that are updates (0.0 to 1.0)
+	MaxConnections int     // Simulated DB connection pool size (0 for unlimited)
+	CorpusSize     int     // Pre-generated patients to serve reads from (0 to generate per query)
+	MaxGoroutines  int     // Safety cap on the naive pattern's goroutines (0 for unbounded)
+
+	// Tail latency injection
+	TailProbability float64
+	TailLatency     time.Duration
+
+	// Unmeasured warm-up traffic sent before the run: a request count, or
+	// if WarmupRequests is zero, a duration. Warm-up fills caches and, for
+	// the optimized pattern, sync.Pool, so cold starts don't skew results.
+	WarmupRequests int
+	WarmupDuration time.Duration
+
+	// Pause each client between requests for a random duration in
+	// [ThinkTimeMin, ThinkTimeMax], modelling user pacing. The pause is
+	// never counted in measured latency.
+	ThinkTimeMin time.Duration
+	ThinkTimeMax time.Duration
+
+	// Coordinated-omission correction: each client sends on a fixed
+	// schedule and latency is also measured from the intended send time,
+	// so a stall is charged to every request it delayed, not just one.
+	CorrectCO bool
+	// Per-client gap between intended sends; zero means the mean
+	// simulated query latency, the pace a healthy server sustains.
+	ScheduleInterval time.Duration
+
+	// Replay a recorded trace (see LoadTrace) instead of the synthetic
+	// load: its requests go out at their recorded times, divided by
+	// TraceSpeed (2 replays twice as fast). Concurrency, think time and
+	// WriteRatio don't apply to the replay; set TotalRequests to
+	// len(Trace) so results report it correctly.
+	Trace      []TraceEntry
+	TraceSpeed float64
+
+	// Record the heap allocations made during the measured run (not the
+	// warm-up) in Result.MemoryAllocations and MemoryBytes. The counters
+	// are process-wide, so they include the load generator's own
+	// allocations, which are the same for every pattern.
+	MeasureMemory bool
+}
+
+// Validate checks that every numeric setting is positive.
+// A zero or negative value would otherwise cause a divide-by-zero when
+// splitting requests across clients, or a panic when sizing channels.
+// Problems are reported by the cmd/loadtest flag name.
+func (c Config) Validate() error {
+	var problems []string
+
+	check := func(flagName string, value int) {
+		if value <= 0 {
+			problems = append(problems, fmt.Sprintf("-%s must be positive (got %d)", flagName, value))
+		}
+	}
+
+	check("requests", c.TotalRequests)
+	check("concurrency", c.Concurrency)
+	check("workers", c.Workers)
+	check("queue-size", c.QueueSize)
+	if c.EnqueueTimeout <= 0 {
+		problems = append(problems, fmt.Sprintf("-enqueue-timeout must be positive (got %v)", c.EnqueueTimeout))
+	}
+	if c.MaxConnections < 0 {
+		problems = append(problems, fmt.Sprintf("-max-connections must not be negative (got %d)", c.MaxConnections))
+	}
+	if c.MaxGoroutines < 0 {
+		problems = append(problems, fmt.Sprintf("-max-goroutines must not be negative (got %d)", c.MaxGoroutines))
+	}
+	if c.CorpusSize < 0 {
+		problems = append(problems, fmt.Sprintf("-corpus-size must not be negative (got %d)", c.CorpusSize))
+	}
+	if c.TailProbability < 0 || c.TailProbability > 1 {
+		problems = append(problems, fmt.Sprintf("-tail-probability must be between 0 and 1 (got %v)", c.TailProbability))
+	}
+	if c.WarmupRequests < 0 || c.WarmupDuration < 0 {
+		problems = append(problems, "-warmup must not be negative")
+	}
+	if c.ThinkTimeMin < 0 || c.ThinkTimeMax < c.ThinkTimeMin {
+		problems = append(problems, fmt.Sprintf("-think-time must be a non-negative duration or ascending range (got %v-%v)", c.ThinkTimeMin, c.ThinkTimeMax))
+	}
+	if c.ScheduleInterval < 0 {
+		problems = append(problems, fmt.Sprintf("-co-interval must not be negative (got %v)", c.ScheduleInterval))
+	}
+	if c.WriteRatio < 0 || c.WriteRatio > 1 {
+		problems = append(problems, fmt.Sprintf("-write-ratio must be between 0 and 1 (got %v)", c.WriteRatio))
+	}
+	if len(c.Trace) > 0 && c.TraceSpeed <= 0 {
+		problems = append(problems, fmt.Sprintf("-trace-speed must be positive (got %v)", c.TraceSpeed))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ParseWarmup parses a -warmup value: a request count such as "500", or a
+// duration such as "2s". An empty value means no warm-up.
+func ParseWarmup(value string) (requests int, duration time.Duration, err error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+	if requests, err = strconv.Atoi(value); err == nil {
+		return requests, 0, nil
+	}
+	if duration, err = time.ParseDuration(value); err == nil {
+		return 0, duration, nil
+	}
+	return 0, 0, fmt.Errorf("invalid warm-up %q: want a request count or a duration", value)
+}
+
+// ParseThinkTime parses a -think-time value: a single duration such as
+// "20ms", or a range such as "10ms-50ms". An empty value means no think time.
+func ParseThinkTime(value string) (low, high time.Duration, err error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+
+	first, second, isRange := strings.Cut(value, "-")
+	if low, err = time.ParseDuration(first); err != nil {
+		return 0, 0, fmt.Errorf("invalid think time %q: %w", value, err)
+	}
+	if !isRange {
+		return low, low, nil
+	}
+	if high, err = time.ParseDuration(second); err != nil {
+		return 0, 0, fmt.Errorf("invalid think time %q: %w", value, err)
+	}
+	if high < low {
+		return 0, 0, fmt.Errorf("invalid think time %q: range must be ascending", value)
+	}
+	return low, high, nil
+}
+
+// thinkTime picks a pause from the configured range.
+func (c Config) thinkTime(rng *rand.Rand) time.Duration {
+	if c.ThinkTimeMax <= c.ThinkTimeMin {
+		return c.ThinkTimeMin
+	}
+	return c.ThinkTimeMin + time.Duration(rng.Int63n(int64(c.ThinkTimeMax-c.ThinkTimeMin)+1))
+}
+
+// schedule returns the per-client gap between intended sends.
+func (c Config) schedule() time.Duration {
+	if c.ScheduleInterval > 0 {
+		return c.ScheduleInterval
+	}
+	return time.Duration(simulator.MinQueryLatency+simulator.MaxQueryLatency) * time.Millisecond / 2
+}
+
+// NewDatabase creates the simulated database described by the config.
+func (c Config) NewDatabase() *simulator.Database {
+	return simulator.NewDefaultDatabase(
+		simulator.WithMaxConnections(c.MaxConnections),
+		simulator.WithTailLatency(c.TailProbability, c.TailLatency),
+		simulator.WithCorpus(c.CorpusSize, simulator.DefaultCorpusSeed),
+	)
+}
+
+// PatternHandler wraps the handler interface for testing.
+type PatternHandler interface {
+	HandleRequest(ctx context.Context, patientID string) (*models.PatientResponse, error)
+	HandleUpdate(ctx context.Context, patient *models.Patient) (*models.PatientResponse, error)
+	GetName() string
+	Shutdown(ctx context.Context) error
+}
+
+// Pattern names a handler pattern and how to build it.
+type Pattern struct {
+	Key  string // Value accepted by the -pattern flag
+	Name string // Display name used in results
+	New  func(db *simulator.Database) PatternHandler
+}
+
+// Patterns returns every pattern, sized from the config, in the order
+// they are compared.
+func (c Config) Patterns() []Pattern {
+	poolConfig := patterns.WorkerPoolConfig{
+		Workers:        c.Workers,
+		QueueSize:      c.QueueSize,
+		EnqueueTimeout: c.EnqueueTimeout,
+	}
+	semConfig := patterns.SemaphoreConfig{
+		MaxConcurrent:  c.Workers,
+		AcquireTimeout: c.EnqueueTimeout,
+	}
+	pipelineConfig := patterns.DefaultPipelineConfig()
+	pipelineConfig.QueryWorkers = c.Workers
+	pipelineConfig.QueueSize = c.QueueSize
+	pipelineConfig.EnqueueTimeout = c.EnqueueTimeout
+
+	return []Pattern{
+		{"naive", "Naive", func(db *simulator.Database) PatternHandler {
+			return patterns.NewNaiveHandlerWithConfig(db, patterns.NaiveConfig{MaxGoroutines: c.MaxGoroutines})
+		}},
+		{"workerpool", "Worker Pool", func(db *simulator.Database) PatternHandler {
+			return patterns.NewWorkerPoolHandler(db, poolConfig)
+		}},
+		{"optimized", "Optimized", func(db *simulator.Database) PatternHandler {
+			return patterns.NewOptimizedHandler(db, poolConfig)
+		}},
+		{"semaphore", "Semaphore", func(db *simulator.Database) PatternHandler {
+			return patterns.NewSemaphoreHandler(db, semConfig)
+		}},
+		{"pipeline", "Pipeline", func(db *simulator.Database) PatternHandler {
+			return patterns.NewPipelineHandler(db, pipelineConfig)
+		}},
+		{"bulkhead", "Bulkhead", func(db *simulator.Database) PatternHandler {
+			return patterns.NewBulkheadHandler(db, patterns.SplitBulkheadConfig(poolConfig))
+		}},
+	}
+}
+
+// Select returns the patterns whose keys are listed, in the given order.
+// An unknown key is an error.
+func (c Config) Select(keys ...string) ([]Pattern, error) {
+	all := c.Patterns()
+	selected := make([]Pattern, 0, len(keys))
+	for _, key := range keys {
+		found := false
+		for _, p := range all {
+			if p.Key == key {
+				selected = append(selected, p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown pattern: %s", key)
+		}
+	}
+	return selected, nil
+}
+
+// Result holds the results of a single test run. Latencies are in
+// milliseconds, rates in percent.
+type Result struct {
+	PatternName      string           `json:"pattern"`
+	TotalRequests    int64            `json:"total_requests"`
+	SuccessRequests  int64            `json:"success_requests"`
+	ErrorRequests    int64            `json:"error_requests"`
+	RejectedRequests int64            `json:"rejected_requests"`
+	Duration         float64          `json:"duration_seconds"`
+	RequestsPerSec   float64          `json:"requests_per_second"`
+	MinLatency       float64          `json:"min_latency_ms"`
+	MeanLatency      float64          `json:"mean_latency_ms"`
+	MedianLatency    float64          `json:"median_latency_ms"`
+	P95Latency       float64          `json:"p95_latency_ms"`
+	P99Latency       float64          `json:"p99_latency_ms"`
+	P999Latency      float64          `json:"p999_latency_ms"`
+	MaxLatency       float64          `json:"max_latency_ms"`
+	ErrorRate        float64          `json:"error_rate_percent"`
+	RejectionRate    float64          `json:"rejection_rate_percent"`
+	ErrorsByCategory map[string]int64 `json:"errors_by_category,omitempty"`
+	WarmupRequests   int64            `json:"warmup_requests,omitempty"` // Sent before measuring; not in the counts above
+	LatencySamples   int64            `json:"latency_samples,omitempty"` // Latencies the percentiles were taken from, per run
+
+	// Heap allocations during the run, set only with Config.MeasureMemory
+	MemoryAllocations int64 `json:"memory_allocations,omitempty"`
+	MemoryBytes       int64 `json:"memory_bytes,omitempty"`
+
+	// Set by Repeat: the number of runs averaged into this result, and
+	// 95% confidence intervals across them
+	Runs         int               `json:"runs,omitempty"`
+	ThroughputCI *metrics.Interval `json:"requests_per_second_ci95,omitempty"`
+	P99CI        *metrics.Interval `json:"p99_latency_ms_ci95,omitempty"`
+
+	// Latency measured from each request's intended send time, set only
+	// with Config.CorrectCO. The fields above remain uncorrected service time.
+	Corrected *LatencySummary `json:"corrected_latency_ms,omitempty"`
+
+	// The job queue's high-water mark as a percentage of its capacity,
+	// warm-up included; nil for patterns without a queue. Repeat keeps the
+	// highest across runs.
+	PeakQueueUtilization *float64 `json:"peak_queue_utilization_percent,omitempty"`
+
+	// Share of response objects reused from a sync.Pool rather than
+	// allocated, over the measured requests; nil for patterns without one.
+	// Repeat averages it across runs.
+	PoolHitRate *float64 `json:"pool_hit_rate_percent,omitempty"`
+
+	// Ideal performance given the database latency and parallelism
+	TheoreticalRPS        float64 `json:"theoretical_requests_per_second"`
+	TheoreticalMinLatency float64 `json:"theoretical_min_latency_ms"`
+	Efficiency            float64 `json:"efficiency_percent"`
+
+	// Everything the run recorded, for exporting elsewhere (see
+	// metrics.Collector.PushPrometheus); nil for results read back from JSON
+	Collector *metrics.Collector `json:"-"`
+}
+
+// HasReliablePercentiles reports whether each run recorded enough
+// latencies for the pth percentile to mean anything; see
+// metrics.MinSamplesForPercentile. Results read from JSON written before
+// LatencySamples existed have none, and are reported as unreliable.
+func (r Result) HasReliablePercentiles(p float64) bool {
+	return metrics.Stats{LatencySamples: r.LatencySamples}.HasReliablePercentiles(p)
+}
+
+// LatencySummary holds latency statistics in milliseconds.
+type LatencySummary struct {
+	Min    float64 `json:"min"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P95    float64 `json:"p95"`
+	P99    float64 `json:"p99"`
+	P999   float64 `json:"p999"`
+	Max    float64 `json:"max"`
+}
+
+// collectorShards is the number of metrics collectors a run spreads its
+// clients across.
+const collectorShards = 8
+
+// Run executes a load test for a specific pattern.
+func Run(pattern Pattern, config Config, db *simulator.Database) Result {
+	// Create handler
+	handler := pattern.New(db)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		handler.Shutdown(ctx)
+	}()
+
+	// Warm up before any collector exists, so none of it is measured
+	warmedUp := warmUp(handler, config)
+
+	// Give each group of clients its own collector so they don't all
+	// contend on one mutex; the shards are merged once the run is over
+	shards := make([]*metrics.Collector, min(config.Concurrency, collectorShards))
+	for i := range shards {
+		shards[i] = metrics.NewCollector()
+	}
+
+	// Latencies from the intended send time, if correcting
+	var corrected *metrics.Collector
+	if config.CorrectCO {
+		corrected = metrics.NewCollector()
+	}
+
+	var memBefore runtime.MemStats
+	if config.MeasureMemory {
+		memBefore = readMemStats()
+	}
+	pool, pooled := handler.(poolStatser)
+	var hitsBefore, missesBefore int64
+	if pooled {
+		hitsBefore, missesBefore, _ = pool.GetPoolStats()
+	}
+	runStart := time.Now()
+
+	if len(config.Trace) > 0 {
+		replayTrace(handler, config, runStart, shards[0], corrected)
+	} else {
+		runClients(handler, config, runStart, shards, corrected)
+	}
+	collector := metrics.NewCollector()
+	for _, shard := range shards {
+		shard.Stop()
+		collector.Merge(shard)
+	}
+	if config.MeasureMemory {
+		memAfter := readMemStats()
+		collector.RecordMemory(int64(memAfter.Mallocs-memBefore.Mallocs), int64(memAfter.TotalAlloc-memBefore.TotalAlloc))
+	}
+
+	// Get statistics
+	stats := collector.GetStats()
+
+	// Build the ideal model
+	model := metrics.NewTheoreticalModel(modelParallelism(handler, config),
+		time.Duration(simulator.MinQueryLatency)*time.Millisecond,
+		time.Duration(simulator.MaxQueryLatency)*time.Millisecond)
+
+	// Convert to Result
+	result := Result{
+		PatternName:      pattern.Name,
+		TotalRequests:    stats.TotalRequests,
+		SuccessRequests:  stats.SuccessRequests,
+		ErrorRequests:    stats.ErrorRequests,
+		RejectedRequests: stats.RejectedRequests,
+		Duration:         stats.Duration,
+		RequestsPerSec:   stats.RequestsPerSec,
+		MinLatency:       stats.MinLatency,
+		MeanLatency:      stats.MeanLatency,
+		MedianLatency:    stats.MedianLatency,
+		P95Latency:       stats.P95Latency,
+		P99Latency:       stats.P99Latency,
+		P999Latency:      stats.P999Latency,
+		MaxLatency:       stats.MaxLatency,
+		ErrorRate:        stats.ErrorRate,
+		RejectionRate:    stats.RejectionRate,
+		ErrorsByCategory: stats.ErrorsByCategory,
+		WarmupRequests:   warmedUp,
+		LatencySamples:   stats.LatencySamples,
+
+		MemoryAllocations: stats.MemoryAllocations,
+		MemoryBytes:       stats.MemoryBytes,
+
+		TheoreticalRPS:        model.MaxThroughput(),
+		TheoreticalMinLatency: model.MinLatencyMs(),
+		Efficiency:            model.Efficiency(stats.RequestsPerSec),
+
+		Collector: collector,
+	}
+	if q, ok := handler.(queueUtilizer); ok {
+		peak := q.QueueUtilizationPct()
+		result.PeakQueueUtilization = &peak
+	}
+	if pooled {
+		hits, misses, _ := pool.GetPoolStats()
+		hits, misses = hits-hitsBefore, misses-missesBefore
+		if hits+misses > 0 {
+			rate := float64(hits) / float64(hits+misses) * 100
+			result.PoolHitRate = &rate
+		}
+	}
+	if corrected != nil {
+		co := corrected.GetStats()
+		result.Corrected = &LatencySummary{
+			Min:    co.MinLatency,
+			Mean:   co.MeanLatency,
+			Median: co.MedianLatency,
+			P95:    co.P95Latency,
+			P99:    co.P99Latency,
+			P999:   co.P999Latency,
+			Max:    co.MaxLatency,
+		}
+	}
+	return result
+}
+
+// queueUtilizer is implemented by handlers that queue jobs for a fixed set
+// of workers.
+type queueUtilizer interface {
+	QueueUtilizationPct() float64
+}
+
+// modelParallelism returns how many queries handler can have in flight
+// at once under config: the naive pattern is bounded only by the number
+// of clients (and MaxGoroutines, if set), the others by the smaller of
+// clients and workers. A connection pool, if set, caps every pattern.
+func modelParallelism(handler PatternHandler, config Config) int {
+	parallelism := config.Concurrency
+	if _, naive := handler.(*patterns.NaiveHandler); !naive && config.Workers < parallelism {
+		parallelism = config.Workers
+	} else if naive && config.MaxGoroutines > 0 && config.MaxGoroutines < parallelism {
+		parallelism = config.MaxGoroutines
+	}
+	if config.MaxConnections > 0 && config.MaxConnections < parallelism {
+		parallelism = config.MaxConnections
+	}
+	return parallelism
+}
+
+// poolStatser is implemented by handlers that reuse responses from a
+// sync.Pool; see patterns.OptimizedHandler.GetPoolStats.
+type poolStatser interface {
+	GetPoolStats() (hits, misses int64, hitRate float64)
+}
+
+// runClients sends config.TotalRequests from config.Concurrency clients,
+// each recording in one of shards and, if non-nil, in corrected.
+func runClients(handler PatternHandler, config Config, runStart time.Time, shards []*metrics.Collector, corrected *metrics.Collector) {
+	interval := config.schedule()
+
+	// Calculate requests per worker
+	requestsPerWorker := config.TotalRequests / config.Concurrency
+	remainder := config.TotalRequests % config.Concurrency
+
+	// Run the load test
+	var wg sync.WaitGroup
+
+	for i := 0; i < config.Concurrency; i++ {
+		wg.Add(1)
+		requests := requestsPerWorker
+		if i < remainder {
+			requests++
+		}
+
+		go func(workerID, numRequests int, collector *metrics.Collector) {
+			defer wg.Done()
+
+			// Per-client source so the read/write mix doesn't contend on a lock
+			rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(workerID)))
+
+			for j := 0; j < numRequests; j++ {
+				// Pause like a user would, outside the timed section
+				if j > 0 && config.ThinkTimeMax > 0 {
+					time.Sleep(config.thinkTime(rng))
+				}
+
+				// Build the request before the timer starts
+				patientID, update := config.nextRequest(rng, workerID, j)
+
+				// On a fixed schedule, wait for this request's slot. A
+				// client that has fallen behind sends at once.
+				intended := runStart.Add(time.Duration(j) * interval)
+				if corrected != nil {
+					if wait := time.Until(intended); wait > 0 {
+						time.Sleep(wait)
+					}
+				}
+
+				// Time the request
+				requestStart := time.Now()
+				err := send(handler, patientID, update)
+				latency := time.Since(requestStart)
+
+				// Record metrics, classifying any error by category
+				collector.RecordRequestWithError(latency, err)
+				if corrected != nil {
+					corrected.RecordRequestWithError(time.Since(intended), err)
+				}
+			}
+		}(i, requests, shards[i%len(shards)])
+	}
+
+	// Wait for all workers to complete
+	wg.Wait()
+}
+
+// readMemStats reads the allocation counters after a forced GC, so that
+// samples are taken from a settled heap.
+func readMemStats() runtime.MemStats {
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	return m
+}
+
+// AllocsPerRequest returns the heap allocations per measured request, or
+// zero if memory wasn't measured.
+func (r Result) AllocsPerRequest() float64 {
+	if r.TotalRequests == 0 {
+		return 0
+	}
+	return float64(r.MemoryAllocations) / float64(r.TotalRequests)
+}
+
+// nextRequest picks client workerID's jth request: a read of a patient ID
+// or, with probability WriteRatio, an update to that patient.
+func (c Config) nextRequest(rng *rand.Rand, workerID, j int) (patientID string, update *models.Patient) {
+	// Use a variety of patient IDs
+	patientID = fmt.Sprintf("P%05d", (workerID*1000+j)%10000)
+	if c.WriteRatio > 0 && rng.Float64() < c.WriteRatio {
+		update = models.GeneratePatient(patientID)
+	}
+	return patientID, update
+}
+
+// responseReleaser is implemented by handlers that pool their responses
+// and want them back once the caller is done.
+type responseReleaser interface {
+	ReleaseResponse(response *models.PatientResponse)
+}
+
+// send issues one request to handler, releasing the response if the
+// handler pools them.
+func send(handler PatternHandler, patientID string, update *models.Patient) error {
+	ctx := context.Background()
+
+	var response *models.PatientResponse
+	var err error
+	if update != nil {
+		response, err = handler.HandleUpdate(ctx, update)
+	} else {
+		response, err = handler.HandleRequest(ctx, patientID)
+	}
+
+	if releaser, ok := handler.(responseReleaser); ok && err == nil {
+		releaser.ReleaseResponse(response)
+	}
+	return err
+}
+
+// warmUp sends unmeasured traffic from config.Concurrency clients until
+// WarmupRequests have gone out or WarmupDuration has passed, and returns
+// the number sent.
+func warmUp(handler PatternHandler, config Config) int64 {
+	if config.WarmupRequests <= 0 && config.WarmupDuration <= 0 {
+		return 0
+	}
+
+	deadline := time.Now().Add(config.WarmupDuration)
+	limit := int64(config.WarmupRequests)
+	var sent atomic.Int64
+	var wg sync.WaitGroup
+
+	for i := 0; i < config.Concurrency; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+
+			rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(workerID)))
+			for j := 0; ; j++ {
+				if limit > 0 {
+					if sent.Add(1) > limit {
+						return
+					}
+				} else {
+					if !time.Now().Before(deadline) {
+						return
+					}
+					sent.Add(1)
+				}
+
+				patientID, update := config.nextRequest(rng, workerID, j)
+				send(handler, patientID, update)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if limit > 0 {
+		return min(sent.Load(), limit)
+	}
+	return sent.Load()
+}
+
+// Repeat runs the pattern runs times and returns the mean of each measured
+// field, with 95% confidence intervals for throughput and P99 latency.
+func Repeat(pattern Pattern, config Config, db *simulator.Database, runs int) Result {
+	all := make([]Result, runs)
+	for i := range all {
+		all[i] = Run(pattern, config, db)
+	}
+	return meanResult(all)
+}
+
+// meanResult averages results from repeated runs of one pattern.
+func meanResult(runs []Result) Result {
+	field := func(get func(Result) float64) []float64 {
+		values := make([]float64, len(runs))
+		for i, r := range runs {
+			values[i] = get(r)
+		}
+		return values
+	}
+	mean := func(get func(Result) float64) float64 {
+		return metrics.Mean(field(get))
+	}
+	count := func(get func(Result) int64) int64 {
+		return int64(math.Round(mean(func(r Result) float64 { return float64(get(r)) })))
+	}
+
+	throughput := metrics.ConfidenceInterval95(field(func(r Result) float64 { return r.RequestsPerSec }))
+	p99 := metrics.ConfidenceInterval95(field(func(r Result) float64 { return r.P99Latency }))
+
+	last := runs[len(runs)-1]
+	result := Result{
+		PatternName:      last.PatternName,
+		TotalRequests:    count(func(r Result) int64 { return r.TotalRequests }),
+		SuccessRequests:  count(func(r Result) int64 { return r.SuccessRequests }),
+		ErrorRequests:    count(func(r Result) int64 { return r.ErrorRequests }),
+		RejectedRequests: count(func(r Result) int64 { return r.RejectedRequests }),
+		Duration:         mean(func(r Result) float64 { return r.Duration }),
+		RequestsPerSec:   throughput.Mean,
+		MinLatency:       mean(func(r Result) float64 { return r.MinLatency }),
+		MeanLatency:      mean(func(r Result) float64 { return r.MeanLatency }),
+		MedianLatency:    mean(func(r Result) float64 { return r.MedianLatency }),
+		P95Latency:       mean(func(r Result) float64 { return r.P95Latency }),
+		P99Latency:       p99.Mean,
+		P999Latency:      mean(func(r Result) float64 { return r.P999Latency }),
+		MaxLatency:       mean(func(r Result) float64 { return r.MaxLatency }),
+		ErrorRate:        mean(func(r Result) float64 { return r.ErrorRate }),
+		RejectionRate:    mean(func(r Result) float64 { return r.RejectionRate }),
+		WarmupRequests:   count(func(r Result) int64 { return r.WarmupRequests }),
+		LatencySamples:   count(func(r Result) int64 { return r.LatencySamples }),
+
+		MemoryAllocations: count(func(r Result) int64 { return r.MemoryAllocations }),
+		MemoryBytes:       count(func(r Result) int64 { return r.MemoryBytes }),
+
+		Runs:         len(runs),
+		ThroughputCI: &throughput,
+		P99CI:        &p99,
+
+		TheoreticalRPS:        last.TheoreticalRPS,
+		TheoreticalMinLatency: last.TheoreticalMinLatency,
+		Efficiency:            mean(func(r Result) float64 { return r.Efficiency }),
+	}
+
+	// Error categories are summed: they explain the totals across all runs
+	for _, r := range runs {
+		for category, n := range r.ErrorsByCategory {
+			if result.ErrorsByCategory == nil {
+				result.ErrorsByCategory = make(map[string]int64)
+			}
+			result.ErrorsByCategory[category] += n
+		}
+	}
+
+	// So are the recorded metrics, as Prometheus counters would be
+	for _, r := range runs {
+		if r.Collector == nil {
+			continue
+		}
+		if result.Collector == nil {
+			result.Collector = metrics.NewCollector()
+		}
+		result.Collector.Merge(r.Collector)
+	}
+
+	for _, r := range runs {
+		if r.PeakQueueUtilization == nil {
+			continue
+		}
+		if result.PeakQueueUtilization == nil || *r.PeakQueueUtilization > *result.PeakQueueUtilization {
+			peak := *r.PeakQueueUtilization
+			result.PeakQueueUtilization = &peak
+		}
+	}
+
+	var hitRates []float64
+	for _, r := range runs {
+		if r.PoolHitRate != nil {
+			hitRates = append(hitRates, *r.PoolHitRate)
+		}
+	}
+	if len(hitRates) > 0 {
+		rate := metrics.Mean(hitRates)
+		result.PoolHitRate = &rate
+	}
+
+	if last.Corrected != nil {
+		co := func(get func(*LatencySummary) float64) float64 {
+			return mean(func(r Result) float64 { return get(r.Corrected) })
+		}
+		result.Corrected = &LatencySummary{
+			Min:    co(func(l *LatencySummary) float64 { return l.Min }),
+			Mean:   co(func(l *LatencySummary) float64 { return l.Mean }),
+			Median: co(func(l *LatencySummary) float64 { return l.Median }),
+			P95:    co(func(l *LatencySummary) float64 { return l.P95 }),
+			P99:    co(func(l *LatencySummary) float64 { return l.P99 }),
+			P999:   co(func(l *LatencySummary) float64 { return l.P999 }),
+			Max:    co(func(l *LatencySummary) float64 { return l.Max }),
+		}
+	}
+	return result
+}
+
+// SignificantWinner returns the result with the highest throughput and
+// whether its lead is significant: false when its throughput confidence
+// interval overlaps another pattern's. Results without intervals (single
+// runs) are always reported as significant.
+func SignificantWinner(results []Result) (Result, bool) {
+	best := Winner(results)
+	if best.ThroughputCI == nil {
+		return best, true
+	}
+	for _, r := range results {
+		if r.PatternName == best.PatternName || r.ThroughputCI == nil {
+			continue
+		}
+		if best.ThroughputCI.Overlaps(*r.ThroughputCI) {
+			return best, false
+		}
+	}
+	return best, true
+}
+
+// Winner returns the result with the highest throughput. It returns the
+// zero Result when results is empty.
+func Winner(results []Result) Result {
+	var best Result
+	for i, r := range results {
+		if i == 0 || r.RequestsPerSec > best.RequestsPerSec {
+			best = r
+		}
+	}
+	return best
+}
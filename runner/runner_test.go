@@ -0,0 +1,525 @@
+package runner
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+func validConfig() Config {
+	return Config{
+		TotalRequests:  1000,
+		Concurrency:    100,
+		Workers:        20,
+		QueueSize:      100,
+		EnqueueTimeout: 100 * time.Millisecond,
+	}
+}
+
+// TestValidateRejectsNonPositive sets each numeric flag to zero and to a
+// negative value and checks for a validation error naming that flag.
+func TestValidateRejectsNonPositive(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+
+	tests := []struct {
+		flag string
+		set  func(c *Config, v int)
+	}{
+		{"requests", func(c *Config, v int) { c.TotalRequests = v }},
+		{"concurrency", func(c *Config, v int) { c.Concurrency = v }},
+		{"workers", func(c *Config, v int) { c.Workers = v }},
+		{"queue-size", func(c *Config, v int) { c.QueueSize = v }},
+		{"enqueue-timeout", func(c *Config, v int) { c.EnqueueTimeout = time.Duration(v) }},
+	}
+
+	for _, tc := range tests {
+		for _, v := range []int{0, -1} {
+			config := validConfig()
+			tc.set(&config, v)
+
+			err := config.Validate()
+			if err == nil {
+				t.Errorf("-%s=%d: expected a validation error", tc.flag, v)
+				continue
+			}
+			if !strings.Contains(err.Error(), "-"+tc.flag+" ") {
+				t.Errorf("-%s=%d: error %q does not name the flag", tc.flag, v, err)
+			}
+		}
+	}
+}
+
+// TestValidateWriteRatioRange checks -write-ratio is confined to [0, 1].
+func TestValidateWriteRatioRange(t *testing.T) {
+	for _, ratio := range []float64{0, 0.25, 1} {
+		config := validConfig()
+		config.WriteRatio = ratio
+		if err := config.Validate(); err != nil {
+			t.Errorf("-write-ratio=%v: unexpected error: %v", ratio, err)
+		}
+	}
+
+	for _, ratio := range []float64{-0.1, 1.5} {
+		config := validConfig()
+		config.WriteRatio = ratio
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "-write-ratio") {
+			t.Errorf("-write-ratio=%v: expected a validation error naming the flag, got %v", ratio, err)
+		}
+	}
+}
+
+// TestValidateListsEveryOffendingFlag checks that multiple bad flags are
+// all reported together.
+func TestValidateListsEveryOffendingFlag(t *testing.T) {
+	config := validConfig()
+	config.TotalRequests = 0
+	config.Concurrency = -5
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("expected a validation error")
+	}
+	for _, flag := range []string{"-requests", "-concurrency"} {
+		if !strings.Contains(err.Error(), flag) {
+			t.Errorf("error %q does not mention %s", err, flag)
+		}
+	}
+}
+
+func TestSelectPatterns(t *testing.T) {
+	selected, err := validConfig().Select("optimized", "naive")
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if len(selected) != 2 || selected[0].Name != "Optimized" || selected[1].Name != "Naive" {
+		t.Errorf("Select returned %+v, want Optimized then Naive", selected)
+	}
+
+	if _, err := validConfig().Select("naive", "bogus"); err == nil {
+		t.Error("expected an error for an unknown pattern")
+	}
+}
+
+func TestWinnerHasHighestThroughput(t *testing.T) {
+	results := []Result{
+		{PatternName: "Naive", RequestsPerSec: 800},
+		{PatternName: "Worker Pool", RequestsPerSec: 250},
+		{PatternName: "Optimized", RequestsPerSec: 900},
+	}
+	if got := Winner(results).PatternName; got != "Optimized" {
+		t.Errorf("Winner = %q, want Optimized", got)
+	}
+	if got := Winner(nil).PatternName; got != "" {
+		t.Errorf("Winner(nil) = %q, want empty", got)
+	}
+}
+
+// stallHandler answers in about a millisecond, except that one request
+// stalls for stall.
+type stallHandler struct {
+	calls   atomic.Int64
+	stallAt int64
+	stall   time.Duration
+}
+
+func (h *stallHandler) HandleRequest(ctx context.Context, patientID string) (*models.PatientResponse, error) {
+	if h.calls.Add(1) == h.stallAt {
+		time.Sleep(h.stall)
+	} else {
+		time.Sleep(time.Millisecond)
+	}
+	return &models.PatientResponse{}, nil
+}
+
+func (h *stallHandler) HandleUpdate(ctx context.Context, patient *models.Patient) (*models.PatientResponse, error) {
+	return h.HandleRequest(ctx, patient.ID)
+}
+
+func (h *stallHandler) GetName() string                    { return "Stall" }
+func (h *stallHandler) Shutdown(ctx context.Context) error { return nil }
+
+// TestCorrectCOChargesStallToDelayedRequests runs one client on a 2ms
+// schedule against a server that stalls once for 200ms. Service time hides
+// the stall behind a single slow request; the corrected latency counts
+// every request that should have been sent during it.
+func TestCorrectCOChargesStallToDelayedRequests(t *testing.T) {
+	config := validConfig()
+	config.TotalRequests = 200
+	config.Concurrency = 1
+	config.CorrectCO = true
+	config.ScheduleInterval = 2 * time.Millisecond
+
+	stall := Pattern{Key: "stall", Name: "Stall", New: func(*simulator.Database) PatternHandler {
+		return &stallHandler{stallAt: 10, stall: 200 * time.Millisecond}
+	}}
+	result := Run(stall, config, nil)
+
+	if result.Corrected == nil {
+		t.Fatal("no corrected latency reported")
+	}
+	if result.MaxLatency < 200 {
+		t.Errorf("uncorrected max = %.2fms, want the 200ms stall", result.MaxLatency)
+	}
+	if result.P99Latency > 50 {
+		t.Errorf("uncorrected p99 = %.2fms; the single stall should fall outside it", result.P99Latency)
+	}
+	if result.Corrected.P99 < 100 || result.Corrected.P99 < 10*result.P99Latency {
+		t.Errorf("corrected p99 = %.2fms, want well above uncorrected %.2fms",
+			result.Corrected.P99, result.P99Latency)
+	}
+}
+
+// TestRepeatAveragesCorrectedLatency checks that every percentile of the
+// corrected latency survives averaging over repeated runs.
+func TestRepeatAveragesCorrectedLatency(t *testing.T) {
+	config := validConfig()
+	config.TotalRequests = 100
+	config.Concurrency = 1
+	config.CorrectCO = true
+	config.ScheduleInterval = 2 * time.Millisecond
+
+	stall := Pattern{Key: "stall", Name: "Stall", New: func(*simulator.Database) PatternHandler {
+		return &stallHandler{stallAt: 10, stall: 100 * time.Millisecond}
+	}}
+	result := Repeat(stall, config, nil, 2)
+
+	if result.Corrected == nil {
+		t.Fatal("no corrected latency reported")
+	}
+	c := result.Corrected
+	if c.P999 < c.P99 || c.Max < c.P999 {
+		t.Errorf("corrected p99 %.2fms, p99.9 %.2fms, max %.2fms; want them in order",
+			c.P99, c.P999, c.Max)
+	}
+	if c.P999 < 50 {
+		t.Errorf("corrected p99.9 = %.2fms, want the 100ms stall reflected", c.P999)
+	}
+}
+
+func TestRunWithoutCorrectCOReportsServiceTimeOnly(t *testing.T) {
+	config := validConfig()
+	config.TotalRequests = 20
+	config.Concurrency = 2
+
+	fast := Pattern{Key: "fast", Name: "Fast", New: func(*simulator.Database) PatternHandler {
+		return &stallHandler{}
+	}}
+	result := Run(fast, config, nil)
+	if result.Corrected != nil {
+		t.Errorf("corrected latency reported without CorrectCO: %+v", result.Corrected)
+	}
+	if result.TotalRequests != 20 || result.MinLatency <= 0 {
+		t.Errorf("total %d, min latency %.2fms; want 20 requests with latency recorded",
+			result.TotalRequests, result.MinLatency)
+	}
+}
+
+// TestThinkTimeExcludedFromLatency checks the pause between requests
+// stretches the run but never shows up in the measured latency.
+func TestThinkTimeExcludedFromLatency(t *testing.T) {
+	config := validConfig()
+	config.TotalRequests = 10
+	config.Concurrency = 1
+	fast := Pattern{Key: "fast", Name: "Fast", New: func(*simulator.Database) PatternHandler {
+		return &stallHandler{}
+	}}
+
+	baseline := Run(fast, config, nil)
+
+	config.ThinkTimeMin = 20 * time.Millisecond
+	config.ThinkTimeMax = 30 * time.Millisecond
+	paced := Run(fast, config, nil)
+
+	// Nine pauses between ten requests, at least 20ms each
+	if minDuration := 0.180; paced.Duration < minDuration {
+		t.Errorf("duration = %.3fs, want at least %.3fs", paced.Duration, minDuration)
+	}
+	if paced.Duration <= baseline.Duration {
+		t.Errorf("think time did not lengthen the run: %.3fs vs %.3fs", paced.Duration, baseline.Duration)
+	}
+	if paced.MaxLatency >= 20 {
+		t.Errorf("max latency = %.2fms; think time leaked into the measurement", paced.MaxLatency)
+	}
+}
+
+func TestParseThinkTime(t *testing.T) {
+	tests := []struct {
+		value     string
+		low, high time.Duration
+	}{
+		{"", 0, 0},
+		{"20ms", 20 * time.Millisecond, 20 * time.Millisecond},
+		{"10ms-50ms", 10 * time.Millisecond, 50 * time.Millisecond},
+	}
+	for _, tc := range tests {
+		low, high, err := ParseThinkTime(tc.value)
+		if err != nil || low != tc.low || high != tc.high {
+			t.Errorf("ParseThinkTime(%q) = %v, %v, %v; want %v, %v", tc.value, low, high, err, tc.low, tc.high)
+		}
+	}
+
+	for _, value := range []string{"fast", "50ms-10ms", "10ms-", "-5ms"} {
+		if _, _, err := ParseThinkTime(value); err == nil {
+			t.Errorf("ParseThinkTime(%q): expected an error", value)
+		}
+	}
+}
+
+// TestWarmupExcludedFromStats checks warm-up requests reach the handler
+// but not the measured results.
+func TestWarmupExcludedFromStats(t *testing.T) {
+	config := validConfig()
+	config.TotalRequests = 20
+	config.Concurrency = 4
+	config.WarmupRequests = 50
+
+	handler := &stallHandler{}
+	counting := Pattern{Key: "count", Name: "Count", New: func(*simulator.Database) PatternHandler {
+		return handler
+	}}
+	result := Run(counting, config, nil)
+
+	if got := handler.calls.Load(); got != 70 {
+		t.Errorf("handler saw %d requests, want 50 warm-up + 20 measured", got)
+	}
+	if result.TotalRequests != 20 || result.SuccessRequests != 20 {
+		t.Errorf("measured %d requests (%d successful), want only the 20 after warm-up",
+			result.TotalRequests, result.SuccessRequests)
+	}
+	if result.WarmupRequests != 50 {
+		t.Errorf("warm-up = %d, want 50", result.WarmupRequests)
+	}
+
+	// A duration-based warm-up sends as much as fits in the window
+	config.WarmupRequests = 0
+	config.WarmupDuration = 20 * time.Millisecond
+	result = Run(counting, config, nil)
+	if result.WarmupRequests == 0 || result.TotalRequests != 20 {
+		t.Errorf("warm-up %d, measured %d; want some warm-up and 20 measured",
+			result.WarmupRequests, result.TotalRequests)
+	}
+}
+
+func TestParseWarmup(t *testing.T) {
+	tests := []struct {
+		value    string
+		requests int
+		duration time.Duration
+	}{
+		{"", 0, 0},
+		{"500", 500, 0},
+		{"2s", 0, 2 * time.Second},
+	}
+	for _, tc := range tests {
+		requests, duration, err := ParseWarmup(tc.value)
+		if err != nil || requests != tc.requests || duration != tc.duration {
+			t.Errorf("ParseWarmup(%q) = %d, %v, %v; want %d, %v", tc.value, requests, duration, err, tc.requests, tc.duration)
+		}
+	}
+
+	if _, _, err := ParseWarmup("soon"); err == nil {
+		t.Error("expected an error for an invalid warm-up")
+	}
+}
+
+func TestMeanResult(t *testing.T) {
+	runs := []Result{
+		{PatternName: "Naive", TotalRequests: 100, RequestsPerSec: 90, P99Latency: 110, MeanLatency: 70,
+			ErrorsByCategory: map[string]int64{"timeout": 1}},
+		{PatternName: "Naive", TotalRequests: 100, RequestsPerSec: 100, P99Latency: 100, MeanLatency: 80},
+		{PatternName: "Naive", TotalRequests: 100, RequestsPerSec: 110, P99Latency: 90, MeanLatency: 90,
+			ErrorsByCategory: map[string]int64{"timeout": 2}},
+	}
+
+	got := meanResult(runs)
+	if got.Runs != 3 || got.TotalRequests != 100 || got.MeanLatency != 80 {
+		t.Errorf("runs %d, total %d, mean latency %v; want 3, 100, 80", got.Runs, got.TotalRequests, got.MeanLatency)
+	}
+	if got.RequestsPerSec != 100 || got.P99Latency != 100 {
+		t.Errorf("req/s %v, p99 %v; want the means 100 and 100", got.RequestsPerSec, got.P99Latency)
+	}
+
+	// Standard deviation 10 over 3 runs: t(2) = 4.303, so 4.303 * 10 / sqrt(3)
+	want := 4.303 * 10 / math.Sqrt(3)
+	if got.ThroughputCI == nil || math.Abs(got.ThroughputCI.HalfWidth-want) > 1e-9 {
+		t.Errorf("throughput CI = %+v, want ± %v", got.ThroughputCI, want)
+	}
+	if got.P99CI == nil || math.Abs(got.P99CI.HalfWidth-want) > 1e-9 {
+		t.Errorf("p99 CI = %+v, want ± %v", got.P99CI, want)
+	}
+	if got.ErrorsByCategory["timeout"] != 3 {
+		t.Errorf("timeouts = %d, want 3 summed across runs", got.ErrorsByCategory["timeout"])
+	}
+	if got.PeakQueueUtilization != nil {
+		t.Errorf("peak queue utilization = %v for a pattern without a queue, want nil", *got.PeakQueueUtilization)
+	}
+
+	peaks := []float64{40, 90, 60}
+	hitRates := []float64{90, 95, 100}
+	for i := range runs {
+		runs[i].PeakQueueUtilization = &peaks[i]
+		runs[i].PoolHitRate = &hitRates[i]
+	}
+	got = meanResult(runs)
+	if got.PeakQueueUtilization == nil || *got.PeakQueueUtilization != 90 {
+		t.Errorf("peak queue utilization = %v, want the highest run's 90", got.PeakQueueUtilization)
+	}
+	if got.PoolHitRate == nil || *got.PoolHitRate != 95 {
+		t.Errorf("pool hit rate = %v, want the mean 95", got.PoolHitRate)
+	}
+}
+
+// TestRunReportsPoolHitRate checks the optimized pattern's result carries
+// its sync.Pool hit rate, matching the handler's own GetPoolStats when
+// nothing ran before the measured requests.
+func TestRunReportsPoolHitRate(t *testing.T) {
+	config := validConfig()
+	config.TotalRequests = 500
+	config.Concurrency = 20
+	config.EnqueueTimeout = time.Second
+	db := simulator.NewDatabase(1, 2, 0, simulator.WithCorpus(100, simulator.DefaultCorpusSeed))
+
+	var handler *patterns.OptimizedHandler
+	optimized := Pattern{Key: "optimized", Name: "Optimized", New: func(db *simulator.Database) PatternHandler {
+		handler = patterns.NewOptimizedHandler(db, patterns.WorkerPoolConfig{Workers: config.Workers, QueueSize: config.QueueSize, EnqueueTimeout: config.EnqueueTimeout})
+		return handler
+	}}
+	result := Run(optimized, config, db)
+
+	_, _, want := handler.GetPoolStats()
+	if result.PoolHitRate == nil || math.Abs(*result.PoolHitRate-want) > 1e-9 {
+		t.Fatalf("pool hit rate = %v, want GetPoolStats' %.2f%%", result.PoolHitRate, want)
+	}
+	if want <= 0 {
+		t.Errorf("hit rate %.2f%% after %d requests; want responses reused", want, config.TotalRequests)
+	}
+
+	selected, err := config.Select("workerpool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := Run(selected[0], config, db); r.PoolHitRate != nil {
+		t.Errorf("%s reported pool hit rate %v, want none", r.PatternName, *r.PoolHitRate)
+	}
+}
+
+func TestModelParallelism(t *testing.T) {
+	naive := patterns.NewNaiveHandler(simulator.NewDatabase(1, 2, 0))
+	pooled := &stallHandler{}
+
+	tests := []struct {
+		name    string
+		handler PatternHandler
+		modify  func(*Config)
+		want    int
+	}{
+		{"pool bounded by workers", pooled, func(c *Config) { c.Concurrency, c.Workers = 50, 20 }, 20},
+		{"pool bounded by clients", pooled, func(c *Config) { c.Concurrency, c.Workers = 10, 20 }, 10},
+		{"pool capped by connections", pooled, func(c *Config) { c.Concurrency, c.Workers, c.MaxConnections = 50, 20, 5 }, 5},
+		{"naive bounded by clients", naive, func(c *Config) { c.Concurrency, c.Workers = 50, 20 }, 50},
+		{"naive capped by goroutines", naive, func(c *Config) { c.Concurrency, c.MaxGoroutines = 50, 30 }, 30},
+		{"naive capped by connections", naive, func(c *Config) { c.Concurrency, c.MaxGoroutines, c.MaxConnections = 50, 30, 8 }, 8},
+		{"connections above the bound", pooled, func(c *Config) { c.Concurrency, c.Workers, c.MaxConnections = 50, 20, 40 }, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig()
+			tt.modify(&config)
+			if got := modelParallelism(tt.handler, config); got != tt.want {
+				t.Errorf("modelParallelism = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunReportsPeakQueueUtilization checks that queue-based patterns
+// report their queue's high-water mark and the rest report none.
+func TestRunReportsPeakQueueUtilization(t *testing.T) {
+	config := validConfig()
+	config.TotalRequests = 500
+	config.Concurrency = 3 * config.Workers
+	config.EnqueueTimeout = time.Second
+	db := simulator.NewDatabase(1, 2, 0, simulator.WithCorpus(100, simulator.DefaultCorpusSeed))
+	selected, err := config.Select("naive", "workerpool", "optimized")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if naive := Run(selected[0], config, db); naive.PeakQueueUtilization != nil {
+		t.Errorf("naive reported peak queue utilization %v, want none", *naive.PeakQueueUtilization)
+	}
+	for _, pattern := range selected[1:] {
+		r := Run(pattern, config, db)
+		if r.PeakQueueUtilization == nil || *r.PeakQueueUtilization <= 0 || *r.PeakQueueUtilization > 100 {
+			t.Errorf("%s: peak queue utilization %v, want a percentage above 0 with more clients than workers",
+				r.PatternName, r.PeakQueueUtilization)
+		}
+	}
+}
+
+func TestSignificantWinner(t *testing.T) {
+	result := func(name string, mean, half float64) Result {
+		return Result{PatternName: name, RequestsPerSec: mean, ThroughputCI: &metrics.Interval{Mean: mean, HalfWidth: half}}
+	}
+
+	best, significant := SignificantWinner([]Result{result("Naive", 100, 5), result("Optimized", 120, 5)})
+	if best.PatternName != "Optimized" || !significant {
+		t.Errorf("separated intervals: %s, significant %v; want Optimized, true", best.PatternName, significant)
+	}
+
+	best, significant = SignificantWinner([]Result{result("Naive", 100, 15), result("Optimized", 120, 15)})
+	if best.PatternName != "Optimized" || significant {
+		t.Errorf("overlapping intervals: %s, significant %v; want Optimized, false", best.PatternName, significant)
+	}
+
+	// Single runs carry no interval and are compared on the number alone
+	_, significant = SignificantWinner([]Result{{PatternName: "A", RequestsPerSec: 100}, {PatternName: "B", RequestsPerSec: 101}})
+	if !significant {
+		t.Error("single runs should always report a significant winner")
+	}
+}
+
+// TestMeasureMemory checks allocations are only sampled when asked for, and
+// that they show the optimized pattern's pooled responses paying off
+// against the plain worker pool.
+func TestMeasureMemory(t *testing.T) {
+	config := validConfig()
+	config.TotalRequests = 2000
+	config.Concurrency = 20
+	config.EnqueueTimeout = time.Second
+	db := simulator.NewDatabase(1, 2, 0, simulator.WithCorpus(100, simulator.DefaultCorpusSeed))
+	selected, err := config.Select("workerpool", "optimized")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result := Run(selected[0], config, db); result.MemoryAllocations != 0 || result.MemoryBytes != 0 {
+		t.Errorf("memory reported without MeasureMemory: %d allocations, %d bytes", result.MemoryAllocations, result.MemoryBytes)
+	}
+
+	config.MeasureMemory = true
+	pool := Run(selected[0], config, db)
+	optimized := Run(selected[1], config, db)
+
+	for _, r := range []Result{pool, optimized} {
+		if r.MemoryAllocations <= 0 || r.MemoryBytes <= 0 || r.Collector.GetStats().MemoryAllocations != r.MemoryAllocations {
+			t.Fatalf("%s: %d allocations, %d bytes; want both recorded in the result and its collector",
+				r.PatternName, r.MemoryAllocations, r.MemoryBytes)
+		}
+	}
+	if optimized.AllocsPerRequest() >= pool.AllocsPerRequest() {
+		t.Errorf("optimized made %.1f allocs/request, worker pool %.1f; want fewer with pooled responses",
+			optimized.AllocsPerRequest(), pool.AllocsPerRequest())
+	}
+}
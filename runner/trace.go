@@ -0,0 +1,149 @@
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// TraceEntry is one request in a recorded trace.
+type TraceEntry struct {
+	Offset    time.Duration // When the request arrived, from the first entry
+	PatientID string
+	Write     bool // An update to the patient rather than a read
+}
+
+// LoadTrace reads a trace file; see ParseTrace for the format.
+func LoadTrace(path string) ([]TraceEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading trace: %w", err)
+	}
+	defer f.Close()
+
+	trace, err := ParseTrace(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return trace, nil
+}
+
+// ParseTrace parses a recorded request trace: one request per line, as a
+// timestamp, a patient ID and optionally "read" (the default) or "write",
+// separated by spaces:
+//
+//	2024-03-01T09:00:00.000Z P00042
+//	2024-03-01T09:00:00.120Z P00007 write
+//
+// Timestamps are RFC 3339, as in most access logs, or offsets from the
+// start of the trace such as "0s" and "120ms"; each trace must use one
+// kind. They must not go backwards. Any further fields, such as the status
+// and latency in a trace recorded by the server's -trace-out, are ignored,
+// as are blank lines and lines starting with "#".
+func ParseTrace(r io.Reader) ([]TraceEntry, error) {
+	var (
+		trace    []TraceEntry
+		start    time.Time
+		absolute bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: want a timestamp, a patient ID and optionally an operation, got %q", line, text)
+		}
+
+		entry := TraceEntry{PatientID: fields[1]}
+		if len(fields) >= 3 {
+			switch fields[2] {
+			case "read":
+			case "write":
+				entry.Write = true
+			default:
+				return nil, fmt.Errorf("line %d: unknown operation %q, want read or write", line, fields[2])
+			}
+		}
+
+		if at, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+			if len(trace) == 0 {
+				start, absolute = at, true
+			} else if !absolute {
+				return nil, fmt.Errorf("line %d: timestamp %s in a trace of offsets", line, fields[0])
+			}
+			entry.Offset = at.Sub(start)
+		} else if offset, err := time.ParseDuration(fields[0]); err == nil {
+			if absolute {
+				return nil, fmt.Errorf("line %d: offset %s in a trace of timestamps", line, fields[0])
+			}
+			entry.Offset = offset
+		} else {
+			return nil, fmt.Errorf("line %d: invalid timestamp %q: want RFC 3339 or a duration", line, fields[0])
+		}
+
+		if entry.Offset < 0 || (len(trace) > 0 && entry.Offset < trace[len(trace)-1].Offset) {
+			return nil, fmt.Errorf("line %d: timestamp %s goes backwards", line, fields[0])
+		}
+		trace = append(trace, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(trace) == 0 {
+		return nil, fmt.Errorf("trace has no requests")
+	}
+	return trace, nil
+}
+
+// replayTrace sends config.Trace to handler, each request at its offset
+// from runStart divided by TraceSpeed. It is an open loop: requests go out
+// on time however many are still outstanding, so Concurrency doesn't
+// apply, and a slow server can't hold back later arrivals. Latency is
+// recorded in collector, and in corrected, if non-nil, from each
+// request's scheduled time.
+func replayTrace(handler PatternHandler, config Config, runStart time.Time, collector, corrected *metrics.Collector) {
+	speed := config.TraceSpeed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	var wg sync.WaitGroup
+	for _, entry := range config.Trace {
+		intended := runStart.Add(time.Duration(float64(entry.Offset) / speed))
+		if wait := time.Until(intended); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		var update *models.Patient
+		if entry.Write {
+			update = models.GeneratePatient(entry.PatientID)
+		}
+
+		wg.Add(1)
+		go func(patientID string) {
+			defer wg.Done()
+
+			requestStart := time.Now()
+			err := send(handler, patientID, update)
+			latency := time.Since(requestStart)
+
+			collector.RecordRequestWithError(latency, err)
+			if corrected != nil {
+				corrected.RecordRequestWithError(time.Since(intended), err)
+			}
+		}(entry.PatientID)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,146 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+func TestParseTrace(t *testing.T) {
+	const absolute = `# Captured from the gateway access log
+2024-03-01T09:00:00.000Z P00042
+2024-03-01T09:00:00.120Z P00007 write
+
+2024-03-01T09:00:01.5Z P00042 read 200 53.2ms
+`
+	trace, err := ParseTrace(strings.NewReader(absolute))
+	if err != nil {
+		t.Fatalf("ParseTrace: %v", err)
+	}
+	want := []TraceEntry{
+		{Offset: 0, PatientID: "P00042"},
+		{Offset: 120 * time.Millisecond, PatientID: "P00007", Write: true},
+		{Offset: 1500 * time.Millisecond, PatientID: "P00042"},
+	}
+	if len(trace) != len(want) {
+		t.Fatalf("parsed %+v, want %+v", trace, want)
+	}
+	for i := range want {
+		if trace[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, trace[i], want[i])
+		}
+	}
+
+	offsets, err := ParseTrace(strings.NewReader("0s P00001\n250ms P00002 write\n"))
+	if err != nil {
+		t.Fatalf("ParseTrace with offsets: %v", err)
+	}
+	if offsets[1] != (TraceEntry{Offset: 250 * time.Millisecond, PatientID: "P00002", Write: true}) {
+		t.Errorf("offset entry = %+v", offsets[1])
+	}
+
+	bad := map[string]string{
+		"empty":           "# nothing but a comment\n",
+		"backwards":       "1s P00001\n500ms P00002\n",
+		"mixed kinds":     "2024-03-01T09:00:00Z P00001\n1s P00002\n",
+		"unknown op":      "0s P00001 delete\n",
+		"missing ID":      "0s\n",
+		"bad timestamp":   "yesterday P00001\n",
+		"negative offset": "-1s P00001\n",
+	}
+	for name, input := range bad {
+		if _, err := ParseTrace(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: ParseTrace accepted %q", name, input)
+		}
+	}
+}
+
+// traceHandler records each request it receives and when.
+type traceHandler struct {
+	start time.Time
+
+	mu       sync.Mutex
+	received []tracedRequest
+}
+
+type tracedRequest struct {
+	patientID string
+	write     bool
+	at        time.Duration // Since start
+}
+
+func (h *traceHandler) record(patientID string, write bool) (*models.PatientResponse, error) {
+	h.mu.Lock()
+	h.received = append(h.received, tracedRequest{patientID, write, time.Since(h.start)})
+	h.mu.Unlock()
+	return &models.PatientResponse{}, nil
+}
+
+func (h *traceHandler) HandleRequest(ctx context.Context, patientID string) (*models.PatientResponse, error) {
+	return h.record(patientID, false)
+}
+
+func (h *traceHandler) HandleUpdate(ctx context.Context, patient *models.Patient) (*models.PatientResponse, error) {
+	return h.record(patient.ID, true)
+}
+
+func (h *traceHandler) GetName() string                    { return "Trace" }
+func (h *traceHandler) Shutdown(ctx context.Context) error { return nil }
+
+// TestReplayTraceHonoursArrivalTimes replays a tiny trace at double speed
+// and checks each request arrives in order, as the right operation, at
+// half its recorded offset.
+func TestReplayTraceHonoursArrivalTimes(t *testing.T) {
+	const tolerance = 20 * time.Millisecond
+	trace := []TraceEntry{
+		{Offset: 0, PatientID: "P00042"},
+		{Offset: 80 * time.Millisecond, PatientID: "P00007", Write: true},
+		{Offset: 160 * time.Millisecond, PatientID: "P00042"},
+		{Offset: 320 * time.Millisecond, PatientID: "P00100"},
+	}
+
+	config := validConfig()
+	config.TotalRequests = len(trace)
+	config.Trace = trace
+	config.TraceSpeed = 2
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	handler := &traceHandler{}
+	replay := Pattern{Key: "trace", Name: "Trace", New: func(*simulator.Database) PatternHandler {
+		handler.start = time.Now()
+		return handler
+	}}
+	result := Run(replay, config, nil)
+
+	if result.TotalRequests != int64(len(trace)) {
+		t.Errorf("TotalRequests = %d, want %d", result.TotalRequests, len(trace))
+	}
+	if len(handler.received) != len(trace) {
+		t.Fatalf("handler received %+v, want %d requests", handler.received, len(trace))
+	}
+	for i, entry := range trace {
+		got := handler.received[i]
+		if got.patientID != entry.PatientID || got.write != entry.Write {
+			t.Errorf("request %d = %s (write %v), want %s (write %v)", i, got.patientID, got.write, entry.PatientID, entry.Write)
+		}
+		want := entry.Offset / 2
+		if got.at < want || got.at > want+tolerance {
+			t.Errorf("request %d arrived at %v, want %v (tolerance %v)", i, got.at, want, tolerance)
+		}
+	}
+}
+
+func TestValidateTraceSpeed(t *testing.T) {
+	config := validConfig()
+	config.Trace = []TraceEntry{{PatientID: "P00001"}}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "-trace-speed") {
+		t.Errorf("Validate with a zero -trace-speed = %v, want a -trace-speed error", err)
+	}
+}
@@ -0,0 +1,176 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// CachingDatabase is a read-through cache in front of a Database, like an
+// application-side cache of patient records. A read of a cached patient
+// (a warm hit) returns at once; any other read (a cold miss) pays the
+// database's full latency and fills the cache.
+//
+// Writes go through to the database and replace the cached record, so
+// reads never see stale data. Failed reads aren't cached.
+type CachingDatabase struct {
+	db *Database
+
+	mu      sync.RWMutex
+	entries map[string]*models.Patient
+
+	statsMu sync.Mutex
+	stats   CacheStats
+}
+
+// CacheStats separates the reads a cache served from those that went to
+// the database, so steady-state performance can be measured apart from
+// the cost of filling the cache. Warm doesn't count towards either.
+type CacheStats struct {
+	Hits        int64
+	Misses      int64
+	HitLatency  time.Duration // Total latency of hits
+	MissLatency time.Duration // Total latency of misses, failed ones included
+}
+
+// MeanHitLatency returns the average latency of a warm hit.
+func (s CacheStats) MeanHitLatency() time.Duration {
+	if s.Hits == 0 {
+		return 0
+	}
+	return s.HitLatency / time.Duration(s.Hits)
+}
+
+// MeanMissLatency returns the average latency of a cold miss.
+func (s CacheStats) MeanMissLatency() time.Duration {
+	if s.Misses == 0 {
+		return 0
+	}
+	return s.MissLatency / time.Duration(s.Misses)
+}
+
+// HitRate returns the fraction of reads served from the cache.
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// NewCachingDatabase returns an empty cache in front of db.
+func NewCachingDatabase(db *Database) *CachingDatabase {
+	return &CachingDatabase{db: db, entries: make(map[string]*models.Patient)}
+}
+
+// QueryPatient returns the cached record for patientID, or reads it from
+// the database and caches it.
+func (c *CachingDatabase) QueryPatient(ctx context.Context, patientID string) (*models.Patient, error) {
+	start := c.db.clock.Now()
+
+	if patient := c.lookup(patientID); patient != nil {
+		c.record(true, c.db.clock.Now().Sub(start))
+		return patient, nil
+	}
+
+	patient, err := c.db.QueryPatient(ctx, patientID)
+	c.record(false, c.db.clock.Now().Sub(start))
+	if err != nil {
+		return nil, err
+	}
+	c.store(patient)
+	return patient, nil
+}
+
+// UpdatePatient writes patient to the database and, once the write
+// succeeds, to the cache.
+func (c *CachingDatabase) UpdatePatient(ctx context.Context, patient *models.Patient) error {
+	if err := c.db.UpdatePatient(ctx, patient); err != nil {
+		return err
+	}
+	c.store(patient)
+	return nil
+}
+
+// Warm reads every patient in ids that isn't cached yet from the
+// database, so that later reads of them are hits. Run it before
+// measurement to benchmark a cache in its steady state. It stops at the
+// first failed read; the patients read before it stay cached.
+func (c *CachingDatabase) Warm(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		if c.lookup(id) != nil {
+			continue
+		}
+		patient, err := c.db.QueryPatient(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to warm patient %s: %w", id, err)
+		}
+		c.store(patient)
+	}
+	return nil
+}
+
+// Len returns the number of cached patients.
+func (c *CachingDatabase) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// Stats returns the hits and misses since the cache was created or last
+// reset.
+func (c *CachingDatabase) Stats() CacheStats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.stats
+}
+
+// ResetStats clears the hit and miss counts but keeps the cached
+// records, so a benchmark can warm the cache and then measure from zero.
+func (c *CachingDatabase) ResetStats() {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	c.stats = CacheStats{}
+}
+
+// Database returns the database behind the cache.
+func (c *CachingDatabase) Database() *Database {
+	return c.db
+}
+
+// lookup returns a copy of the cached record for patientID, or nil.
+func (c *CachingDatabase) lookup(patientID string) *models.Patient {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cached, ok := c.entries[patientID]
+	if !ok {
+		return nil
+	}
+	return cached.Clone()
+}
+
+// store caches a copy of patient, so later changes by the caller don't
+// leak into the cache.
+func (c *CachingDatabase) store(patient *models.Patient) {
+	cached := patient.Clone()
+	c.mu.Lock()
+	c.entries[patient.ID] = cached
+	c.mu.Unlock()
+}
+
+// record counts one read as a hit or a miss.
+func (c *CachingDatabase) record(hit bool, latency time.Duration) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	if hit {
+		c.stats.Hits++
+		c.stats.HitLatency += latency
+	} else {
+		c.stats.Misses++
+		c.stats.MissLatency += latency
+	}
+}
@@ -0,0 +1,108 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCachingDatabaseWarmedQueriesAreHits(t *testing.T) {
+	const (
+		minLatency = 20 * time.Millisecond
+		hitBudget  = 5 * time.Millisecond
+	)
+	cache := NewCachingDatabase(NewDatabase(20, 30, 0))
+	ctx := context.Background()
+
+	ids := make([]string, 5)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("P%05d", i)
+	}
+	if err := cache.Warm(ctx, ids); err != nil {
+		t.Fatalf("Warm: %v", err)
+	}
+	if cache.Len() != len(ids) {
+		t.Fatalf("Len after Warm = %d, want %d", cache.Len(), len(ids))
+	}
+	if stats := cache.Stats(); stats != (CacheStats{}) {
+		t.Errorf("Stats after Warm = %+v, want warming left out", stats)
+	}
+	queries, _ := cache.Database().GetStats()
+
+	for _, id := range ids {
+		start := time.Now()
+		patient, err := cache.QueryPatient(ctx, id)
+		if err != nil {
+			t.Fatalf("QueryPatient(%s): %v", id, err)
+		}
+		if elapsed := time.Since(start); elapsed > hitBudget {
+			t.Errorf("QueryPatient(%s) took %v, want a hit under %v", id, elapsed, hitBudget)
+		}
+		if patient.ID != id {
+			t.Errorf("QueryPatient(%s) returned patient %s", id, patient.ID)
+		}
+	}
+	if after, _ := cache.Database().GetStats(); after != queries {
+		t.Errorf("warm reads made %d database queries, want none", after-queries)
+	}
+
+	// A patient left out of the warm set is a cold miss
+	if _, err := cache.QueryPatient(ctx, "P99999"); err != nil {
+		t.Fatalf("QueryPatient(P99999): %v", err)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != int64(len(ids)) || stats.Misses != 1 {
+		t.Errorf("Stats = %d hits, %d misses; want %d hits, 1 miss", stats.Hits, stats.Misses, len(ids))
+	}
+	if stats.MeanHitLatency() > hitBudget {
+		t.Errorf("MeanHitLatency = %v, want under %v", stats.MeanHitLatency(), hitBudget)
+	}
+	if stats.MeanMissLatency() < minLatency {
+		t.Errorf("MeanMissLatency = %v, want at least the database's %v", stats.MeanMissLatency(), minLatency)
+	}
+}
+
+func TestCachingDatabaseWritesReplaceCachedRecords(t *testing.T) {
+	db := NewDatabase(1, 2, 0)
+	db.SetWriteProfile(1, 2, 0)
+	cache := NewCachingDatabase(db)
+	ctx := context.Background()
+
+	patient, err := cache.QueryPatient(ctx, "P00001")
+	if err != nil {
+		t.Fatalf("QueryPatient: %v", err)
+	}
+	patient.FirstName = "Updated"
+	if err := cache.UpdatePatient(ctx, patient); err != nil {
+		t.Fatalf("UpdatePatient: %v", err)
+	}
+	patient.FirstName = "Changed after the write"
+
+	got, err := cache.QueryPatient(ctx, "P00001")
+	if err != nil {
+		t.Fatalf("QueryPatient after update: %v", err)
+	}
+	if got.FirstName != "Updated" {
+		t.Errorf("FirstName = %q, want the written %q", got.FirstName, "Updated")
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Stats = %+v, want 1 hit and 1 miss", stats)
+	}
+}
+
+func TestCachingDatabaseDoesNotCacheFailures(t *testing.T) {
+	cache := NewCachingDatabase(NewScriptedDatabase([]Step{{Err: ErrConnectionTimeout}}))
+	ctx := context.Background()
+
+	if _, err := cache.QueryPatient(ctx, "P00001"); err == nil {
+		t.Fatal("first QueryPatient succeeded, want the scripted error")
+	}
+	if _, err := cache.QueryPatient(ctx, "P00001"); err != nil {
+		t.Fatalf("second QueryPatient: %v", err)
+	}
+	if stats := cache.Stats(); stats.Misses != 2 || stats.Hits != 0 {
+		t.Errorf("Stats = %+v, want 2 misses", stats)
+	}
+}
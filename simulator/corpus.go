@@ -0,0 +1,78 @@
+package simulator
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+const (
+	// DefaultCorpusSize is how many patients SearchPatients scans when no
+	// corpus is configured with WithCorpus or WithSearchCorpus.
+	DefaultCorpusSize = 1000
+
+	// DefaultCorpusSeed seeds the default search corpus, so the same
+	// search returns the same patients on every run.
+	DefaultCorpusSeed = 42
+)
+
+// GenerateCorpus returns size patients, P00000 upwards, generated from
+// seed. The same size and seed always produce the same records (apart
+// from dates, which are relative to when the corpus was generated).
+func GenerateCorpus(size int, seed int64) []*models.Patient {
+	r := rand.New(rand.NewSource(seed))
+	patients := make([]*models.Patient, size)
+	for i := range patients {
+		patients[i] = models.GeneratePatientFrom(fmt.Sprintf("P%05d", i), r)
+	}
+	return patients
+}
+
+// WithCorpus pre-generates size patients from seed when the database is
+// created, and QueryPatient then serves reads from them instead of
+// generating a fresh record for every query.
+//
+// Without a corpus, generating the record is CPU work that lands inside
+// the measured latency, and the data differs from run to run. With one, a
+// query's latency is just the simulated delay, and the same ID always
+// returns the same patient: corpus IDs (P00000 upwards) map to their own
+// record, and any other ID is hashed to a slot and returned under its own
+// ID. The corpus is also what SearchPatients scans.
+//
+// A size of zero or less disables the corpus (the default).
+func WithCorpus(size int, seed int64) Option {
+	return func(db *Database) {
+		if size <= 0 {
+			db.corpus, db.corpusIndex = nil, nil
+			return
+		}
+
+		db.corpus = GenerateCorpus(size, seed)
+		db.corpusIndex = make(map[string]int, size)
+		for i, patient := range db.corpus {
+			db.corpusIndex[patient.ID] = i
+		}
+		db.searchCorpus = db.corpus
+	}
+}
+
+// lookupCorpus returns a copy of the corpus record for patientID, or nil
+// when there is no corpus.
+func (db *Database) lookupCorpus(patientID string) *models.Patient {
+	if db.corpus == nil {
+		return nil
+	}
+
+	slot, ok := db.corpusIndex[patientID]
+	if !ok {
+		h := fnv.New64a()
+		h.Write([]byte(patientID))
+		slot = int(h.Sum64() % uint64(len(db.corpus)))
+	}
+
+	patient := db.corpus[slot].Clone()
+	patient.ID = patientID
+	return patient
+}
@@ -0,0 +1,148 @@
+package simulator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// newCorpusDatabase returns a database with a corpus and no query latency.
+func newCorpusDatabase(size int, seed int64) *Database {
+	db := NewDatabase(1, 2, 0, WithCorpus(size, seed))
+	db.SetLatencySource(NewTraceLatencySource(nil))
+	return db
+}
+
+func TestCorpusReturnsTheSamePatient(t *testing.T) {
+	db := newCorpusDatabase(100, 1)
+	ctx := context.Background()
+
+	for _, id := range []string{"P00007", "not-a-corpus-id"} {
+		first, err := db.QueryPatient(ctx, id)
+		if err != nil {
+			t.Fatalf("QueryPatient(%s): %v", id, err)
+		}
+		for i := 0; i < 5; i++ {
+			again, err := db.QueryPatient(ctx, id)
+			if err != nil {
+				t.Fatalf("QueryPatient(%s): %v", id, err)
+			}
+			if !reflect.DeepEqual(first, again) {
+				t.Fatalf("%s returned different patients:\n%+v\n%+v", id, first, again)
+			}
+		}
+		if first.ID != id {
+			t.Errorf("patient ID = %q, want %q", first.ID, id)
+		}
+	}
+
+	// Corpus IDs map to their own record, so lookups agree with searches
+	got, _ := db.QueryPatient(ctx, "P00007")
+	if want := GenerateCorpus(100, 1)[7]; got.LastName != want.LastName || got.MedicalRecordNumber != want.MedicalRecordNumber {
+		t.Errorf("P00007 = %s %s, want the corpus record %s %s",
+			got.LastName, got.MedicalRecordNumber, want.LastName, want.MedicalRecordNumber)
+	}
+}
+
+func TestCorpusIsReproducibleAcrossDatabases(t *testing.T) {
+	a := newCorpusDatabase(50, 9)
+	b := newCorpusDatabase(50, 9)
+
+	for _, id := range []string{"P00003", "P99999", "anything"} {
+		pa, _ := a.QueryPatient(context.Background(), id)
+		pb, _ := b.QueryPatient(context.Background(), id)
+		if pa.MedicalRecordNumber != pb.MedicalRecordNumber || pa.LastName != pb.LastName {
+			t.Errorf("%s differs between databases with the same seed", id)
+		}
+	}
+}
+
+func TestCorpusKeepsGenerationOutOfTheHotPath(t *testing.T) {
+	ctx := context.Background()
+	query := func(db *Database) func() {
+		return func() { db.QueryPatient(ctx, "P00042") }
+	}
+
+	withCorpus := testing.AllocsPerRun(100, query(newCorpusDatabase(100, 1)))
+
+	generating := NewDatabase(1, 2, 0)
+	generating.SetLatencySource(NewTraceLatencySource(nil))
+	withoutCorpus := testing.AllocsPerRun(100, query(generating))
+
+	// Generating a patient allocates its slices and strings; a corpus
+	// lookup only copies the record
+	if withCorpus >= withoutCorpus {
+		t.Errorf("QueryPatient allocates %.0f times with a corpus and %.0f without; want fewer with one",
+			withCorpus, withoutCorpus)
+	}
+}
+
+func TestWrittenRecordsTakePrecedenceOverCorpus(t *testing.T) {
+	db := newCorpusDatabase(10, 1)
+	db.SetWriteProfile(1, 2, 0)
+	ctx := context.Background()
+
+	patient, _ := db.QueryPatient(ctx, "P00001")
+	patient.LastName = "Written"
+	if err := db.UpdatePatient(ctx, patient); err != nil {
+		t.Fatalf("UpdatePatient: %v", err)
+	}
+
+	if got, _ := db.QueryPatient(ctx, "P00001"); got.LastName != "Written" {
+		t.Errorf("LastName = %q, want the written record", got.LastName)
+	}
+}
+
+func TestCorpusResultsDoNotAliasTheCorpus(t *testing.T) {
+	db := newCorpusDatabase(10, 1)
+	db.SetWriteProfile(1, 2, 0)
+	ctx := context.Background()
+
+	// A corpus record, and a written record, each read back and mutated
+	written, _ := db.QueryPatient(ctx, "P00002")
+	written.DiagnosisCodes = []string{"I10"}
+	if err := db.UpdatePatient(ctx, written); err != nil {
+		t.Fatalf("UpdatePatient: %v", err)
+	}
+	written.DiagnosisCodes[0] = "caller's change"
+
+	for _, id := range []string{"P00001", "P00002"} {
+		before, _ := db.QueryPatient(ctx, id)
+		want := before.Clone()
+
+		for i := range before.DiagnosisCodes {
+			before.DiagnosisCodes[i] = "mutated"
+		}
+		before.Allergies = append(before.Allergies[:0], "mutated")
+
+		if after, _ := db.QueryPatient(ctx, id); !reflect.DeepEqual(after, want) {
+			t.Errorf("%s changed after mutating a returned copy:\n got %+v\nwant %+v", id, after, want)
+		}
+	}
+
+	if stored, _ := db.QueryPatient(ctx, "P00002"); stored.DiagnosisCodes[0] != "I10" {
+		t.Errorf("stored diagnosis = %q; the caller's slice leaked into the database", stored.DiagnosisCodes[0])
+	}
+}
+
+func TestGeneratedCorpusIsPlausible(t *testing.T) {
+	stats := models.CohortStats(GenerateCorpus(2000, DefaultCorpusSeed))
+
+	// GeneratePatient only makes adults, of both genders
+	if n := stats.ByAgeBand["0-17"]; n != 0 {
+		t.Errorf("%d minors in the corpus, want none", n)
+	}
+	for _, band := range []string{"18-34", "35-49", "50-64", "65-74", "75-89"} {
+		if stats.ByAgeBand[band] == 0 {
+			t.Errorf("no patients in the %s band: %v", band, stats.ByAgeBand)
+		}
+	}
+	if stats.ByGender["Male"] < 800 || stats.ByGender["Female"] < 800 {
+		t.Errorf("genders are lopsided: %v", stats.ByGender)
+	}
+	if len(stats.TopDiagnoses) == 0 || stats.TopDiagnoses[0].Count > stats.Total/2 {
+		t.Errorf("implausible diagnoses: %v", stats.TopDiagnoses)
+	}
+}
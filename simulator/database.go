@@ -3,10 +3,12 @@ package simulator
 import (
 	"context"
 	"fmt"
+	"log"
 	"math/rand"
 	"sync"
 	"time"
 
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/clock"
 	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
 )
 
@@ -27,8 +29,25 @@ const (
 	// - Transient infrastructure issues
 	ErrorRate = 0.05
 
+	// MinWriteLatency represents the minimum database write time in milliseconds.
+	// Writes are slower than reads: they take row locks, update indexes,
+	// and must be flushed to the write-ahead log before committing.
+	MinWriteLatency = 80
+
+	// MaxWriteLatency represents the maximum database write time in milliseconds.
+	MaxWriteLatency = 150
+
+	// WriteErrorRate represents the probability of a write failing (0.02 = 2%)
+	// Typical causes are lock timeouts and serialization failures when
+	// two clinicians update the same patient record concurrently.
+	WriteErrorRate = 0.02
+
 	// ContextTimeout is the maximum time to wait for a query before canceling
 	ContextTimeout = 5 * time.Second
+
+	// PingLatency is the round trip of a Ping, the equivalent of SELECT 1:
+	// no planning, no rows, just a trip to the server and back.
+	PingLatency = time.Millisecond
 )
 
 var (
@@ -50,24 +69,125 @@ func init() {
 type Database struct {
 	queryCount    int64
 	errorCount    int64
+	labels        labelStats // Queries by WithQueryLabel label
 	mu            sync.RWMutex
 	minLatency    time.Duration
 	maxLatency    time.Duration
 	errorRate     float64
+	latencySource LatencySource
+
+	// Source of time for latencies and the error schedule (see WithClock)
+	clock     clock.Clock
+	createdAt time.Time
+
+	// Read error rate over time (see WithErrorSchedule), timed from createdAt
+	errorSchedule []ErrorPhase
+
+	// Occasional slow queries (see WithTailLatency)
+	tailProbability float64
+	tailLatency     time.Duration
+
+	// Reads served from a lagging replica (see WithReplicationLag)
+	staleProbability float64
+	staleness        time.Duration
+
+	// Circuit breaker and last known good records (see WithDegradedMode);
+	// nil if off
+	degraded *degradedMode
+
+	// Exact latencies and errors for each call, instead of random ones
+	// (see NewScriptedDatabase); nil unless scripted
+	script *script
+
+	// Write profile, separate from reads
+	writeErrorRate     float64
+	writeLatencySource LatencySource
+
+	// Records written by UpdatePatient, served back by QueryPatient
+	recordsMu sync.RWMutex
+	records   map[string]*models.Patient
+
+	// Pre-generated records served by QueryPatient (see WithCorpus)
+	corpus      []*models.Patient
+	corpusIndex map[string]int
+
+	// Queries slower than a threshold (see WithSlowQueryLog); nil if off
+	slowLog *slowQueryLog
+
+	// Search profile and the records searches scan (see SearchPatients)
+	searchLatencySource LatencySource
+	searchCorpus        []*models.Patient
+	searchCorpusOnce    sync.Once
+
+	// Simulated connection pool (nil means unlimited, see WithMaxConnections)
+	connections      chan struct{}
+	connectionsInUse int64
+	waitOnExhaustion bool
 }
 
 // NewDatabase creates a new database simulator with configurable parameters.
-func NewDatabase(minLatencyMs, maxLatencyMs int, errorRate float64) *Database {
-	return &Database{
-		minLatency: time.Duration(minLatencyMs) * time.Millisecond,
-		maxLatency: time.Duration(maxLatencyMs) * time.Millisecond,
-		errorRate:  errorRate,
+// Additional behaviour, such as a connection limit, is enabled via options.
+func NewDatabase(minLatencyMs, maxLatencyMs int, errorRate float64, opts ...Option) *Database {
+	minLatency := time.Duration(minLatencyMs) * time.Millisecond
+	maxLatency := time.Duration(maxLatencyMs) * time.Millisecond
+
+	db := &Database{
+		minLatency:    minLatency,
+		maxLatency:    maxLatency,
+		errorRate:     errorRate,
+		latencySource: &randomLatencySource{min: minLatency, max: maxLatency},
+		clock:         clock.Real,
+		createdAt:     time.Now(),
+
+		writeErrorRate: WriteErrorRate,
+		writeLatencySource: &randomLatencySource{
+			min: time.Duration(MinWriteLatency) * time.Millisecond,
+			max: time.Duration(MaxWriteLatency) * time.Millisecond,
+		},
+		searchLatencySource: &randomLatencySource{
+			min: time.Duration(MinSearchLatency) * time.Millisecond,
+			max: time.Duration(MaxSearchLatency) * time.Millisecond,
+		},
+		records:          make(map[string]*models.Patient),
+		waitOnExhaustion: true,
 	}
+
+	for _, opt := range opts {
+		opt(db)
+	}
+
+	return db
 }
 
 // NewDefaultDatabase creates a database simulator with default healthcare-realistic settings.
-func NewDefaultDatabase() *Database {
-	return NewDatabase(MinQueryLatency, MaxQueryLatency, ErrorRate)
+func NewDefaultDatabase(opts ...Option) *Database {
+	return NewDatabase(MinQueryLatency, MaxQueryLatency, ErrorRate, opts...)
+}
+
+// SetLatencySource replaces the source of simulated query latencies.
+// Use this to replay a recorded production trace instead of the default
+// uniform distribution. Passing nil restores the default random source.
+func (db *Database) SetLatencySource(src LatencySource) {
+	if src == nil {
+		src = &randomLatencySource{min: db.minLatency, max: db.maxLatency}
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.latencySource = src
+}
+
+// SetWriteProfile configures the latency range and error rate for writes.
+// By default writes use MinWriteLatency, MaxWriteLatency and WriteErrorRate.
+func (db *Database) SetWriteProfile(minLatencyMs, maxLatencyMs int, errorRate float64) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.writeErrorRate = errorRate
+	db.writeLatencySource = &randomLatencySource{
+		min: time.Duration(minLatencyMs) * time.Millisecond,
+		max: time.Duration(maxLatencyMs) * time.Millisecond,
+	}
 }
 
 // QueryPatient simulates fetching a patient record from the database.
@@ -83,6 +203,14 @@ func NewDefaultDatabase() *Database {
 // - In production, would include retry logic with exponential backoff
 // - Healthcare systems must handle errors gracefully without data loss
 func (db *Database) QueryPatient(ctx context.Context, patientID string) (*models.Patient, error) {
+	ctx, span := startSpan(ctx, "simulator.QueryPatient", "SELECT")
+	patient, err := db.readPatient(ctx, patientID)
+	endSpan(span, err)
+	return patient, err
+}
+
+// queryPatient is the untraced body of QueryPatient.
+func (db *Database) queryPatient(ctx context.Context, patientID string) (*models.Patient, error) {
 	// Create a timeout context if one isn't already set
 	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
 		var cancel context.CancelFunc
@@ -90,26 +218,46 @@ func (db *Database) QueryPatient(ctx context.Context, patientID string) (*models
 		defer cancel()
 	}
 
+	// Check out a connection; under a connection limit this is where
+	// excess concurrency queues up (or fails fast)
+	release, err := db.acquireConnection(ctx)
+	if err != nil {
+		db.incrementErrorCount()
+		return nil, err
+	}
+	defer release()
+
 	// Simulate random database latency
 	// In real systems, this varies based on:
 	// - Query complexity (joins, aggregations)
 	// - Database load and concurrent queries
 	// - Network latency between app server and database
 	// - Index efficiency and query optimization
-	latency := db.getRandomLatency()
+	step, scripted := db.script.step()
+	latency := step.Latency
+	if !scripted {
+		latency = db.getRandomLatency()
+	}
 
 	// Use a select to respect context cancellation during the simulated delay
 	select {
-	case <-time.After(latency):
+	case <-db.clock.After(latency):
 		// Query completed
 	case <-ctx.Done():
 		// Context was cancelled or timed out
 		db.incrementErrorCount()
-		return nil, fmt.Errorf("query cancelled: %w", ctx.Err())
+		return nil, fmt.Errorf("%w: %w", ErrQueryCancelled, ctx.Err())
 	}
 
 	// Increment query counter (thread-safe)
 	db.incrementQueryCount()
+	db.labels.record(ctx, latency)
+	db.slowLog.record(patientID, latency, db.clock.Now())
+
+	if step.Err != nil {
+		db.incrementErrorCount()
+		return nil, step.Err
+	}
 
 	// Simulate random database errors (5% error rate by default)
 	// Common healthcare database errors:
@@ -117,9 +265,25 @@ func (db *Database) QueryPatient(ctx context.Context, patientID string) (*models
 	// - Lock timeout (concurrent updates to same patient record)
 	// - Network partition
 	// - Replication lag causing stale reads
-	if db.shouldSimulateError() {
+	if !scripted && db.shouldSimulateError() {
 		db.incrementErrorCount()
-		return nil, fmt.Errorf("database error: connection timeout for patient %s", patientID)
+		return nil, fmt.Errorf("database error: %w for patient %s", ErrConnectionTimeout, patientID)
+	}
+
+	// An empty ID matches no rows. The round trip still happened, so this
+	// counts as a query rather than a database error.
+	if patientID == "" {
+		return nil, fmt.Errorf("%w: empty patient ID", ErrPatientNotFound)
+	}
+
+	// Serve records previously written by UpdatePatient
+	if stored := db.lookupRecord(patientID); stored != nil {
+		return db.replicaRead(stored), nil
+	}
+
+	// Serve the pre-generated corpus, if there is one
+	if corpusRecord := db.lookupCorpus(patientID); corpusRecord != nil {
+		return db.replicaRead(corpusRecord), nil
 	}
 
 	// Generate realistic patient data
@@ -131,19 +295,26 @@ func (db *Database) QueryPatient(ctx context.Context, patientID string) (*models
 	// - patient_visits
 	patient := models.GeneratePatient(patientID)
 
-	return patient, nil
+	return db.replicaRead(patient), nil
 }
 
 // BatchQueryPatients simulates fetching multiple patient records.
 // This demonstrates a more efficient query pattern that could be used
 // for operations like ward census, care team rosters, or bulk data export.
+//
+// If a query fails or ctx ends part-way through, the patients fetched so
+// far are returned, in request order, with a *PartialResultError listing
+// the IDs that weren't.
 func (db *Database) BatchQueryPatients(ctx context.Context, patientIDs []string) ([]*models.Patient, error) {
 	patients := make([]*models.Patient, 0, len(patientIDs))
 
-	for _, id := range patientIDs {
+	for i, id := range patientIDs {
 		patient, err := db.QueryPatient(ctx, id)
 		if err != nil {
-			return nil, fmt.Errorf("failed to query patient %s: %w", id, err)
+			return patients, &PartialResultError{
+				Missing: append([]string(nil), patientIDs[i:]...),
+				Err:     fmt.Errorf("failed to query patient %s: %w", id, err),
+			}
 		}
 		patients = append(patients, patient)
 	}
@@ -151,6 +322,85 @@ func (db *Database) BatchQueryPatients(ctx context.Context, patientIDs []string)
 	return patients, nil
 }
 
+// UpdatePatient simulates writing a patient record to the database.
+// Writes have their own latency profile and error rate (see SetWriteProfile).
+// Once written, the record is returned by subsequent QueryPatient calls.
+//
+// In production, this would be an UPDATE inside a transaction touching the
+// same tables QueryPatient joins across, plus an audit log entry.
+func (db *Database) UpdatePatient(ctx context.Context, patient *models.Patient) error {
+	ctx, span := startSpan(ctx, "simulator.UpdatePatient", "UPDATE")
+	err := db.updatePatient(ctx, patient)
+	endSpan(span, err)
+	return err
+}
+
+// updatePatient is the untraced body of UpdatePatient.
+func (db *Database) updatePatient(ctx context.Context, patient *models.Patient) error {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ContextTimeout)
+		defer cancel()
+	}
+
+	release, err := db.acquireConnection(ctx)
+	if err != nil {
+		db.incrementErrorCount()
+		return err
+	}
+	defer release()
+
+	db.mu.RLock()
+	latency := db.writeLatencySource.Next()
+	errorRate := db.writeErrorRate
+	db.mu.RUnlock()
+	step, scripted := db.script.step()
+	if scripted {
+		latency, errorRate = step.Latency, 0
+	}
+
+	select {
+	case <-db.clock.After(latency):
+		// Write completed
+	case <-ctx.Done():
+		db.incrementErrorCount()
+		return fmt.Errorf("update %w: %w", ErrQueryCancelled, ctx.Err())
+	}
+
+	db.incrementQueryCount()
+
+	if step.Err != nil {
+		db.incrementErrorCount()
+		return step.Err
+	}
+	if db.shouldFail(errorRate) {
+		db.incrementErrorCount()
+		return fmt.Errorf("database error: %w updating patient %s", ErrLockTimeout, patient.ID)
+	}
+
+	// Store a copy so later changes by the caller don't leak into the database
+	stored := patient.Clone()
+	stored.Stale = false
+	db.recordsMu.Lock()
+	db.records[patient.ID] = stored
+	db.recordsMu.Unlock()
+	db.degraded.remember(stored)
+
+	return nil
+}
+
+// lookupRecord returns a copy of a previously written record, or nil.
+func (db *Database) lookupRecord(patientID string) *models.Patient {
+	db.recordsMu.RLock()
+	defer db.recordsMu.RUnlock()
+
+	stored, ok := db.records[patientID]
+	if !ok {
+		return nil
+	}
+	return stored.Clone()
+}
+
 // GetStats returns current database statistics.
 // In production, this would include connection pool stats, query performance metrics,
 // slow query logs, and replication lag information.
@@ -167,6 +417,8 @@ func (db *Database) ResetStats() {
 	defer db.mu.Unlock()
 	db.queryCount = 0
 	db.errorCount = 0
+	db.labels.reset()
+	db.degraded.resetServed()
 }
 
 // incrementQueryCount safely increments the query counter.
@@ -183,24 +435,63 @@ func (db *Database) incrementErrorCount() {
 	db.errorCount++
 }
 
-// getRandomLatency returns a random latency within the configured range.
-// This simulates real-world database query time variance.
+// getRandomLatency returns the next latency from the configured source.
+// By default this is a random latency within the configured range.
+// When tail latency is enabled, some queries get an additional spike.
 func (db *Database) getRandomLatency() time.Duration {
-	rngMu.Lock()
-	defer rngMu.Unlock()
+	db.mu.RLock()
+	src := db.latencySource
+	db.mu.RUnlock()
 
-	// Generate latency between min and max
-	delta := db.maxLatency - db.minLatency
-	randomDelta := time.Duration(rng.Int63n(int64(delta)))
-	return db.minLatency + randomDelta
+	latency := src.Next()
+	if db.tailProbability > 0 && db.shouldFail(db.tailProbability) {
+		latency += db.tailLatency
+	}
+	return latency
 }
 
-// shouldSimulateError determines if this query should fail.
+// shouldSimulateError determines if this query should fail, at the error
+// rate currently in effect (see WithErrorSchedule).
 // Uses thread-safe random number generation.
 func (db *Database) shouldSimulateError() bool {
+	return db.shouldFail(db.currentErrorRate())
+}
+
+// shouldFail reports whether an operation with the given error rate should fail.
+func (db *Database) shouldFail(errorRate float64) bool {
 	rngMu.Lock()
 	defer rngMu.Unlock()
-	return rng.Float64() < db.errorRate
+	return rng.Float64() < errorRate
+}
+
+// Ping checks that the database is reachable, the way a driver's Ping runs
+// SELECT 1. It checks out a connection for a short fixed round trip, but
+// generates no patient, never fails at random and leaves GetStats alone, so
+// frequent readiness probes don't skew benchmark results.
+//
+// Ping fails if no connection can be checked out (see WithMaxConnections)
+// or ctx ends first.
+func (db *Database) Ping(ctx context.Context) error {
+	ctx, span := startSpan(ctx, "simulator.Ping", "SELECT")
+	err := db.ping(ctx)
+	endSpan(span, err)
+	return err
+}
+
+// ping is the untraced body of Ping.
+func (db *Database) ping(ctx context.Context) error {
+	release, err := db.acquireConnection(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	select {
+	case <-db.clock.After(PingLatency):
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", ErrQueryCancelled, ctx.Err())
+	}
 }
 
 // HealthCheck performs a database health check.
@@ -209,13 +500,14 @@ func (db *Database) shouldSimulateError() bool {
 // - Check replication lag
 // - Validate connection pool health
 // - Ensure read/write capability
+//
+// It uses Ping rather than a patient query, so health checks neither
+// generate data nor count towards GetStats.
 func (db *Database) HealthCheck(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
-	// Simulate a simple query
-	_, err := db.QueryPatient(ctx, "health-check")
-	if err != nil {
+	if err := db.Ping(ctx); err != nil {
 		return fmt.Errorf("database health check failed: %w", err)
 	}
 
@@ -233,7 +525,7 @@ func (db *Database) Close() error {
 	queries, errors := db.GetStats()
 	if queries > 0 {
 		errorRate := float64(errors) / float64(queries) * 100
-		fmt.Printf("Database closing: %d queries, %d errors (%.2f%% error rate)\n",
+		log.Printf("Database closing: %d queries, %d errors (%.2f%% error rate)",
 			queries, errors, errorRate)
 	}
 	return nil
@@ -0,0 +1,140 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/clock"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+func TestUpdatePatientIsReadBack(t *testing.T) {
+	db := NewDatabase(1, 2, 0)
+	db.SetWriteProfile(1, 2, 0)
+	ctx := context.Background()
+
+	patient := models.GeneratePatient("P00007")
+	patient.LastName = "Written"
+	if err := db.UpdatePatient(ctx, patient); err != nil {
+		t.Fatalf("UpdatePatient: %v", err)
+	}
+
+	// Mutating the caller's copy must not change what was stored.
+	patient.LastName = "Mutated"
+
+	got, err := db.QueryPatient(ctx, "P00007")
+	if err != nil {
+		t.Fatalf("QueryPatient: %v", err)
+	}
+	if got.LastName != "Written" {
+		t.Errorf("LastName = %q, want %q", got.LastName, "Written")
+	}
+}
+
+func TestUpdatePatientErrorRate(t *testing.T) {
+	db := NewDatabase(1, 2, 0)
+	db.SetWriteProfile(1, 2, 1)
+
+	if err := db.UpdatePatient(context.Background(), models.GeneratePatient("P1")); err == nil {
+		t.Fatal("expected a write error with a 100% write error rate")
+	}
+	if _, errors := db.GetStats(); errors != 1 {
+		t.Errorf("error count = %d, want 1", errors)
+	}
+}
+
+func TestPingLeavesStatsAlone(t *testing.T) {
+	// Every query would fail, and slowly; a ping does neither
+	db := NewDatabase(500, 1000, 1)
+
+	for i := 0; i < 20; i++ {
+		if err := db.Ping(context.Background()); err != nil {
+			t.Fatalf("Ping: %v", err)
+		}
+	}
+	if queries, errors := db.GetStats(); queries != 0 || errors != 0 {
+		t.Errorf("GetStats() = (%d, %d) after pings, want (0, 0)", queries, errors)
+	}
+}
+
+func TestHealthCheckLeavesStatsAlone(t *testing.T) {
+	db := NewDatabase(1, 2, 1)
+	ctx := context.Background()
+
+	// One real, failing query so the counts are non-zero to begin with
+	db.QueryPatient(ctx, "P00001")
+	queries, errors := db.GetStats()
+
+	for i := 0; i < 20; i++ {
+		if err := db.HealthCheck(ctx); err != nil {
+			t.Fatalf("HealthCheck: %v", err)
+		}
+	}
+	if gotQueries, gotErrors := db.GetStats(); gotQueries != queries || gotErrors != errors {
+		t.Errorf("GetStats() = (%d, %d) after health checks, want (%d, %d)", gotQueries, gotErrors, queries, errors)
+	}
+}
+
+func TestQueryLatencyRunsOnTheClock(t *testing.T) {
+	const latency = 80 * time.Millisecond
+
+	clk := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	db := NewDatabase(1, 2, 0, WithClock(clk))
+	db.SetLatencySource(NewTraceLatencySource([]time.Duration{latency}))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := db.QueryPatient(context.Background(), "P00001")
+		done <- err
+	}()
+
+	clk.BlockUntil(1)
+	clk.Advance(latency - time.Nanosecond)
+	select {
+	case <-done:
+		t.Fatal("query finished before its simulated latency had passed")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clk.Advance(time.Nanosecond)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("QueryPatient: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("query did not finish once the clock reached its latency")
+	}
+}
+
+func TestBatchQueryReturnsCompletedPatientsOnTimeout(t *testing.T) {
+	db := NewDatabase(20, 21, 0)
+	ids := []string{"P00001", "P00002", "P00003", "P00004", "P00005"}
+
+	// Long enough for two 20ms queries, not all five
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	patients, err := db.BatchQueryPatients(ctx, ids)
+
+	var partial *PartialResultError
+	if !errors.As(err, &partial) {
+		t.Fatalf("BatchQueryPatients error = %v, want a *PartialResultError", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v does not match context.DeadlineExceeded", err)
+	}
+	if len(patients) == 0 || len(patients) == len(ids) {
+		t.Fatalf("fetched %d of %d patients, want the batch cut short part-way", len(patients), len(ids))
+	}
+	for i, patient := range patients {
+		if patient.ID != ids[i] {
+			t.Errorf("patient %d = %s, want %s", i, patient.ID, ids[i])
+		}
+	}
+	if want := ids[len(patients):]; !reflect.DeepEqual(partial.Missing, want) {
+		t.Errorf("Missing = %v, want %v", partial.Missing, want)
+	}
+}
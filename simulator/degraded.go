@@ -0,0 +1,158 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+const (
+	// DefaultBreakerThreshold is how many reads in a row must fail with a
+	// connection problem before degraded mode's breaker opens.
+	DefaultBreakerThreshold = 5
+
+	// DefaultBreakerCooldown is how long the breaker stays open before a
+	// read is let through to see whether the database has recovered.
+	DefaultBreakerCooldown = 5 * time.Second
+)
+
+// WithDegradedMode puts a circuit breaker in front of reads and serves
+// the last known good record while it is open, instead of an error.
+//
+// A clinician looking up allergies during an outage is better served by a
+// record from a few minutes ago, clearly flagged, than by a 504. Every
+// record QueryPatient returns or UpdatePatient writes is remembered. Once
+// threshold reads in a row fail because the database is unreachable
+// (ErrConnectionTimeout or ErrPoolExhausted), the breaker opens: for the
+// next cooldown, reads skip the database entirely and get the remembered
+// record marked Degraded, or ErrCircuitOpen if there is none. The first
+// read after the cooldown goes to the database again; it closes the
+// breaker if it succeeds and reopens it if it fails.
+//
+// A threshold of zero or less disables degraded mode (the default).
+func WithDegradedMode(threshold int, cooldown time.Duration) Option {
+	return func(db *Database) {
+		if threshold <= 0 {
+			db.degraded = nil
+			return
+		}
+		db.degraded = &degradedMode{
+			threshold: threshold,
+			cooldown:  cooldown,
+			lastGood:  make(map[string]*models.Patient),
+		}
+	}
+}
+
+// degradedMode is the breaker and last-known-good records behind
+// WithDegradedMode. A nil *degradedMode is disabled.
+type degradedMode struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int       // Consecutive reads that failed with a connection problem
+	openUntil time.Time // Reads skip the database until then
+	lastGood  map[string]*models.Patient
+	served    int64 // Reads answered from lastGood while open
+}
+
+// readPatient is queryPatient behind degraded mode's breaker, if enabled.
+func (db *Database) readPatient(ctx context.Context, patientID string) (*models.Patient, error) {
+	d := db.degraded
+	if d == nil {
+		return db.queryPatient(ctx, patientID)
+	}
+
+	if d.isOpen(db.clock.Now()) {
+		return d.serve(patientID)
+	}
+
+	patient, err := db.queryPatient(ctx, patientID)
+	d.record(patient, err, db.clock.Now())
+	return patient, err
+}
+
+// isOpen reports whether reads should skip the database at now.
+func (d *degradedMode) isOpen(now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return now.Before(d.openUntil)
+}
+
+// serve answers a read while the breaker is open.
+func (d *degradedMode) serve(patientID string) (*models.Patient, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cached, ok := d.lastGood[patientID]
+	if !ok {
+		return nil, fmt.Errorf("%w: no cached record for patient %s", ErrCircuitOpen, patientID)
+	}
+	d.served++
+	patient := cached.Clone()
+	patient.Degraded = true
+	return patient, nil
+}
+
+// record updates the breaker with the outcome of a read that went to the
+// database, remembering the record if it succeeded.
+func (d *degradedMode) record(patient *models.Patient, err error, now time.Time) {
+	if err == nil {
+		d.remember(patient)
+		return
+	}
+	if !errors.Is(err, ErrConnectionTimeout) && !errors.Is(err, ErrPoolExhausted) {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.failures++
+	if d.failures >= d.threshold {
+		d.openUntil = now.Add(d.cooldown)
+	}
+}
+
+// remember keeps a copy of patient as its last known good record and,
+// since the database just answered, closes the breaker.
+func (d *degradedMode) remember(patient *models.Patient) {
+	if d == nil {
+		return
+	}
+
+	cached := patient.Clone()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastGood[patient.ID] = cached
+	d.failures = 0
+}
+
+// GetDegradedStats reports how many reads degraded mode has answered
+// from its last known good records, and whether its breaker is open now.
+// Both are zero when degraded mode is off. ResetStats clears the count.
+func (db *Database) GetDegradedStats() (served int64, open bool) {
+	d := db.degraded
+	if d == nil {
+		return 0, false
+	}
+
+	now := db.clock.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.served, now.Before(d.openUntil)
+}
+
+// resetServed clears the count of degraded reads.
+func (d *degradedMode) resetServed() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.served = 0
+}
@@ -0,0 +1,84 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/clock"
+)
+
+// TestDegradedModeServesLastKnownGood reads a patient while the database
+// is up, fails enough reads to open the breaker, and checks the patient is
+// then served from the last known good record, marked degraded, until the
+// cooldown passes and a successful read closes the breaker.
+func TestDegradedModeServesLastKnownGood(t *testing.T) {
+	const threshold, cooldown = 3, 10 * time.Second
+
+	outage := Step{Err: ErrConnectionTimeout}
+	clk := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	db := NewScriptedDatabase([]Step{{}, outage, outage, outage},
+		WithClock(clk), WithCorpus(10, DefaultCorpusSeed), WithDegradedMode(threshold, cooldown))
+	ctx := context.Background()
+
+	good, err := db.QueryPatient(ctx, "P00001")
+	if err != nil || good.Degraded {
+		t.Fatalf("read before the outage = %+v, %v; want a fresh record", good, err)
+	}
+	for i := 0; i < threshold; i++ {
+		if _, err := db.QueryPatient(ctx, "P00001"); !errors.Is(err, ErrConnectionTimeout) {
+			t.Fatalf("outage read %d: error = %v, want ErrConnectionTimeout", i, err)
+		}
+	}
+	if _, open := db.GetDegradedStats(); !open {
+		t.Fatalf("breaker closed after %d failures in a row", threshold)
+	}
+
+	// Open: the script has run out, so a read reaching the database would
+	// succeed undegraded; these must not reach it
+	queries, _ := db.GetStats()
+	for i := 0; i < 2; i++ {
+		patient, err := db.QueryPatient(ctx, "P00001")
+		if err != nil {
+			t.Fatalf("read while open: %v", err)
+		}
+		if !patient.Degraded {
+			t.Error("read while open not marked degraded")
+		}
+		if !patient.LastVisitDate.Equal(good.LastVisitDate) || patient.MedicalRecordNumber != good.MedicalRecordNumber {
+			t.Errorf("read while open = %+v, want the last known good %+v", patient, good)
+		}
+	}
+	if _, err := db.QueryPatient(ctx, "P00002"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("uncached read while open: error = %v, want ErrCircuitOpen", err)
+	}
+	if after, _ := db.GetStats(); after != queries {
+		t.Errorf("reads while open ran %d queries, want none", after-queries)
+	}
+	if served, _ := db.GetDegradedStats(); served != 2 {
+		t.Errorf("degraded reads = %d, want 2", served)
+	}
+
+	// After the cooldown a read goes through again and closes the breaker
+	clk.Advance(cooldown)
+	patient, err := db.QueryPatient(ctx, "P00002")
+	if err != nil || patient.Degraded {
+		t.Fatalf("read after cooldown = %+v, %v; want a fresh record", patient, err)
+	}
+	if _, open := db.GetDegradedStats(); open {
+		t.Error("breaker still open after a successful read")
+	}
+}
+
+func TestDegradedModeIgnoresNonConnectionErrors(t *testing.T) {
+	notFound := Step{Err: ErrPatientNotFound}
+	db := NewScriptedDatabase([]Step{notFound, notFound, notFound}, WithDegradedMode(2, time.Minute))
+
+	for i := 0; i < 3; i++ {
+		db.QueryPatient(context.Background(), "P00001")
+	}
+	if _, open := db.GetDegradedStats(); open {
+		t.Error("breaker opened on ErrPatientNotFound")
+	}
+}
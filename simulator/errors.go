@@ -0,0 +1,84 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned (wrapped) by the simulator.
+// Use errors.Is to classify a failure instead of matching on its message.
+var (
+	// ErrConnectionTimeout is a transient failure talking to the database,
+	// such as a network timeout or a dropped connection. Safe to retry.
+	ErrConnectionTimeout = errors.New("database connection timeout")
+
+	// ErrLockTimeout is a write that could not obtain a row lock in time,
+	// usually because another update to the same patient was in progress.
+	ErrLockTimeout = errors.New("database lock timeout")
+
+	// ErrQueryCancelled means the caller's context ended before the query
+	// finished. The wrapped error also matches the context error itself.
+	ErrQueryCancelled = errors.New("query cancelled")
+
+	// ErrPatientNotFound means no record exists for the requested ID.
+	ErrPatientNotFound = errors.New("patient not found")
+
+	// ErrPoolExhausted is returned when every simulated connection is busy and
+	// the database is configured not to wait for one to become free.
+	ErrPoolExhausted = errors.New("connection pool exhausted")
+
+	// ErrCircuitOpen is returned by a read while degraded mode's breaker
+	// is open and there is no last known good record to serve instead.
+	ErrCircuitOpen = errors.New("circuit breaker open")
+)
+
+// PartialResultError is returned by BatchQueryPatients when the batch stops
+// before every patient is fetched, alongside the patients that were.
+// It wraps the error that stopped the batch, so errors.Is still matches
+// ErrQueryCancelled or context.DeadlineExceeded.
+type PartialResultError struct {
+	Missing []string // IDs not fetched, in the order they were requested
+	Err     error
+}
+
+func (e *PartialResultError) Error() string {
+	return fmt.Sprintf("batch incomplete, %d patients not fetched: %v", len(e.Missing), e.Err)
+}
+
+func (e *PartialResultError) Unwrap() error { return e.Err }
+
+// Error categories, as reported by ErrorCategory.
+const (
+	CategoryTimeout       = "timeout"
+	CategoryLockTimeout   = "lock_timeout"
+	CategoryCancelled     = "cancelled"
+	CategoryNotFound      = "not_found"
+	CategoryPoolExhausted = "pool_exhausted"
+	CategoryCircuitOpen   = "circuit_open"
+	CategoryOther         = "other"
+)
+
+// ErrorCategory returns a short, stable label for err suitable for metrics.
+// Context errors that did not come from the simulator are reported as
+// cancelled; anything unrecognised is CategoryOther.
+func ErrorCategory(err error) string {
+	switch {
+	case errors.Is(err, ErrConnectionTimeout):
+		return CategoryTimeout
+	case errors.Is(err, ErrLockTimeout):
+		return CategoryLockTimeout
+	case errors.Is(err, ErrPatientNotFound):
+		return CategoryNotFound
+	case errors.Is(err, ErrPoolExhausted):
+		return CategoryPoolExhausted
+	case errors.Is(err, ErrCircuitOpen):
+		return CategoryCircuitOpen
+	case errors.Is(err, ErrQueryCancelled),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return CategoryCancelled
+	default:
+		return CategoryOther
+	}
+}
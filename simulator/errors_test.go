@@ -0,0 +1,80 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+func TestQueryPatientConnectionTimeout(t *testing.T) {
+	db := NewDatabase(1, 2, 1)
+
+	_, err := db.QueryPatient(context.Background(), "P00001")
+	if !errors.Is(err, ErrConnectionTimeout) {
+		t.Errorf("error = %v, want ErrConnectionTimeout", err)
+	}
+}
+
+func TestQueryPatientCancelled(t *testing.T) {
+	db := NewDatabase(1, 2, 0)
+	db.SetLatencySource(NewTraceLatencySource([]time.Duration{time.Second}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := db.QueryPatient(ctx, "P00001")
+	if !errors.Is(err, ErrQueryCancelled) {
+		t.Errorf("error = %v, want ErrQueryCancelled", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, should also match context.DeadlineExceeded", err)
+	}
+}
+
+func TestQueryPatientNotFound(t *testing.T) {
+	db := NewDatabase(1, 2, 0)
+
+	_, err := db.QueryPatient(context.Background(), "")
+	if !errors.Is(err, ErrPatientNotFound) {
+		t.Errorf("error = %v, want ErrPatientNotFound", err)
+	}
+	if _, errs := db.GetStats(); errs != 0 {
+		t.Errorf("not-found counted as %d database errors, want 0", errs)
+	}
+}
+
+func TestUpdatePatientLockTimeout(t *testing.T) {
+	db := NewDatabase(1, 2, 0)
+	db.SetWriteProfile(1, 2, 1)
+
+	err := db.UpdatePatient(context.Background(), models.GeneratePatient("P00001"))
+	if !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("error = %v, want ErrLockTimeout", err)
+	}
+}
+
+func TestErrorCategory(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("wrapped: %w", ErrConnectionTimeout), CategoryTimeout},
+		{fmt.Errorf("wrapped: %w", ErrLockTimeout), CategoryLockTimeout},
+		{fmt.Errorf("wrapped: %w", ErrPatientNotFound), CategoryNotFound},
+		{ErrPoolExhausted, CategoryPoolExhausted},
+		{fmt.Errorf("wrapped: %w", ErrCircuitOpen), CategoryCircuitOpen},
+		{fmt.Errorf("%w: %w", ErrQueryCancelled, context.Canceled), CategoryCancelled},
+		{context.DeadlineExceeded, CategoryCancelled},
+		{errors.New("queue full"), CategoryOther},
+	}
+
+	for _, tc := range tests {
+		if got := ErrorCategory(tc.err); got != tc.want {
+			t.Errorf("ErrorCategory(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package simulator
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// queryLabelKey is the context key for the query label.
+type queryLabelKey struct{}
+
+// WithQueryLabel returns a copy of ctx whose queries are attributed to
+// label, such as a tenant or priority ("icu", "outpatient"), in
+// GetStatsByLabel.
+func WithQueryLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, queryLabelKey{}, label)
+}
+
+// QueryLabelFromContext returns the label stored by WithQueryLabel, or ""
+// if there is none.
+func QueryLabelFromContext(ctx context.Context) string {
+	label, _ := ctx.Value(queryLabelKey{}).(string)
+	return label
+}
+
+// LabelStats is the share of a database's queries made under one label.
+type LabelStats struct {
+	Queries int64         // Completed queries, as counted by GetStats
+	Latency time.Duration // Total simulated latency of those queries
+}
+
+// MeanLatency returns the average simulated latency of the label's
+// queries.
+func (s LabelStats) MeanLatency() time.Duration {
+	if s.Queries == 0 {
+		return 0
+	}
+	return s.Latency / time.Duration(s.Queries)
+}
+
+// labelStats accumulates LabelStats for each label seen.
+type labelStats struct {
+	mu    sync.Mutex
+	stats map[string]LabelStats
+}
+
+// record attributes one completed query, and its latency, to the label
+// in ctx. Unlabelled queries aren't tracked.
+func (l *labelStats) record(ctx context.Context, latency time.Duration) {
+	label := QueryLabelFromContext(ctx)
+	if label == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stats == nil {
+		l.stats = make(map[string]LabelStats)
+	}
+	s := l.stats[label]
+	s.Queries++
+	s.Latency += latency
+	l.stats[label] = s
+}
+
+// snapshot returns a copy of the stats, or nil if no labelled query has
+// completed.
+func (l *labelStats) snapshot() map[string]LabelStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.stats) == 0 {
+		return nil
+	}
+	stats := make(map[string]LabelStats, len(l.stats))
+	for label, s := range l.stats {
+		stats[label] = s
+	}
+	return stats
+}
+
+// reset forgets every label.
+func (l *labelStats) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats = nil
+}
+
+// GetStatsByLabel returns query counts and latency for each label set
+// with WithQueryLabel, keyed by label. Queries without a label appear only
+// in GetStats. ResetStats clears these too.
+func (db *Database) GetStatsByLabel() map[string]LabelStats {
+	return db.labels.snapshot()
+}
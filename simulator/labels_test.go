@@ -0,0 +1,52 @@
+package simulator
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStatsByLabel(t *testing.T) {
+	db := NewScriptedDatabase([]Step{
+		{Latency: 2 * time.Millisecond},
+		{Latency: 4 * time.Millisecond},
+		{Latency: time.Millisecond},
+		{Latency: time.Millisecond, Err: ErrConnectionTimeout},
+	})
+	icu := WithQueryLabel(context.Background(), "icu")
+	outpatient := WithQueryLabel(context.Background(), "outpatient")
+
+	for _, ctx := range []context.Context{icu, icu, outpatient} {
+		if _, err := db.QueryPatient(ctx, "P00001"); err != nil {
+			t.Fatalf("labelled query: %v", err)
+		}
+	}
+	db.QueryPatient(context.Background(), "P00001") // Scripted to fail
+
+	stats := db.GetStatsByLabel()
+	want := map[string]LabelStats{
+		"icu":        {Queries: 2, Latency: 6 * time.Millisecond},
+		"outpatient": {Queries: 1, Latency: time.Millisecond},
+	}
+	if len(stats) != len(want) {
+		t.Errorf("GetStatsByLabel = %v, want %v", stats, want)
+	}
+	for label, w := range want {
+		if got := stats[label]; got != w {
+			t.Errorf("%s: got %+v, want %+v", label, got, w)
+		}
+	}
+	if mean := stats["icu"].MeanLatency(); mean != 3*time.Millisecond {
+		t.Errorf("icu mean latency = %v, want 3ms", mean)
+	}
+
+	// Unlabelled queries still count in GetStats as before
+	if queries, errs := db.GetStats(); queries != 4 || errs != 1 {
+		t.Errorf("GetStats = %d queries, %d errors; want 4, 1", queries, errs)
+	}
+
+	db.ResetStats()
+	if stats := db.GetStatsByLabel(); stats != nil {
+		t.Errorf("GetStatsByLabel after ResetStats = %v, want nil", stats)
+	}
+}
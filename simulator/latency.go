@@ -0,0 +1,71 @@
+package simulator
+
+import (
+	"sync"
+	"time"
+)
+
+// LatencySource supplies the simulated latency for each database query.
+//
+// The default source draws uniformly from the configured min/max range.
+// Custom sources let benchmarks reproduce a specific production latency
+// trace instead of a synthetic distribution.
+//
+// Implementations must be safe for concurrent use: queries from many
+// goroutines call Next at the same time.
+type LatencySource interface {
+	Next() time.Duration
+}
+
+// randomLatencySource is the default LatencySource.
+// It returns a random latency between min and max.
+type randomLatencySource struct {
+	min time.Duration
+	max time.Duration
+}
+
+// Next returns a random latency within the configured range.
+// This simulates real-world database query time variance.
+func (s *randomLatencySource) Next() time.Duration {
+	delta := s.max - s.min
+	if delta <= 0 {
+		return s.min
+	}
+
+	rngMu.Lock()
+	defer rngMu.Unlock()
+
+	randomDelta := time.Duration(rng.Int63n(int64(delta)))
+	return s.min + randomDelta
+}
+
+// TraceLatencySource replays a recorded sequence of latencies in order.
+// Once the trace is exhausted it wraps around to the beginning, so a short
+// trace can drive an arbitrarily long benchmark.
+type TraceLatencySource struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+// NewTraceLatencySource creates a source that replays the given latencies.
+// An empty trace yields zero latency for every query.
+func NewTraceLatencySource(latencies []time.Duration) *TraceLatencySource {
+	trace := make([]time.Duration, len(latencies))
+	copy(trace, latencies)
+	return &TraceLatencySource{latencies: trace}
+}
+
+// Next returns the next latency in the trace.
+func (s *TraceLatencySource) Next() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.latencies) == 0 {
+		return 0
+	}
+
+	latency := s.latencies[s.next]
+	s.next = (s.next + 1) % len(s.latencies)
+	return latency
+}
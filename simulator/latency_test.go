@@ -0,0 +1,46 @@
+package simulator
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestTraceLatencySourceReplaysInOrder verifies that queries take the
+// scripted latencies, in the order they were scripted.
+func TestTraceLatencySourceReplaysInOrder(t *testing.T) {
+	script := []time.Duration{
+		40 * time.Millisecond,
+		10 * time.Millisecond,
+		70 * time.Millisecond,
+		25 * time.Millisecond,
+	}
+	const tolerance = 20 * time.Millisecond
+
+	db := NewDatabase(MinQueryLatency, MaxQueryLatency, 0)
+	db.SetLatencySource(NewTraceLatencySource(script))
+
+	for i, want := range script {
+		start := time.Now()
+		if _, err := db.QueryPatient(context.Background(), "P00001"); err != nil {
+			t.Fatalf("query %d: unexpected error: %v", i, err)
+		}
+		got := time.Since(start)
+
+		if got < want || got > want+tolerance {
+			t.Errorf("query %d: took %v, want %v (tolerance %v)", i, got, want, tolerance)
+		}
+	}
+}
+
+// TestTraceLatencySourceWraps verifies the trace restarts once exhausted.
+func TestTraceLatencySourceWraps(t *testing.T) {
+	src := NewTraceLatencySource([]time.Duration{1, 2, 3})
+
+	want := []time.Duration{1, 2, 3, 1, 2}
+	for i, w := range want {
+		if got := src.Next(); got != w {
+			t.Errorf("Next() #%d = %v, want %v", i, got, w)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package simulator
+
+import "github.com/Stella-Achar-Oiro/healthcare-api-benchmark/clock"
+
+// Option configures optional Database behaviour.
+// Options are applied by NewDatabase and NewDefaultDatabase after the
+// latency range and error rate have been set.
+type Option func(*Database)
+
+// WithClock makes the database tell time by c instead of the wall clock:
+// simulated latencies wait on c.After, and WithErrorSchedule's phases are
+// timed from c.Now() at construction. With a clock.Fake, tests can step
+// through latencies and schedules without sleeping.
+//
+// Context deadlines are unaffected; they always run on the wall clock.
+func WithClock(c clock.Clock) Option {
+	return func(db *Database) {
+		db.clock = c
+		db.createdAt = c.Now()
+	}
+}
@@ -0,0 +1,80 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// WithMaxConnections limits the database to n concurrent connections.
+//
+// Every query must acquire a connection before it runs, just as a real
+// driver checks one out of database/sql's pool. Without a limit, the naive
+// pattern's unbounded goroutines all "connect" instantly; with one, they
+// queue up behind the pool exactly as they would in production.
+//
+// A value of zero or less means unlimited connections (the default).
+func WithMaxConnections(n int) Option {
+	return func(db *Database) {
+		if n <= 0 {
+			db.connections = nil
+			return
+		}
+		db.connections = make(chan struct{}, n)
+	}
+}
+
+// WithWaitOnExhaustion controls what happens when all connections are busy.
+// When wait is true (the default) queries block until a connection frees up
+// or their context ends. When false they fail immediately with
+// ErrPoolExhausted, like a driver configured with a zero checkout timeout.
+func WithWaitOnExhaustion(wait bool) Option {
+	return func(db *Database) {
+		db.waitOnExhaustion = wait
+	}
+}
+
+// acquireConnection checks a connection out of the pool.
+// The returned function must be called to give it back.
+func (db *Database) acquireConnection(ctx context.Context) (release func(), err error) {
+	if db.connections == nil {
+		return func() {}, nil
+	}
+
+	release = func() {
+		atomic.AddInt64(&db.connectionsInUse, -1)
+		<-db.connections
+	}
+
+	if !db.waitOnExhaustion {
+		select {
+		case db.connections <- struct{}{}:
+			atomic.AddInt64(&db.connectionsInUse, 1)
+			return release, nil
+		default:
+			return nil, ErrPoolExhausted
+		}
+	}
+
+	select {
+	case db.connections <- struct{}{}:
+		atomic.AddInt64(&db.connectionsInUse, 1)
+		return release, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: waiting for connection: %w", ErrQueryCancelled, ctx.Err())
+	}
+}
+
+// GetPoolStats returns connection pool statistics.
+// Utilization is the percentage of connections currently checked out;
+// it stays at zero when the pool is unlimited.
+func (db *Database) GetPoolStats() (inUse int64, maxConnections int, utilization float64) {
+	inUse = atomic.LoadInt64(&db.connectionsInUse)
+	maxConnections = cap(db.connections)
+
+	if maxConnections > 0 {
+		utilization = float64(inUse) / float64(maxConnections) * 100
+	}
+
+	return inUse, maxConnections, utilization
+}
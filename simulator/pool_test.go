@@ -0,0 +1,117 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// saturate starts n queries that each hold a connection for the scripted
+// latency and waits until they have all checked one out.
+func saturate(t *testing.T, db *Database, n int) *sync.WaitGroup {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db.QueryPatient(context.Background(), "P00001")
+		}()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if inUse, _, _ := db.GetPoolStats(); inUse == int64(n) {
+			return &wg
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pool never reached %d connections in use", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConnectionPoolFailsFastWhenExhausted(t *testing.T) {
+	const maxConns = 2
+
+	db := NewDatabase(1, 2, 0, WithMaxConnections(maxConns), WithWaitOnExhaustion(false))
+	db.SetLatencySource(NewTraceLatencySource([]time.Duration{100 * time.Millisecond}))
+
+	wg := saturate(t, db, maxConns)
+	defer wg.Wait()
+
+	inUse, capacity, utilization := db.GetPoolStats()
+	if inUse != maxConns || capacity != maxConns || utilization != 100 {
+		t.Errorf("GetPoolStats() = (%d, %d, %.1f), want (%d, %d, 100)", inUse, capacity, utilization, maxConns, maxConns)
+	}
+
+	_, err := db.QueryPatient(context.Background(), "P00002")
+	if !errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("QueryPatient error = %v, want ErrPoolExhausted", err)
+	}
+}
+
+func TestConnectionPoolBlocksWhenExhausted(t *testing.T) {
+	const (
+		maxConns = 2
+		latency  = 100 * time.Millisecond
+	)
+
+	db := NewDatabase(1, 2, 0, WithMaxConnections(maxConns), WithWaitOnExhaustion(true))
+	db.SetLatencySource(NewTraceLatencySource([]time.Duration{latency}))
+
+	wg := saturate(t, db, maxConns)
+	defer wg.Wait()
+
+	start := time.Now()
+	if _, err := db.QueryPatient(context.Background(), "P00002"); err != nil {
+		t.Fatalf("QueryPatient: unexpected error: %v", err)
+	}
+
+	// The query waits for a connection to free up, then runs its own latency.
+	if elapsed := time.Since(start); elapsed < latency+latency/2 {
+		t.Errorf("query took %v; expected it to wait for a connection first", elapsed)
+	}
+}
+
+func TestConnectionPoolWaitRespectsContext(t *testing.T) {
+	db := NewDatabase(1, 2, 0, WithMaxConnections(1))
+	db.SetLatencySource(NewTraceLatencySource([]time.Duration{200 * time.Millisecond}))
+
+	wg := saturate(t, db, 1)
+	defer wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := db.QueryPatient(ctx, "P00002")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("QueryPatient error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestUnlimitedConnectionsByDefault(t *testing.T) {
+	db := NewDatabase(1, 2, 0)
+
+	if _, capacity, utilization := db.GetPoolStats(); capacity != 0 || utilization != 0 {
+		t.Errorf("GetPoolStats() capacity = %d, utilization = %.1f; want 0, 0", capacity, utilization)
+	}
+}
+
+func TestPingNeedsAConnection(t *testing.T) {
+	db := NewDatabase(1, 2, 0, WithMaxConnections(1), WithWaitOnExhaustion(false))
+	db.SetLatencySource(NewTraceLatencySource([]time.Duration{100 * time.Millisecond}))
+
+	wg := saturate(t, db, 1)
+	if err := db.Ping(context.Background()); !errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("Ping with the pool exhausted = %v, want ErrPoolExhausted", err)
+	}
+
+	wg.Wait()
+	if err := db.Ping(context.Background()); err != nil {
+		t.Errorf("Ping after the pool drained = %v, want nil", err)
+	}
+}
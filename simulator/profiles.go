@@ -0,0 +1,36 @@
+package simulator
+
+import "time"
+
+// Point reads, searches and writes cost different amounts in a real
+// database: a primary-key lookup touches one index page, a search scans
+// rows, and a write takes locks and flushes the write-ahead log. These
+// options give each operation its own uniform latency range, so a mixed
+// workload sees each one at its own cost.
+
+// WithReadLatency sets the latency range of QueryPatient, overriding the
+// range passed to NewDatabase. SetLatencySource(nil) restores it.
+func WithReadLatency(min, max time.Duration) Option {
+	return func(db *Database) {
+		db.minLatency = min
+		db.maxLatency = max
+		db.latencySource = &randomLatencySource{min: min, max: max}
+	}
+}
+
+// WithSearchLatency sets the latency range of SearchPatients, by default
+// MinSearchLatency to MaxSearchLatency.
+func WithSearchLatency(min, max time.Duration) Option {
+	return func(db *Database) {
+		db.searchLatencySource = &randomLatencySource{min: min, max: max}
+	}
+}
+
+// WithWriteLatency sets the latency range of UpdatePatient, by default
+// MinWriteLatency to MaxWriteLatency. The write error rate is unchanged;
+// see SetWriteProfile to set both.
+func WithWriteLatency(min, max time.Duration) Option {
+	return func(db *Database) {
+		db.writeLatencySource = &randomLatencySource{min: min, max: max}
+	}
+}
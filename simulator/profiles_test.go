@@ -0,0 +1,104 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// recordingClock fires every After at once, recording the latency asked for.
+type recordingClock struct {
+	mu    sync.Mutex
+	waits []time.Duration
+}
+
+func (c *recordingClock) Now() time.Time { return time.Time{} }
+
+func (c *recordingClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.waits = append(c.waits, d)
+	c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	ch <- time.Time{}
+	return ch
+}
+
+// take returns the latencies recorded since the last call.
+func (c *recordingClock) take() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	waits := c.waits
+	c.waits = nil
+	return waits
+}
+
+// ignoreLockTimeout drops the random write failures, which still wait out
+// their latency first.
+func ignoreLockTimeout(err error) error {
+	if errors.Is(err, ErrLockTimeout) {
+		return nil
+	}
+	return err
+}
+
+func TestOperationLatenciesSampleTheirOwnRange(t *testing.T) {
+	const samples = 200
+	clk := &recordingClock{}
+	db := NewDatabase(1, 2, 0,
+		WithClock(clk),
+		WithReadLatency(10*time.Millisecond, 20*time.Millisecond),
+		WithSearchLatency(300*time.Millisecond, 400*time.Millisecond),
+		WithWriteLatency(50*time.Millisecond, 60*time.Millisecond),
+		WithSearchCorpus([]*models.Patient{}),
+	)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		run      func() error
+		min, max time.Duration
+	}{
+		{"read", func() error { _, err := db.QueryPatient(ctx, "P00001"); return err }, 10 * time.Millisecond, 20 * time.Millisecond},
+		{"search", func() error { _, err := db.SearchPatients(ctx, SearchCriteria{}); return err }, 300 * time.Millisecond, 400 * time.Millisecond},
+		{"write", func() error { return ignoreLockTimeout(db.UpdatePatient(ctx, models.GeneratePatient("P00001"))) }, 50 * time.Millisecond, 60 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < samples; i++ {
+				if err := tt.run(); err != nil {
+					t.Fatalf("call %d: %v", i, err)
+				}
+			}
+			waits := clk.take()
+			if len(waits) != samples {
+				t.Fatalf("recorded %d latencies, want %d", len(waits), samples)
+			}
+			lowest, highest := waits[0], waits[0]
+			for _, d := range waits {
+				if d < tt.min || d > tt.max {
+					t.Fatalf("latency %v outside [%v, %v]", d, tt.min, tt.max)
+				}
+				lowest, highest = min(lowest, d), max(highest, d)
+			}
+			// A uniform draw should spread across the range, not sit at one end
+			if spread := highest - lowest; spread < (tt.max-tt.min)/2 {
+				t.Errorf("latencies span only %v-%v of [%v, %v]", lowest, highest, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestReadLatencySurvivesLatencySourceReset(t *testing.T) {
+	db := NewDatabase(1, 2, 0, WithReadLatency(30*time.Millisecond, 30*time.Millisecond))
+	db.SetLatencySource(NewTraceLatencySource([]time.Duration{time.Millisecond}))
+	db.SetLatencySource(nil)
+
+	if got := db.getRandomLatency(); got != 30*time.Millisecond {
+		t.Errorf("latency after SetLatencySource(nil) = %v, want the WithReadLatency range", got)
+	}
+}
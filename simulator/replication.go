@@ -0,0 +1,35 @@
+package simulator
+
+import (
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// WithReplicationLag makes a fraction of reads come from a lagging read
+// replica.
+//
+// Scaling reads out to replicas (or caching them) buys throughput at the
+// cost of consistency: a replica applies the primary's writes some time
+// after they commit, so a read it serves may miss the latest visit. With
+// probability p, QueryPatient returns the patient as they were staleness
+// ago, which here means a LastVisitDate that much earlier, and marks the
+// record Stale so clients can see which reads were affected.
+//
+// A probability of zero or less disables replication lag (the default).
+func WithReplicationLag(probability float64, staleness time.Duration) Option {
+	return func(db *Database) {
+		db.staleProbability = probability
+		db.staleness = staleness
+	}
+}
+
+// replicaRead returns patient as read from a replica: usually unchanged,
+// but with probability staleProbability lagging staleness behind.
+func (db *Database) replicaRead(patient *models.Patient) *models.Patient {
+	if db.staleProbability > 0 && db.shouldFail(db.staleProbability) {
+		patient.LastVisitDate = patient.LastVisitDate.Add(-db.staleness)
+		patient.Stale = true
+	}
+	return patient
+}
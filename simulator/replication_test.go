@@ -0,0 +1,82 @@
+package simulator
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestReplicationLagStaleFraction(t *testing.T) {
+	const (
+		samples     = 2000
+		probability = 0.3
+		staleness   = 48 * time.Hour
+	)
+
+	db := NewDatabase(0, 0, 0, WithCorpus(10, DefaultCorpusSeed), WithReplicationLag(probability, staleness))
+	db.SetLatencySource(NewTraceLatencySource([]time.Duration{0}))
+	primary := db.corpus[3]
+
+	stale := 0
+	for i := 0; i < samples; i++ {
+		patient, err := db.QueryPatient(context.Background(), "P00003")
+		if err != nil {
+			t.Fatalf("QueryPatient: %v", err)
+		}
+
+		wantVisit := primary.LastVisitDate
+		if patient.Stale {
+			stale++
+			wantVisit = wantVisit.Add(-staleness)
+		}
+		if !patient.LastVisitDate.Equal(wantVisit) {
+			t.Fatalf("read %d (stale %v): LastVisitDate = %v, want %v", i, patient.Stale, patient.LastVisitDate, wantVisit)
+		}
+	}
+
+	fraction := float64(stale) / samples
+	if math.Abs(fraction-probability) > 0.04 {
+		t.Errorf("stale fraction = %.3f, want %.3f ± 0.04", fraction, probability)
+	}
+}
+
+func TestReplicationLagDisabledByDefault(t *testing.T) {
+	db := NewDatabase(0, 0, 0)
+	db.SetLatencySource(NewTraceLatencySource([]time.Duration{0}))
+
+	for i := 0; i < 500; i++ {
+		patient, err := db.QueryPatient(context.Background(), "P00001")
+		if err != nil {
+			t.Fatalf("QueryPatient: %v", err)
+		}
+		if patient.Stale {
+			t.Fatalf("read %d marked stale without replication lag", i)
+		}
+	}
+}
+
+func TestReplicationLagWriteIsNotStale(t *testing.T) {
+	db := NewDatabase(0, 0, 0, WithCorpus(10, DefaultCorpusSeed), WithReplicationLag(1, time.Hour))
+	db.SetLatencySource(NewTraceLatencySource([]time.Duration{0}))
+	db.SetWriteProfile(0, 0, 0)
+
+	patient, err := db.QueryPatient(context.Background(), "P00001")
+	if err != nil {
+		t.Fatalf("QueryPatient: %v", err)
+	}
+	if !patient.Stale {
+		t.Fatal("read not marked stale with a replication lag probability of 1")
+	}
+
+	// Writes go to the primary, which is never stale
+	if err := db.UpdatePatient(context.Background(), patient); err != nil {
+		t.Fatalf("UpdatePatient: %v", err)
+	}
+	db.recordsMu.RLock()
+	stored := db.records["P00001"]
+	db.recordsMu.RUnlock()
+	if stored.Stale {
+		t.Error("stored record kept the stale mark of the read it was written from")
+	}
+}
@@ -0,0 +1,51 @@
+package simulator
+
+import (
+	"sort"
+	"time"
+)
+
+// ErrorPhase is one step of an error-rate schedule: from After, measured
+// from when the database was created, reads fail with probability Rate.
+type ErrorPhase struct {
+	After time.Duration
+	Rate  float64
+}
+
+// WithErrorSchedule varies the read error rate over time, to model an
+// incident window for exercising circuit breakers and retries:
+//
+//	WithErrorSchedule([]ErrorPhase{
+//		{After: 0, Rate: 0.01},
+//		{After: 10 * time.Second, Rate: 0.5},  // incident starts
+//		{After: 20 * time.Second, Rate: 0.01}, // and recovers
+//	})
+//
+// Each phase lasts until the next one starts; the last lasts forever.
+// Before the first phase, the error rate passed to NewDatabase applies.
+// Phases may be given in any order.
+func WithErrorSchedule(phases []ErrorPhase) Option {
+	return func(db *Database) {
+		schedule := make([]ErrorPhase, len(phases))
+		copy(schedule, phases)
+		sort.SliceStable(schedule, func(i, j int) bool { return schedule[i].After < schedule[j].After })
+		db.errorSchedule = schedule
+	}
+}
+
+// currentErrorRate returns the read error rate in effect now.
+func (db *Database) currentErrorRate() float64 {
+	rate := db.errorRate
+	if len(db.errorSchedule) == 0 {
+		return rate
+	}
+
+	elapsed := db.clock.Now().Sub(db.createdAt)
+	for _, phase := range db.errorSchedule {
+		if phase.After > elapsed {
+			break
+		}
+		rate = phase.Rate
+	}
+	return rate
+}
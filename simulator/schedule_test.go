@@ -0,0 +1,85 @@
+package simulator
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/clock"
+)
+
+// newScheduledDatabase returns a database on a fake clock following phases.
+func newScheduledDatabase(errorRate float64, phases []ErrorPhase) (*Database, *clock.Fake) {
+	clk := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	db := NewDatabase(1, 2, errorRate, WithClock(clk), WithErrorSchedule(phases))
+	return db, clk
+}
+
+func TestErrorScheduleFollowsPhases(t *testing.T) {
+	db, clk := newScheduledDatabase(0, []ErrorPhase{
+		{After: 0, Rate: 0.01},
+		{After: 10 * time.Second, Rate: 0.5},
+		{After: 20 * time.Second, Rate: 0.01},
+	})
+
+	steps := []struct {
+		advance time.Duration
+		want    float64
+	}{
+		{0, 0.01},
+		{9 * time.Second, 0.01},
+		{time.Second, 0.5}, // 10s: the incident starts exactly on time
+		{5 * time.Second, 0.5},
+		{5 * time.Second, 0.01},
+		{time.Hour, 0.01}, // The last phase lasts forever
+	}
+
+	for _, step := range steps {
+		clk.Advance(step.advance)
+		elapsed := clk.Now().Sub(db.createdAt)
+
+		if got := db.currentErrorRate(); got != step.want {
+			t.Errorf("at %v: error rate = %v, want %v", elapsed, got, step.want)
+		}
+
+		// And the simulated failures actually follow it
+		const samples = 20000
+		failures := 0
+		for i := 0; i < samples; i++ {
+			if db.shouldSimulateError() {
+				failures++
+			}
+		}
+		if fraction := float64(failures) / samples; math.Abs(fraction-step.want) > 0.02 {
+			t.Errorf("at %v: %.3f of queries failed, want %.2f ± 0.02", elapsed, fraction, step.want)
+		}
+	}
+}
+
+func TestErrorScheduleBeforeFirstPhaseAndUnsorted(t *testing.T) {
+	db, clk := newScheduledDatabase(0.05, []ErrorPhase{
+		{After: 30 * time.Second, Rate: 0},
+		{After: 10 * time.Second, Rate: 1},
+	})
+
+	for _, step := range []struct {
+		at   time.Duration
+		want float64
+	}{
+		{0, 0.05}, // NewDatabase's rate until the first phase
+		{10 * time.Second, 1},
+		{30 * time.Second, 0},
+	} {
+		clk.Advance(db.createdAt.Add(step.at).Sub(clk.Now()))
+		if got := db.currentErrorRate(); got != step.want {
+			t.Errorf("at %v: error rate = %v, want %v", step.at, got, step.want)
+		}
+	}
+}
+
+func TestNoErrorScheduleUsesFixedRate(t *testing.T) {
+	db := NewDatabase(1, 2, 0.25)
+	if got := db.currentErrorRate(); got != 0.25 {
+		t.Errorf("error rate = %v, want 0.25", got)
+	}
+}
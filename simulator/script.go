@@ -0,0 +1,66 @@
+package simulator
+
+import (
+	"sync"
+	"time"
+)
+
+// Step is one scripted database call: it takes Latency, then fails with
+// Err, or succeeds if Err is nil.
+type Step struct {
+	Latency time.Duration
+	Err     error
+}
+
+// NewScriptedDatabase returns a database whose calls follow steps in
+// order instead of drawing latencies and errors at random, so a test can
+// set up an exact sequence such as "the 3rd call errors, the 4th is slow":
+//
+//	db := NewScriptedDatabase([]Step{
+//		{Latency: time.Millisecond},
+//		{Latency: time.Millisecond},
+//		{Latency: time.Millisecond, Err: ErrConnectionTimeout},
+//		{Latency: time.Second},
+//	})
+//
+// Reads and writes share the script, taking steps in the order they are
+// called; calls past the end of the script succeed at once. A call whose
+// context ends during its latency still uses up its step. Errors are
+// returned as given, so tests can match them with errors.Is.
+func NewScriptedDatabase(steps []Step, opts ...Option) *Database {
+	db := NewDatabase(0, 0, 0, opts...)
+	db.script = newScript(steps)
+	return db
+}
+
+// script hands out the steps of a scripted database in order.
+// A nil *script means the database is not scripted.
+type script struct {
+	mu    sync.Mutex
+	steps []Step
+	next  int
+}
+
+// newScript copies steps so the caller can't change the script later.
+func newScript(steps []Step) *script {
+	s := &script{steps: make([]Step, len(steps))}
+	copy(s.steps, steps)
+	return s
+}
+
+// step returns the next step, or the zero Step once the script has run
+// out. ok is false if the database is not scripted.
+func (s *script) step() (step Step, ok bool) {
+	if s == nil {
+		return Step{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next < len(s.steps) {
+		step = s.steps[s.next]
+		s.next++
+	}
+	return step, true
+}
@@ -0,0 +1,73 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+func TestScriptedDatabaseFollowsScript(t *testing.T) {
+	const slow = 100 * time.Millisecond
+	errLocked := errors.New("row locked")
+
+	db := NewScriptedDatabase([]Step{
+		{Latency: time.Millisecond},
+		{Latency: time.Millisecond},
+		{Err: ErrConnectionTimeout},
+		{Latency: slow},
+		{Err: errLocked}, // Taken by the write
+	})
+	ctx := context.Background()
+
+	for i := 1; i <= 4; i++ {
+		start := time.Now()
+		_, err := db.QueryPatient(ctx, "P00001")
+		elapsed := time.Since(start)
+
+		if i == 3 && !errors.Is(err, ErrConnectionTimeout) {
+			t.Errorf("call %d: got %v, want ErrConnectionTimeout", i, err)
+		}
+		if i != 3 && err != nil {
+			t.Errorf("call %d: unexpected error: %v", i, err)
+		}
+		if i == 4 && elapsed < slow {
+			t.Errorf("call %d took %v, want at least %v", i, elapsed, slow)
+		}
+		if i != 4 && elapsed >= slow {
+			t.Errorf("call %d took %v, want well under %v", i, elapsed, slow)
+		}
+	}
+
+	if err := db.UpdatePatient(ctx, models.GeneratePatient("P00001")); !errors.Is(err, errLocked) {
+		t.Errorf("write: got %v, want the scripted error", err)
+	}
+
+	// Past the end of the script every call succeeds
+	for i := 0; i < 3; i++ {
+		if _, err := db.QueryPatient(ctx, "P00001"); err != nil {
+			t.Errorf("call past the end of the script: %v", err)
+		}
+	}
+
+	if queries, errs := db.GetStats(); queries != 8 || errs != 2 {
+		t.Errorf("GetStats = %d queries, %d errors; want 8, 2", queries, errs)
+	}
+}
+
+func TestScriptedStepCancelled(t *testing.T) {
+	db := NewScriptedDatabase([]Step{{Latency: time.Second}, {Err: ErrPatientNotFound}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := db.QueryPatient(ctx, "P00001"); !errors.Is(err, ErrQueryCancelled) {
+		t.Fatalf("got %v, want ErrQueryCancelled", err)
+	}
+
+	// The cancelled call used up its step
+	if _, err := db.QueryPatient(context.Background(), "P00001"); !errors.Is(err, ErrPatientNotFound) {
+		t.Errorf("next call: got %v, want the second step's error", err)
+	}
+}
@@ -0,0 +1,147 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+const (
+	// MinSearchLatency is the minimum search time in milliseconds.
+	// Searches filter on unindexed columns, so even a fast one scans far
+	// more rows than a primary-key lookup.
+	MinSearchLatency = 200
+
+	// MaxSearchLatency is the maximum search time in milliseconds.
+	MaxSearchLatency = 400
+)
+
+// SearchCriteria filters a patient search. Empty fields match every
+// patient; a patient must match all the non-empty ones.
+type SearchCriteria struct {
+	LastNamePrefix string // Case-insensitive prefix of the last name
+	Physician      string // Primary physician, case-insensitive, e.g. "Dr. Patel"
+	DiagnosisCode  string // ICD-10 code the patient has been diagnosed with, e.g. "I10"
+}
+
+// IsEmpty reports whether c has no filters, and so matches every patient.
+func (c SearchCriteria) IsEmpty() bool {
+	return c.LastNamePrefix == "" && c.Physician == "" && c.DiagnosisCode == ""
+}
+
+// matches reports whether p satisfies every filter in c.
+func (c SearchCriteria) matches(p *models.Patient) bool {
+	if c.LastNamePrefix != "" && !strings.HasPrefix(strings.ToLower(p.LastName), strings.ToLower(c.LastNamePrefix)) {
+		return false
+	}
+	if c.Physician != "" && !strings.EqualFold(p.PrimaryPhysician, c.Physician) {
+		return false
+	}
+	if c.DiagnosisCode != "" && !containsString(p.DiagnosisCodes, c.DiagnosisCode) {
+		return false
+	}
+	return true
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// WithSearchCorpus sets the patients SearchPatients scans. By default it
+// scans the corpus from WithCorpus if there is one, and otherwise
+// GenerateCorpus(DefaultCorpusSize, DefaultCorpusSeed), generated on the
+// first search.
+func WithSearchCorpus(patients []*models.Patient) Option {
+	return func(db *Database) {
+		db.searchCorpus = patients
+	}
+}
+
+// SetSearchLatency sets the latency range of SearchPatients.
+// By default searches take MinSearchLatency to MaxSearchLatency.
+func (db *Database) SetSearchLatency(minLatencyMs, maxLatencyMs int) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.searchLatencySource = &randomLatencySource{
+		min: time.Duration(minLatencyMs) * time.Millisecond,
+		max: time.Duration(maxLatencyMs) * time.Millisecond,
+	}
+}
+
+// SearchPatients simulates finding patients by criteria rather than ID:
+// a full scan of the search corpus, in corpus order. It is much slower
+// than QueryPatient (see MinSearchLatency) and counts as one query in
+// GetStats, failing at the same rate.
+//
+// Records written by UpdatePatient are not searched; the corpus is fixed
+// so that results are reproducible.
+func (db *Database) SearchPatients(ctx context.Context, criteria SearchCriteria) ([]*models.Patient, error) {
+	ctx, span := startSpan(ctx, "simulator.SearchPatients", "SELECT")
+	patients, err := db.searchPatients(ctx, criteria)
+	endSpan(span, err)
+	return patients, err
+}
+
+// searchPatients is the untraced body of SearchPatients.
+func (db *Database) searchPatients(ctx context.Context, criteria SearchCriteria) ([]*models.Patient, error) {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ContextTimeout)
+		defer cancel()
+	}
+
+	release, err := db.acquireConnection(ctx)
+	if err != nil {
+		db.incrementErrorCount()
+		return nil, err
+	}
+	defer release()
+
+	db.mu.RLock()
+	latency := db.searchLatencySource.Next()
+	db.mu.RUnlock()
+
+	select {
+	case <-db.clock.After(latency):
+		// Scan completed
+	case <-ctx.Done():
+		db.incrementErrorCount()
+		return nil, fmt.Errorf("search %w: %w", ErrQueryCancelled, ctx.Err())
+	}
+
+	db.incrementQueryCount()
+
+	if db.shouldSimulateError() {
+		db.incrementErrorCount()
+		return nil, fmt.Errorf("database error: %w during patient search", ErrConnectionTimeout)
+	}
+
+	var matches []*models.Patient
+	for _, p := range db.searchRecords() {
+		if criteria.matches(p) {
+			// Copy so callers can't modify the corpus
+			matches = append(matches, p.Clone())
+		}
+	}
+	return matches, nil
+}
+
+// searchRecords returns the search corpus, generating the default one on
+// first use.
+func (db *Database) searchRecords() []*models.Patient {
+	db.searchCorpusOnce.Do(func() {
+		if db.searchCorpus == nil {
+			db.searchCorpus = GenerateCorpus(DefaultCorpusSize, DefaultCorpusSeed)
+		}
+	})
+	return db.searchCorpus
+}
@@ -0,0 +1,102 @@
+package simulator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+)
+
+// fixedCorpus is a small hand-written corpus with known matches.
+func fixedCorpus() []*models.Patient {
+	return []*models.Patient{
+		{ID: "P1", LastName: "Smith", PrimaryPhysician: "Dr. Patel", DiagnosisCodes: []string{"I10", "E11.9"}},
+		{ID: "P2", LastName: "Smithers", PrimaryPhysician: "Dr. Chen", DiagnosisCodes: []string{"I10"}},
+		{ID: "P3", LastName: "Jones", PrimaryPhysician: "Dr. Patel", DiagnosisCodes: []string{"J45.909"}},
+		{ID: "P4", LastName: "Smyth", PrimaryPhysician: "Dr. Patel", DiagnosisCodes: []string{"E11.9"}},
+		{ID: "P5", LastName: "Garcia", PrimaryPhysician: "Dr. Kim", DiagnosisCodes: []string{"I10", "F41.9"}},
+	}
+}
+
+func TestSearchPatientsFilters(t *testing.T) {
+	db := NewDatabase(1, 2, 0, WithSearchCorpus(fixedCorpus()))
+	db.SetSearchLatency(1, 2)
+
+	tests := []struct {
+		name     string
+		criteria SearchCriteria
+		want     []string
+	}{
+		{"last name prefix", SearchCriteria{LastNamePrefix: "Smith"}, []string{"P1", "P2"}},
+		{"prefix is case-insensitive", SearchCriteria{LastNamePrefix: "sm"}, []string{"P1", "P2", "P4"}},
+		{"physician", SearchCriteria{Physician: "dr. patel"}, []string{"P1", "P3", "P4"}},
+		{"diagnosis", SearchCriteria{DiagnosisCode: "I10"}, []string{"P1", "P2", "P5"}},
+		{"name and physician", SearchCriteria{LastNamePrefix: "Sm", Physician: "Dr. Patel"}, []string{"P1", "P4"}},
+		{"all three", SearchCriteria{LastNamePrefix: "Sm", Physician: "Dr. Patel", DiagnosisCode: "I10"}, []string{"P1"}},
+		{"no match", SearchCriteria{LastNamePrefix: "Jones", DiagnosisCode: "I10"}, nil},
+		{"no filters", SearchCriteria{}, []string{"P1", "P2", "P3", "P4", "P5"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			patients, err := db.SearchPatients(context.Background(), tc.criteria)
+			if err != nil {
+				t.Fatalf("SearchPatients: %v", err)
+			}
+
+			var got []string
+			for _, p := range patients {
+				got = append(got, p.ID)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	if queries, _ := db.GetStats(); queries != int64(len(tests)) {
+		t.Errorf("query count = %d, want one per search (%d)", queries, len(tests))
+	}
+}
+
+func TestSearchResultsAreCopies(t *testing.T) {
+	db := NewDatabase(1, 2, 0, WithSearchCorpus(fixedCorpus()))
+	db.SetSearchLatency(1, 2)
+	criteria := SearchCriteria{LastNamePrefix: "Jones"}
+
+	patients, err := db.SearchPatients(context.Background(), criteria)
+	if err != nil || len(patients) != 1 {
+		t.Fatalf("SearchPatients = %v, %v; want one patient", patients, err)
+	}
+	patients[0].LastName = "Changed"
+
+	if again, _ := db.SearchPatients(context.Background(), criteria); len(again) != 1 {
+		t.Errorf("modifying a result changed the corpus")
+	}
+}
+
+func TestGenerateCorpusIsReproducible(t *testing.T) {
+	a := GenerateCorpus(50, 7)
+	b := GenerateCorpus(50, 7)
+
+	for i := range a {
+		if a[i].ID != b[i].ID || a[i].LastName != b[i].LastName ||
+			a[i].PrimaryPhysician != b[i].PrimaryPhysician ||
+			!reflect.DeepEqual(a[i].DiagnosisCodes, b[i].DiagnosisCodes) {
+			t.Fatalf("patient %d differs between runs: %+v vs %+v", i, a[i], b[i])
+		}
+	}
+
+	if c := GenerateCorpus(50, 8); reflect.DeepEqual(lastNames(a), lastNames(c)) {
+		t.Error("different seeds produced the same corpus")
+	}
+}
+
+func lastNames(patients []*models.Patient) []string {
+	names := make([]string, len(patients))
+	for i, p := range patients {
+		names[i] = p.LastName
+	}
+	return names
+}
@@ -0,0 +1,84 @@
+package simulator
+
+import (
+	"sync"
+	"time"
+)
+
+// SlowQuery is an entry in the slow-query log.
+type SlowQuery struct {
+	PatientID string        `json:"patient_id"`
+	Latency   time.Duration `json:"latency_ns"`
+	Time      time.Time     `json:"time"` // When the query finished
+}
+
+// WithSlowQueryLog records every QueryPatient call whose simulated latency,
+// tail-latency spike included, exceeds threshold, the way a real database's
+// slow-query log does. Only the most recent size entries are kept; read
+// them with SlowQueries. A threshold or size of zero leaves the log off.
+func WithSlowQueryLog(threshold time.Duration, size int) Option {
+	return func(db *Database) {
+		if threshold <= 0 || size <= 0 {
+			db.slowLog = nil
+			return
+		}
+		db.slowLog = &slowQueryLog{threshold: threshold, entries: make([]SlowQuery, 0, size)}
+	}
+}
+
+// slowQueryLog is a ring buffer of the most recent slow queries.
+// A nil *slowQueryLog is disabled.
+type slowQueryLog struct {
+	threshold time.Duration
+
+	mu      sync.Mutex
+	entries []SlowQuery // Grows to cap(entries), then wraps
+	next    int         // Where the next entry goes once full
+}
+
+// record logs the query if latency is over the threshold, overwriting the
+// oldest entry once the log is full.
+func (l *slowQueryLog) record(patientID string, latency time.Duration, now time.Time) {
+	if l == nil || latency <= l.threshold {
+		return
+	}
+
+	entry := SlowQuery{PatientID: patientID, Latency: latency, Time: now}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.entries) < cap(l.entries) {
+		l.entries = append(l.entries, entry)
+		return
+	}
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+}
+
+// snapshot returns the entries, newest first.
+func (l *slowQueryLog) snapshot() []SlowQuery {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := len(l.entries)
+	entries := make([]SlowQuery, n)
+	if n == 0 {
+		return entries
+	}
+	// The newest entry is just before next (which is 0 until the log wraps)
+	for i := range entries {
+		entries[i] = l.entries[((l.next-1-i)%n+n)%n]
+	}
+	return entries
+}
+
+// SlowQueries returns the slow-query log, newest first. It is nil unless
+// WithSlowQueryLog is set, and empty until a query exceeds the threshold.
+func (db *Database) SlowQueries() []SlowQuery {
+	return db.slowLog.snapshot()
+}
@@ -0,0 +1,70 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSlowQueryLogKeepsOnlySlowQueries(t *testing.T) {
+	const threshold = 20 * time.Millisecond
+	latencies := []time.Duration{
+		time.Millisecond, 30 * time.Millisecond, 2 * time.Millisecond,
+		40 * time.Millisecond, threshold, 50 * time.Millisecond, 60 * time.Millisecond,
+	}
+	steps := make([]Step, len(latencies))
+	for i, latency := range latencies {
+		steps[i] = Step{Latency: latency}
+	}
+	db := NewScriptedDatabase(steps, WithSlowQueryLog(threshold, 3))
+
+	if entries := db.SlowQueries(); len(entries) != 0 {
+		t.Fatalf("SlowQueries before any query = %v, want empty", entries)
+	}
+	for i := range latencies {
+		if _, err := db.QueryPatient(context.Background(), fmt.Sprintf("P%05d", i)); err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+	}
+
+	// Four queries were over the threshold; the log keeps the newest three
+	entries := db.SlowQueries()
+	want := []SlowQuery{
+		{PatientID: "P00006", Latency: 60 * time.Millisecond},
+		{PatientID: "P00005", Latency: 50 * time.Millisecond},
+		{PatientID: "P00003", Latency: 40 * time.Millisecond},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("SlowQueries = %+v, want %d entries", entries, len(want))
+	}
+	for i, w := range want {
+		if entries[i].PatientID != w.PatientID || entries[i].Latency != w.Latency {
+			t.Errorf("entry %d = %s in %v, want %s in %v", i, entries[i].PatientID, entries[i].Latency, w.PatientID, w.Latency)
+		}
+		if entries[i].Time.IsZero() {
+			t.Errorf("entry %d has no timestamp", i)
+		}
+	}
+}
+
+func TestSlowQueryLogIncludesTailSpikes(t *testing.T) {
+	db := NewDatabase(1, 2, 0, WithTailLatency(1, 25*time.Millisecond), WithSlowQueryLog(20*time.Millisecond, 10))
+
+	for i := 0; i < 2; i++ {
+		if _, err := db.QueryPatient(context.Background(), "P00001"); err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+	}
+	if entries := db.SlowQueries(); len(entries) != 2 {
+		t.Errorf("SlowQueries = %+v, want both spiking queries", entries)
+	}
+}
+
+func TestSlowQueryLogOffByDefault(t *testing.T) {
+	db := NewScriptedDatabase([]Step{{Latency: 10 * time.Millisecond}})
+	db.QueryPatient(context.Background(), "P00001")
+	if entries := db.SlowQueries(); entries != nil {
+		t.Errorf("SlowQueries = %v without WithSlowQueryLog, want nil", entries)
+	}
+}
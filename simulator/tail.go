@@ -0,0 +1,21 @@
+package simulator
+
+import "time"
+
+// WithTailLatency makes a fraction of queries dramatically slower.
+//
+// Real databases occasionally stall for reasons unrelated to the query:
+// garbage collection, autovacuum, checkpoints, or a replica failover. With
+// probability p, a QueryPatient call sleeps for an extra duration on top of
+// its normal latency. The spike is part of the same cancellable wait, so a
+// caller's deadline still cuts it short.
+//
+// This is what separates P99 from the mean: bounded patterns keep queueing
+// the rest of the traffic behind a few slow queries, while the naive pattern
+// piles up goroutines waiting on them.
+func WithTailLatency(probability float64, extra time.Duration) Option {
+	return func(db *Database) {
+		db.tailProbability = probability
+		db.tailLatency = extra
+	}
+}
@@ -0,0 +1,64 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTailLatencyDistribution(t *testing.T) {
+	const (
+		samples     = 10000
+		probability = 0.2
+		base        = 10 * time.Millisecond
+		extra       = 500 * time.Millisecond
+	)
+
+	db := NewDatabase(1, 2, 0, WithTailLatency(probability, extra))
+	db.SetLatencySource(NewTraceLatencySource([]time.Duration{base}))
+
+	spikes := 0
+	for i := 0; i < samples; i++ {
+		switch latency := db.getRandomLatency(); latency {
+		case base:
+		case base + extra:
+			spikes++
+		default:
+			t.Fatalf("sample %d: latency %v is neither %v nor %v", i, latency, base, base+extra)
+		}
+	}
+
+	fraction := float64(spikes) / samples
+	if math.Abs(fraction-probability) > 0.02 {
+		t.Errorf("spike fraction = %.3f, want %.3f ± 0.02", fraction, probability)
+	}
+}
+
+func TestTailLatencyDisabledByDefault(t *testing.T) {
+	db := NewDatabase(1, 2, 0)
+	db.SetLatencySource(NewTraceLatencySource([]time.Duration{time.Millisecond}))
+
+	for i := 0; i < 1000; i++ {
+		if latency := db.getRandomLatency(); latency != time.Millisecond {
+			t.Fatalf("sample %d: latency = %v, want 1ms", i, latency)
+		}
+	}
+}
+
+func TestTailLatencySpikeRespectsContext(t *testing.T) {
+	db := NewDatabase(1, 2, 0, WithTailLatency(1, 10*time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := db.QueryPatient(ctx, "P00001")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("QueryPatient error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled query took %v; the spike ignored the deadline", elapsed)
+	}
+}
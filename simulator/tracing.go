@@ -0,0 +1,36 @@
+package simulator
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies spans created by the simulated database.
+const tracerName = "github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+
+// startSpan starts a client span for a database operation.
+// Patient IDs are deliberately not recorded: trace backends are rarely
+// cleared to hold PHI.
+func startSpan(ctx context.Context, name, operation string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "simulated"),
+			attribute.String("db.operation", operation),
+		),
+	)
+}
+
+// endSpan records err on span, tagged with its error category, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attribute.String("error.category", ErrorCategory(err)))
+	}
+	span.End()
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// adminSlowQueriesHandler returns a handler for GET /admin/slow-queries,
+// which lists the database's slow-query log, newest first (see
+// -slow-query-threshold).
+func adminSlowQueriesHandler(db *simulator.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		entries := db.SlowQueries()
+		if entries == nil {
+			entries = []simulator.SlowQuery{}
+		}
+
+		patterns.WriteJSON(w, r, http.StatusOK, map[string]interface{}{
+			"slow_queries": entries,
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+func TestAdminSlowQueries(t *testing.T) {
+	config := validConfig()
+	config.APIKeys = []string{"admin-key"}
+	config.SlowQueryThreshold = 20 * time.Millisecond
+	config.SlowQueryLogSize = 10
+
+	db := simulator.NewScriptedDatabase([]simulator.Step{
+		{Latency: time.Millisecond},
+		{Latency: 30 * time.Millisecond},
+	}, simulator.WithSlowQueryLog(config.SlowQueryThreshold, config.SlowQueryLogSize))
+	mux := newServeMux(config, http.NotFoundHandler(), db, metrics.NewCollector(), nil)
+
+	for _, id := range []string{"P00001", "P00002"} {
+		if _, err := db.QueryPatient(context.Background(), id); err != nil {
+			t.Fatalf("query %s: %v", id, err)
+		}
+	}
+
+	get := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/slow-queries", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get(""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("without a key: status = %d, want 401", rec.Code)
+	}
+
+	rec := get("Bearer admin-key")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		SlowQueries []simulator.SlowQuery `json:"slow_queries"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.SlowQueries) != 1 || body.SlowQueries[0].PatientID != "P00002" || body.SlowQueries[0].Latency != 30*time.Millisecond {
+		t.Errorf("slow_queries = %+v, want only P00002 at 30ms", body.SlowQueries)
+	}
+}
+
+func TestValidateConfigSlowQueryLog(t *testing.T) {
+	config := validConfig()
+	config.SlowQueryThreshold = -time.Second
+	if err := validateConfig(config); err == nil {
+		t.Error("expected an error for a negative -slow-query-threshold")
+	}
+
+	config.SlowQueryThreshold = time.Second
+	if err := validateConfig(config); err == nil {
+		t.Error("expected an error for a zero -slow-query-log-size")
+	}
+
+	config.SlowQueryLogSize = 100
+	if err := validateConfig(config); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/patterns"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/store/postgres"
+)
+
+// Stores accepted by -store.
+const (
+	storeSimulator = "simulator"
+	storePostgres  = "postgres"
+)
+
+// openStore opens the database selected by -store: the simulator, shaped
+// by the -min-latency and related flags, or PostgreSQL at -dsn.
+func openStore(config Config) (patterns.PatientStore, error) {
+	switch config.Store {
+	case storeSimulator:
+		return newSimulator(config), nil
+	case storePostgres:
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		store, err := postgres.Open(ctx, postgres.Config{
+			DSN:             config.DSN,
+			MaxOpenConns:    config.DBMaxOpenConns,
+			MaxIdleConns:    config.DBMaxIdleConns,
+			ConnMaxLifetime: config.DBConnMaxLifetime,
+			ConnMaxIdleTime: config.DBConnMaxIdleTime,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := store.Migrate(ctx); err != nil {
+			store.Close()
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown store: %s", config.Store)
+	}
+}
+
+// newSimulator creates the simulated database the flags describe.
+func newSimulator(config Config) *simulator.Database {
+	return simulator.NewDatabase(config.MinLatency, config.MaxLatency, config.ErrorRate,
+		simulator.WithMaxConnections(config.MaxConnections),
+		simulator.WithTailLatency(config.TailProbability, config.TailLatency),
+		simulator.WithReplicationLag(config.StaleProbability, config.Staleness),
+		withDegradedMode(config),
+		simulator.WithCorpus(config.CorpusSize, simulator.DefaultCorpusSeed),
+		simulator.WithSlowQueryLog(config.SlowQueryThreshold, config.SlowQueryLogSize))
+}
+
+// hedged wraps db with request hedging for -hedge-delay, if it is set.
+// Only the patterns' single-patient reads go through it.
+func hedged(config Config, db patterns.PatientStore) patterns.PatientStore {
+	if config.HedgeDelay <= 0 {
+		return db
+	}
+	return patterns.NewHedgedStore(db, patterns.HedgeConfig{
+		Delay:        config.HedgeDelay,
+		Adaptive:     config.HedgeAdaptive,
+		MaxHedgeRate: config.HedgeMaxRate,
+	})
+}
+
+// hedgeStats returns the hedging statistics of the store behind handler,
+// if it hedges (see -hedge-delay).
+func hedgeStats(handler http.Handler) (patterns.HedgeStats, bool) {
+	switchable, ok := handler.(*switchableHandler)
+	if !ok {
+		return patterns.HedgeStats{}, false
+	}
+	store, ok := switchable.db.(*patterns.HedgedStore)
+	if !ok {
+		return patterns.HedgeStats{}, false
+	}
+	return store.Stats(), true
+}
@@ -0,0 +1,246 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// testDSNEnv names the database the integration tests run against. They
+// are skipped when it is unset, e.g.
+//
+//	POSTGRES_TEST_DSN="postgres://postgres@localhost/test?sslmode=disable" go test ./store/postgres
+const testDSNEnv = "POSTGRES_TEST_DSN"
+
+// openTestStore opens the test database and seeds it with patients whose
+// IDs are unique to this test, so tests can share a database. The patients
+// are deleted when the test ends.
+func openTestStore(t *testing.T, n int) (*Store, []*models.Patient) {
+	t.Helper()
+
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set; skipping PostgreSQL integration test", testDSNEnv)
+	}
+
+	ctx := context.Background()
+	store, err := Open(ctx, Config{DSN: dsn, MaxOpenConns: 4})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	prefix := fmt.Sprintf("T%d-", time.Now().UnixNano())
+	patients := make([]*models.Patient, n)
+	for i := range patients {
+		patients[i] = models.GeneratePatient(fmt.Sprintf("%s%03d", prefix, i))
+		if err := store.UpdatePatient(ctx, patients[i]); err != nil {
+			t.Fatalf("UpdatePatient(%s): %v", patients[i].ID, err)
+		}
+	}
+
+	t.Cleanup(func() {
+		store.db.Exec(`DELETE FROM patients WHERE id LIKE $1`, prefix+"%")
+		store.Close()
+	})
+	return store, patients
+}
+
+// assertSamePatient compares the fields the store persists. Timestamps are
+// compared to the microsecond, PostgreSQL's resolution.
+func assertSamePatient(t *testing.T, got, want *models.Patient) {
+	t.Helper()
+
+	same := got.ID == want.ID &&
+		got.MedicalRecordNumber == want.MedicalRecordNumber &&
+		got.FirstName == want.FirstName &&
+		got.LastName == want.LastName &&
+		got.DateOfBirth.Truncate(time.Microsecond).Equal(want.DateOfBirth.Truncate(time.Microsecond)) &&
+		got.Gender == want.Gender &&
+		fmt.Sprint(got.DiagnosisCodes) == fmt.Sprint(want.DiagnosisCodes) &&
+		fmt.Sprint(got.Medications) == fmt.Sprint(want.Medications) &&
+		fmt.Sprint(got.Allergies) == fmt.Sprint(want.Allergies) &&
+		got.LastVisitDate.Truncate(time.Microsecond).Equal(want.LastVisitDate.Truncate(time.Microsecond)) &&
+		got.PrimaryPhysician == want.PrimaryPhysician &&
+		got.InsuranceProvider == want.InsuranceProvider &&
+		got.BloodType == want.BloodType
+	if !same {
+		t.Errorf("patient %s read back as\n  %+v\nwant\n  %+v", want.ID, got, want)
+	}
+}
+
+func TestQueryPatientRoundTrip(t *testing.T) {
+	store, patients := openTestStore(t, 3)
+	ctx := context.Background()
+
+	for _, want := range patients {
+		got, err := store.QueryPatient(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("QueryPatient(%s): %v", want.ID, err)
+		}
+		assertSamePatient(t, got, want)
+	}
+
+	// An update replaces the stored record
+	updated := *patients[0]
+	updated.BloodType = "AB-"
+	updated.Medications = append([]string{"Metformin 500mg"}, updated.Medications...)
+	if err := store.UpdatePatient(ctx, &updated); err != nil {
+		t.Fatalf("UpdatePatient: %v", err)
+	}
+	got, err := store.QueryPatient(ctx, updated.ID)
+	if err != nil {
+		t.Fatalf("QueryPatient after update: %v", err)
+	}
+	assertSamePatient(t, got, &updated)
+
+	if _, err := store.QueryPatient(ctx, "no-such-patient"); !errors.Is(err, simulator.ErrPatientNotFound) {
+		t.Errorf("QueryPatient(missing) error = %v, want ErrPatientNotFound", err)
+	}
+}
+
+func TestBatchQueryPatientsOrderAndMissing(t *testing.T) {
+	store, patients := openTestStore(t, 4)
+	ctx := context.Background()
+
+	ids := []string{patients[2].ID, patients[0].ID, patients[3].ID}
+	got, err := store.BatchQueryPatients(ctx, ids)
+	if err != nil {
+		t.Fatalf("BatchQueryPatients: %v", err)
+	}
+	if len(got) != len(ids) {
+		t.Fatalf("got %d patients, want %d", len(got), len(ids))
+	}
+	for i, id := range ids {
+		if got[i].ID != id {
+			t.Errorf("patient %d = %s, want %s", i, got[i].ID, id)
+		}
+	}
+
+	ids = []string{patients[1].ID, "no-such-patient", patients[2].ID}
+	got, err = store.BatchQueryPatients(ctx, ids)
+	var partial *simulator.PartialResultError
+	if !errors.As(err, &partial) {
+		t.Fatalf("BatchQueryPatients error = %v, want a *PartialResultError", err)
+	}
+	if len(got) != 1 || got[0].ID != patients[1].ID {
+		t.Errorf("fetched %v, want just %s", got, patients[1].ID)
+	}
+	if fmt.Sprint(partial.Missing) != fmt.Sprint(ids[1:]) {
+		t.Errorf("Missing = %v, want %v", partial.Missing, ids[1:])
+	}
+}
+
+func TestSearchPatientsMatchesSimulator(t *testing.T) {
+	store, patients := openTestStore(t, 20)
+	ctx := context.Background()
+
+	target := patients[5]
+	criteria := simulator.SearchCriteria{
+		LastNamePrefix: target.LastName[:2],
+		Physician:      target.PrimaryPhysician,
+	}
+
+	got, err := store.SearchPatients(ctx, criteria)
+	if err != nil {
+		t.Fatalf("SearchPatients: %v", err)
+	}
+
+	// Only this test's patients are compared; the table may hold others
+	var ours []string
+	for _, p := range got {
+		for _, seeded := range patients {
+			if p.ID == seeded.ID {
+				ours = append(ours, p.ID)
+			}
+		}
+	}
+
+	want := simulator.NewDatabase(0, 0, 0, simulator.WithSearchCorpus(patients))
+	want.SetSearchLatency(0, 0)
+	expected, err := want.SearchPatients(ctx, criteria)
+	if err != nil {
+		t.Fatalf("simulator SearchPatients: %v", err)
+	}
+	var wantIDs []string
+	for _, p := range expected {
+		wantIDs = append(wantIDs, p.ID)
+	}
+
+	if fmt.Sprint(ours) != fmt.Sprint(wantIDs) {
+		t.Errorf("search matched %v, want %v", ours, wantIDs)
+	}
+}
+
+func TestQueryPatientCancelled(t *testing.T) {
+	store, patients := openTestStore(t, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := store.QueryPatient(ctx, patients[0].ID)
+	if !errors.Is(err, simulator.ErrQueryCancelled) || !errors.Is(err, context.Canceled) {
+		t.Errorf("QueryPatient on a cancelled context error = %v, want ErrQueryCancelled wrapping context.Canceled", err)
+	}
+}
+
+func TestQueryErrorClassification(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		err     error
+		want    error
+		wantNot []error
+	}{
+		{"cancelled", cancelled, errors.New("canceling statement"), simulator.ErrQueryCancelled, nil},
+		{"bad connection", context.Background(), driver.ErrBadConn, simulator.ErrConnectionTimeout, nil},
+		{"connection done", context.Background(), sql.ErrConnDone, simulator.ErrConnectionTimeout, nil},
+		{"network", context.Background(), &net.OpError{Op: "dial", Err: errors.New("connection refused")}, simulator.ErrConnectionTimeout, nil},
+		{"SQL error", context.Background(), &pq.Error{Code: "42703", Message: "column does not exist"}, nil,
+			[]error{simulator.ErrConnectionTimeout, simulator.ErrQueryCancelled}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := queryError(tt.ctx, "test query", tt.err)
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("queryError = %v, want it to wrap %v", err, tt.want)
+			}
+			for _, not := range tt.wantNot {
+				if errors.Is(err, not) {
+					t.Errorf("queryError = %v, want it not to wrap %v", err, not)
+				}
+			}
+			if !errors.Is(err, tt.err) && tt.ctx.Err() == nil {
+				t.Errorf("queryError = %v, want it to wrap the original %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestOpenRequiresDSN(t *testing.T) {
+	if _, err := Open(context.Background(), Config{}); err == nil {
+		t.Error("Open with no DSN succeeded, want an error")
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got, want := escapeLike(`O'_Br%i\en`), `O'\_Br\%i\\en`; got != want {
+		t.Errorf("escapeLike = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// tlsVersions maps -tls-min-version values to crypto/tls constants.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsEnabled reports whether both a certificate and a key were configured.
+func (c Config) tlsEnabled() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
+}
+
+// parseTLSVersion converts a version such as "1.2" to its crypto/tls constant.
+func parseTLSVersion(version string) (uint16, error) {
+	v, ok := tlsVersions[version]
+	if !ok {
+		return 0, fmt.Errorf("unsupported TLS version %q (want 1.0, 1.1, 1.2 or 1.3)", version)
+	}
+	return v, nil
+}
+
+// serve accepts connections on ln until the server is shut down, over TLS
+// when a certificate and key are configured and plain HTTP otherwise.
+// Like http.Server.Serve, it returns http.ErrServerClosed after Shutdown.
+func serve(server *http.Server, ln net.Listener, config Config) error {
+	if !config.tlsEnabled() {
+		return server.Serve(ln)
+	}
+
+	minVersion, err := parseTLSVersion(config.TLSMinVersion)
+	if err != nil {
+		return err
+	}
+	server.TLSConfig = &tls.Config{MinVersion: minVersion}
+
+	return server.ServeTLS(ln, config.TLSCert, config.TLSKey)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// writeSelfSignedCert writes a certificate and key for 127.0.0.1 to dir and
+// returns their paths along with a pool that trusts the certificate.
+func writeSelfSignedCert(t *testing.T, dir string) (certFile, keyFile string, roots *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots = x509.NewCertPool()
+	roots.AddCert(cert)
+
+	return certFile, keyFile, roots
+}
+
+// startServer serves the API on a random local port and returns its address
+// and a function that shuts it down and reports serve's result.
+func startServer(t *testing.T, config Config) (addr string, shutdown func() error) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	db := simulator.NewDatabase(1, 2, 0)
+	server := &http.Server{Handler: newServeMux(config, http.NotFoundHandler(), db, metrics.NewCollector(), nil)}
+
+	served := make(chan error, 1)
+	go func() { served <- serve(server, ln, config) }()
+
+	return ln.Addr().String(), func() error {
+		if err := server.Shutdown(context.Background()); err != nil {
+			return err
+		}
+		return <-served
+	}
+}
+
+func TestServeTLS(t *testing.T) {
+	config := validConfig()
+	var roots *x509.CertPool
+	config.TLSCert, config.TLSKey, roots = writeSelfSignedCert(t, t.TempDir())
+
+	addr, shutdown := startServer(t, config)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	resp, err := client.Get("https://" + addr + "/health")
+	if err != nil {
+		t.Fatalf("GET /health over HTTPS: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if resp.TLS == nil || resp.TLS.Version < tls.VersionTLS12 {
+		t.Errorf("connection state = %+v, want TLS 1.2 or later", resp.TLS)
+	}
+
+	// Plain HTTP must not be served on the TLS port
+	if resp, err := http.Get("http://" + addr + "/health"); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Error("plain HTTP request succeeded against the TLS server")
+		}
+	}
+
+	if err := shutdown(); !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("serve returned %v after shutdown, want http.ErrServerClosed", err)
+	}
+}
+
+func TestServeTLSMinVersion(t *testing.T) {
+	config := validConfig()
+	config.TLSMinVersion = "1.3"
+	var roots *x509.CertPool
+	config.TLSCert, config.TLSKey, roots = writeSelfSignedCert(t, t.TempDir())
+
+	addr, shutdown := startServer(t, config)
+	defer shutdown()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:    roots,
+		MaxVersion: tls.VersionTLS12,
+	}}}
+	if resp, err := client.Get("https://" + addr + "/health"); err == nil {
+		resp.Body.Close()
+		t.Error("TLS 1.2 client connected to a server requiring TLS 1.3")
+	}
+}
+
+func TestServePlainHTTPWithoutCert(t *testing.T) {
+	config := validConfig()
+	config.TLSCert = "cert.pem" // key missing: falls back to HTTP
+
+	addr, shutdown := startServer(t, config)
+	defer shutdown()
+
+	resp, err := http.Get("http://" + addr + "/health")
+	if err != nil {
+		t.Fatalf("GET /health over HTTP: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// traceBufferSize is how many sampled requests can wait to be written
+// before the recorder starts dropping them rather than hold up requests.
+const traceBufferSize = 4096
+
+// traceRecord is one sampled request, waiting to be written.
+type traceRecord struct {
+	start   time.Time
+	id      string // From the query string; empty for a PUT, whose ID is in body
+	body    []byte // A PUT's body
+	status  int
+	latency time.Duration
+}
+
+// traceRecorder writes sampled patient API requests to a trace that
+// cmd/loadtest -trace can replay. Each line is the request's arrival time
+// (RFC 3339), the patient ID, read or write, then the status code and
+// latency, which replay ignores:
+//
+//	2024-03-01T09:00:00.123456789Z P00042 read 200 53.2ms
+//
+// Requests hand their record to a background writer over a buffered
+// channel and never wait on the file: if the writer falls behind and the
+// buffer fills, records are dropped and counted instead.
+type traceRecorder struct {
+	rate    float64 // Fraction of requests recorded
+	dropped atomic.Int64
+
+	// Held for reading while sending, so Close can't close records under a
+	// request that outlived the shutdown timeout
+	mu      sync.RWMutex
+	closed  bool
+	records chan traceRecord
+	done    chan struct{}
+
+	w *bufio.Writer
+	c io.Closer // Closed after the last write, if non-nil
+}
+
+// openTraceRecorder appends a trace of a fraction rate of requests to the
+// file at path, creating it if need be.
+func openTraceRecorder(path string, rate float64) (*traceRecorder, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening trace: %w", err)
+	}
+	t := newTraceRecorder(f, rate, traceBufferSize)
+	t.c = f
+	return t, nil
+}
+
+// newTraceRecorder records a fraction rate of requests to w, buffering up
+// to buffer of them, and starts the writer.
+func newTraceRecorder(w io.Writer, rate float64, buffer int) *traceRecorder {
+	t := &traceRecorder{
+		rate:    rate,
+		records: make(chan traceRecord, buffer),
+		done:    make(chan struct{}),
+		w:       bufio.NewWriter(w),
+	}
+	go t.run()
+	return t
+}
+
+// run writes records until Close, flushing whenever it catches up.
+func (t *traceRecorder) run() {
+	defer close(t.done)
+
+	for record := range t.records {
+		t.write(record)
+		if len(t.records) == 0 {
+			t.w.Flush()
+		}
+	}
+	t.w.Flush()
+}
+
+// write formats one record. A PUT's patient ID is decoded here, off the
+// request path; a record without one is skipped, since it can't be
+// replayed.
+func (t *traceRecorder) write(record traceRecord) {
+	id, op := record.id, "read"
+	if record.body != nil {
+		var patient struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(record.body, &patient)
+		id, op = patient.ID, "write"
+	}
+	if id == "" {
+		return
+	}
+
+	fmt.Fprintf(t.w, "%s %s %s %d %s\n", record.start.UTC().Format(time.RFC3339Nano),
+		id, op, record.status, record.latency.Round(time.Microsecond))
+}
+
+// middleware records a sample of the requests to /api/v1/patients that
+// next serves. Batch, search and stream requests aren't recorded; replay
+// only sends single-patient requests.
+func (t *traceRecorder) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/patients" || rand.Float64() >= t.rate {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		record := traceRecord{start: time.Now(), id: r.URL.Query().Get("id")}
+		if r.Method == http.MethodPut {
+			// Keep a copy of the body for the writer, which decodes the ID
+			body, err := io.ReadAll(r.Body)
+			if err == nil {
+				record.body = body
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		record.status = rec.status
+		record.latency = time.Since(record.start)
+
+		t.send(record)
+	})
+}
+
+// send queues record for the writer, or drops it if the buffer is full or
+// the recorder is closed.
+func (t *traceRecorder) send(record traceRecord) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.closed {
+		t.dropped.Add(1)
+		return
+	}
+	select {
+	case t.records <- record:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// Close writes out every buffered record and closes the trace. Call it
+// once requests have drained; any still being served aren't recorded.
+func (t *traceRecorder) Close() error {
+	t.mu.Lock()
+	t.closed = true
+	close(t.records)
+	t.mu.Unlock()
+	<-t.done
+
+	if dropped := t.dropped.Load(); dropped > 0 {
+		log.Printf("Trace recording dropped %d requests", dropped)
+	}
+	if t.c != nil {
+		return t.c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/metrics"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/models"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/runner"
+	"github.com/Stella-Achar-Oiro/healthcare-api-benchmark/simulator"
+)
+
+// newTracedServer serves config's pattern over a fast database, recording
+// a trace to a file in a temporary directory.
+func newTracedServer(t *testing.T, rate float64) (http.Handler, *traceRecorder, string) {
+	t.Helper()
+
+	config := validConfig()
+	db := simulator.NewDatabase(1, 2, 0)
+	db.SetWriteProfile(1, 2, 0)
+	handler, err := createHandler(config, db)
+	if err != nil {
+		t.Fatalf("createHandler: %v", err)
+	}
+	t.Cleanup(func() { handler.Shutdown(context.Background()) })
+
+	path := filepath.Join(t.TempDir(), "requests.trace")
+	recorder, err := openTraceRecorder(path, rate)
+	if err != nil {
+		t.Fatalf("openTraceRecorder: %v", err)
+	}
+	mux := newServeMux(config, handler, db, metrics.NewCollector(), nil)
+	return recorder.middleware(mux), recorder, path
+}
+
+func TestTraceRecorderWritesReplayableTrace(t *testing.T) {
+	server, recorder, path := newTracedServer(t, 1)
+
+	body, err := json.Marshal(models.GeneratePatient("P00002"))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	requests := []struct {
+		req    *http.Request
+		status int
+	}{
+		{httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil), http.StatusOK},
+		{httptest.NewRequest(http.MethodPut, "/api/v1/patients", bytes.NewReader(body)), http.StatusOK},
+		{httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00003&fields=bogus", nil), http.StatusBadRequest},
+		{httptest.NewRequest(http.MethodGet, "/api/v1/patients", nil), http.StatusBadRequest}, // No ID to replay
+		{httptest.NewRequest(http.MethodGet, "/health", nil), http.StatusOK},                  // Not a patient request
+	}
+	for i, r := range requests {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, r.req)
+		if rec.Code != r.status {
+			t.Fatalf("request %d: status = %d, want %d: %s", i, rec.Code, r.status, rec.Body)
+		}
+		time.Sleep(time.Millisecond) // Keep timestamps apart
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read trace: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := []struct {
+		id, op, status string
+	}{
+		{"P00001", "read", "200"},
+		{"P00002", "write", "200"},
+		{"P00003", "read", "400"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("trace has %d lines, want %d:\n%s", len(lines), len(want), data)
+	}
+	for i, w := range want {
+		fields := strings.Fields(lines[i])
+		if len(fields) != 5 || fields[1] != w.id || fields[2] != w.op || fields[3] != w.status {
+			t.Errorf("line %d = %q, want %s %s with status %s", i, lines[i], w.id, w.op, w.status)
+			continue
+		}
+		if latency, err := time.ParseDuration(fields[4]); err != nil || latency <= 0 {
+			t.Errorf("line %d: latency %q, want a positive duration", i, fields[4])
+		}
+	}
+
+	// The recorded trace is what loadtest -trace replays
+	trace, err := runner.LoadTrace(path)
+	if err != nil {
+		t.Fatalf("LoadTrace: %v", err)
+	}
+	if len(trace) != 3 || trace[0].PatientID != "P00001" || !trace[1].Write || trace[2].Offset <= trace[1].Offset {
+		t.Errorf("replayed trace = %+v, want the three requests in order", trace)
+	}
+}
+
+func TestTraceRecorderSamples(t *testing.T) {
+	server, recorder, path := newTracedServer(t, 0)
+
+	for i := 0; i < 10; i++ {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil))
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if data, err := os.ReadFile(path); err != nil || len(data) != 0 {
+		t.Errorf("trace at a zero sample rate = %q (err %v), want empty", data, err)
+	}
+}
+
+func TestTraceRecorderDropsRatherThanBlocks(t *testing.T) {
+	// A writer that never returns stands in for a stalled disk
+	stalled := make(chan struct{})
+	recorder := newTraceRecorder(blockingWriter(stalled), 1, 1)
+	defer func() {
+		close(stalled)
+		recorder.Close()
+	}()
+	server := recorder.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/patients?id=P00001", nil))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("requests blocked on the trace writer")
+	}
+	if recorder.dropped.Load() == 0 {
+		t.Error("no records dropped with the writer stalled")
+	}
+}
+
+// blockingWriter blocks every write until it is closed.
+type blockingWriter chan struct{}
+
+func (b blockingWriter) Write(p []byte) (int, error) {
+	<-b
+	return len(p), nil
+}